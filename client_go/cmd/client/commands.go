@@ -0,0 +1,477 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+)
+
+func init() {
+	for _, c := range []*command{
+		{name: "help", args: []string{"[COMMAND...]"}, summary: "Show options, or a command's usage", completions: []completion{completeCommands}, run: (*UserSession).helpCommand},
+		{name: "users", summary: "List users", run: (*UserSession).usersCommand},
+		{name: "n", summary: "Show the next page of users, lists, guests or items", run: (*UserSession).nextCommand},
+		{name: "user", args: []string{"UserID"}, summary: "Select UserID", completions: []completion{completeUsers}, run: (*UserSession).userCommand},
+		{name: "email", args: []string{"user@domain.com"}, summary: "Select User by Email", completions: []completion{completeEmails}, run: (*UserSession).emailCommand},
+		{name: "seq", summary: "Reset sequence counter", run: (*UserSession).seqCommand},
+		{name: "slow", args: []string{"SECONDS"}, summary: "Delay DB operations", run: (*UserSession).slowCommand},
+		{name: "timeout", args: []string{"SECONDS"}, summary: "Deadline for DB operations (0 = none)", run: (*UserSession).timeoutCommand},
+		{name: "output", args: []string{"json|yaml|csv|table"}, summary: "Format of listings and errors", completions: []completion{completeFormats}, run: (*UserSession).outputCommand},
+		{name: "ratio", args: []string{"CREATE", "UPDATE", "TICK_UNTICK"}, summary: "Ratio (integer) for interact actions", run: (*UserSession).ratioCommand},
+		{name: "exit", summary: "Exit application", run: (*UserSession).exitCommand},
+
+		{name: "lists", requires: requiresUser, summary: "Show User's To Do lists", run: (*UserSession).listsCommand},
+		{name: "list", args: []string{"ListID"}, requires: requiresUser, summary: "Select a List", completions: []completion{completeLists}, run: (*UserSession).listCommand},
+		{name: "list create", args: []string{"NAME..."}, requires: requiresUser, summary: "Create a new list", run: (*UserSession).listCreateCommand},
+		{name: "list delete", args: []string{"ListID"}, requires: requiresUser, summary: "Delete existing list", completions: []completion{completeLists}, run: (*UserSession).listDeleteCommand},
+
+		{name: "guests", requires: requiresList, summary: "List guests invited to the list", run: (*UserSession).guestsCommand},
+		{name: "guest add", args: []string{"UserID"}, requires: requiresList, summary: "Add a guest to the list", completions: []completion{completeUsers}, run: (*UserSession).guestAddCommand},
+		{name: "guest remove", args: []string{"UserID"}, requires: requiresList, summary: "Remove guest from the list", completions: []completion{completeGuests}, run: (*UserSession).guestRemoveCommand},
+		{name: "items", requires: requiresList, summary: "Show items in the list", run: (*UserSession).itemsCommand},
+		{name: "item create", args: []string{"DESCRIPTION..."}, requires: requiresList, summary: "Create a new item", run: (*UserSession).itemCreateCommand},
+		{name: "item delete", args: []string{"DATETIME"}, requires: requiresList, summary: "Delete item by datetime", completions: []completion{completeItems}, run: (*UserSession).itemDeleteCommand},
+		{name: "item tick", args: []string{"DATETIME"}, requires: requiresList, summary: "Set item as done", completions: []completion{completeItems}, run: (*UserSession).itemTickCommand},
+		{name: "item untick", args: []string{"DATETIME"}, requires: requiresList, summary: "Set item as pending", completions: []completion{completeItems}, run: (*UserSession).itemUntickCommand},
+		{name: "item rename", args: []string{"DATETIME", "DESCRIPTION..."}, requires: requiresList, summary: "Change item's description", completions: []completion{completeItems}, run: (*UserSession).itemRenameCommand},
+		{name: "item move", args: []string{"DATETIME", "POSITION"}, requires: requiresList, summary: "Move item to POSITION (1 = top)", completions: []completion{completeItems}, run: (*UserSession).itemMoveCommand},
+		{name: "item up", args: []string{"DATETIME"}, requires: requiresList, summary: "Move item up one place", completions: []completion{completeItems}, run: (*UserSession).itemUpCommand},
+		{name: "item down", args: []string{"DATETIME"}, requires: requiresList, summary: "Move item down one place", completions: []completion{completeItems}, run: (*UserSession).itemDownCommand},
+		{name: "item top", args: []string{"DATETIME"}, requires: requiresList, summary: "Move item to the top", completions: []completion{completeItems}, run: (*UserSession).itemTopCommand},
+		{name: "item bottom", args: []string{"DATETIME"}, requires: requiresList, summary: "Move item to the bottom", completions: []completion{completeItems}, run: (*UserSession).itemBottomCommand},
+		{name: "interact", args: []string{"THREADS", "RUNS_PER_THREAD"}, requires: requiresList, unbounded: true, summary: "Interact with list automatically (Ctrl-C stops)", run: (*UserSession).interactCommand},
+	} {
+		commands.register(c)
+	}
+}
+
+// number parses a numeric argument
+func number(arg string) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a number", arg)
+	}
+	return n, nil
+}
+
+func (session *UserSession) helpCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		commands.help(os.Stdout)
+		return nil
+	}
+	c, _, err := commands.find(args)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Usage: %s\n   %s\n", c.usage(), c.summary)
+	return nil
+}
+
+func (session *UserSession) nextCommand(ctx context.Context, args []string) error {
+	c, ok := commands.byName[session.lastCommand]
+	if !ok {
+		return errors.New("there is no context for the 'n' (next) command")
+	}
+	if _, err := c.check(session, nil); err != nil {
+		return err
+	}
+	return c.run(session, ctx, nil)
+}
+
+func (session *UserSession) slowCommand(ctx context.Context, args []string) error {
+	n, err := number(args[0])
+	if err != nil {
+		return err
+	}
+	session.backend.Slowdown(n)
+	return nil
+}
+
+func (session *UserSession) timeoutCommand(ctx context.Context, args []string) error {
+	n, err := number(args[0])
+	if err != nil {
+		return err
+	}
+	session.timeout = time.Duration(n) * time.Second
+	return nil
+}
+
+func (session *UserSession) outputCommand(ctx context.Context, args []string) error {
+	if err := checkOutput(args[0]); err != nil {
+		return err
+	}
+	session.output = args[0]
+	return nil
+}
+
+func (session *UserSession) seqCommand(ctx context.Context, args []string) error {
+	session.sequenceCounter = 0
+	return nil
+}
+
+func (session *UserSession) exitCommand(ctx context.Context, args []string) error {
+	return errExit
+}
+
+func (session *UserSession) usersCommand(ctx context.Context, args []string) error {
+	if !session.continuing {
+		session.cursor = ""
+	}
+	users, cursor, err := session.backend.ListUsers(ctx, session.cursor, maxResults)
+	if err != nil {
+		return err
+	}
+	if session.structured() {
+		for _, user := range users {
+			session.remember(user.ID)
+		}
+		session.nextPage("users", cursor, len(users))
+		return session.printPage(users, cursor)
+	}
+
+	if len(users) > 0 {
+		fmt.Printf("%-4s %-37s  %-50s\n", "Seq", "UserID", "Email")
+		fmt.Printf("%-4s %-37s  %-50s\n", "---", "------", "-----")
+		for _, user := range users {
+			fmt.Printf("%3d  %-37s  %-50s\n", session.sequenceCounter, user.ID, user.Email)
+			session.remember(user.ID)
+		}
+		fmt.Println("---")
+		fmt.Printf("Use Seq numbers in lieu of IDs. For example, 'user $%d'\n", session.sequenceCounter-1)
+	}
+	session.nextPage("users", cursor, len(users))
+	return nil
+}
+
+// Select User by Email
+func (session *UserSession) emailCommand(ctx context.Context, args []string) error {
+	user, err := session.backend.GetUserByEmail(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	session.loggedUser = user
+	return nil
+}
+
+// Select User by ID
+func (session *UserSession) userCommand(ctx context.Context, args []string) error {
+	userID := args[0]
+	users, err := session.backend.GetUsersByIDs(ctx, []string{userID})
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return &model.NotFoundError{Entity: model.EntityUser, Key: userID}
+	}
+	session.loggedUser = users[0]
+	session.selectedList = model.List{}
+	return nil
+}
+
+func (session *UserSession) listsCommand(ctx context.Context, args []string) error {
+	if !session.continuing {
+		session.cursor = ""
+	}
+	lists, cursor, err := session.backend.GetAggregateListsByUserIDPage(ctx, session.loggedUser.ID, session.cursor, maxResults)
+	if err != nil {
+		return err
+	}
+	if session.structured() {
+		for _, list := range lists {
+			session.remember(list.ID)
+		}
+		session.nextPage("lists", cursor, len(lists))
+		return session.printPage(lists, cursor)
+	}
+	if len(lists) > 0 {
+
+		var listType string
+
+		fmt.Printf("%-3s %-36s %-5s %-6s %-5s %-50s\n", "Seq", "ListID", "Type", "Guests", "Items", "Title")
+		fmt.Printf("%-3s %-36s %-5s %-6s %-5s %-50s\n", "---", "------", "----", "------", "-----", "-----")
+
+		for _, list := range lists {
+			if list.AsGuest {
+				listType = "Guest"
+			} else {
+				listType = "Owner"
+			}
+			fmt.Printf("%3d %-36s %-5s %6d %5d %-50s\n", session.sequenceCounter, list.ID, listType, list.GuestCount, list.ItemCount, list.Title)
+			session.remember(list.ID)
+		}
+		fmt.Println("---")
+		fmt.Printf("Use Seq numbers in lieu of IDs. For example, 'list $%d'\n", session.sequenceCounter-1)
+	}
+	session.nextPage("lists", cursor, len(lists))
+	return nil
+}
+
+func (session *UserSession) listCreateCommand(ctx context.Context, args []string) error {
+	listID, err := session.backend.CreateList(ctx, session.loggedUser.ID, args[0])
+	if err != nil {
+		return err
+	}
+	if session.structured() {
+		return writePage(os.Stdout, session.output, []created{{ID: listID}}, "", true)
+	}
+	fmt.Printf("List %s created\n", listID)
+	return nil
+}
+
+func (session *UserSession) listDeleteCommand(ctx context.Context, args []string) error {
+	listID := args[0]
+	list, err := session.backend.GetListByListID(ctx, listID)
+	if err != nil {
+		return err
+	}
+	if list.UserID != session.loggedUser.ID {
+		return errors.New("This list doesn't belong to you!")
+	}
+	if list.UnderDeletion {
+		session.printf("Resuming the interrupted deletion of list %s\n", listID)
+	}
+	if err := session.backend.DeleteList(ctx, listID, session.loggedUser.ID); err != nil {
+		return err
+	}
+	session.printf("List %s deleted\n", listID)
+	if listID == session.selectedList.ID {
+		session.selectedList = model.List{}
+	}
+	return nil
+}
+
+// List Select
+func (session *UserSession) listCommand(ctx context.Context, args []string) error {
+	listID := args[0]
+	list, err := session.backend.GetListByListID(ctx, listID)
+	if err != nil {
+		return err
+	}
+	if list.UserID != session.loggedUser.ID {
+		isPresent, err := session.backend.IsPresentGuest(ctx, listID, session.loggedUser.ID)
+		if err != nil {
+			return err
+		}
+		if !isPresent {
+			return errors.New("This list does not belong to you and you are not a guest in it either!")
+		}
+	}
+	if list.UnderDeletion {
+		session.printf("This list is being deleted; new guests and items will be rejected\n")
+	}
+	session.selectedList = list
+	return nil
+}
+
+func (session *UserSession) guestsCommand(ctx context.Context, args []string) error {
+	if !session.continuing {
+		session.cursor = ""
+	}
+	guests, cursor, err := session.backend.GetAggregateGuestsByListIDPage(ctx, session.selectedList.ID, session.cursor, maxResults)
+	if err != nil {
+		return err
+	}
+	if session.structured() {
+		for _, guest := range guests {
+			session.remember(guest.UserID)
+		}
+		session.nextPage("guests", cursor, len(guests))
+		return session.printPage(guests, cursor)
+	}
+	if len(guests) > 0 {
+		fmt.Printf("%-4s %-37s  %-50s\n", "Seq", "UserID", "Email")
+		fmt.Printf("%-4s %-37s  %-50s\n", "---", "------", "-----")
+		for _, guest := range guests {
+			fmt.Printf("%3d  %-37s  %-50s\n", session.sequenceCounter, guest.UserID, guest.Email)
+			session.remember(guest.UserID)
+
+		}
+		fmt.Println("---")
+		fmt.Printf("Use Seq numbers in lieu of IDs. For example, 'guest remove $%d'\n", session.sequenceCounter-1)
+	}
+	session.nextPage("guests", cursor, len(guests))
+	return nil
+}
+
+func (session *UserSession) guestAddCommand(ctx context.Context, args []string) error {
+	userID := args[0]
+	if userID == session.loggedUser.ID {
+		return errors.New("You can't add yourself to the guest list")
+	}
+	return session.backend.CreateGuest(ctx, session.selectedList.ID, userID)
+}
+
+func (session *UserSession) guestRemoveCommand(ctx context.Context, args []string) error {
+	return session.backend.DeleteGuest(ctx, session.selectedList.ID, args[0])
+}
+
+func (session *UserSession) itemsCommand(ctx context.Context, args []string) error {
+	if !session.continuing {
+		session.cursor = ""
+	}
+	items, cursor, err := session.backend.GetItemsByListIDPage(ctx, session.selectedList.ID, session.cursor, maxResults)
+	if err != nil {
+		return err
+	}
+	if session.structured() {
+		for _, item := range items {
+			session.itemVersions[item.Datetime] = item.Version
+			session.remember(item.Datetime)
+		}
+		session.nextPage("items", cursor, len(items))
+		return session.printPage(items, cursor)
+	}
+	if len(items) > 0 {
+
+		var done string
+
+		fmt.Printf("%-3s %-27s %-7s %-7s %s\n", "Seq", "Datetime", "Version", "Done", "Description")
+		fmt.Printf("%-3s %-27s %-7s %-7s %s\n", "---", "--------", "-------", "----", "-----------")
+
+		for _, item := range items {
+			if item.Done {
+				done = "Done"
+			} else {
+				done = "Pending"
+			}
+
+			fmt.Printf("%3d %-27s %7d %-7s %s\n", session.sequenceCounter, item.Datetime, item.Version, done, item.Description)
+			session.itemVersions[item.Datetime] = item.Version
+			session.remember(item.Datetime)
+		}
+		fmt.Println("---")
+		fmt.Printf("Use Seq numbers in lieu of IDs. For example, 'item delete $%d'\n", session.sequenceCounter-1)
+	}
+	session.nextPage("items", cursor, len(items))
+	return nil
+}
+
+func (session *UserSession) itemCreateCommand(ctx context.Context, args []string) error {
+	datetime, err := session.backend.CreateItem(ctx, session.selectedList.ID, args[0])
+	if err != nil {
+		return err
+	}
+	if session.structured() {
+		return writePage(os.Stdout, session.output, []created{{ID: datetime}}, "", true)
+	}
+	return nil
+}
+
+func (session *UserSession) itemDeleteCommand(ctx context.Context, args []string) error {
+	return session.backend.DeleteItem(ctx, session.selectedList.ID, args[0])
+}
+
+// updateItem changes the item's description or done flag on the basis
+// of the last version seen
+func (session *UserSession) updateItem(ctx context.Context, datetime string, description *string, done *bool) error {
+	newVersion, err := session.backend.UpdateItem(ctx, session.selectedList.ID, datetime, session.itemVersions[datetime], description, done)
+	if err != nil {
+		return err
+	}
+	session.itemVersions[datetime] = newVersion
+	return nil
+}
+
+func (session *UserSession) itemTickCommand(ctx context.Context, args []string) error {
+	return session.updateItem(ctx, args[0], nil, aws.Bool(true))
+}
+
+func (session *UserSession) itemUntickCommand(ctx context.Context, args []string) error {
+	return session.updateItem(ctx, args[0], nil, aws.Bool(false))
+}
+
+func (session *UserSession) itemRenameCommand(ctx context.Context, args []string) error {
+	return session.updateItem(ctx, args[0], &args[1], nil)
+}
+
+func (session *UserSession) itemMoveCommand(ctx context.Context, args []string) error {
+	position, err := number(args[1])
+	if err != nil {
+		return err
+	}
+	return session.moveItem(ctx, args[0], position-1)
+}
+
+// moveItem puts the item at position (from 0) on the basis of the last
+// version seen
+func (session *UserSession) moveItem(ctx context.Context, datetime string, position int) error {
+	newVersion, err := session.backend.MoveItem(ctx, session.selectedList.ID, datetime, session.itemVersions[datetime], position)
+	if err != nil {
+		return err
+	}
+	session.itemVersions[datetime] = newVersion
+	return nil
+}
+
+// moveItemBy puts the item at the position returned by target, given
+// the item's current position and the number of items
+func (session *UserSession) moveItemBy(ctx context.Context, datetime string, target func(position int, count int) int) error {
+	items, err := session.backend.GetItemsByListID(ctx, session.selectedList.ID)
+	if err != nil {
+		return err
+	}
+	for i, item := range items {
+		if item.Datetime == datetime {
+			return session.moveItem(ctx, datetime, target(i, len(items)))
+		}
+	}
+	return &model.NotFoundError{
+		Entity: model.EntityItem,
+		ListID: session.selectedList.ID,
+		Key:    datetime,
+	}
+}
+
+func (session *UserSession) itemUpCommand(ctx context.Context, args []string) error {
+	return session.moveItemBy(ctx, args[0], func(position int, count int) int {
+		return position - 1
+	})
+}
+
+func (session *UserSession) itemDownCommand(ctx context.Context, args []string) error {
+	return session.moveItemBy(ctx, args[0], func(position int, count int) int {
+		return position + 1
+	})
+}
+
+func (session *UserSession) itemTopCommand(ctx context.Context, args []string) error {
+	return session.moveItem(ctx, args[0], 0)
+}
+
+func (session *UserSession) itemBottomCommand(ctx context.Context, args []string) error {
+	return session.moveItemBy(ctx, args[0], func(position int, count int) int {
+		return count - 1
+	})
+}
+
+// interact bounds each call rather than the whole command
+func (session *UserSession) interactCommand(ctx context.Context, args []string) error {
+	threads, err := number(args[0])
+	if err != nil {
+		return err
+	}
+	runs, err := number(args[1])
+	if err != nil {
+		return err
+	}
+	return simulateInteraction(ctx, session, threads, runs)
+}
+
+func (session *UserSession) ratioCommand(ctx context.Context, args []string) error {
+	ratios := make([]int, len(args))
+	for i, arg := range args {
+		n, err := number(arg)
+		if err != nil {
+			return err
+		}
+		ratios[i] = n
+	}
+	session.createRatio, session.updateRatio, session.tickRatio = ratios[0], ratios[1], ratios[2]
+	return nil
+}
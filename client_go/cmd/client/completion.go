@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// completionTimeout bounds the backend calls made to complete a word, as
+// Tab blocks the prompt until they return
+const completionTimeout = 2 * time.Second
+
+// completionLimit caps the number of users fetched to complete a word
+const completionLimit = 100
+
+// completion returns the values an argument may take
+type completion func(session *UserSession, ctx context.Context) ([]string, error)
+
+func completeUsers(session *UserSession, ctx context.Context) ([]string, error) {
+	users, _, err := session.backend.ListUsers(ctx, "", completionLimit)
+	values := make([]string, len(users))
+	for i, user := range users {
+		values[i] = user.ID
+	}
+	return values, err
+}
+
+func completeEmails(session *UserSession, ctx context.Context) ([]string, error) {
+	users, _, err := session.backend.ListUsers(ctx, "", completionLimit)
+	values := make([]string, len(users))
+	for i, user := range users {
+		values[i] = user.Email
+	}
+	return values, err
+}
+
+func completeLists(session *UserSession, ctx context.Context) ([]string, error) {
+	if session.loggedUser.ID == "" {
+		return nil, nil
+	}
+	lists, err := session.backend.GetAggregateListsByUserID(ctx, session.loggedUser.ID)
+	values := make([]string, len(lists))
+	for i, list := range lists {
+		values[i] = list.ID
+	}
+	return values, err
+}
+
+func completeGuests(session *UserSession, ctx context.Context) ([]string, error) {
+	if session.selectedList.ID == "" {
+		return nil, nil
+	}
+	guests, err := session.backend.GetGuestsByListID(ctx, session.selectedList.ID)
+	values := make([]string, len(guests))
+	for i, guest := range guests {
+		values[i] = guest.UserID
+	}
+	return values, err
+}
+
+func completeItems(session *UserSession, ctx context.Context) ([]string, error) {
+	if session.selectedList.ID == "" {
+		return nil, nil
+	}
+	items, err := session.backend.GetItemsByListID(ctx, session.selectedList.ID)
+	values := make([]string, len(items))
+	for i, item := range items {
+		values[i] = item.Datetime
+	}
+	return values, err
+}
+
+func completeCommands(session *UserSession, ctx context.Context) ([]string, error) {
+	values := make([]string, len(commands.commands))
+	for i, c := range commands.commands {
+		values[i] = c.name
+	}
+	return values, nil
+}
+
+func completeFormats(session *UserSession, ctx context.Context) ([]string, error) {
+	return []string{outputJSON, outputYAML, outputCSV, outputTable}, nil
+}
+
+// completer implements readline.AutoCompleter: it completes command
+// names and then their arguments, with values fetched from the backend
+// or the $N references to them
+type completer struct {
+	session *UserSession
+}
+
+// Do returns what may follow the word under the cursor, and its length
+func (c *completer) Do(line []rune, pos int) ([][]rune, int) {
+	text := string(line[:pos])
+	words := strings.Fields(text)
+	current := ""
+	if len(words) > 0 && !unicode.IsSpace(line[pos-1]) {
+		current = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+	candidates := c.session.candidates(words, current)
+	suffixes := make([][]rune, len(candidates))
+	for i, candidate := range candidates {
+		suffixes[i] = []rune(candidate[len(current):] + " ")
+	}
+	return suffixes, len([]rune(current))
+}
+
+// candidates returns the completions of current, the word following
+// words, sorted
+func (session *UserSession) candidates(words []string, current string) []string {
+	var candidates []string
+	if len(words) < 2 {
+		prefix := strings.Join(append(words, current), " ")
+		for _, c := range commands.commands {
+			if strings.HasPrefix(c.name, prefix) {
+				candidates = append(candidates, current+c.name[len(prefix):])
+			}
+		}
+	}
+	if len(words) == 0 {
+		sort.Strings(candidates)
+		return candidates
+	}
+	c, args, err := commands.find(words)
+	if err != nil {
+		sort.Strings(candidates)
+		return candidates
+	}
+	// An argument taking the rest of the line is completed as a whole
+	index, prefix := len(args), current
+	if last := len(c.args) - 1; last >= 0 && index > last && strings.Contains(c.args[last], "...") {
+		index, prefix = last, strings.Join(append(args[last:], current), " ")
+	}
+	if index >= len(c.completions) || c.completions[index] == nil {
+		sort.Strings(candidates)
+		return candidates
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+	values, err := c.completions[index](session, ctx)
+	if err != nil {
+		log.Printf("Completion of %s failed: %v", c.usage(), err)
+	}
+	if strings.HasPrefix(current, "$") {
+		// Only offer the references to values the argument may take
+		valid := make(map[string]bool, len(values))
+		for _, value := range values {
+			valid[value] = true
+		}
+		for n := 0; n < session.sequenceCounter; n++ {
+			reference := "$" + strconv.Itoa(n)
+			if valid[session.sequenceList[n]] && strings.HasPrefix(reference, current) {
+				candidates = append(candidates, reference)
+			}
+		}
+	} else {
+		for _, value := range values {
+			if strings.HasPrefix(value, prefix) {
+				candidates = append(candidates, current+value[len(prefix):])
+			}
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// defaultHistoryFile returns the file the prompt's history is kept in
+// unless the -history flag says otherwise
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".todo_client_history")
+}
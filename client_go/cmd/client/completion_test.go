@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestCandidates(t *testing.T) {
+	session := newTestSession()
+	script := "users\nuser $0\nlist create Groceries\nlists\nlist $3\nitem create Milk\nitems\n"
+	if err := runScript(session, "script", strings.NewReader(script)); err != nil {
+		t.Fatal(err)
+	}
+	user, list, item := session.sequenceList[0], session.sequenceList[3], session.sequenceList[4]
+	tests := []struct {
+		line     string
+		expected []string
+	}{
+		{"ite", []string{"item bottom", "item create", "item delete", "item down", "item move", "item rename", "item tick", "item top", "item untick", "item up", "items"}},
+		{"item t", []string{"tick", "top"}},
+		{"list ", []string{"create", "delete", list}},
+		{"list delete " + list[:4], []string{list}},
+		{"list $", []string{"$3"}},
+		{"user $", []string{"$0", "$1", "$2"}},
+		{"item tick $", []string{"$4"}},
+		{"item tick ", []string{item}},
+		{"item rename " + item + " ", nil},
+		{"guest add " + user[:8], []string{user}},
+		{"guest remove ", nil},
+		{"output y", []string{"yaml"}},
+		{"help item un", []string{"untick"}},
+		{"frobnicate ", nil},
+	}
+	completer := &completer{session: session}
+	for _, test := range tests {
+		line := []rune(test.line)
+		suffixes, length := completer.Do(line, len(line))
+		var candidates []string
+		for _, suffix := range suffixes {
+			candidate := test.line[len(test.line)-length:] + string(suffix)
+			candidates = append(candidates, candidate[:len(candidate)-1])
+		}
+		sort.Strings(test.expected)
+		if !reflect.DeepEqual(candidates, test.expected) {
+			t.Errorf("%q: expected %q, got %q", test.line, test.expected, candidates)
+		}
+	}
+}
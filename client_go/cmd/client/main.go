@@ -1,10 +1,17 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"math/rand"
 	"os"
+	"os/signal"
 	"regexp"
 	"strconv"
 	"strings"
@@ -13,13 +20,15 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/buger/goterm"
 	"github.com/chzyer/readline"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/config"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/database/bolt"
 	"github.com/egarbarino/dry_dynamodb/client_go/internal/database/dynamo"
 	"github.com/egarbarino/dry_dynamodb/client_go/internal/database/memory"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/database/sqlite"
 	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/seed"
 	"syreclabs.com/go/faker"
 )
 
@@ -27,20 +36,109 @@ const maxResults = 5
 
 // UserSession maintains contextual settings and data
 type UserSession struct {
-	backend          model.Interface
-	loggedUser       model.User
-	selectedList     model.List
-	lastEvaluatedKey string
-	lastCommand      string
-	sequenceList     []string
-	sequenceCounter  int
-	itemVersions     map[string]int
-	createRatio      int
-	updateRatio      int
-	tickRatio        int
+	backend         model.Interface
+	loggedUser      model.User
+	selectedList    model.List
+	cursor          model.Cursor
+	lastCommand     string
+	sequenceList    []string
+	sequenceCounter int
+	itemVersions    map[string]int
+	createRatio     int
+	updateRatio     int
+	tickRatio       int
+	timeout         time.Duration
+	cancelMutex     sync.Mutex
+	cancelCommand   context.CancelFunc
+	followPages     bool
+	output          string
+	continuing      bool
 }
 
-func simulateInteraction(session *UserSession, threads int, runs int) error {
+// structured reports whether output is meant for programs rather than
+// people
+func (session *UserSession) structured() bool {
+	return session.output != "" && session.output != outputTable
+}
+
+// printf prints messages meant for people, which structured output
+// leaves out
+func (session *UserSession) printf(format string, a ...interface{}) {
+	if !session.structured() {
+		fmt.Printf(format, a...)
+	}
+}
+
+// printPage prints a page of a listing in a structured format. Pages
+// fetched with 'n' carry on the same CSV table.
+func (session *UserSession) printPage(records interface{}, cursor model.Cursor) error {
+	return writePage(os.Stdout, session.output, records, cursor, !session.continuing)
+}
+
+// printError prints err as typed or, if output is structured, as an
+// error object
+func (session *UserSession) printError(err error) {
+	if !session.structured() || writeError(os.Stdout, session.output, err) != nil {
+		fmt.Println(err)
+	}
+}
+
+// callContext derives the context for a single backend call, bounded by
+// the deadline set with the 'timeout' command
+func (session *UserSession) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if session.timeout > 0 {
+		return context.WithTimeout(ctx, session.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// beginCommand returns the context for the command about to run, which
+// is cancelled by Ctrl-C
+func (session *UserSession) beginCommand() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	session.cancelMutex.Lock()
+	session.cancelCommand = cancel
+	session.cancelMutex.Unlock()
+	return ctx
+}
+
+func (session *UserSession) endCommand() {
+	session.cancelMutex.Lock()
+	defer session.cancelMutex.Unlock()
+	if session.cancelCommand != nil {
+		session.cancelCommand()
+		session.cancelCommand = nil
+	}
+}
+
+// interruptCommand cancels the running command, if any. readline
+// captures Ctrl-C itself while waiting for input.
+func (session *UserSession) interruptCommand() {
+	session.cancelMutex.Lock()
+	defer session.cancelMutex.Unlock()
+	if session.cancelCommand != nil {
+		log.Println("Interrupt received: cancelling command")
+		session.cancelCommand()
+	}
+}
+
+// nextPage remembers where a paginated command stopped so that 'n' can
+// carry on from there
+func (session *UserSession) nextPage(command string, cursor model.Cursor, count int) {
+	session.lastCommand = command
+	session.cursor = cursor
+	switch {
+	case session.followPages || session.structured():
+	case count == 0:
+		fmt.Println("No further results. Type 'n' again to start from the beginning.")
+	case cursor != "":
+		fmt.Println("Type 'n' to see more results")
+	default:
+		fmt.Println("--- End of list ---")
+	}
+}
+
+func simulateInteraction(ctx context.Context, session *UserSession, threads int, runs int) error {
 
 	if threads < 1 {
 		threads = 1
@@ -63,13 +161,21 @@ func simulateInteraction(session *UserSession, threads int, runs int) error {
 	var updateCounter int32 = 0
 	var updateErrorCounter int32 = 0
 
+	var wg sync.WaitGroup
+
 	interact := func(session *UserSession) {
-		for i := 0; i < runs; i++ {
-			items, err := session.backend.GetItemsByListID(session.selectedList.ID)
+		defer wg.Done()
+		for i := 0; i < runs && ctx.Err() == nil; i++ {
+			callCtx, cancel := session.callContext(ctx)
+			items, err := session.backend.GetItemsByListID(callCtx, session.selectedList.ID)
+			cancel()
 			if err != nil {
 				atomic.AddInt32(&itemErrorCounter, 1)
 				atomic.AddInt32(&globalCounter, 1)
 				log.Printf("GetItemsByListID Error: %v", err)
+				if ctx.Err() != nil {
+					return
+				}
 			}
 			mutex.Lock()
 			currentItems = items
@@ -90,7 +196,10 @@ func simulateInteraction(session *UserSession, threads int, runs int) error {
 			// Create a new item and delete some other from the list
 			case session.createRatio != 0 && randomNumber < session.createRatio:
 
-				if err := session.backend.DeleteItem(session.selectedList.ID, randomItem.Datetime); err != nil {
+				callCtx, cancel := session.callContext(ctx)
+				err := session.backend.DeleteItem(callCtx, session.selectedList.ID, randomItem.Datetime)
+				cancel()
+				if err != nil {
 					atomic.AddInt32(&deleteErrorCounter, 1)
 					atomic.AddInt32(&globalCounter, 1)
 					log.Printf("DeleteItem Error: %v", err)
@@ -101,7 +210,10 @@ func simulateInteraction(session *UserSession, threads int, runs int) error {
 
 				for j := 0; j < 5; j++ {
 					description := faker.Hacker().Verb() + " " + faker.Hacker().Noun()
-					if err := session.backend.CreateItem(session.selectedList.ID, description); err != nil {
+					callCtx, cancel := session.callContext(ctx)
+					_, err := session.backend.CreateItem(callCtx, session.selectedList.ID, description)
+					cancel()
+					if err != nil {
 						atomic.AddInt32(&createErrorCounter, 1)
 						atomic.AddInt32(&globalCounter, 1)
 						log.Printf("CreateItem Error Attempt #%d: %v", j, err)
@@ -115,7 +227,9 @@ func simulateInteraction(session *UserSession, threads int, runs int) error {
 			// Update Description
 			case session.updateRatio != 0 && randomNumber >= session.createRatio && randomNumber < session.createRatio+session.updateRatio:
 				description := faker.Hacker().Verb() + " " + faker.Hacker().Noun()
-				_, err := session.backend.UpdateItem(session.selectedList.ID, randomItem.Datetime, randomItem.Version, aws.String(description), nil)
+				callCtx, cancel := session.callContext(ctx)
+				_, err := session.backend.UpdateItem(callCtx, session.selectedList.ID, randomItem.Datetime, randomItem.Version, aws.String(description), nil)
+				cancel()
 				if err != nil {
 					atomic.AddInt32(&updateErrorCounter, 1)
 					atomic.AddInt32(&globalCounter, 1)
@@ -126,7 +240,9 @@ func simulateInteraction(session *UserSession, threads int, runs int) error {
 
 			// Tick/Untick an item
 			case session.tickRatio != 0 && randomNumber >= (session.createRatio+session.updateRatio) && randomNumber < session.createRatio+session.updateRatio+session.tickRatio:
-				_, err := session.backend.UpdateItem(session.selectedList.ID, randomItem.Datetime, randomItem.Version, nil, aws.Bool(!randomItem.Done))
+				callCtx, cancel := session.callContext(ctx)
+				_, err := session.backend.UpdateItem(callCtx, session.selectedList.ID, randomItem.Datetime, randomItem.Version, nil, aws.Bool(!randomItem.Done))
+				cancel()
 				if err != nil {
 					atomic.AddInt32(&tickErrorCounter, 1)
 					atomic.AddInt32(&globalCounter, 1)
@@ -140,6 +256,7 @@ func simulateInteraction(session *UserSession, threads int, runs int) error {
 	}
 	start := time.Now()
 	for i := 0; i < threads; i++ {
+		wg.Add(1)
 		go interact(session)
 	}
 	goterm.Clear()
@@ -206,51 +323,130 @@ func simulateInteraction(session *UserSession, threads int, runs int) error {
 			time.Sleep(5 * time.Second)
 			break
 		}
+		if ctx.Err() != nil {
+			fmt.Println("Interrupted (waiting for pending calls)")
+			wg.Wait()
+			return ctx.Err()
+		}
+
+	}
+	return nil
+}
+
+// errExit is returned by the exit command
+var errExit = errors.New("exit")
+
+// sequencePattern matches the $N references to the IDs remembered by
+// listings
+var sequencePattern = regexp.MustCompile(`\$([0-9]+)`)
+
+// remember assigns the next sequence number to id
+func (session *UserSession) remember(id string) {
+	if session.sequenceCounter < len(session.sequenceList) {
+		session.sequenceList[session.sequenceCounter] = id
+	} else {
+		session.sequenceList = append(session.sequenceList, id)
+	}
+	session.sequenceCounter++
+}
+
+// substitute replaces $1, $232, etc with saved data
+func (session *UserSession) substitute(text string) (string, error) {
+	var err error
+	text = sequencePattern.ReplaceAllStringFunc(text, func(v string) string {
+		n, _ := strconv.Atoi(v[1:])
+		if n >= session.sequenceCounter {
+			if err == nil {
+				err = fmt.Errorf("%s hasn't been set yet", v)
+			}
+			return v
+		}
+		return session.sequenceList[n]
+	})
+	return text, err
+}
 
+// execute runs a single command as typed at the prompt. Errors, be they
+// the backend's or the command's misuse, are returned rather than
+// printed.
+func (session *UserSession) execute(text string) error {
+	text, err := session.substitute(text)
+	if err != nil {
+		return err
+	}
+	words, err := tokenize(text)
+	if err != nil {
+		return err
+	}
+	if len(words) == 0 {
+		words = []string{"help"}
+	}
+	c, args, err := commands.find(words)
+	if err != nil {
+		return err
+	}
+	session.continuing = c.name == "n"
+	if !session.continuing {
+		session.lastCommand = ""
+	}
+	if args, err = c.check(session, args); err != nil {
+		return err
+	}
+	commandCtx := session.beginCommand()
+	defer session.endCommand()
+	if c.unbounded {
+		return c.run(session, commandCtx, args)
+	}
+	ctx, cancel := session.callContext(commandCtx)
+	defer cancel()
+	return c.run(session, ctx, args)
+}
+
+// executeAll runs text and, when following pages, the 'n' commands that
+// fetch the rest of a listing
+func (session *UserSession) executeAll(text string) error {
+	if err := session.execute(text); err != nil {
+		return err
+	}
+	for session.followPages && session.lastCommand != "" && session.cursor != "" {
+		if err := session.execute("n"); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func help() {
-	fmt.Print("" +
-		"General Options:\n" +
-		"   help                                 Show options\n" +
-		"   users                                List users\n" +
-		"   user UserID                          Select UserID\n" +
-		"   email user@domain.com                Select User by Email\n" +
-		"   seq                                  Reset sequence counter\n" +
-		"   slow SECONDS                         Delay DB operations\n" +
-		"   exit                                 Exit application\n" +
-		"Once a user is selected\n" +
-		"   lists                                Show User's To Do lists\n" +
-		"   list ListID                          Select a List\n" +
-		"   list create NAME                     Create a new list\n" +
-		"   list delete ListID                   Delete existing list\n" +
-		"Once a list is selected\n" +
-		"   guests                               List guests invited to the list\n" +
-		"   guest add UserID                     Add a guest to the list\n" +
-		"   guest remove UserID                  Remove guest from the list\n" +
-		"   items                                Show items in the list\n" +
-		"   item create DESCRIPTION              Create a new item\n" +
-		"   item delete DATETIME                 Delete item by datetimem\n" +
-		"   item tick DATETIME                   Set item as done\n" +
-		"   item untick DATETIME                 Set item as pending\n" +
-		"   item rename DATETIME DESCRIPTION     Change item's description\n" +
-		"   interact THREADS RUNS_PER_THREAD     Interact with list automatically\n" +
-		"   ratio CREATE UPDATE TICK_UNTICK      Ratio (integer) for interact actions\n")
+// handleInterrupts cancels the running command on Ctrl-C until the
+// returned function is called
+func handleInterrupts(session *UserSession) func() {
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		for range interrupts {
+			session.interruptCommand()
+		}
+	}()
+	return func() {
+		signal.Stop(interrupts)
+		close(interrupts)
+	}
 }
 
-func inputLoop(session *UserSession) {
+// inputLoop reads commands at the prompt, saving them to historyFile
+// unless it is empty
+func inputLoop(session *UserSession, historyFile string) {
 
-	session.sequenceCounter = 0
-	session.sequenceList = make([]string, 1000)
-	session.itemVersions = make(map[string]int)
 	var promptStr string
-	rl, err := readline.New("> ")
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:       "> ",
+		HistoryFile:  historyFile,
+		AutoComplete: &completer{session: session},
+	})
 	if err != nil {
 		panic(err)
 	}
 	defer rl.Close()
+	defer handleInterrupts(session)()
 
 	for {
 		promptStr = ""
@@ -267,553 +463,326 @@ func inputLoop(session *UserSession) {
 		if err != nil { // io.EOF
 			break
 		}
-
-		// Replace $1, $232, etc with saved data
-
-		r, _ := regexp.Compile("\\$([0-9]+)")
-		for _, v := range r.FindAllString(text, 9) {
-			if n, err := strconv.Atoi(v[1:]); err != nil {
-				fmt.Printf("%s is an invalid sequence.\n", v)
-				break
-			} else {
-				if n >= session.sequenceCounter {
-					fmt.Printf("%s hasn't been set yet.\n", v)
-					break
-				}
-				text = strings.ReplaceAll(text, v, session.sequenceList[n])
-			}
+		if err := session.execute(text); err == errExit {
+			return
+		} else if err != nil {
+			session.printError(err)
 		}
-		switch {
-
-		case strings.HasPrefix(text, "slow"):
-			if len(text) < len("slow _") {
-				fmt.Println("No arguments provided")
-				break
-			}
-			argumentStr := text[len("slow "):]
-			if n, err := strconv.Atoi(argumentStr); err == nil {
-				session.backend.Slowdown(n)
-			} else {
-				fmt.Printf("%s is not a number\n", argumentStr)
-			}
-			break
-
-		// Help
-		case strings.HasPrefix(text, "help") || text == "":
-			help()
-
-		case strings.HasPrefix(text, "seq"):
-			session.sequenceCounter = 0
-
-		// Users
-		case strings.HasPrefix(text, "users") || (session.lastCommand == "users" && text == "n"):
-			if strings.HasPrefix(text, "users") {
-				session.lastEvaluatedKey = ""
-			}
-			users, lastEvaluatedKey, err := session.backend.ListUsers(session.lastEvaluatedKey, maxResults)
-			if err != nil {
-				fmt.Println(err)
-				break
-			}
-			session.lastEvaluatedKey = lastEvaluatedKey
-
-			if len(users) > 0 {
-				fmt.Printf("%-4s %-37s  %-50s\n", "Seq", "UserID", "Email")
-				fmt.Printf("%-4s %-37s  %-50s\n", "---", "------", "-----")
-				for _, user := range users {
-					fmt.Printf("%3d  %-37s  %-50s\n", session.sequenceCounter, user.ID, user.Email)
-					session.sequenceList[session.sequenceCounter] = user.ID
-					session.sequenceCounter++
-				}
-				fmt.Println("---")
-				fmt.Printf("Use Seq numbers in lieu of IDs. For example, 'user $%d'\n", session.sequenceCounter-1)
-				if lastEvaluatedKey != "" && len(users) == maxResults {
-					session.lastCommand = "users"
-					fmt.Println("Type 'n' to see more results")
-				} else {
-					fmt.Println("--- End of list ---")
-				}
-			} else {
-				fmt.Println("No further results. Type 'n' again to start from the beginning.")
-			}
-
-		// Select User by Email
-		case strings.HasPrefix(text, "email"):
-			if len(text) < len("email _") {
-				fmt.Println("No email specified")
-				break
-			}
-			email := text[len("email "):]
-			user, err := session.backend.GetUserByEmail(email)
-			if err != nil {
-				fmt.Println(err)
-				break
-			}
-			session.loggedUser = user
-			break
-
-		// Select User by ID
-		case strings.HasPrefix(text, "user"):
-			if len(text) < len("user _") {
-				fmt.Println("No ID specified")
-				break
-			}
-			userID := text[len("user "):]
-			users, err := session.backend.GetUsersByIDs([]string{userID}) // wgamble@fields.com
-			if err != nil {
-				fmt.Println(err)
-				break
-			}
-			if len(users) == 0 {
-				fmt.Printf("No user found by ID %s\n", userID)
-				break
-			}
-			session.loggedUser = users[0]
-			session.selectedList = model.List{}
-			break
-
-		// Lists
-		case strings.HasPrefix(text, "lists"):
-			if session.loggedUser.Email == "" {
-				fmt.Println("This command requires a current user via the 'user UserID' command")
-				break
-			}
-			lists, err := session.backend.GetAggregateListsByUserID(session.loggedUser.ID)
-			if err != nil {
-				fmt.Println(err)
-				break
-			}
-			if len(lists) > 0 {
-
-				var listType string
-
-				fmt.Printf("%-3s %-36s %-5s %-6s %-5s %-50s\n", "Seq", "ListID", "Type", "Guests", "Items", "Title")
-				fmt.Printf("%-3s %-36s %-5s %-6s %-5s %-50s\n", "---", "------", "----", "------", "-----", "-----")
-
-				for _, list := range lists {
-					if list.AsGuest {
-						listType = "Guest"
-					} else {
-						listType = "Owner"
-					}
-					fmt.Printf("%3d %-36s %-5s %6d %5d %-50s\n", session.sequenceCounter, list.ID, listType, list.GuestCount, list.ItemCount, list.Title)
-					session.sequenceList[session.sequenceCounter] = list.ID
-					session.sequenceCounter++
-				}
-				fmt.Println("---")
-				fmt.Printf("Use Seq numbers in lieu of IDs. For example, 'list $%d'\n", session.sequenceCounter-1)
-			}
-
-		// List Create
-		case strings.HasPrefix(text, "list create"):
-			if session.loggedUser.ID == "" {
-				fmt.Println("This command requires a current user via the 'user UserID' command")
-				break
-			}
-			if len(text) < len("list create _") {
-				fmt.Println("No title specified")
-				break
-			}
-			title := text[len("list create "):]
-			listID, err := session.backend.CreateList(session.loggedUser.ID, title)
-			if err != nil {
-				fmt.Println(err)
-				break
-			}
-			fmt.Printf("List %s created\n", listID)
-
-		// List Delete
-		case strings.HasPrefix(text, "list delete"):
-			if session.loggedUser.ID == "" {
-				fmt.Println("This command requires a current user via the 'user UserID' command")
-				break
-			}
-			if len(text) < len("list delete _") {
-				fmt.Println("No list Id specified")
-				break
-			}
-			listID := text[len("list delete "):]
-
-			list, err := session.backend.GetListByListID(listID)
-			if err != nil {
-				fmt.Println(err)
-			}
-			if list.UserID != session.loggedUser.ID {
-				fmt.Println("This list doesn't belong to you!")
-				break
-			}
-			if err := session.backend.DeleteList(listID, session.loggedUser.ID); err != nil {
-				fmt.Println(err)
-				break
-			}
-			fmt.Printf("List %s deleted\n", listID)
-			if listID == session.selectedList.ID {
-				session.selectedList = model.List{}
-			}
-
-		// Guests
-		case strings.HasPrefix(text, "guests"):
-			if session.loggedUser.ID == "" {
-				fmt.Println("This command requires a current user via the 'user UserID' command")
-				break
-			}
-			if session.selectedList.ID == "" {
-				fmt.Println("This command requires a selected list via the 'list ListID' command")
-				break
-			}
-			guests, err := session.backend.GetAggregateGuestsByListID(session.selectedList.ID)
-			if err != nil {
-				fmt.Println(err)
-				break
-			}
-			if len(guests) > 0 {
-				fmt.Printf("%-4s %-37s  %-50s\n", "Seq", "UserID", "Email")
-				fmt.Printf("%-4s %-37s  %-50s\n", "---", "------", "-----")
-				for _, guest := range guests {
-					fmt.Printf("%3d  %-37s  %-50s\n", session.sequenceCounter, guest.UserID, guest.Email)
-					session.sequenceList[session.sequenceCounter] = guest.UserID
-					session.sequenceCounter++
-
-				}
-				fmt.Println("---")
-				fmt.Printf("Use Seq numbers in lieu of IDs. For example, 'guest remove $%d'\n", session.sequenceCounter-1)
-
-			}
-
-		// List Select
-		case strings.HasPrefix(text, "list"):
-			if session.loggedUser.ID == "" {
-				fmt.Println("This command requires a logged user via the login command")
-				break
-			}
-			if len(text) < len("list _") {
-				fmt.Println("No list specified")
-				break
-			}
-			listID := text[len("list "):]
-			list, err := session.backend.GetListByListID(listID)
-			if err != nil {
-				fmt.Println(err)
-				break
-			}
-			if list.UserID != session.loggedUser.ID {
-				isPresent, err := session.backend.IsPresentGuest(listID, session.loggedUser.ID)
-				if err != nil {
-					fmt.Println(err)
-				}
-				if !isPresent {
-					fmt.Println("This list does not belong to you and you are not a guest in it either!")
-					break
-				}
-			}
-			session.selectedList = list
+	}
+}
 
-		// Add Guest
-		case strings.HasPrefix(text, "guest add"):
-			if session.loggedUser.ID == "" {
-				fmt.Println("This command requires a current user via the 'user UserID' command")
-				break
-			}
-			if session.selectedList.ID == "" {
-				fmt.Println("This command requires a selected list via the 'list ListID' command")
-				break
-			}
-			if len(text) < len("guest add _") {
-				fmt.Println("No guest specified")
-				break
-			}
-			userID := text[len("guest add "):]
-			if userID == session.loggedUser.ID {
-				fmt.Println("You can't add yourself to the guest list")
-				break
-			}
-			err := session.backend.CreateGuest(session.selectedList.ID, userID)
-			if err != nil {
-				fmt.Println(err)
-				break
-			}
+// runScript executes the commands read from script, one per line, and
+// stops at the first one that fails. Blank lines and lines starting with
+// '#' are skipped.
+func runScript(session *UserSession, name string, script io.Reader) error {
+	defer handleInterrupts(session)()
+
+	scanner := bufio.NewScanner(script)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		session.printf("> %s\n", text)
+		if err := session.executeAll(text); err == errExit {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s:%d: %s: %w", name, line, text, err)
+		}
+	}
+	return scanner.Err()
+}
 
-		case strings.HasPrefix(text, "guest remove"):
-			if session.loggedUser.ID == "" {
-				fmt.Println("This command requires a current user via the 'user UserID' command")
-				break
-			}
-			if session.selectedList.ID == "" {
-				fmt.Println("This command requires a selected list via the 'list ListID' command")
-				break
-			}
-			if len(text) < len("guest remove _") {
-				fmt.Println("No guest specified")
-				break
-			}
-			userID := text[len("guest remove "):]
-			err := session.backend.DeleteGuest(session.selectedList.ID, userID) // CHECK IF USER EXISTS!!!!
-			if err != nil {
-				fmt.Println(err)
-			}
+// scriptCommand runs the commands found in the file at path, or in the
+// standard input if path is "-"
+func scriptCommand(session *UserSession, path string) error {
+	if path == "-" {
+		return runScript(session, "stdin", os.Stdin)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return runScript(session, path, file)
+}
 
-		case strings.HasPrefix(text, "items"):
-			if session.loggedUser.ID == "" {
-				fmt.Println("This command requires a current user via the 'user UserID' command")
-				break
-			}
-			if session.selectedList.ID == "" {
-				fmt.Println("This command requires a selected list via the 'list ListID' command")
-				break
-			}
-			items, err := session.backend.GetItemsByListID(session.selectedList.ID)
-			if err != nil {
-				fmt.Println(err)
-				break
-			}
-			if len(items) > 0 {
+// runCommand runs a single command given as arguments, such as
+// 'items -user UserID -list ListID' or 'item create -list ListID Milk'.
+// The words before the first flag name the command, and those after the
+// flags are its arguments. Listings are printed in full.
+func runCommand(session *UserSession, args []string) error {
+	words := make([]string, 0, len(args))
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		words = append(words, args[0])
+		args = args[1:]
+	}
+	commandFlags := flag.NewFlagSet(strings.Join(words, " "), flag.ExitOnError)
+	userID := commandFlags.String("user", "", "select the user by ID first")
+	email := commandFlags.String("email", "", "select the user by email first")
+	listID := commandFlags.String("list", "", "select the list by ID first")
+	commandFlags.StringVar(&session.output, "output", session.output, "json, yaml, csv or table")
+	commandFlags.Parse(args)
+	for _, arg := range commandFlags.Args() {
+		words = append(words, quote(arg))
+	}
+	if err := checkOutput(session.output); err != nil {
+		return err
+	}
 
-				var done string
+	defer handleInterrupts(session)()
+	session.followPages = true
+	switch {
+	case *userID != "":
+		if err := session.execute("user " + *userID); err != nil {
+			return err
+		}
+	case *email != "":
+		if err := session.execute("email " + *email); err != nil {
+			return err
+		}
+	}
+	if *listID != "" {
+		if err := session.execute("list " + *listID); err != nil {
+			return err
+		}
+	}
+	if err := session.executeAll(strings.Join(words, " ")); err != errExit {
+		return err
+	}
+	return nil
+}
 
-				fmt.Printf("%-3s %-27s %-7s %-7s %s\n", "Seq", "Datetime", "Version", "Done", "Description")
-				fmt.Printf("%-3s %-27s %-7s %-7s %s\n", "---", "--------", "-------", "----", "-----------")
+// schemaCommand applies, verifies or diffs the DynamoDB tables against
+// the declaration in the dynamo package, or repairs the list counters
+func schemaCommand(ctx context.Context, dbSession dynamo.Backend, action string) error {
+	switch action {
+	case "apply":
+		if err := dbSession.ApplySchema(ctx); err != nil {
+			return err
+		}
+		fmt.Println("Schema applied")
+	case "verify":
+		if err := dbSession.VerifySchema(ctx); err != nil {
+			return err
+		}
+		fmt.Println("Schema verified")
+	case "repair":
+		repaired, err := dbSession.RepairCounters(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Repaired the counters of %d lists\n", repaired)
+	case "diff":
+		differences, err := dbSession.DiffSchema(ctx)
+		if err != nil {
+			return err
+		}
+		for _, difference := range differences {
+			fmt.Println(difference)
+		}
+		if len(differences) == 0 {
+			fmt.Println("No differences")
+		}
+	default:
+		return fmt.Errorf("unknown schema action %q: expected apply, verify or diff", action)
+	}
+	return nil
+}
 
-				for _, item := range items {
-					if item.Done {
-						done = "Done"
-					} else {
-						done = "Pending"
-					}
+func main() {
 
-					fmt.Printf("%3d %-27s %7d %-7s %s\n", session.sequenceCounter, item.Datetime, item.Version, done, item.Description)
-					session.itemVersions[item.Datetime] = item.Version
-					session.sequenceList[session.sequenceCounter] = item.Datetime
-					session.sequenceCounter++
-				}
-				fmt.Println("---")
-				fmt.Printf("Use Seq numbers in lieu of IDs. For example, 'item delete $%d'\n", session.sequenceCounter-1)
-			}
+	// Abstract interface
+	var backend model.Interface
 
-		case strings.HasPrefix(text, "item create"):
-			if session.loggedUser.ID == "" {
-				fmt.Println("This command requires a current user via the 'user UserID' command")
-				break
-			}
-			if session.selectedList.ID == "" {
-				fmt.Println("This command requires a selected list via the 'list ListID' command")
-				break
-			}
-			if len(text) < len("item create _") {
-				fmt.Println("No description specified")
-				break
-			}
-			description := text[len("item create "):]
-			err := session.backend.CreateItem(session.selectedList.ID, description)
-			if err != nil {
-				fmt.Println(err)
-				break
-			}
+	flagSet := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	configFlags := config.Register(flagSet)
+	output := flagSet.String("output", outputTable, "format of listings and errors: json, yaml, csv or table")
+	historyFile := flagSet.String("history", defaultHistoryFile(), "file to keep the prompt's history in; empty to keep none")
+	dataDir := flagSet.String("data", "", "directory the memory backend keeps its data in; empty to lose it on exit")
+	dbFile := flagSet.String("db", "todo.db", "SQLite database file the sql backend keeps its data in; empty to lose it on exit")
+	boltFile := flagSet.String("bolt", "todo.bolt", "file the bolt backend keeps its data in")
+	flagSet.Parse(os.Args[1:])
+	if err := checkOutput(*output); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
 
-		case strings.HasPrefix(text, "item delete"):
-			if session.loggedUser.ID == "" {
-				fmt.Println("This command requires a current user via the 'user UserID' command")
-				break
-			}
-			if session.selectedList.ID == "" {
-				fmt.Println("This command requires a selected list via the 'list ListID' command")
-				break
-			}
-			if len(text) < len("item delete _") {
-				fmt.Println("No datetime specified")
-				break
-			}
-			datetime := text[len("item delete "):]
-			err := session.backend.DeleteItem(session.selectedList.ID, datetime)
-			if err != nil {
-				fmt.Println(err)
-				break
-			}
+	// The seed subcommand takes flags of its own and may be followed by
+	// the backend argument
+	args := flagSet.Args()
+	var seedConfig *seed.Config
+	var seedQuiet *bool
+	if len(args) > 0 && args[0] == "seed" {
+		defaults := seed.DefaultConfig()
+		seedConfig = &defaults
+		seedFlags := flag.NewFlagSet("seed", flag.ExitOnError)
+		seedFlags.IntVar(&seedConfig.Users, "users", defaults.Users, "number of users")
+		seedFlags.IntVar(&seedConfig.ListsPerUser, "lists", defaults.ListsPerUser, "lists per user")
+		seedFlags.IntVar(&seedConfig.ItemsPerList, "items", defaults.ItemsPerList, "items per list")
+		seedFlags.IntVar(&seedConfig.GuestsPerList, "guests", defaults.GuestsPerList, "guests per list")
+		seedFlags.Int64Var(&seedConfig.Seed, "seed", defaults.Seed, "faker seed; the same seed yields the same fixtures")
+		seedQuiet = seedFlags.Bool("quiet", false, "only print the summary")
+		seedFlags.Parse(args[1:])
+		args = seedFlags.Args()
+	}
 
-		case strings.HasPrefix(text, "item tick"):
-			if session.loggedUser.ID == "" {
-				fmt.Println("This command requires a current user via the 'user UserID' command")
-				break
-			}
-			if session.selectedList.ID == "" {
-				fmt.Println("This command requires a selected list via the 'list ListID' command")
-				break
-			}
-			if len(text) < len("item tick _") {
-				fmt.Println("No datetime specified")
-				break
-			}
-			datetime := text[len("item tick "):]
-			newVersion, err := session.backend.UpdateItem(session.selectedList.ID, datetime, session.itemVersions[datetime], nil, aws.Bool(true))
-			if err != nil {
-				fmt.Println(err)
-				break
-			}
-			session.itemVersions[datetime] = newVersion
+	// The schema and repair subcommands only apply to DynamoDB
+	schemaAction := ""
+	if len(args) > 0 && args[0] == "repair" {
+		if len(args) > 2 || (len(args) == 2 && args[1] != "single") {
+			fmt.Println("Usage: ./client [flags] repair [single]")
+			os.Exit(2)
+		}
+		schemaAction = "repair"
+		args = args[1:]
+	}
+	if len(args) > 0 && args[0] == "schema" {
+		if len(args) != 2 && !(len(args) == 3 && args[2] == "single") {
+			fmt.Println("Usage: ./client [flags] schema apply|verify|diff [single]")
+			os.Exit(2)
+		}
+		schemaAction = args[1]
+		args = args[2:]
+	}
 
-		case strings.HasPrefix(text, "item untick"):
-			if session.loggedUser.ID == "" {
-				fmt.Println("This command requires a current user via the 'user UserID' command")
-				break
-			}
-			if session.selectedList.ID == "" {
-				fmt.Println("This command requires a selected list via the 'list ListID' command")
-				break
-			}
-			if len(text) < len("item untick _") {
-				fmt.Println("No datetime specified")
-				break
-			}
-			datetime := text[len("item untick "):]
-			newVersion, err := session.backend.UpdateItem(session.selectedList.ID, datetime, session.itemVersions[datetime], nil, aws.Bool(false))
-			if err != nil {
-				fmt.Println(err)
-				break
-			}
-			session.itemVersions[datetime] = newVersion
+	// Anything after the backend argument is a command to run instead of
+	// the interactive prompt, which then keeps quiet about the backend
+	backendName := ""
+	if len(args) > 0 && (args[0] == "memory" || args[0] == "sql" || args[0] == "bolt" || args[0] == "single") {
+		backendName = args[0]
+		args = args[1:]
+	}
+	var info io.Writer = os.Stdout
+	if len(args) > 0 {
+		info = ioutil.Discard
+	}
 
-		case strings.HasPrefix(text, "item rename"):
-			if session.loggedUser.ID == "" {
-				fmt.Println("This command requires a current user via the 'user UserID' command")
-				break
-			}
-			if session.selectedList.ID == "" {
-				fmt.Println("This command requires a selected list via the 'list ListID' command")
-				break
-			}
-			if len(text) < len("item rename _") {
-				fmt.Println("No arguments provided")
-				break
-			}
-			argumentStr := text[len("item rename "):]
-			arguments := strings.Split(argumentStr, " ")
-			if len(arguments) < 2 {
-				fmt.Println("Invalid number of arguments")
-				break
-			}
-			datetime := arguments[0]
-			description := strings.Join(arguments[1:], " ")
-			newVersion, err := session.backend.UpdateItem(session.selectedList.ID, datetime, session.itemVersions[datetime], &description, nil)
+	fmt.Fprint(info, "*** Todo List Application ***\n\n")
+	fmt.Fprint(info, "Usage: ./client [flags] [seed [seed flags]] memory|sql|bolt | ./client [flags] [seed [seed flags]] [single] (default using DynamoDB) | ./client [flags] schema apply|verify|diff [single] | ./client [flags] repair [single]\n")
+	fmt.Fprint(info, "       ./client [flags] [memory|sql|bolt|single] COMMAND [-user UserID|-email EMAIL] [-list ListID] [ARGUMENTS] | ./client [flags] [memory|sql|bolt|single] run FILE\n")
+	if backendName == "memory" {
+		// Use Memory Implementation
+		if *dataDir == "" {
+			fmt.Fprint(info, "\nMemory backend selected\n\n")
+			backend = memory.New()
+		} else {
+			fmt.Fprintf(info, "\nMemory backend selected (data=%s)\n\n", *dataDir)
+			memorySession, err := memory.Open(*dataDir)
 			if err != nil {
 				fmt.Println(err)
-				break
+				os.Exit(1)
 			}
-			session.itemVersions[datetime] = newVersion
+			// Exiting without closing loses nothing, as every change
+			// is already on disk
+			defer memorySession.Close()
+			backend = memorySession
+		}
 
-		case strings.HasPrefix(text, "interact"):
-			if session.loggedUser.ID == "" {
-				fmt.Println("This command requires a current user via the 'user UserID' command")
-				break
-			}
-			if session.selectedList.ID == "" {
-				fmt.Println("This command requires a selected list via the 'list ListID' command")
-				break
-			}
-			if len(text) < len("interact _") {
-				fmt.Println("No arguments provided")
-				break
-			}
-			argumentStr := text[len("interact "):]
-			arguments := strings.Split(argumentStr, " ")
-			if len(arguments) < 2 {
-				fmt.Println("Invalid number of arguments")
-				break
-			}
-			var threads int
-			var runs int
-			if n, err := strconv.Atoi(arguments[0]); err == nil {
-				threads = n
-			} else {
-				fmt.Printf("%s is not a number\n", arguments[0])
-			}
-			if n, err := strconv.Atoi(arguments[1]); err == nil {
-				runs = n
-			} else {
-				fmt.Printf("%s is not a number\n", arguments[1])
-			}
+	} else if backendName == "sql" {
+		// Use SQLite Implementation
+		fmt.Fprintf(info, "\nSQL backend selected (db=%s)\n\n", *dbFile)
+		sqlSession, err := sqlite.Open(*dbFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer sqlSession.Close()
+		backend = sqlSession
+
+	} else if backendName == "bolt" {
+		// Use bbolt Implementation
+		fmt.Fprintf(info, "\nBolt backend selected (file=%s)\n\n", *boltFile)
+		boltSession, err := bolt.Open(*boltFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer boltSession.Close()
+		backend = boltSession
 
-			if err := simulateInteraction(session, threads, runs); err != nil {
+	} else {
+		dynamoConfig, err := configFlags.Load()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(info, "\nDynamoDB backend selected (region=%s", dynamoConfig.Region)
+		if dynamoConfig.Endpoint != "" {
+			fmt.Fprintf(info, ",endpoint=%s", dynamoConfig.Endpoint)
+		}
+		// Use DynamoDB Implementation
+		var dbSession dynamo.Backend
+		if backendName == "single" {
+			fmt.Fprintf(info, ",single table=%s)\n\n", dynamoConfig.TableNames().Single)
+			dbSession, err = dynamo.NewSingleTable(dynamoConfig)
+		} else {
+			fmt.Fprintf(info, ",users table=%s)\n\n", dynamoConfig.TableNames().Users)
+			dbSession, err = dynamo.New(dynamoConfig)
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if schemaAction != "" {
+			if err := schemaCommand(context.Background(), dbSession, schemaAction); err != nil {
 				fmt.Println(err)
-				break
-			}
-		case strings.HasPrefix(text, "ratio"):
-			if len(text) < len("ratio _") {
-				fmt.Println("No arguments provided")
-				break
-			}
-			argumentStr := text[len("ratio "):]
-			arguments := strings.Split(argumentStr, " ")
-			if len(arguments) < 3 {
-				fmt.Println("Invalid number of arguments")
-				break
+				os.Exit(1)
 			}
-			if n, err := strconv.Atoi(arguments[0]); err == nil {
-				session.createRatio = n
-			} else {
-				fmt.Printf("%s is not a number\n", arguments[0])
-			}
-
-			if n, err := strconv.Atoi(arguments[1]); err == nil {
-				session.updateRatio = n
-			} else {
-				fmt.Printf("%s is not a number\n", arguments[1])
-			}
-
-			if n, err := strconv.Atoi(arguments[2]); err == nil {
-				session.tickRatio = n
-			} else {
-				fmt.Printf("%s is not a number\n", arguments[2])
-			}
-
-		// Exit
-		case strings.HasPrefix(text, "exit"):
 			return
+		}
+		if err := dbSession.PrepareSchema(context.Background(), dynamoConfig.Schema); err != nil {
+			fmt.Println(err)
+			fmt.Println("Run './client schema apply' to create the missing tables and indexes, or pass '-schema none' to skip this check")
+			os.Exit(1)
+		}
+		backend = dbSession
 
-		// Next without context
-		case text == "n":
-			fmt.Println("There is no context for the 'n' (next) command")
-
-		// Unknown command
-		default:
-			fmt.Printf("%s is not a valid command.\n", text)
-			break
+	}
 
+	if seedConfig != nil {
+		var out io.Writer = os.Stdout
+		if *seedQuiet {
+			out = ioutil.Discard
 		}
+		fmt.Print("*** Populating tables ***\n\n")
+		stats, err := seed.Run(context.Background(), backend, *seedConfig, out)
+		fmt.Print("\n*** Data upload ")
+		if err != nil {
+			fmt.Printf("failed: %v ***\n\n", err)
+		} else {
+			fmt.Print("completed ***\n\n")
+		}
+		stats.Report(os.Stdout)
+		if err != nil {
+			os.Exit(1)
+		}
+		return
 	}
-}
-
-func main() {
-
-	// Abstract interface
-	var backend model.Interface
-
-	fmt.Print("*** Todo List Application ***\n\n")
-	fmt.Print("Usage: ./client memory | ./client (default using DynamoDB)\n")
-	if len(os.Args) > 1 && os.Args[1] == "memory" {
-		fmt.Print("\nMemory backend selected\n\n")
-
-		// Use Memory Implementation
-		backend = memory.New()
-
-	} else {
-		fmt.Print("\nDynamoDB backend selected\n\n")
-
-		var session *session.Session = session.Must(
-			session.NewSessionWithOptions(
-				session.Options{
-					Profile: "dynamodb_profile",
-					Config: aws.Config{
-						Region: aws.String("eu-west-2"),
-					},
-				}))
-
-		// Use DynamoDB Implementation
-		backend = &dynamo.DBSession{DynamoDBresource: dynamodb.New(session)}
 
+	session := &UserSession{
+		backend:      backend,
+		itemVersions: make(map[string]int),
+		output:       *output,
+	}
+	switch {
+	case len(args) == 0:
+		commands.help(os.Stdout)
+		inputLoop(session, *historyFile)
+	case args[0] == "run":
+		if len(args) != 2 {
+			fmt.Println("Usage: ./client [flags] [memory|sql|bolt|single] run FILE ('-' reads standard input)")
+			os.Exit(2)
+		}
+		if err := scriptCommand(session, args[1]); err != nil {
+			session.printError(err)
+			os.Exit(1)
+		}
+	default:
+		if err := runCommand(session, args); err != nil {
+			session.printError(err)
+			os.Exit(1)
+		}
 	}
-
-	help()
-	inputLoop(&UserSession{
-		backend: backend,
-	})
 
 }
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/database/memory"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+)
+
+func newTestSession() *UserSession {
+	return &UserSession{
+		backend:      memory.New(),
+		itemVersions: make(map[string]int),
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	session := newTestSession()
+	script := `
+# $N refers to the IDs printed by listings
+users
+user $0
+list create Groceries
+lists
+list $3
+item create Milk
+items
+item tick $4
+`
+	if err := runScript(session, "script", strings.NewReader(script)); err != nil {
+		t.Fatalf("runScript: %v", err)
+	}
+	items, err := session.backend.GetItemsByListID(context.Background(), session.selectedList.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || !items[0].Done {
+		t.Fatalf("unexpected items %v", items)
+	}
+}
+
+func TestRunScriptStops(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		expected string
+	}{
+		{"Unset", "users\nuser $9\nusers\n", "script:2: user $9: $9 hasn't been set yet"},
+		{"Unknown", "\nfrobnicate\n", "script:2: frobnicate: frobnicate is not a valid command"},
+		{"Context", "lists\n", "script:1: lists: This command requires a current user via the 'user UserID' command"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := runScript(newTestSession(), "script", strings.NewReader(test.script))
+			if err == nil || err.Error() != test.expected {
+				t.Fatalf("expected %q, got %v", test.expected, err)
+			}
+		})
+	}
+
+	err := runScript(newTestSession(), "script", strings.NewReader("users\nuser $0\nlist 00000000-0000-0000-0000-000000000000\n"))
+	if !errors.Is(err, model.ErrNotFound) {
+		t.Fatalf("expected the backend's error to be wrapped, got %v", err)
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	session := newTestSession()
+	users, _, err := session.backend.ListUsers(context.Background(), "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := runCommand(session, []string{"list", "create", "-user", users[0].ID, "Chores"}); err != nil {
+		t.Fatalf("runCommand: %v", err)
+	}
+	if session.loggedUser.ID != users[0].ID {
+		t.Fatalf("expected user %s to be selected, got %+v", users[0].ID, session.loggedUser)
+	}
+	if err := runCommand(session, []string{"item", "create", "Dishes"}); err == nil {
+		t.Fatal("expected item create to require a list")
+	}
+}
+
+func TestMoveItems(t *testing.T) {
+	session := newTestSession()
+	script := `
+users
+user $0
+list create Groceries
+lists
+list $3
+item create A
+item create B
+item create C
+items
+item top $6
+items
+item down $7
+item move $9 1
+`
+	if err := runScript(session, "script", strings.NewReader(script)); err != nil {
+		t.Fatalf("runScript: %v", err)
+	}
+	items, err := session.backend.GetItemsByListID(context.Background(), session.selectedList.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var descriptions []string
+	for _, item := range items {
+		descriptions = append(descriptions, item.Description)
+	}
+	if strings.Join(descriptions, " ") != "B A C" {
+		t.Fatalf("expected B A C, got %v", descriptions)
+	}
+}
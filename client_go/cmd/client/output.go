@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/wire"
+	"gopkg.in/yaml.v2"
+)
+
+// Output formats for listings, created records and errors
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+	outputCSV   = "csv"
+)
+
+// checkOutput returns an error unless format is a known output format
+// or empty, which stands for table
+func checkOutput(format string) error {
+	switch format {
+	case "", outputTable, outputJSON, outputYAML, outputCSV:
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q: expected json, yaml, csv or table", format)
+}
+
+// created is how a command that creates a record reports its ID
+type created struct {
+	ID string `json:"id"`
+}
+
+// writeValue serializes v, which must marshal to a JSON object, as a
+// single JSON or YAML document
+func writeValue(w io.Writer, format string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if format == outputJSON {
+		_, err := fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+	// JSON is YAML, so the JSON tags carry over. Keys come out sorted.
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return err
+	}
+	data, err = yaml.Marshal(document)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "---\n%s", data)
+	return err
+}
+
+// csvColumns returns the JSON names of the fields of the struct type t,
+// including those of embedded structs, in declaration order, along with
+// their indexes
+func csvColumns(t reflect.Type) ([]string, [][]int) {
+	var names []string
+	var indexes [][]int
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			embeddedNames, embeddedIndexes := csvColumns(field.Type)
+			names = append(names, embeddedNames...)
+			for _, index := range embeddedIndexes {
+				indexes = append(indexes, append([]int{i}, index...))
+			}
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" || field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names = append(names, name)
+		indexes = append(indexes, []int{i})
+	}
+	return names, indexes
+}
+
+// writeCSV writes records, a slice of structs, one per row, preceded by
+// the column names if header is set
+func writeCSV(w io.Writer, records interface{}, header bool) error {
+	value := reflect.ValueOf(records)
+	if value.Kind() != reflect.Slice {
+		value = reflect.Append(reflect.MakeSlice(reflect.SliceOf(value.Type()), 0, 1), value)
+	}
+	names, indexes := csvColumns(value.Type().Elem())
+	writer := csv.NewWriter(w)
+	if header {
+		writer.Write(names)
+	}
+	row := make([]string, len(indexes))
+	for i := 0; i < value.Len(); i++ {
+		for j, index := range indexes {
+			row[j] = fmt.Sprint(value.Index(i).FieldByIndex(index).Interface())
+		}
+		writer.Write(row)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvCursorMarker starts the row following a page in CSV, which has no
+// room for the cursor otherwise
+const csvCursorMarker = "#next_cursor"
+
+// writePage serializes a page of records. In CSV, the cursor, if any,
+// follows the rows as a csvCursorMarker row.
+func writePage(w io.Writer, format string, records interface{}, cursor model.Cursor, header bool) error {
+	if format == outputCSV {
+		if err := writeCSV(w, records, header); err != nil || cursor == "" {
+			return err
+		}
+		writer := csv.NewWriter(w)
+		writer.Write([]string{csvCursorMarker, string(cursor)})
+		writer.Flush()
+		return writer.Error()
+	}
+	// An empty page has an empty list of records rather than null
+	if value := reflect.ValueOf(records); value.Kind() == reflect.Slice && value.IsNil() {
+		records = reflect.MakeSlice(value.Type(), 0, 0).Interface()
+	}
+	return writeValue(w, format, wire.Page{Data: records, NextCursor: cursor})
+}
+
+// writeError serializes err in the given structured format
+func writeError(w io.Writer, format string, err error) error {
+	body := wire.NewError(err)
+	if format == outputCSV {
+		return writeCSV(w, body, true)
+	}
+	return writeValue(w, format, wire.ErrorBody{Error: body})
+}
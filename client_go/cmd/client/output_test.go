@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+)
+
+func TestWritePage(t *testing.T) {
+	lists := []model.AggregateList{
+		{List: model.List{ID: "l1", Title: "Chores, weekly", UserID: "u1"}, GuestCount: 2, ItemCount: 3},
+	}
+	tests := []struct {
+		format   string
+		records  interface{}
+		cursor   model.Cursor
+		header   bool
+		expected string
+	}{
+		{outputJSON, lists, "next", true,
+			`{"data":[{"id":"l1","title":"Chores, weekly","user_id":"u1","guest_count":2,"item_count":3,"as_guest":false}],"next_cursor":"next"}` + "\n"},
+		{outputJSON, []model.User(nil), "", true, `{"data":[]}` + "\n"},
+		{outputYAML, lists, "", true,
+			"---\ndata:\n- as_guest: false\n  guest_count: 2\n  id: l1\n  item_count: 3\n  title: Chores, weekly\n  user_id: u1\n"},
+		{outputCSV, lists, "next", true,
+			"id,title,user_id,under_deletion,guest_count,item_count,as_guest\nl1,\"Chores, weekly\",u1,false,2,3,false\n#next_cursor,next\n"},
+		{outputCSV, []model.User(nil), "next", false, "#next_cursor,next\n"},
+		{outputCSV, lists, "", false,
+			"l1,\"Chores, weekly\",u1,false,2,3,false\n"},
+	}
+	for i, test := range tests {
+		var buffer bytes.Buffer
+		if err := writePage(&buffer, test.format, test.records, test.cursor, test.header); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if buffer.String() != test.expected {
+			t.Errorf("#%d: expected %q, got %q", i, test.expected, buffer.String())
+		}
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	err := fmt.Errorf("script:1: list l1: %w", &model.NotFoundError{Entity: "list", Key: "l1"})
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{outputJSON, `{"error":{"code":"not_found","message":"script:1: list l1: list l1 not found"}}` + "\n"},
+		{outputYAML, "---\nerror:\n  code: not_found\n  message: 'script:1: list l1: list l1 not found'\n"},
+		{outputCSV, "code,message\nnot_found,script:1: list l1: list l1 not found\n"},
+	}
+	for _, test := range tests {
+		var buffer bytes.Buffer
+		if err := writeError(&buffer, test.format, err); err != nil {
+			t.Fatal(err)
+		}
+		if buffer.String() != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.format, test.expected, buffer.String())
+		}
+	}
+}
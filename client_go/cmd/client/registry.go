@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// requirement is the context a command needs before it can run
+type requirement int
+
+const (
+	requiresNothing requirement = iota
+	requiresUser
+	requiresList
+)
+
+// command describes a command of the prompt. Its arguments are named in
+// the order they are given: a name in brackets is optional and a name
+// ending in "..." takes the rest of the line, quoted or not.
+type command struct {
+	name     string
+	args     []string
+	summary  string
+	requires requirement
+	// unbounded commands run under the command's context rather than one
+	// bounded by the 'timeout' deadline, and bound their calls themselves
+	unbounded bool
+	// completions complete the arguments at the same positions, if set
+	completions []completion
+	run         func(session *UserSession, ctx context.Context, args []string) error
+}
+
+func (c *command) usage() string {
+	return strings.Join(append([]string{c.name}, c.args...), " ")
+}
+
+// arity returns the minimum and maximum number of arguments, the latter
+// being -1 if there is no maximum
+func (c *command) arity() (int, int) {
+	min, max := 0, 0
+	for _, arg := range c.args {
+		if !strings.HasPrefix(arg, "[") {
+			min++
+		}
+		switch {
+		case strings.Contains(arg, "..."):
+			max = -1
+		case max >= 0:
+			max++
+		}
+	}
+	return min, max
+}
+
+// check validates the session's context and the number of args, and
+// returns args with the trailing ones joined if the last argument takes
+// the rest of the line
+func (c *command) check(session *UserSession, args []string) ([]string, error) {
+	switch {
+	case c.requires >= requiresUser && session.loggedUser.ID == "":
+		return nil, errors.New("This command requires a current user via the 'user UserID' command")
+	case c.requires >= requiresList && session.selectedList.ID == "":
+		return nil, errors.New("This command requires a selected list via the 'list ListID' command")
+	}
+	min, max := c.arity()
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		return nil, fmt.Errorf("Usage: %s", c.usage())
+	}
+	if last := len(c.args) - 1; max < 0 && len(args) > last+1 {
+		args = append(args[:last:last], strings.Join(args[last:], " "))
+	}
+	return args, nil
+}
+
+// registry holds the commands of the prompt in the order they were
+// registered, which is the order help lists them in
+type registry struct {
+	commands []*command
+	byName   map[string]*command
+}
+
+// commands is the registry execute looks commands up in. Commands add
+// themselves to it from init functions.
+var commands = &registry{byName: make(map[string]*command)}
+
+func (r *registry) register(c *command) {
+	if _, ok := r.byName[c.name]; ok {
+		panic("command registered twice: " + c.name)
+	}
+	r.commands = append(r.commands, c)
+	r.byName[c.name] = c
+}
+
+// isGroup reports whether word starts the name of commands made of more
+// than one word, such as 'item'
+func (r *registry) isGroup(word string) bool {
+	for _, c := range r.commands {
+		if strings.HasPrefix(c.name, word+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// find returns the command named by the leading words, preferring the
+// longest name, along with its arguments
+func (r *registry) find(words []string) (*command, []string, error) {
+	if len(words) >= 2 {
+		if c, ok := r.byName[words[0]+" "+words[1]]; ok {
+			return c, words[2:], nil
+		}
+	}
+	if c, ok := r.byName[words[0]]; ok {
+		return c, words[1:], nil
+	}
+	name := words[0]
+	typed := []string{name}
+	if len(words) >= 2 {
+		typed = append(typed, words[0]+" "+words[1])
+		if r.isGroup(words[0]) {
+			name = typed[1]
+		}
+	}
+	return nil, nil, fmt.Errorf("%s is not a valid command%s", name, r.suggest(typed))
+}
+
+// suggest returns a "did you mean" hint listing the commands whose names
+// are close to any of the typed ones, or that a typed name starts, if any
+func (r *registry) suggest(typed []string) string {
+	var candidates []string
+	for _, c := range r.commands {
+		for _, name := range typed {
+			tolerance := 1
+			if len(name) > 4 {
+				tolerance = 2
+			}
+			if distance(name, c.name) <= tolerance || strings.HasPrefix(c.name, name+" ") {
+				candidates = append(candidates, "'"+c.name+"'")
+				break
+			}
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf("; did you mean %s?", candidates[0])
+	}
+	last := len(candidates) - 1
+	return fmt.Sprintf("; did you mean %s or %s?", strings.Join(candidates[:last], ", "), candidates[last])
+}
+
+// distance is the number of insertions, deletions, substitutions and
+// transpositions of adjacent letters that turn a into b
+func distance(a string, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = minimum(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = minimum(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func minimum(first int, others ...int) int {
+	for _, n := range others {
+		if n < first {
+			first = n
+		}
+	}
+	return first
+}
+
+// sections are the headings help groups commands under, by requirement
+var sections = []string{
+	requiresNothing: "General Options:",
+	requiresUser:    "Once a user is selected",
+	requiresList:    "Once a list is selected",
+}
+
+// help prints every command's usage and summary, grouped by what they
+// require
+func (r *registry) help(w io.Writer) {
+	for requires, section := range sections {
+		fmt.Fprintln(w, section)
+		for _, c := range r.commands {
+			if c.requires == requirement(requires) {
+				fmt.Fprintf(w, "   %-37s%s\n", c.usage(), c.summary)
+			}
+		}
+	}
+}
+
+// tokenize splits text into words at white space. Single quotes keep
+// everything up to the next single quote as is. Double quotes do the
+// same, except that a backslash escapes the character after it, as it
+// does outside quotes.
+func tokenize(text string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	var quote rune
+	inWord := false
+	escaped := false
+	for _, r := range text {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'' && r == '\'', quote == '"' && r == '"':
+			quote = 0
+		case quote == '\'':
+			word.WriteRune(r)
+		case r == '\\':
+			escaped = true
+			inWord = true
+		case quote == '"':
+			word.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	switch {
+	case quote != 0:
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	case escaped:
+		return nil, errors.New("nothing to escape after the trailing backslash")
+	case inWord:
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// quote returns word quoted, if need be, so that tokenize reads it back
+// as a single word
+func quote(word string) string {
+	if word != "" && !strings.ContainsAny(word, " \t\n'\"\\") {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		{"  item   rename  $4 Buy milk ", []string{"item", "rename", "$4", "Buy", "milk"}},
+		{`list create "Weekend  plans"`, []string{"list", "create", "Weekend  plans"}},
+		{`item create 'It'\''s "quoted"'`, []string{"item", "create", `It's "quoted"`}},
+		{`item create "a \"b\" \\ c" d\ e`, []string{"item", "create", `a "b" \ c`, "d e"}},
+		{`list create ""`, []string{"list", "create", ""}},
+		{"", nil},
+	}
+	for _, test := range tests {
+		words, err := tokenize(test.text)
+		if err != nil {
+			t.Fatalf("%s: %v", test.text, err)
+		}
+		if !reflect.DeepEqual(words, test.expected) {
+			t.Errorf("%s: expected %q, got %q", test.text, test.expected, words)
+		}
+	}
+	for _, text := range []string{`item create "Milk`, `item create 'Milk`, `item create Milk\`} {
+		if _, err := tokenize(text); err == nil {
+			t.Errorf("%s: expected an error", text)
+		}
+	}
+	for _, word := range []string{"plain", "two words", `It's "quoted"`, `back\slash`, ""} {
+		words, err := tokenize("item create " + quote(word))
+		if err != nil || len(words) != 3 || words[2] != word {
+			t.Errorf("%s: quoted as %s, read back as %q (%v)", word, quote(word), words, err)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		text     string
+		name     string
+		args     []string
+		expected string
+	}{
+		{"list create Chores", "list create", []string{"Chores"}, ""},
+		{"list create", "list create", []string{}, ""},
+		{"list 1234", "list", []string{"1234"}, ""},
+		{"lsts", "", nil, "lsts is not a valid command; did you mean 'lists'?"},
+		{"itme create Milk", "", nil, "itme is not a valid command; did you mean 'item create'?"},
+		{"guest ad 1234", "", nil, "guest ad is not a valid command; did you mean 'guests', 'guest add' or 'guest remove'?"},
+		{"frobnicate", "", nil, "frobnicate is not a valid command"},
+	}
+	for _, test := range tests {
+		words, _ := tokenize(test.text)
+		c, args, err := commands.find(words)
+		switch {
+		case test.expected != "":
+			if err == nil || err.Error() != test.expected {
+				t.Errorf("%s: expected %q, got %v", test.text, test.expected, err)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", test.text, err)
+		case c.name != test.name || !reflect.DeepEqual(args, test.args):
+			t.Errorf("%s: expected %s %q, got %s %q", test.text, test.name, test.args, c.name, args)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	session := newTestSession()
+	session.loggedUser = model.User{ID: "u1"}
+	session.selectedList = model.List{ID: "l1"}
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		{"item rename 2020 Buy some milk", []string{"2020", "Buy some milk"}},
+		{`item rename 2020 "Buy  milk"`, []string{"2020", "Buy  milk"}},
+		{"item rename 2020", nil},
+		{"item tick 2020 2021", nil},
+		{"help", []string{}},
+		{"help item tick", []string{"item tick"}},
+		{"ratio 1 2", nil},
+	}
+	for _, test := range tests {
+		words, _ := tokenize(test.text)
+		c, args, err := commands.find(words)
+		if err != nil {
+			t.Fatalf("%s: %v", test.text, err)
+		}
+		args, err = c.check(session, args)
+		switch {
+		case test.expected == nil && (err == nil || err.Error() != "Usage: "+c.usage()):
+			t.Errorf("%s: expected a usage error, got %v", test.text, err)
+		case test.expected != nil && !reflect.DeepEqual(args, test.expected):
+			t.Errorf("%s: expected %q, got %q (%v)", test.text, test.expected, args, err)
+		}
+	}
+}
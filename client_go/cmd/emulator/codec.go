@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"time"
+)
+
+// timeType is encoded as seconds since the epoch, as the SDKs expect
+var timeType = reflect.TypeOf(time.Time{})
+
+// decodeInput reads the JSON body of a request into input, one of the
+// SDK's input structs, whose field names are those of the protocol. An
+// empty body leaves input as it is.
+func decodeInput(body io.Reader, input interface{}) error {
+	if err := json.NewDecoder(body).Decode(input); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// encodeOutput returns the JSON body of output, one of the SDK's output
+// or error structs. Unset fields are left out and fields are named by
+// their locationName tag, if any, as in the protocol.
+func encodeOutput(output interface{}) ([]byte, error) {
+	return json.Marshal(plain(reflect.ValueOf(output)))
+}
+
+// plain converts value into maps, slices and scalars that encoding/json
+// writes as the protocol does
+func plain(value reflect.Value) interface{} {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Struct:
+		if value.Type() == timeType {
+			return float64(value.Interface().(time.Time).UnixNano()) / float64(time.Second)
+		}
+		fields := make(map[string]interface{})
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if field.PkgPath != "" || field.Tag.Get("json") == "-" {
+				continue
+			}
+			member := value.Field(i)
+			switch member.Kind() {
+			case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+				if member.IsNil() {
+					continue
+				}
+			}
+			name := field.Name
+			if locationName := field.Tag.Get("locationName"); locationName != "" {
+				name = locationName
+			}
+			fields[name] = plain(member)
+		}
+		return fields
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			// Blobs, which encoding/json writes in base64 as the protocol does
+			return value.Interface()
+		}
+		elements := make([]interface{}, value.Len())
+		for i := range elements {
+			elements[i] = plain(value.Index(i))
+		}
+		return elements
+	case reflect.Map:
+		entries := make(map[string]interface{}, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			entries[iter.Key().String()] = plain(iter.Value())
+		}
+		return entries
+	}
+	return value.Interface()
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"hash/crc32"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/database/dynamo/dynamotest"
+	"github.com/google/uuid"
+)
+
+// targetPrefix precedes the operation in the X-Amz-Target header of the
+// requests the SDKs send
+const targetPrefix = "DynamoDB_20120810."
+
+// errorPrefix precedes the code of an error in the __type field
+const errorPrefix = "com.amazonaws.dynamodb.v20120810#"
+
+// operations are the calls the emulator answers, each by the method of
+// dynamotest.Fake with the same name and a context
+var operations = map[string]bool{
+	"CreateTable":               true,
+	"DescribeTable":             true,
+	"UpdateTable":               true,
+	"DeleteTable":               true,
+	"ListTables":                true,
+	"DescribeTimeToLive":        true,
+	"DescribeContinuousBackups": true,
+	"ListTagsOfResource":        true,
+	"TagResource":               true,
+	"UntagResource":             true,
+	"GetItem":                   true,
+	"PutItem":                   true,
+	"UpdateItem":                true,
+	"DeleteItem":                true,
+	"Query":                     true,
+	"Scan":                      true,
+	"BatchGetItem":              true,
+	"BatchWriteItem":            true,
+	"TransactWriteItems":        true,
+}
+
+// Emulator answers DynamoDB requests, as the SDKs and the AWS CLI send
+// them, from a dynamotest.Fake: a POST to / whose X-Amz-Target header
+// names the operation and whose JSON body holds its input.
+type Emulator struct {
+	fake *dynamotest.Fake
+}
+
+// NewEmulator serves the tables of fake
+func NewEmulator(fake *dynamotest.Fake) *Emulator {
+	return &Emulator{fake: fake}
+}
+
+// statusRecorder keeps the status code for the access log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	recorder.status = status
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+func (emulator *Emulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	name := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), targetPrefix)
+	defer func() {
+		log.Printf("%s %d (%s)", name, recorder.status, time.Since(start))
+	}()
+
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("X-Amz-Target"), targetPrefix) || !operations[name] {
+		writeError(recorder, awserr.NewRequestFailure(awserr.New("UnknownOperationException", "", nil), http.StatusBadRequest, requestID()))
+		return
+	}
+	method := reflect.ValueOf(emulator.fake).MethodByName(name + "WithContext")
+	input := reflect.New(method.Type().In(1).Elem())
+	if err := decodeInput(r.Body, input.Interface()); err != nil {
+		writeError(recorder, awserr.NewRequestFailure(awserr.New("SerializationException", err.Error(), nil), http.StatusBadRequest, requestID()))
+		return
+	}
+	results := method.Call([]reflect.Value{reflect.ValueOf(r.Context()), input})
+	if err, _ := results[1].Interface().(error); err != nil {
+		writeError(recorder, err)
+		return
+	}
+	body, err := encodeOutput(results[0].Interface())
+	if err != nil {
+		log.Printf("Encoding %s output: %v", name, err)
+		writeError(recorder, awserr.NewRequestFailure(awserr.New("InternalServerError", "Internal server error", nil), http.StatusInternalServerError, requestID()))
+		return
+	}
+	writeBody(recorder, http.StatusOK, requestID(), body)
+}
+
+func requestID() string {
+	return strings.ToUpper(strings.Replace(uuid.New().String(), "-", "", -1))
+}
+
+// writeBody sends a response as DynamoDB does, with a checksum the SDKs
+// verify
+func writeBody(w http.ResponseWriter, status int, requestID string, body []byte) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.Header().Set("X-Amzn-Requestid", requestID)
+	w.Header().Set("X-Amz-Crc32", strconv.FormatUint(uint64(crc32.ChecksumIEEE(body)), 10))
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		log.Printf("Writing response: %v", err)
+	}
+}
+
+// writeError sends err in the body of an error response: its code under
+// __type, its message, and the fields of typed SDK errors such as the
+// CancellationReasons of a TransactionCanceledException. Errors the SDK
+// would have raised before sending a request become ValidationException.
+func writeError(w http.ResponseWriter, err error) {
+	failure, ok := err.(awserr.RequestFailure)
+	if !ok {
+		failure = awserr.NewRequestFailure(awserr.New("ValidationException", err.Error(), nil), http.StatusBadRequest, requestID())
+	}
+	fields := make(map[string]interface{})
+	if encoded, err := encodeOutput(failure); err == nil {
+		json.Unmarshal(encoded, &fields)
+	}
+	fields["__type"] = errorPrefix + failure.Code()
+	fields["message"] = failure.Message()
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(fields); err != nil {
+		log.Printf("Encoding error: %v", err)
+	}
+	writeBody(w, failure.StatusCode(), failure.RequestID(), bytes.TrimSpace(body.Bytes()))
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/database/dynamo"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/database/dynamo/dynamotest"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model/modeltest"
+)
+
+// newClient starts an emulator serving fake and returns the
+// configuration and the SDK client that reach it
+func newClient(t *testing.T, fake *dynamotest.Fake) (*dynamodb.DynamoDB, dynamo.Config) {
+	t.Helper()
+	server := httptest.NewServer(NewEmulator(fake))
+	t.Cleanup(server.Close)
+	config := dynamo.DefaultConfig()
+	config.Endpoint = server.URL
+	config.Credentials = dynamo.CredentialsStatic
+	awsSession, err := dynamo.NewAWSSession(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dynamodb.New(awsSession), config
+}
+
+// TestConformance runs the behavioural contract against the DynamoDB
+// backend, talking to the emulator over HTTP
+func TestConformance(t *testing.T) {
+	_, config := newClient(t, dynamotest.New())
+	backend, err := dynamo.New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := backend.PrepareSchema(ctx, dynamo.SchemaApply); err != nil {
+		t.Fatalf("PrepareSchema: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := backend.CreateUser(ctx, fmt.Sprintf("user%d@example.invalid", i)); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+	modeltest.Run(t, func(t *testing.T) model.Interface {
+		return backend
+	})
+}
+
+func TestErrors(t *testing.T) {
+	client, _ := newClient(t, dynamotest.New())
+	_, err := client.CreateTable(&dynamodb.CreateTableInput{
+		TableName:   aws.String("test"),
+		BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{AttributeName: aws.String("id"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("id"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+
+	_, err = client.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String("missing")})
+	if _, ok := err.(*dynamodb.ResourceNotFoundException); !ok {
+		t.Fatalf("DescribeTable: got %v, expected a ResourceNotFoundException", err)
+	}
+
+	_, err = client.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String("test"),
+		Item:      map[string]*dynamodb.AttributeValue{"id": {N: aws.String("1")}},
+	})
+	if aerr, ok := err.(awserr.RequestFailure); !ok || aerr.Code() != "ValidationException" || aerr.StatusCode() != http.StatusBadRequest ||
+		!strings.Contains(aerr.Message(), "Type mismatch") {
+		t.Fatalf("PutItem with a mistyped key: got %v, expected a ValidationException", err)
+	}
+
+	old := map[string]*dynamodb.AttributeValue{"id": {S: aws.String("a")}, "v": {B: []byte{1, 2}}}
+	if _, err := client.PutItem(&dynamodb.PutItemInput{TableName: aws.String("test"), Item: old}); err != nil {
+		t.Fatalf("PutItem: %v", err)
+	}
+	_, err = client.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{Put: &dynamodb.Put{
+				TableName: aws.String("test"),
+				Item:      map[string]*dynamodb.AttributeValue{"id": {S: aws.String("b")}},
+			}},
+			{ConditionCheck: &dynamodb.ConditionCheck{
+				TableName:                           aws.String("test"),
+				Key:                                 map[string]*dynamodb.AttributeValue{"id": {S: aws.String("a")}},
+				ConditionExpression:                 aws.String("attribute_not_exists(v)"),
+				ReturnValuesOnConditionCheckFailure: aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld),
+			}},
+		},
+	})
+	cancelled, ok := err.(*dynamodb.TransactionCanceledException)
+	if !ok || len(cancelled.CancellationReasons) != 2 {
+		t.Fatalf("TransactWriteItems: got %v, expected a TransactionCanceledException", err)
+	}
+	if reason := cancelled.CancellationReasons[1]; aws.StringValue(reason.Code) != "ConditionalCheckFailed" || !reflect.DeepEqual(reason.Item, old) {
+		t.Fatalf("TransactWriteItems: got reason %v, expected ConditionalCheckFailed with %v", reason, old)
+	}
+
+	request, _ := http.NewRequest(http.MethodPost, client.Endpoint, strings.NewReader("{}"))
+	request.Header.Set("X-Amz-Target", targetPrefix+"RestoreTableFromBackup")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusBadRequest {
+		t.Fatalf("RestoreTableFromBackup: got status %d, expected %d", response.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestEncodeOutput(t *testing.T) {
+	created := time.Unix(1600000000, 0)
+	body, err := encodeOutput(&dynamodb.DescribeTableOutput{
+		Table: &dynamodb.TableDescription{
+			TableName:        aws.String("test"),
+			CreationDateTime: &created,
+			ItemCount:        aws.Int64(0),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Unset fields are left out, and times are seconds since the epoch
+	if expected := `{"Table":{"CreationDateTime":1600000000,"ItemCount":0,"TableName":"test"}}`; string(body) != expected {
+		t.Fatalf("got %s, expected %s", body, expected)
+	}
+	body, err = encodeOutput(&dynamodb.ResourceNotFoundException{Message_: aws.String("missing")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"message":"missing"}`; string(body) != expected {
+		t.Fatalf("got %s, expected %s", body, expected)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	fake := dynamotest.New()
+	client, config := newClient(t, fake)
+	backend := dynamo.NewWithClient(client, config)
+	ctx := context.Background()
+	if err := backend.ApplySchema(ctx); err != nil {
+		t.Fatalf("ApplySchema: %v", err)
+	}
+	userID, err := backend.CreateUser(ctx, "saved@example.invalid")
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "emulator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tables.json")
+	if err := save(fake, path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	loaded := dynamotest.New()
+	if err := load(loaded, path); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	client, _ = newClient(t, loaded)
+	backend = dynamo.NewWithClient(client, config)
+	if err := backend.VerifySchema(ctx); err != nil {
+		t.Fatalf("VerifySchema: %v", err)
+	}
+	user, err := backend.GetUserByEmail(ctx, "saved@example.invalid")
+	if err != nil || user.ID != userID {
+		t.Fatalf("GetUserByEmail = %+v (%v), expected user %s", user, err, userID)
+	}
+
+	// A missing snapshot leaves the fake empty
+	if err := load(dynamotest.New(), filepath.Join(dir, "missing.json")); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/database/dynamo/dynamotest"
+)
+
+func main() {
+
+	flagSet := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	addr := flagSet.String("addr", "localhost:8000", "address to listen on")
+	snapshot := flagSet.String("snapshot", "", "file the tables are loaded from at start and saved to on exit (none = in memory only)")
+	interval := flagSet.Duration("interval", 0, "how often the snapshot is also saved while running (0 = on exit only)")
+	flagSet.Parse(os.Args[1:])
+
+	fake := dynamotest.New()
+	if *snapshot != "" {
+		if err := load(fake, *snapshot); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	server := &http.Server{
+		Addr:    *addr,
+		Handler: NewEmulator(fake),
+	}
+
+	// The periodic saves stop, and the one under way finishes, once
+	// stopTicker is closed
+	stopTicker := make(chan struct{})
+	var ticking sync.WaitGroup
+	if *snapshot != "" && *interval > 0 {
+		ticking.Add(1)
+		go func() {
+			defer ticking.Done()
+			ticker := time.NewTicker(*interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					if err := save(fake, *snapshot); err != nil {
+						log.Printf("Saving snapshot: %v", err)
+					}
+				case <-stopTicker:
+					return
+				}
+			}
+		}()
+	}
+
+	// Finish the requests in flight on Ctrl-C or SIGTERM, then save the
+	// snapshot
+	done := make(chan struct{})
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		received := <-signals
+		log.Printf("%v received: shutting down", received)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("Shutdown: %v", err)
+		}
+		close(stopTicker)
+		ticking.Wait()
+		if *snapshot != "" {
+			if err := save(fake, *snapshot); err != nil {
+				log.Printf("Saving snapshot: %v", err)
+			} else {
+				log.Printf("Snapshot saved to %s", *snapshot)
+			}
+		}
+		close(done)
+	}()
+
+	log.Printf("DynamoDB emulator listening on %s", *addr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-done
+}
+
+// load reads the snapshot at path into fake, unless there is none yet
+func load(fake *dynamotest.Fake, path string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		log.Printf("No snapshot at %s: starting without tables", path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := fake.Load(file); err != nil {
+		return err
+	}
+	log.Printf("Snapshot loaded from %s", path)
+	return nil
+}
+
+// saving serialises the calls to save, which share a temporary file
+var saving sync.Mutex
+
+// save writes the snapshot to a temporary file first, so that path holds
+// either the previous snapshot or the new one, never a partial one
+func save(fake *dynamotest.Fake, path string) error {
+	saving.Lock()
+	defer saving.Unlock()
+	file, err := os.Create(filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp"))
+	if err != nil {
+		return err
+	}
+	if err := fake.Save(file); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
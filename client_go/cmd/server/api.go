@@ -0,0 +1,491 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/wire"
+)
+
+// Page sizes accepted through the limit query parameter
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+// API serves model.Interface as a REST API under /v1:
+//
+//	GET    /v1/users[?email=]                        Users, or the one with email
+//	GET    /v1/users/{userID}                        User
+//	GET    /v1/users/{userID}/lists                  Lists owned by or shared with the user
+//	POST   /v1/users/{userID}/lists                  Create a list: {"title"}
+//	DELETE /v1/users/{userID}/lists/{listID}         Delete a list owned by the user
+//	GET    /v1/lists/{listID}                        List
+//	GET    /v1/lists/{listID}/guests                 Guests of the list
+//	GET    /v1/lists/{listID}/guests/{userID}        Guest
+//	PUT    /v1/lists/{listID}/guests/{userID}        Add a guest
+//	DELETE /v1/lists/{listID}/guests/{userID}        Remove a guest
+//	GET    /v1/lists/{listID}/items                  Items of the list
+//	POST   /v1/lists/{listID}/items                  Create an item: {"description"}
+//	GET    /v1/lists/{listID}/items/{datetime}       Item, with its version as ETag
+//	PATCH  /v1/lists/{listID}/items/{datetime}       Update an item: {"description","done"}, If-Match required
+//	DELETE /v1/lists/{listID}/items/{datetime}       Delete an item
+//
+// Collections are paginated with the cursor and limit query parameters.
+type API struct {
+	backend model.Interface
+	timeout time.Duration
+}
+
+// NewAPI bounds the backend calls made for each request by timeout,
+// unless it is zero
+func NewAPI(backend model.Interface, timeout time.Duration) *API {
+	return &API{
+		backend: backend,
+		timeout: timeout,
+	}
+}
+
+// statusRecorder keeps the status code for the access log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	recorder.status = status
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+// route is a handler for a path whose segments have been matched
+type route func(ctx context.Context, w http.ResponseWriter, r *http.Request, params []string)
+
+func (api *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	defer func() {
+		log.Printf("%s %s %d (%s)", r.Method, r.URL.Path, recorder.status, time.Since(start))
+	}()
+
+	ctx := r.Context()
+	if api.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, api.timeout)
+		defer cancel()
+	}
+
+	routes, params := api.match(strings.Split(strings.Trim(r.URL.Path, "/"), "/"))
+	if routes == nil {
+		writeError(recorder, http.StatusNotFound, fmt.Errorf("no such resource: %s", r.URL.Path))
+		return
+	}
+	handler, ok := routes[r.Method]
+	if !ok {
+		allowed := make([]string, 0, len(routes))
+		for method := range routes {
+			allowed = append(allowed, method)
+		}
+		recorder.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(recorder, http.StatusMethodNotAllowed, fmt.Errorf("%s not allowed on %s", r.Method, r.URL.Path))
+		return
+	}
+	handler(ctx, recorder, r, params)
+}
+
+// match returns the handlers by HTTP method for the given path segments,
+// along with the path parameters, or nil if the path is unknown
+func (api *API) match(segments []string) (map[string]route, []string) {
+	if len(segments) < 2 || segments[0] != "v1" {
+		return nil, nil
+	}
+	segments = segments[1:]
+	for _, s := range segments {
+		if s == "" {
+			return nil, nil
+		}
+	}
+	switch segments[0] {
+	case "users":
+		switch {
+		case len(segments) == 1:
+			return map[string]route{http.MethodGet: api.listUsers}, nil
+		case len(segments) == 2:
+			return map[string]route{http.MethodGet: api.getUser}, segments[1:]
+		case len(segments) == 3 && segments[2] == "lists":
+			return map[string]route{
+				http.MethodGet:  api.listLists,
+				http.MethodPost: api.createList,
+			}, segments[1:2]
+		case len(segments) == 4 && segments[2] == "lists":
+			return map[string]route{http.MethodDelete: api.deleteList}, []string{segments[1], segments[3]}
+		}
+	case "lists":
+		switch {
+		case len(segments) == 2:
+			return map[string]route{http.MethodGet: api.getList}, segments[1:]
+		case len(segments) == 3 && segments[2] == "guests":
+			return map[string]route{http.MethodGet: api.listGuests}, segments[1:2]
+		case len(segments) == 4 && segments[2] == "guests":
+			return map[string]route{
+				http.MethodGet:    api.getGuest,
+				http.MethodPut:    api.createGuest,
+				http.MethodDelete: api.deleteGuest,
+			}, []string{segments[1], segments[3]}
+		case len(segments) == 3 && segments[2] == "items":
+			return map[string]route{
+				http.MethodGet:  api.listItems,
+				http.MethodPost: api.createItem,
+			}, segments[1:2]
+		case len(segments) == 4 && segments[2] == "items":
+			return map[string]route{
+				http.MethodGet:    api.getItem,
+				http.MethodPatch:  api.updateItem,
+				http.MethodDelete: api.deleteItem,
+			}, []string{segments[1], segments[3]}
+		}
+	}
+	return nil, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Encoding response: %v", err)
+	}
+}
+
+// writeError reports err as a wire.ErrorBody. Errors that the model does
+// not classify are coded after status, such as method_not_allowed.
+func writeError(w http.ResponseWriter, status int, err error) {
+	body := wire.NewError(err)
+	if body.Code == wire.CodeUnknown {
+		body.Code = strings.Replace(strings.ToLower(http.StatusText(status)), " ", "_", -1)
+	}
+	writeJSON(w, status, wire.ErrorBody{Error: body})
+}
+
+// writeBackendError maps backend errors onto status codes
+func writeBackendError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, model.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, model.ErrForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, model.ErrVersionConflict), errors.Is(err, model.ErrAlreadyExists), errors.Is(err, model.ErrUnderDeletion):
+		status = http.StatusConflict
+	case errors.Is(err, model.ErrValidation):
+		status = http.StatusBadRequest
+	case errors.Is(err, model.ErrThrottled):
+		status = http.StatusTooManyRequests
+	case errors.Is(err, model.ErrTransient):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		// The client went away; nobody reads the status
+		status = http.StatusServiceUnavailable
+	}
+	if status == http.StatusInternalServerError {
+		log.Printf("Backend error: %v", err)
+	}
+	writeError(w, status, err)
+}
+
+// pagination reads the cursor and limit query parameters
+func pagination(r *http.Request) (model.Cursor, int64, error) {
+	query := r.URL.Query()
+	limit := int64(defaultLimit)
+	if s := query.Get("limit"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n < 1 || n > maxLimit {
+			return "", 0, fmt.Errorf("limit must be a number between 1 and %d", maxLimit)
+		}
+		limit = n
+	}
+	return model.Cursor(query.Get("cursor")), limit, nil
+}
+
+// readJSON decodes the request body into v, rejecting unknown fields
+func readJSON(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid JSON body: %v", err)
+	}
+	return nil
+}
+
+// etag renders an item version as a strong entity tag
+func etag(version int) string {
+	return fmt.Sprintf("\"%d\"", version)
+}
+
+// ifMatch extracts the version from an If-Match header holding a single
+// entity tag as rendered by etag
+func ifMatch(r *http.Request) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if len(header) < 3 || header[0] != '"' || header[len(header)-1] != '"' {
+		return 0, fmt.Errorf("If-Match must hold the item's ETag, e.g. \"3\"")
+	}
+	version, err := strconv.Atoi(header[1 : len(header)-1])
+	if err != nil {
+		return 0, fmt.Errorf("If-Match must hold the item's ETag, e.g. \"3\"")
+	}
+	return version, nil
+}
+
+func (api *API) listUsers(ctx context.Context, w http.ResponseWriter, r *http.Request, params []string) {
+	if email := r.URL.Query().Get("email"); email != "" {
+		user, err := api.backend.GetUserByEmail(ctx, email)
+		if err != nil {
+			writeBackendError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, wire.Page{Data: []model.User{user}})
+		return
+	}
+	cursor, limit, err := pagination(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	users, next, err := api.backend.ListUsers(ctx, cursor, limit)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, wire.Page{Data: users, NextCursor: next})
+}
+
+func (api *API) getUser(ctx context.Context, w http.ResponseWriter, r *http.Request, params []string) {
+	users, err := api.backend.GetUsersByIDs(ctx, params[:1])
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	if len(users) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no user with ID %s", params[0]))
+		return
+	}
+	writeJSON(w, http.StatusOK, users[0])
+}
+
+func (api *API) listLists(ctx context.Context, w http.ResponseWriter, r *http.Request, params []string) {
+	cursor, limit, err := pagination(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	lists, next, err := api.backend.GetAggregateListsByUserIDPage(ctx, params[0], cursor, limit)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, wire.Page{Data: lists, NextCursor: next})
+}
+
+func (api *API) createList(ctx context.Context, w http.ResponseWriter, r *http.Request, params []string) {
+	var body struct {
+		Title string `json:"title"`
+	}
+	if err := readJSON(r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if body.Title == "" {
+		writeError(w, http.StatusBadRequest, errors.New("title is required"))
+		return
+	}
+	listID, err := api.backend.CreateList(ctx, params[0], body.Title)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/v1/lists/"+listID)
+	writeJSON(w, http.StatusCreated, model.List{
+		ID:     listID,
+		Title:  body.Title,
+		UserID: params[0],
+	})
+}
+
+func (api *API) deleteList(ctx context.Context, w http.ResponseWriter, r *http.Request, params []string) {
+	if err := api.backend.DeleteList(ctx, params[1], params[0]); err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *API) getList(ctx context.Context, w http.ResponseWriter, r *http.Request, params []string) {
+	list, err := api.backend.GetListByListID(ctx, params[0])
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (api *API) listGuests(ctx context.Context, w http.ResponseWriter, r *http.Request, params []string) {
+	cursor, limit, err := pagination(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	guests, next, err := api.backend.GetAggregateGuestsByListIDPage(ctx, params[0], cursor, limit)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, wire.Page{Data: guests, NextCursor: next})
+}
+
+func (api *API) getGuest(ctx context.Context, w http.ResponseWriter, r *http.Request, params []string) {
+	isPresent, err := api.backend.IsPresentGuest(ctx, params[0], params[1])
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	if !isPresent {
+		writeError(w, http.StatusNotFound, fmt.Errorf("user %s is not a guest of list %s", params[1], params[0]))
+		return
+	}
+	writeJSON(w, http.StatusOK, model.Guest{ListID: params[0], UserID: params[1]})
+}
+
+func (api *API) createGuest(ctx context.Context, w http.ResponseWriter, r *http.Request, params []string) {
+	if err := api.backend.CreateGuest(ctx, params[0], params[1]); err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, model.Guest{ListID: params[0], UserID: params[1]})
+}
+
+func (api *API) deleteGuest(ctx context.Context, w http.ResponseWriter, r *http.Request, params []string) {
+	if err := api.backend.DeleteGuest(ctx, params[0], params[1]); err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (api *API) listItems(ctx context.Context, w http.ResponseWriter, r *http.Request, params []string) {
+	cursor, limit, err := pagination(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	items, next, err := api.backend.GetItemsByListIDPage(ctx, params[0], cursor, limit)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, wire.Page{Data: items, NextCursor: next})
+}
+
+func (api *API) createItem(ctx context.Context, w http.ResponseWriter, r *http.Request, params []string) {
+	var body struct {
+		Description string `json:"description"`
+	}
+	if err := readJSON(r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if body.Description == "" {
+		writeError(w, http.StatusBadRequest, errors.New("description is required"))
+		return
+	}
+	datetime, err := api.backend.CreateItem(ctx, params[0], body.Description)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/v1/lists/%s/items/%s", params[0], datetime))
+	w.Header().Set("ETag", etag(0))
+	writeJSON(w, http.StatusCreated, model.Item{
+		ListID:      params[0],
+		Datetime:    datetime,
+		Description: body.Description,
+	})
+}
+
+func (api *API) getItem(ctx context.Context, w http.ResponseWriter, r *http.Request, params []string) {
+	item, err := api.backend.GetItem(ctx, params[0], params[1])
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	w.Header().Set("ETag", etag(item.Version))
+	if r.Header.Get("If-None-Match") == etag(item.Version) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+func (api *API) updateItem(ctx context.Context, w http.ResponseWriter, r *http.Request, params []string) {
+	if r.Header.Get("If-Match") == "" {
+		writeError(w, http.StatusPreconditionRequired, errors.New("If-Match is required to update an item"))
+		return
+	}
+	version, err := ifMatch(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var body struct {
+		Description *string `json:"description"`
+		Done        *bool   `json:"done"`
+	}
+	if err := readJSON(r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if body.Description == nil && body.Done == nil {
+		writeError(w, http.StatusBadRequest, errors.New("description or done is required"))
+		return
+	}
+	version, err = api.backend.UpdateItem(ctx, params[0], params[1], version, body.Description, body.Done)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	// Reading the item back could return a later version, written by a
+	// concurrent update, so only what this one wrote is returned
+	w.Header().Set("ETag", etag(version))
+	writeJSON(w, http.StatusOK, itemUpdate{
+		ListID:      params[0],
+		Datetime:    params[1],
+		Description: body.Description,
+		Done:        body.Done,
+		Version:     version,
+	})
+}
+
+// itemUpdate is the body returned by PATCH: the fields that were set,
+// along with the item's new version
+type itemUpdate struct {
+	ListID      string  `json:"list_id"`
+	Datetime    string  `json:"datetime"`
+	Description *string `json:"description,omitempty"`
+	Done        *bool   `json:"done,omitempty"`
+	Version     int     `json:"version"`
+}
+
+func (api *API) deleteItem(ctx context.Context, w http.ResponseWriter, r *http.Request, params []string) {
+	if err := api.backend.DeleteItem(ctx, params[0], params[1]); err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
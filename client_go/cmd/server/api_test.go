@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/database/memory"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/wire"
+)
+
+const (
+	ownerID = "7c2be6b9-746c-44be-bb33-78fb402ce6b8"
+	guestID = "a10f9a38-f6dc-4e8a-ac1c-180486389697"
+)
+
+// do sends a request to the API and decodes the JSON response into out,
+// unless it is nil, failing the test unless the status is as expected
+func do(t *testing.T, server *httptest.Server, method string, path string, body string, header http.Header, status int, out interface{}) *http.Response {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	request, err := http.NewRequest(method, server.URL+path, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		request.Header[k] = v
+	}
+	response, err := server.Client().Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != status {
+		data, _ := ioutil.ReadAll(response.Body)
+		t.Fatalf("%s %s: expected status %d, got %d (%s)", method, path, status, response.StatusCode, data)
+	}
+	if out != nil {
+		if err := json.NewDecoder(response.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return response
+}
+
+func TestItemLifecycle(t *testing.T) {
+	server := httptest.NewServer(NewAPI(memory.New(), time.Second))
+	defer server.Close()
+
+	var list model.List
+	do(t, server, http.MethodPost, "/v1/users/"+ownerID+"/lists", `{"title":"Groceries"}`, nil, http.StatusCreated, &list)
+	itemsPath := "/v1/lists/" + list.ID + "/items"
+
+	var item model.Item
+	response := do(t, server, http.MethodPost, itemsPath, `{"description":"Milk"}`, nil, http.StatusCreated, &item)
+	itemPath := response.Header.Get("Location")
+	if itemPath != itemsPath+"/"+item.Datetime {
+		t.Fatalf("unexpected Location %s", itemPath)
+	}
+
+	response = do(t, server, http.MethodGet, itemPath, "", nil, http.StatusOK, &item)
+	if etag := response.Header.Get("ETag"); etag != `"0"` || item.Description != "Milk" {
+		t.Fatalf("unexpected item %+v with ETag %s", item, etag)
+	}
+
+	do(t, server, http.MethodPatch, itemPath, `{"done":true}`, nil, http.StatusPreconditionRequired, nil)
+	// Only the fields set come back, along with the new version
+	var update map[string]interface{}
+	response = do(t, server, http.MethodPatch, itemPath, `{"done":true}`, http.Header{"If-Match": {`"0"`}}, http.StatusOK, &update)
+	if etag := response.Header.Get("ETag"); etag != `"1"` || update["done"] != true || update["version"] != 1.0 || update["description"] != nil {
+		t.Fatalf("unexpected update %v with ETag %s", update, etag)
+	}
+	response = do(t, server, http.MethodGet, itemPath, "", nil, http.StatusOK, &item)
+	if etag := response.Header.Get("ETag"); etag != `"1"` || !item.Done || item.Description != "Milk" {
+		t.Fatalf("unexpected item %+v with ETag %s", item, etag)
+	}
+	// The version has moved on since "0"
+	do(t, server, http.MethodPatch, itemPath, `{"done":false}`, http.Header{"If-Match": {`"0"`}}, http.StatusConflict, nil)
+
+	do(t, server, http.MethodDelete, itemPath, "", nil, http.StatusNoContent, nil)
+	do(t, server, http.MethodGet, itemPath, "", nil, http.StatusNotFound, nil)
+}
+
+func TestPagination(t *testing.T) {
+	server := httptest.NewServer(NewAPI(memory.New(), time.Second))
+	defer server.Close()
+
+	var list model.List
+	do(t, server, http.MethodPost, "/v1/users/"+ownerID+"/lists", `{"title":"Chores"}`, nil, http.StatusCreated, &list)
+	for _, description := range []string{"Dust", "Mop", "Sweep"} {
+		do(t, server, http.MethodPost, "/v1/lists/"+list.ID+"/items", `{"description":"`+description+`"}`, nil, http.StatusCreated, nil)
+		// Item keys have microsecond resolution
+		time.Sleep(time.Millisecond)
+	}
+
+	seen := 0
+	path := "/v1/lists/" + list.ID + "/items?limit=2"
+	for pages := 0; pages < 3; pages++ {
+		var body struct {
+			Data       []model.Item `json:"data"`
+			NextCursor string       `json:"next_cursor"`
+		}
+		do(t, server, http.MethodGet, path, "", nil, http.StatusOK, &body)
+		seen += len(body.Data)
+		if body.NextCursor == "" {
+			break
+		}
+		path = "/v1/lists/" + list.ID + "/items?limit=2&cursor=" + body.NextCursor
+	}
+	if seen != 3 {
+		t.Fatalf("paged through %d items, expected 3", seen)
+	}
+	do(t, server, http.MethodGet, "/v1/lists/"+list.ID+"/items?cursor=bogus!", "", nil, http.StatusBadRequest, nil)
+	do(t, server, http.MethodGet, "/v1/lists/"+list.ID+"/items?limit=0", "", nil, http.StatusBadRequest, nil)
+}
+
+func TestErrors(t *testing.T) {
+	server := httptest.NewServer(NewAPI(memory.New(), time.Second))
+	defer server.Close()
+
+	var list model.List
+	do(t, server, http.MethodPost, "/v1/users/"+ownerID+"/lists", `{"title":"Party"}`, nil, http.StatusCreated, &list)
+	guestPath := "/v1/lists/" + list.ID + "/guests/" + guestID
+	do(t, server, http.MethodPut, guestPath, "", nil, http.StatusCreated, nil)
+	var body wire.ErrorBody
+	do(t, server, http.MethodPut, guestPath, "", nil, http.StatusConflict, &body)
+	if body.Error.Code != "already_exists" || body.Error.Message == "" {
+		t.Fatalf("unexpected error %+v", body)
+	}
+
+	do(t, server, http.MethodGet, "/v1/lists/unknown", "", nil, http.StatusNotFound, nil)
+	do(t, server, http.MethodGet, "/v2/users", "", nil, http.StatusNotFound, nil)
+	do(t, server, http.MethodPost, "/v1/users/"+ownerID+"/lists", `{"name":"Party"}`, nil, http.StatusBadRequest, nil)
+	body = wire.ErrorBody{}
+	response := do(t, server, http.MethodPost, "/v1/lists/"+list.ID, "", nil, http.StatusMethodNotAllowed, &body)
+	if body.Error.Code != "method_not_allowed" {
+		t.Fatalf("unexpected error %+v", body)
+	}
+	if allow := response.Header.Get("Allow"); allow != http.MethodGet {
+		t.Fatalf("unexpected Allow %s", allow)
+	}
+	// Only the owner may delete the list
+	do(t, server, http.MethodDelete, "/v1/users/"+guestID+"/lists/"+list.ID, "", nil, http.StatusForbidden, nil)
+	do(t, server, http.MethodDelete, "/v1/users/"+ownerID+"/lists/"+list.ID, "", nil, http.StatusNoContent, nil)
+}
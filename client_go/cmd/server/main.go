@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/config"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/database/bolt"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/database/dynamo"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/database/memory"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/database/sqlite"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+)
+
+func main() {
+
+	// Abstract interface
+	var backend model.Interface
+
+	flagSet := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	configFlags := config.Register(flagSet)
+	addr := flagSet.String("addr", ":8080", "address to listen on")
+	timeout := flagSet.Duration("timeout", 10*time.Second, "deadline for each request's DB operations (0 = none)")
+	dataDir := flagSet.String("data", "", "directory the memory backend keeps its data in (none = lost on exit)")
+	dbFile := flagSet.String("db", "todo.db", "SQLite database file the sql backend keeps its data in (none = lost on exit)")
+	boltFile := flagSet.String("bolt", "todo.bolt", "file the bolt backend keeps its data in")
+	flagSet.Parse(os.Args[1:])
+
+	if flagSet.Arg(0) == "memory" {
+		if *dataDir == "" {
+			log.Print("Memory backend selected")
+			backend = memory.New()
+		} else {
+			log.Printf("Memory backend selected (data=%s)", *dataDir)
+			memorySession, err := memory.Open(*dataDir)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer memorySession.Close()
+			backend = memorySession
+		}
+	} else if flagSet.Arg(0) == "sql" {
+		log.Printf("SQL backend selected (db=%s)", *dbFile)
+		sqlSession, err := sqlite.Open(*dbFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer sqlSession.Close()
+		backend = sqlSession
+	} else if flagSet.Arg(0) == "bolt" {
+		log.Printf("Bolt backend selected (file=%s)", *boltFile)
+		boltSession, err := bolt.Open(*boltFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer boltSession.Close()
+		backend = boltSession
+	} else {
+		dynamoConfig, err := configFlags.Load()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		var dbSession dynamo.Backend
+		if flagSet.Arg(0) == "single" {
+			log.Printf("DynamoDB single-table backend selected (region=%s,endpoint=%s,table=%s)",
+				dynamoConfig.Region, dynamoConfig.Endpoint, dynamoConfig.TableNames().Single)
+			dbSession, err = dynamo.NewSingleTable(dynamoConfig)
+		} else {
+			log.Printf("DynamoDB backend selected (region=%s,endpoint=%s,users table=%s)",
+				dynamoConfig.Region, dynamoConfig.Endpoint, dynamoConfig.TableNames().Users)
+			dbSession, err = dynamo.New(dynamoConfig)
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if err := dbSession.PrepareSchema(context.Background(), dynamoConfig.Schema); err != nil {
+			log.Fatal(err)
+		}
+		backend = dbSession
+	}
+
+	server := &http.Server{
+		Addr:    *addr,
+		Handler: NewAPI(backend, *timeout),
+	}
+
+	// Finish the requests in flight on Ctrl-C
+	done := make(chan struct{})
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt)
+		<-signals
+		log.Println("Interrupt received: shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("Shutdown: %v", err)
+		}
+		close(done)
+	}()
+
+	log.Printf("Listening on %s", *addr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-done
+}
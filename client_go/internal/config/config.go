@@ -0,0 +1,146 @@
+// Package config loads the DynamoDB settings from, in increasing order of
+// precedence, built-in defaults, a JSON config file, environment
+// variables and command line flags.
+//
+// A config file may hold named environments whose settings override
+// the top-level ones, for example:
+//
+//	{
+//	  "region": "eu-west-2",
+//	  "environments": {
+//	    "local":   {"endpoint": "http://localhost:8000", "credentials": "static"},
+//	    "staging": {"table_prefix": "staging_"}
+//	  }
+//	}
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/database/dynamo"
+)
+
+// Environment variables
+const (
+	EnvConfig      = "DYNAMODB_CONFIG"
+	EnvEnvironment = "DYNAMODB_ENV"
+	EnvEndpoint    = "DYNAMODB_ENDPOINT"
+	EnvRegion      = "DYNAMODB_REGION"
+	EnvProfile     = "DYNAMODB_PROFILE"
+	EnvCredentials = "DYNAMODB_CREDENTIALS"
+	EnvTablePrefix = "DYNAMODB_TABLE_PREFIX"
+	EnvTableSuffix = "DYNAMODB_TABLE_SUFFIX"
+	EnvSchema      = "DYNAMODB_SCHEMA"
+)
+
+// file is the layout of the JSON config file
+type file struct {
+	dynamo.Config
+	Environments map[string]json.RawMessage `json:"environments"`
+}
+
+// Flags binds the configuration options to a flag.FlagSet
+type Flags struct {
+	flagSet     *flag.FlagSet
+	file        string
+	environment string
+	endpoint    string
+	region      string
+	profile     string
+	credentials string
+	tablePrefix string
+	tableSuffix string
+	schema      string
+}
+
+// Register adds the configuration options to flagSet
+func Register(flagSet *flag.FlagSet) *Flags {
+	flags := &Flags{flagSet: flagSet}
+	flagSet.StringVar(&flags.file, "config", "", "JSON config file (env "+EnvConfig+")")
+	flagSet.StringVar(&flags.environment, "env", "", "environment within the config file (env "+EnvEnvironment+")")
+	flagSet.StringVar(&flags.endpoint, "endpoint", "", "DynamoDB endpoint URL, e.g. http://localhost:8000 (env "+EnvEndpoint+")")
+	flagSet.StringVar(&flags.region, "region", "", "AWS region (env "+EnvRegion+")")
+	flagSet.StringVar(&flags.profile, "profile", "", "AWS named profile (env "+EnvProfile+")")
+	flagSet.StringVar(&flags.credentials, "credentials", "", "credentials source: default, profile, env or static (env "+EnvCredentials+")")
+	flagSet.StringVar(&flags.tablePrefix, "table-prefix", "", "prefix added to every table name (env "+EnvTablePrefix+")")
+	flagSet.StringVar(&flags.tableSuffix, "table-suffix", "", "suffix added to every table name (env "+EnvTableSuffix+")")
+	flagSet.StringVar(&flags.schema, "schema", "", "on startup, verify the tables, apply the schema or do none (env "+EnvSchema+")")
+	return flags
+}
+
+// Load must be called once the flag set has been parsed
+func (flags *Flags) Load() (dynamo.Config, error) {
+	set := make(map[string]bool)
+	flags.flagSet.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	pick := func(name string, value string, env string) string {
+		if set[name] {
+			return value
+		}
+		return os.Getenv(env)
+	}
+
+	config := dynamo.DefaultConfig()
+	path := pick("config", flags.file, EnvConfig)
+	environment := pick("env", flags.environment, EnvEnvironment)
+	if path != "" {
+		if err := loadFile(&config, path, environment); err != nil {
+			return dynamo.Config{}, err
+		}
+	} else if environment != "" {
+		return dynamo.Config{}, fmt.Errorf("environment %q requires a config file", environment)
+	}
+
+	overrides := []struct {
+		field *string
+		name  string
+		value string
+		env   string
+	}{
+		{&config.Endpoint, "endpoint", flags.endpoint, EnvEndpoint},
+		{&config.Region, "region", flags.region, EnvRegion},
+		{&config.Profile, "profile", flags.profile, EnvProfile},
+		{&config.Credentials, "credentials", flags.credentials, EnvCredentials},
+		{&config.TablePrefix, "table-prefix", flags.tablePrefix, EnvTablePrefix},
+		{&config.TableSuffix, "table-suffix", flags.tableSuffix, EnvTableSuffix},
+		{&config.Schema, "schema", flags.schema, EnvSchema},
+	}
+	for _, o := range overrides {
+		if set[o.name] {
+			*o.field = o.value
+		} else if v, ok := os.LookupEnv(o.env); ok {
+			*o.field = v
+		}
+	}
+	return config, nil
+}
+
+// loadFile merges the file's top-level settings and then those of the
+// selected environment into config
+func loadFile(config *dynamo.Config, path string, environment string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	contents := file{Config: *config}
+	if err := json.Unmarshal(data, &contents); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	*config = contents.Config
+	if environment == "" {
+		return nil
+	}
+	overrides, ok := contents.Environments[environment]
+	if !ok {
+		return fmt.Errorf("%s: environment %q not found", path, environment)
+	}
+	if err := json.Unmarshal(overrides, config); err != nil {
+		return fmt.Errorf("%s: environment %q: %v", path, environment, err)
+	}
+	return nil
+}
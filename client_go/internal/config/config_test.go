@@ -0,0 +1,168 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/database/dynamo"
+)
+
+// configFile has top-level settings and two environments
+const configFile = `{
+  "region": "file-region",
+  "endpoint": "http://file:8000",
+  "table_prefix": "file_",
+  "access_key_id": "file-key",
+  "indexes": {"items_by_order": "file_items_by_order"},
+  "environments": {
+    "local":   {"endpoint": "http://localhost:8000", "credentials": "static"},
+    "staging": {"table_prefix": "staging_", "region": "staging-region"}
+  }
+}`
+
+var variables = []string{
+	EnvConfig, EnvEnvironment, EnvEndpoint, EnvRegion, EnvProfile,
+	EnvCredentials, EnvTablePrefix, EnvTableSuffix, EnvSchema,
+}
+
+// setenv sets the given variables, clearing the others, until the end
+// of the test
+func setenv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, name := range variables {
+		previous, ok := os.LookupEnv(name)
+		name := name
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(name, previous)
+			} else {
+				os.Unsetenv(name)
+			}
+		})
+		if value, set := env[name]; set {
+			os.Setenv(name, value)
+		} else {
+			os.Unsetenv(name)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "todo.json")
+	if err := ioutil.WriteFile(path, []byte(configFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	broken := filepath.Join(dir, "broken.json")
+	if err := ioutil.WriteFile(broken, []byte(`{"region": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := dynamo.DefaultConfig()
+	fromFile := defaults
+	fromFile.Region = "file-region"
+	fromFile.Endpoint = "http://file:8000"
+	fromFile.TablePrefix = "file_"
+	fromFile.AccessKeyID = "file-key"
+	fromFile.Indexes.ItemsByOrder = "file_items_by_order"
+
+	tests := []struct {
+		name  string
+		args  []string
+		env   map[string]string
+		check func(config dynamo.Config) bool
+		err   string
+	}{
+		{"Defaults", nil, nil, func(c dynamo.Config) bool {
+			return c == defaults
+		}, ""},
+		{"File", []string{"-config", path}, nil, func(c dynamo.Config) bool {
+			return c == fromFile
+		}, ""},
+		{"FileFromEnv", nil, map[string]string{EnvConfig: path}, func(c dynamo.Config) bool {
+			return c == fromFile
+		}, ""},
+		{"EnvOverFile", []string{"-config", path}, map[string]string{EnvRegion: "env-region"}, func(c dynamo.Config) bool {
+			return c.Region == "env-region" && c.TablePrefix == "file_"
+		}, ""},
+		{"FlagOverEnv", []string{"-config", path, "-region", "flag-region"}, map[string]string{EnvRegion: "env-region"}, func(c dynamo.Config) bool {
+			return c.Region == "flag-region"
+		}, ""},
+		{"EmptyFlagOverEnv", []string{"-table-prefix", ""}, map[string]string{EnvTablePrefix: "env_"}, func(c dynamo.Config) bool {
+			return c.TablePrefix == ""
+		}, ""},
+		{"EnvOverDefaults", nil, map[string]string{EnvSchema: dynamo.SchemaApply, EnvTableSuffix: "_env", EnvProfile: "env-profile"}, func(c dynamo.Config) bool {
+			return c.Schema == dynamo.SchemaApply && c.TableSuffix == "_env" && c.Profile == "env-profile"
+		}, ""},
+		{"Environment", []string{"-config", path, "-env", "staging"}, nil, func(c dynamo.Config) bool {
+			return c.Region == "staging-region" && c.TablePrefix == "staging_" && c.Endpoint == "http://file:8000"
+		}, ""},
+		{"EnvironmentFromEnv", []string{"-config", path}, map[string]string{EnvEnvironment: "local"}, func(c dynamo.Config) bool {
+			return c.Endpoint == "http://localhost:8000" && c.Region == "file-region"
+		}, ""},
+		{"FlagOverEnvironment", []string{"-config", path, "-env", "local", "-endpoint", "http://flag:8000"}, nil, func(c dynamo.Config) bool {
+			return c.Endpoint == "http://flag:8000"
+		}, ""},
+		{"StaticCredentials", []string{"-config", path, "-env", "local"}, nil, func(c dynamo.Config) bool {
+			return c.Credentials == dynamo.CredentialsStatic && c.AccessKeyID == "file-key" && c.SecretAccessKey == "local"
+		}, ""},
+		{"CredentialsFromEnv", nil, map[string]string{EnvCredentials: dynamo.CredentialsEnv}, func(c dynamo.Config) bool {
+			return c.Credentials == dynamo.CredentialsEnv
+		}, ""},
+		{"CredentialsFromFlag", []string{"-credentials", dynamo.CredentialsProfile, "-profile", "work"}, map[string]string{EnvCredentials: dynamo.CredentialsEnv}, func(c dynamo.Config) bool {
+			return c.Credentials == dynamo.CredentialsProfile && c.Profile == "work"
+		}, ""},
+		{"UnknownEnvironment", []string{"-config", path, "-env", "production"}, nil, nil, `environment "production" not found`},
+		{"EnvironmentWithoutFile", []string{"-env", "local"}, nil, nil, `environment "local" requires a config file`},
+		{"MissingFile", []string{"-config", filepath.Join(dir, "missing.json")}, nil, nil, "missing.json"},
+		{"BrokenFile", []string{"-config", broken}, nil, nil, "broken.json"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setenv(t, test.env)
+			flagSet := flag.NewFlagSet(test.name, flag.ContinueOnError)
+			flags := Register(flagSet)
+			if err := flagSet.Parse(test.args); err != nil {
+				t.Fatal(err)
+			}
+			config, err := flags.Load()
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected an error mentioning %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if !test.check(config) {
+				t.Fatalf("unexpected config %+v", config)
+			}
+		})
+	}
+}
+
+func TestUnknownCredentials(t *testing.T) {
+	setenv(t, map[string]string{EnvCredentials: "keychain"})
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := Register(flagSet)
+	if err := flagSet.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	config, err := flags.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	// The source is only checked once a session is made from the config
+	if _, err := dynamo.NewAWSSession(config); err == nil || !strings.Contains(err.Error(), `unknown credentials source "keychain"`) {
+		t.Fatalf("expected the credentials source to be rejected, got %v", err)
+	}
+}
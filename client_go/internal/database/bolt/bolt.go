@@ -0,0 +1,929 @@
+// Package bolt implements model.Interface on a bbolt file laid out as the
+// DynamoDB tables and indexes of main.tf, so that the access patterns of
+// the DynamoDB backend can be followed, and checked, against an embedded
+// store of the same shape. Unlike DynamoDB, bbolt runs every call in a
+// single transaction, however many keys it touches.
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+	"github.com/google/uuid"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// Pages of aggregate lists hold the lists owned by the user first, and
+// then those in which the user is a guest, as in the DynamoDB backend
+const (
+	ownedListsPhase = "owned"
+	guestListsPhase = "guest"
+)
+
+// Session is a type
+type Session struct {
+	db              *bbolt.DB
+	mutex           sync.RWMutex
+	slowdownSeconds int
+}
+
+// slowdown holds method back for the seconds set with Slowdown, outside
+// of any bbolt transaction, and returns ctx's error if it ends the wait
+func slowdown(ctx context.Context, boltSession *Session, method string, description string) error {
+	boltSession.mutex.RLock()
+	seconds := boltSession.slowdownSeconds
+	boltSession.mutex.RUnlock()
+	if seconds > 0 {
+		log.Printf("%s (%s) Sleeping for %d seconds", method, description, seconds)
+		select {
+		case <-time.After(time.Duration(seconds) * time.Second):
+		case <-ctx.Done():
+		}
+	}
+	return ctx.Err()
+}
+
+// scan calls fn with up to max entries of bucket, or all of them if max
+// is negative, in the order of their keys, starting after the key after.
+// It returns the last key passed to fn and whether entries remain, as a
+// DynamoDB Query does with LastEvaluatedKey. A nil bucket has no entries.
+func scan(bucket *bbolt.Bucket, after string, max int64, fn func(k []byte, v []byte) error) (string, bool, error) {
+	if bucket == nil {
+		return "", false, nil
+	}
+	c := bucket.Cursor()
+	k, v := c.First()
+	if after != "" {
+		k, v = c.Seek([]byte(after))
+		if k != nil && string(k) == after {
+			k, v = c.Next()
+		}
+	}
+	last := ""
+	for count := int64(0); k != nil; k, v = c.Next() {
+		if count == max {
+			return last, true, nil
+		}
+		if err := fn(k, v); err != nil {
+			return "", false, err
+		}
+		last = string(k)
+		count++
+	}
+	return last, false, nil
+}
+
+// page checks the arguments of a listing paged by a single key and
+// returns the key to start after
+func page(cursor model.Cursor, max int64) (string, error) {
+	if max < 1 {
+		return "", &model.ValidationError{
+			Field:  "max",
+			Reason: fmt.Sprintf("%d is not a positive page size", max),
+		}
+	}
+	keys, err := cursor.Keys(1)
+	if err != nil || keys == nil {
+		return "", err
+	}
+	return keys[0], nil
+}
+
+// next returns the Cursor of the page following the key last
+func next(last string, more bool) model.Cursor {
+	if !more {
+		return ""
+	}
+	return model.NewCursor(last)
+}
+
+// getList reads a list, failing unless it exists
+func getList(tx *bbolt.Tx, listID string) (listRecord, error) {
+	var l listRecord
+	found, err := listsTable.get(tx, "", listID, &l)
+	if err != nil {
+		return l, err
+	}
+	if !found {
+		return l, &model.NotFoundError{
+			Entity: model.EntityList,
+			Key:    listID,
+		}
+	}
+	return l, nil
+}
+
+// getWritableList reads a list, failing unless guests and items may be
+// added to it
+func getWritableList(tx *bbolt.Tx, listID string) (listRecord, error) {
+	l, err := getList(tx, listID)
+	if err == nil && l.UnderDeletion {
+		err = &model.UnderDeletionError{
+			ListID: listID,
+		}
+	}
+	return l, err
+}
+
+// ListUsers is a method
+//
+// It pages through the users table as a Scan does.
+func (boltSession *Session) ListUsers(ctx context.Context, cursor model.Cursor, max int64) ([]model.User, model.Cursor, error) {
+	if err := slowdown(ctx, boltSession, "ListUsers", "entry"); err != nil {
+		return nil, "", err
+	}
+	after, err := page(cursor, max)
+	if err != nil {
+		return nil, "", err
+	}
+	users := make([]model.User, 0)
+	var last string
+	var more bool
+	err = boltSession.db.View(func(tx *bbolt.Tx) error {
+		last, more, err = scan(usersTable.partition(tx, ""), after, max, func(k []byte, v []byte) error {
+			var u model.User
+			if err := json.Unmarshal(v, &u); err != nil {
+				return err
+			}
+			users = append(users, u)
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return users, next(last, more), nil
+}
+
+// Slowdown is a method
+func (boltSession *Session) Slowdown(seconds int) {
+	boltSession.mutex.Lock()
+	defer boltSession.mutex.Unlock()
+	boltSession.slowdownSeconds = seconds
+}
+
+// GetUsersByIDs is a method
+func (boltSession *Session) GetUsersByIDs(ctx context.Context, ids []string) ([]model.User, error) {
+	if err := slowdown(ctx, boltSession, "GetUsersByIDs", "entry"); err != nil {
+		return []model.User{}, err
+	}
+	users := make([]model.User, 0, len(ids))
+	err := boltSession.db.View(func(tx *bbolt.Tx) error {
+		for _, id := range ids {
+			var u model.User
+			found, err := usersTable.get(tx, "", id, &u)
+			if err != nil {
+				return err
+			}
+			if found {
+				users = append(users, u)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []model.User{}, err
+	}
+	return users, nil
+}
+
+// GetUserByEmail is a method
+//
+// It queries users_by_email.
+func (boltSession *Session) GetUserByEmail(ctx context.Context, email string) (model.User, error) {
+	if err := slowdown(ctx, boltSession, "GetUserByEmail", "entry"); err != nil {
+		return model.User{}, err
+	}
+	var user model.User
+	found := false
+	err := boltSession.db.View(func(tx *bbolt.Tx) error {
+		_, _, err := scan(usersByEmailIndex.partition(tx, email), "", 1, func(k []byte, v []byte) error {
+			found = true
+			return json.Unmarshal(v, &user)
+		})
+		return err
+	})
+	if err != nil {
+		return model.User{}, err
+	}
+	if !found {
+		return model.User{}, &model.NotFoundError{
+			Entity: model.EntityUser,
+			Key:    email,
+		}
+	}
+	return user, nil
+}
+
+// CreateUser is a method
+//
+// users_by_email takes as many users under one email as its DynamoDB
+// counterpart, so emails are not checked for uniqueness.
+func (boltSession *Session) CreateUser(ctx context.Context, email string) (string, error) {
+	if err := slowdown(ctx, boltSession, "CreateUser", "entry"); err != nil {
+		return "", err
+	}
+	uuidString := uuid.New().String()
+	err := boltSession.db.Update(func(tx *bbolt.Tx) error {
+		found, err := usersTable.get(tx, "", uuidString, &model.User{})
+		if err != nil {
+			return err
+		}
+		if found {
+			return &model.AlreadyExistsError{
+				Entity: model.EntityUser,
+				Key:    uuidString,
+			}
+		}
+		return putUser(tx, &model.User{
+			ID:    uuidString,
+			Email: email,
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+	return uuidString, nil
+}
+
+// GetAggregateListsByUserID is a method
+func (boltSession *Session) GetAggregateListsByUserID(ctx context.Context, userID string) ([]model.AggregateList, error) {
+	if err := slowdown(ctx, boltSession, "GetAggregateListsByUserID", "entry"); err != nil {
+		return []model.AggregateList{}, err
+	}
+	var alists []model.AggregateList
+	err := boltSession.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		alists, _, _, err = aggregateLists(tx, userID, ownedListsPhase, "", -1)
+		return err
+	})
+	if err != nil {
+		return []model.AggregateList{}, err
+	}
+	return alists, nil
+}
+
+// GetAggregateListsByUserIDPage is a method
+func (boltSession *Session) GetAggregateListsByUserIDPage(ctx context.Context, userID string, cursor model.Cursor, max int64) ([]model.AggregateList, model.Cursor, error) {
+	if err := slowdown(ctx, boltSession, "GetAggregateListsByUserIDPage", "entry"); err != nil {
+		return nil, "", err
+	}
+	if max < 1 {
+		return nil, "", &model.ValidationError{
+			Field:  "max",
+			Reason: fmt.Sprintf("%d is not a positive page size", max),
+		}
+	}
+	keys, err := cursor.Keys(2)
+	if err != nil {
+		return nil, "", err
+	}
+	phase, after := ownedListsPhase, ""
+	if keys != nil {
+		phase, after = keys[0], keys[1]
+	}
+	if phase != ownedListsPhase && phase != guestListsPhase {
+		return nil, "", &model.ValidationError{
+			Field:  "cursor",
+			Reason: fmt.Sprintf("%q is not a cursor for this listing", cursor),
+		}
+	}
+
+	var alists []model.AggregateList
+	err = boltSession.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		alists, phase, after, err = aggregateLists(tx, userID, phase, after, max)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if phase == "" {
+		return alists, "", nil
+	}
+	return alists, model.NewCursor(phase, after), nil
+}
+
+// aggregateLists reads up to max lists, or all of them if max is
+// negative, starting with phase after the key after. The list IDs are
+// found by querying lists_by_user_id, then guests_by_user_id, and the
+// lists themselves, counters included, are then read from the lists
+// table. It returns the phase and key to carry on from, the phase being
+// empty once there are no lists left.
+func aggregateLists(tx *bbolt.Tx, userID string, phase string, after string, max int64) ([]model.AggregateList, string, string, error) {
+	alists := make([]model.AggregateList, 0, 1)
+	for phase != "" && (max < 0 || int64(len(alists)) < max) {
+		index, asGuest, nextPhase := listsByUserIDIndex, false, guestListsPhase
+		if phase == guestListsPhase {
+			index, asGuest, nextPhase = guestsByUserIDIndex, true, ""
+		}
+		remaining := max
+		if max >= 0 {
+			remaining = max - int64(len(alists))
+		}
+		last, more, err := scan(index.partition(tx, userID), after, remaining, func(k []byte, v []byte) error {
+			l, err := getList(tx, string(k))
+			if err != nil {
+				return err
+			}
+			alists = append(alists, model.AggregateList{
+				List:       l.List,
+				GuestCount: l.GuestCount,
+				ItemCount:  l.ItemCount,
+				AsGuest:    asGuest,
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, "", "", err
+		}
+		if more {
+			return alists, phase, last, nil
+		}
+		phase, after = nextPhase, ""
+	}
+	return alists, phase, after, nil
+}
+
+// GetListsByUserID is a method
+//
+// It queries lists_by_user_id, which does not project under_deletion.
+func (boltSession *Session) GetListsByUserID(ctx context.Context, userID string) ([]model.List, error) {
+	if err := slowdown(ctx, boltSession, "GetListsByUserID", "entry"); err != nil {
+		return []model.List{}, err
+	}
+	lists := make([]model.List, 0)
+	err := boltSession.db.View(func(tx *bbolt.Tx) error {
+		_, _, err := scan(listsByUserIDIndex.partition(tx, userID), "", -1, func(k []byte, v []byte) error {
+			var l model.List
+			if err := json.Unmarshal(v, &l); err != nil {
+				return err
+			}
+			lists = append(lists, l)
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return []model.List{}, err
+	}
+	return lists, nil
+}
+
+// CreateList is a method
+func (boltSession *Session) CreateList(ctx context.Context, userID string, title string) (string, error) {
+	if err := slowdown(ctx, boltSession, "CreateList", "entry"); err != nil {
+		return "", err
+	}
+	uuidString := uuid.New().String()
+	err := boltSession.db.Update(func(tx *bbolt.Tx) error {
+		found, err := usersTable.get(tx, "", userID, &model.User{})
+		if err != nil {
+			return err
+		}
+		if !found {
+			return &model.NotFoundError{
+				Entity: model.EntityUser,
+				Key:    userID,
+			}
+		}
+		found, err = listsTable.get(tx, "", uuidString, &listRecord{})
+		if err != nil {
+			return err
+		}
+		if found {
+			return &model.AlreadyExistsError{
+				Entity: model.EntityList,
+				Key:    uuidString,
+			}
+		}
+		return putList(tx, &listRecord{
+			List: model.List{
+				ID:     uuidString,
+				Title:  title,
+				UserID: userID,
+			},
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+	return uuidString, nil
+}
+
+// DeleteList is a method
+//
+// The list is first flagged as under deletion, as in the DynamoDB
+// backend, so that the flag can be observed when combined with Slowdown.
+// Its guests, items and index entries are then deleted along with it in
+// a single transaction, rather than in batches.
+func (boltSession *Session) DeleteList(ctx context.Context, listID string, userID string) error {
+	const method = "DeleteList"
+	if err := slowdown(ctx, boltSession, method, "entry"); err != nil {
+		return err
+	}
+
+	//
+	// First mark the list as being deleted
+	//
+	if err := slowdown(ctx, boltSession, method, "before setting under_deletion"); err != nil {
+		return err
+	}
+	err := boltSession.db.Update(func(tx *bbolt.Tx) error {
+		l, err := getList(tx, listID)
+		if err != nil {
+			return err
+		}
+		if l.UserID != userID {
+			return &model.ForbiddenError{
+				UserID: userID,
+				ListID: listID,
+				Action: "delete",
+			}
+		}
+		l.UnderDeletion = true
+		return putList(tx, &l)
+	})
+	if err != nil {
+		return err
+	}
+
+	//
+	// Then delete the list, its guests and its items
+	//
+	if err := slowdown(ctx, boltSession, method, "before deleting list"); err != nil {
+		return err
+	}
+	return boltSession.db.Update(func(tx *bbolt.Tx) error {
+		l, err := getList(tx, listID)
+		if err != nil {
+			// A concurrent call has completed the deletion
+			return err
+		}
+		if l.UserID != userID || !l.UnderDeletion {
+			return &model.NotFoundError{
+				Entity: model.EntityList,
+				Key:    listID,
+			}
+		}
+		// Deleting entries while iterating over them skips some, so
+		// the guests are gathered first
+		guests := make([]model.Guest, 0)
+		_, _, err = scan(guestsTable.partition(tx, listID), "", -1, func(k []byte, v []byte) error {
+			guests = append(guests, model.Guest{ListID: listID, UserID: string(k)})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for i := range guests {
+			if err := deleteGuest(tx, &guests[i]); err != nil {
+				return err
+			}
+		}
+		if itemsTable.partition(tx, listID) != nil {
+			if err := tx.Bucket(itemsTable.name).DeleteBucket([]byte(listID)); err != nil {
+				return err
+			}
+		}
+		return deleteList(tx, &l)
+	})
+}
+
+// GetListByListID is a method
+func (boltSession *Session) GetListByListID(ctx context.Context, listID string) (model.List, error) {
+	if err := slowdown(ctx, boltSession, "GetListByListID", "entry"); err != nil {
+		return model.List{}, err
+	}
+	var l listRecord
+	err := boltSession.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		l, err = getList(tx, listID)
+		return err
+	})
+	if err != nil {
+		return model.List{}, err
+	}
+	return l.List, nil
+}
+
+// GetAggregateGuestsByListID is a method
+func (boltSession *Session) GetAggregateGuestsByListID(ctx context.Context, listID string) ([]model.AggregateGuest, error) {
+	if err := slowdown(ctx, boltSession, "GetAggregateGuestsByListID", "entry"); err != nil {
+		return []model.AggregateGuest{}, err
+	}
+	var aggregateGuests []model.AggregateGuest
+	err := boltSession.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		aggregateGuests, _, _, err = aggregateGuestsPage(tx, listID, "", -1)
+		return err
+	})
+	if err != nil {
+		return []model.AggregateGuest{}, err
+	}
+	return aggregateGuests, nil
+}
+
+// GetAggregateGuestsByListIDPage is a method
+func (boltSession *Session) GetAggregateGuestsByListIDPage(ctx context.Context, listID string, cursor model.Cursor, max int64) ([]model.AggregateGuest, model.Cursor, error) {
+	if err := slowdown(ctx, boltSession, "GetAggregateGuestsByListIDPage", "entry"); err != nil {
+		return nil, "", err
+	}
+	after, err := page(cursor, max)
+	if err != nil {
+		return nil, "", err
+	}
+	var aggregateGuests []model.AggregateGuest
+	var last string
+	var more bool
+	err = boltSession.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		aggregateGuests, last, more, err = aggregateGuestsPage(tx, listID, after, max)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return aggregateGuests, next(last, more), nil
+}
+
+// aggregateGuestsPage reads up to max guests of the list, or all of
+// them if max is negative, whose user IDs follow after, then the email
+// of each from the users table
+func aggregateGuestsPage(tx *bbolt.Tx, listID string, after string, max int64) ([]model.AggregateGuest, string, bool, error) {
+	aggregateGuests := make([]model.AggregateGuest, 0)
+	last, more, err := scan(guestsTable.partition(tx, listID), after, max, func(k []byte, v []byte) error {
+		var g model.AggregateGuest
+		if err := json.Unmarshal(v, &g.Guest); err != nil {
+			return err
+		}
+		var u model.User
+		if _, err := usersTable.get(tx, "", g.UserID, &u); err != nil {
+			return err
+		}
+		g.Email = u.Email
+		aggregateGuests = append(aggregateGuests, g)
+		return nil
+	})
+	return aggregateGuests, last, more, err
+}
+
+// GetGuestsByListID is a method
+func (boltSession *Session) GetGuestsByListID(ctx context.Context, listID string) ([]model.Guest, error) {
+	if err := slowdown(ctx, boltSession, "GetGuestsByListID", "entry"); err != nil {
+		return []model.Guest{}, err
+	}
+	return boltSession.guests(guestsTable, listID)
+}
+
+// GetGuestsByUserID is a method
+//
+// It queries guests_by_user_id.
+func (boltSession *Session) GetGuestsByUserID(ctx context.Context, userID string) ([]model.Guest, error) {
+	if err := slowdown(ctx, boltSession, "GetGuestsByUserID", "entry"); err != nil {
+		return []model.Guest{}, err
+	}
+	return boltSession.guests(guestsByUserIDIndex, userID)
+}
+
+// guests reads a partition of the guests table or of its index
+func (boltSession *Session) guests(t table, partition string) ([]model.Guest, error) {
+	guests := make([]model.Guest, 0)
+	err := boltSession.db.View(func(tx *bbolt.Tx) error {
+		_, _, err := scan(t.partition(tx, partition), "", -1, func(k []byte, v []byte) error {
+			var g model.Guest
+			if err := json.Unmarshal(v, &g); err != nil {
+				return err
+			}
+			guests = append(guests, g)
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return []model.Guest{}, err
+	}
+	return guests, nil
+}
+
+// CreateGuest is a method
+func (boltSession *Session) CreateGuest(ctx context.Context, listID string, userID string) error {
+	if err := slowdown(ctx, boltSession, "CreateGuest", "entry"); err != nil {
+		return err
+	}
+	return boltSession.db.Update(func(tx *bbolt.Tx) error {
+		l, err := getWritableList(tx, listID)
+		if err != nil {
+			return err
+		}
+		found, err := usersTable.get(tx, "", userID, &model.User{})
+		if err != nil {
+			return err
+		}
+		if !found {
+			return &model.NotFoundError{
+				Entity: model.EntityUser,
+				Key:    userID,
+			}
+		}
+		found, err = guestsTable.get(tx, listID, userID, &model.Guest{})
+		if err != nil {
+			return err
+		}
+		if found {
+			return &model.AlreadyExistsError{
+				Entity: model.EntityGuest,
+				ListID: listID,
+				Key:    userID,
+			}
+		}
+		if err := putGuest(tx, &model.Guest{
+			ListID: listID,
+			UserID: userID,
+		}); err != nil {
+			return err
+		}
+		l.GuestCount++
+		return putList(tx, &l)
+	})
+}
+
+// DeleteGuest is a method
+func (boltSession *Session) DeleteGuest(ctx context.Context, listID string, userID string) error {
+	if err := slowdown(ctx, boltSession, "DeleteGuest", "entry"); err != nil {
+		return err
+	}
+	return boltSession.db.Update(func(tx *bbolt.Tx) error {
+		var g model.Guest
+		found, err := guestsTable.get(tx, listID, userID, &g)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return &model.NotFoundError{
+				Entity: model.EntityGuest,
+				ListID: listID,
+				Key:    userID,
+			}
+		}
+		if err := deleteGuest(tx, &g); err != nil {
+			return err
+		}
+		l, err := getList(tx, listID)
+		if err != nil {
+			return err
+		}
+		l.GuestCount--
+		return putList(tx, &l)
+	})
+}
+
+// IsPresentGuest is a method
+func (boltSession *Session) IsPresentGuest(ctx context.Context, listID string, userID string) (bool, error) {
+	if err := slowdown(ctx, boltSession, "IsPresentGuest", "entry"); err != nil {
+		return false, err
+	}
+	found := false
+	err := boltSession.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		found, err = guestsTable.get(tx, listID, userID, &model.Guest{})
+		return err
+	})
+	return found, err
+}
+
+// GetItemsByListID is a method
+func (boltSession *Session) GetItemsByListID(ctx context.Context, listID string) ([]model.Item, error) {
+	if err := slowdown(ctx, boltSession, "GetItemsByListID", "entry"); err != nil {
+		return []model.Item{}, err
+	}
+	var items []model.Item
+	err := boltSession.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		items, err = itemsByListID(tx, listID)
+		return err
+	})
+	if err != nil {
+		return []model.Item{}, err
+	}
+	return items, nil
+}
+
+// GetItemsByListIDPage is a method
+func (boltSession *Session) GetItemsByListIDPage(ctx context.Context, listID string, cursor model.Cursor, max int64) ([]model.Item, model.Cursor, error) {
+	if err := slowdown(ctx, boltSession, "GetItemsByListIDPage", "entry"); err != nil {
+		return nil, "", err
+	}
+	var items []model.Item
+	err := boltSession.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		items, err = itemsByListID(tx, listID)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return model.PageItems(items, cursor, max)
+}
+
+// GetItem is a method
+func (boltSession *Session) GetItem(ctx context.Context, listID string, datetime string) (model.Item, error) {
+	if err := slowdown(ctx, boltSession, "GetItem", "entry"); err != nil {
+		return model.Item{}, err
+	}
+	var item model.Item
+	found := false
+	err := boltSession.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		found, err = itemsTable.get(tx, listID, datetime, &item)
+		return err
+	})
+	if err != nil {
+		return model.Item{}, err
+	}
+	if !found {
+		return model.Item{}, &model.NotFoundError{
+			Entity: model.EntityItem,
+			ListID: listID,
+			Key:    datetime,
+		}
+	}
+	return item, nil
+}
+
+// itemsByListID reads the items of the list, sorted with SortItems. The
+// items_by_order index of the DynamoDB backend has no bucket of its own:
+// pages are cut from the whole list, which MoveItem needs anyway.
+func itemsByListID(tx *bbolt.Tx, listID string) ([]model.Item, error) {
+	items := make([]model.Item, 0)
+	_, _, err := scan(itemsTable.partition(tx, listID), "", -1, func(k []byte, v []byte) error {
+		var item model.Item
+		if err := json.Unmarshal(v, &item); err != nil {
+			return err
+		}
+		items = append(items, item)
+		return nil
+	})
+	model.SortItems(items)
+	return items, err
+}
+
+// CreateItem is a method
+func (boltSession *Session) CreateItem(ctx context.Context, listID string, description string) (string, error) {
+	if err := slowdown(ctx, boltSession, "CreateItem", "entry"); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	datetime := now.Format("2006-01-02T15:04:05.999999")
+
+	err := boltSession.db.Update(func(tx *bbolt.Tx) error {
+		l, err := getWritableList(tx, listID)
+		if err != nil {
+			return err
+		}
+		found, err := itemsTable.get(tx, listID, datetime, &model.Item{})
+		if err != nil {
+			return err
+		}
+		if found {
+			return &model.AlreadyExistsError{
+				Entity: model.EntityItem,
+				ListID: listID,
+				Key:    datetime,
+			}
+		}
+		if err := itemsTable.put(tx, listID, datetime, &model.Item{
+			ListID:      listID,
+			Datetime:    datetime,
+			Description: description,
+			Done:        false,
+			Order:       model.NewItemOrder(now),
+		}); err != nil {
+			return err
+		}
+		l.ItemCount++
+		return putList(tx, &l)
+	})
+	if err != nil {
+		return "", err
+	}
+	return datetime, nil
+}
+
+// DeleteItem is a method
+func (boltSession *Session) DeleteItem(ctx context.Context, listID string, datetime string) error {
+	if err := slowdown(ctx, boltSession, "DeleteItem", "entry"); err != nil {
+		return err
+	}
+	return boltSession.db.Update(func(tx *bbolt.Tx) error {
+		found, err := itemsTable.get(tx, listID, datetime, &model.Item{})
+		if err != nil {
+			return err
+		}
+		if !found {
+			return &model.NotFoundError{
+				Entity: model.EntityItem,
+				ListID: listID,
+				Key:    datetime,
+			}
+		}
+		if err := itemsTable.remove(tx, listID, datetime); err != nil {
+			return err
+		}
+		l, err := getList(tx, listID)
+		if err != nil {
+			return err
+		}
+		l.ItemCount--
+		return putList(tx, &l)
+	})
+}
+
+// getItem reads an item, failing unless it exists at the given version
+func getItem(tx *bbolt.Tx, listID string, datetime string, version int) (model.Item, error) {
+	var item model.Item
+	found, err := itemsTable.get(tx, listID, datetime, &item)
+	if err != nil {
+		return item, err
+	}
+	if !found {
+		return item, &model.NotFoundError{
+			Entity: model.EntityItem,
+			ListID: listID,
+			Key:    datetime,
+		}
+	}
+	if item.Version != version {
+		return item, &model.VersionConflictError{
+			ListID:   listID,
+			Datetime: datetime,
+			Version:  version,
+			Current:  item.Version,
+		}
+	}
+	return item, nil
+}
+
+// UpdateItem is a method
+func (boltSession *Session) UpdateItem(ctx context.Context, listID string, datetime string, version int, description *string, done *bool) (int, error) {
+	if err := slowdown(ctx, boltSession, "UpdateItem", "entry"); err != nil {
+		return 0, err
+	}
+	err := boltSession.db.Update(func(tx *bbolt.Tx) error {
+		item, err := getItem(tx, listID, datetime, version)
+		if err != nil {
+			return err
+		}
+		item.Version++
+		if description != nil {
+			item.Description = *description
+		}
+		if done != nil {
+			item.Done = *done
+		}
+		return itemsTable.put(tx, listID, datetime, &item)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return version + 1, nil
+}
+
+// MoveItem is a method
+func (boltSession *Session) MoveItem(ctx context.Context, listID string, datetime string, version int, position int) (int, error) {
+	if err := slowdown(ctx, boltSession, "MoveItem", "entry"); err != nil {
+		return 0, err
+	}
+	err := boltSession.db.Update(func(tx *bbolt.Tx) error {
+		items, err := itemsByListID(tx, listID)
+		if err != nil {
+			return err
+		}
+		moves, err := model.PlanMove(items, listID, datetime, version, position)
+		if err != nil {
+			return err
+		}
+		for _, move := range moves {
+			item, err := getItem(tx, listID, move.Datetime, move.Version)
+			if err != nil {
+				return err
+			}
+			item.Order = move.Order
+			item.Version++
+			if err := itemsTable.put(tx, listID, move.Datetime, &item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return version + 1, nil
+}
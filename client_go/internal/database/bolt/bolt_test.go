@@ -0,0 +1,247 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model/modeltest"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// open returns a session on a file removed at the end of the test
+func open(t *testing.T) (*Session, string) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "bolt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "todo.bolt")
+	session, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { session.Close() })
+	return session, path
+}
+
+// checkIndexes fails unless every index entry is the projection of an
+// entry of its table, and the other way round, and the counters of every
+// list match its guests and items
+func checkIndexes(t *testing.T, session *Session) {
+	t.Helper()
+	// entries returns the entries of a table or index, keyed by
+	// partition and sort key
+	entries := func(tx *bbolt.Tx, tb table) map[[2]string][]byte {
+		found := make(map[[2]string][]byte)
+		tx.Bucket(tb.name).ForEach(func(k []byte, v []byte) error {
+			if !tb.partitioned {
+				found[[2]string{string(k), ""}] = v
+				return nil
+			}
+			return tx.Bucket(tb.name).Bucket(k).ForEach(func(sortKey []byte, v []byte) error {
+				found[[2]string{string(k), string(sortKey)}] = v
+				return nil
+			})
+		})
+		return found
+	}
+	// expect builds the index entry of a table entry
+	expect := func(v []byte, project func(data []byte) ([2]string, interface{})) ([2]string, []byte) {
+		key, projection := project(v)
+		data, err := json.Marshal(projection)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key, data
+	}
+	checks := []struct {
+		table, index table
+		project      func(data []byte) ([2]string, interface{})
+	}{
+		{usersTable, usersByEmailIndex, func(data []byte) ([2]string, interface{}) {
+			var u model.User
+			json.Unmarshal(data, &u)
+			return [2]string{u.Email, u.ID}, u
+		}},
+		{listsTable, listsByUserIDIndex, func(data []byte) ([2]string, interface{}) {
+			var l listRecord
+			json.Unmarshal(data, &l)
+			return [2]string{l.UserID, l.ID}, model.List{ID: l.ID, Title: l.Title, UserID: l.UserID}
+		}},
+		{guestsTable, guestsByUserIDIndex, func(data []byte) ([2]string, interface{}) {
+			var g model.Guest
+			json.Unmarshal(data, &g)
+			return [2]string{g.UserID, g.ListID}, g
+		}},
+	}
+	session.db.View(func(tx *bbolt.Tx) error {
+		for _, check := range checks {
+			expected := make(map[[2]string][]byte)
+			for _, v := range entries(tx, check.table) {
+				key, data := expect(v, check.project)
+				expected[key] = data
+			}
+			actual := entries(tx, check.index)
+			if len(actual) != len(expected) {
+				t.Fatalf("%s holds %d entries, expected %d", check.index.name, len(actual), len(expected))
+			}
+			for key, data := range expected {
+				if !bytes.Equal(actual[key], data) {
+					t.Fatalf("%s %v is %s, expected %s", check.index.name, key, actual[key], data)
+				}
+			}
+		}
+
+		guests, items := entries(tx, guestsTable), entries(tx, itemsTable)
+		for _, v := range entries(tx, listsTable) {
+			var l listRecord
+			json.Unmarshal(v, &l)
+			guestCount, itemCount := 0, 0
+			for key := range guests {
+				if key[0] == l.ID {
+					guestCount++
+				}
+			}
+			for key := range items {
+				if key[0] == l.ID {
+					itemCount++
+				}
+			}
+			if l.GuestCount != guestCount || l.ItemCount != itemCount {
+				t.Fatalf("list %s counts %d guests and %d items, expected %d and %d", l.ID, l.GuestCount, l.ItemCount, guestCount, itemCount)
+			}
+		}
+		for key := range items {
+			if _, ok := entries(tx, listsTable)[[2]string{key[0], ""}]; !ok {
+				t.Fatalf("item %v left behind", key)
+			}
+		}
+		return nil
+	})
+}
+
+func TestConformance(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T) model.Interface {
+		session, _ := open(t)
+		// Every write keeps the indexes in step with the tables
+		t.Cleanup(func() { checkIndexes(t, session) })
+		return session
+	})
+}
+
+func TestResumeDeletion(t *testing.T) {
+	session, _ := open(t)
+	modeltest.RunResumeDeletion(t, session, func(t *testing.T, listID string) {
+		err := session.db.Update(func(tx *bbolt.Tx) error {
+			l, err := getList(tx, listID)
+			if err != nil {
+				return err
+			}
+			l.UnderDeletion = true
+			return putList(tx, &l)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	checkIndexes(t, session)
+}
+
+func TestLayout(t *testing.T) {
+	ctx := context.Background()
+	session, _ := open(t)
+	ownerID := model.InitialUsers()[0].ID
+	listID, err := session.CreateList(ctx, ownerID, "Groceries")
+	if err != nil {
+		t.Fatalf("CreateList: %v", err)
+	}
+	if err := session.CreateGuest(ctx, listID, model.InitialUsers()[1].ID); err != nil {
+		t.Fatalf("CreateGuest: %v", err)
+	}
+	datetime, err := session.CreateItem(ctx, listID, "milk")
+	if err != nil {
+		t.Fatalf("CreateItem: %v", err)
+	}
+
+	// The entries are where the DynamoDB keys put them
+	session.db.View(func(tx *bbolt.Tx) error {
+		var l listRecord
+		var item model.Item
+		var guest model.Guest
+		var indexed model.List
+		for _, lookup := range []struct {
+			table     table
+			partition string
+			key       string
+			v         interface{}
+		}{
+			{listsTable, "", listID, &l},
+			{itemsTable, listID, datetime, &item},
+			{guestsByUserIDIndex, model.InitialUsers()[1].ID, listID, &guest},
+			{listsByUserIDIndex, ownerID, listID, &indexed},
+		} {
+			if found, err := lookup.table.get(tx, lookup.partition, lookup.key, lookup.v); !found || err != nil {
+				t.Fatalf("%s %s/%s not found (%v)", lookup.table.name, lookup.partition, lookup.key, err)
+			}
+		}
+		if l.GuestCount != 1 || l.ItemCount != 1 {
+			t.Fatalf("list counts %d guests and %d items, expected 1 and 1", l.GuestCount, l.ItemCount)
+		}
+		if item.Description != "milk" {
+			t.Fatalf("item is %+v", item)
+		}
+		return nil
+	})
+
+	// Partitions left empty go away
+	if err := session.DeleteList(ctx, listID, ownerID); err != nil {
+		t.Fatalf("DeleteList: %v", err)
+	}
+	session.db.View(func(tx *bbolt.Tx) error {
+		for _, tb := range []table{guestsTable, itemsTable, guestsByUserIDIndex, listsByUserIDIndex} {
+			if k, _ := tx.Bucket(tb.name).Cursor().First(); k != nil {
+				t.Fatalf("%s still holds partition %s", tb.name, k)
+			}
+		}
+		return nil
+	})
+}
+
+func TestReopen(t *testing.T) {
+	ctx := context.Background()
+	session, path := open(t)
+	listID, err := session.CreateList(ctx, model.InitialUsers()[0].ID, "Kept")
+	if err != nil {
+		t.Fatalf("CreateList: %v", err)
+	}
+	if err := session.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// The initial users are only added to an empty file
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer reopened.Close()
+	users, _, err := reopened.ListUsers(ctx, "", 10)
+	if err != nil || !reflect.DeepEqual(users, model.InitialUsers()) {
+		t.Fatalf("ListUsers = %v (%v), expected %v", users, err, model.InitialUsers())
+	}
+	if l, err := reopened.GetListByListID(ctx, listID); err != nil || l.Title != "Kept" {
+		t.Fatalf("GetListByListID = %+v (%v), expected the list created before reopening", l, err)
+	}
+
+	// Only one session may use the file at a time
+	if _, err := Open(path); err == nil {
+		t.Fatal("Open: expected the file to be in use")
+	}
+}
@@ -0,0 +1,212 @@
+package bolt
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// The data is laid out as in the tables and global secondary indexes of
+// main.tf, whose names the buckets take. A table with a sort key holds a
+// nested bucket per partition key, in which entries are keyed by sort
+// key; the others hold their entries directly, keyed by partition key.
+// Entries are the JSON of the DynamoDB items, using the same attribute
+// names.
+//
+//	users              id                     -> model.User
+//	lists              id                     -> listRecord
+//	guests             list_id / user_id      -> model.Guest
+//	items              list_id / datetime     -> model.Item
+//
+// An index holds a copy of the attributes it projects, in a nested
+// bucket per index partition key. Its entries are keyed by the index's
+// sort key, if any, or else by the table's key, so that several items
+// may share an index key, as in DynamoDB.
+//
+//	users_by_email     email / id             -> id, email
+//	lists_by_user_id   user_id / id           -> id, title, user_id
+//	guests_by_user_id  user_id / list_id      -> list_id, user_id
+var (
+	usersTable  = table{name: []byte("users")}
+	listsTable  = table{name: []byte("lists")}
+	guestsTable = table{name: []byte("guests"), partitioned: true}
+	itemsTable  = table{name: []byte("items"), partitioned: true}
+
+	usersByEmailIndex   = table{name: []byte("users_by_email"), partitioned: true}
+	listsByUserIDIndex  = table{name: []byte("lists_by_user_id"), partitioned: true}
+	guestsByUserIDIndex = table{name: []byte("guests_by_user_id"), partitioned: true}
+)
+
+// tables are those created by Open
+var tables = []table{
+	usersTable,
+	listsTable,
+	guestsTable,
+	itemsTable,
+	usersByEmailIndex,
+	listsByUserIDIndex,
+	guestsByUserIDIndex,
+}
+
+// table is the bucket of a table or index. Entries of a partitioned one
+// are found by partition key and sort key, others by partition key
+// alone, which is passed as their key with an empty partition.
+type table struct {
+	name        []byte
+	partitioned bool
+}
+
+// openTimeout is how long Open waits for another process to release the
+// file
+const openTimeout = time.Second
+
+// listRecord is a list as kept in the lists table, with the counters
+// the DynamoDB backend keeps alongside (see counters.go there)
+type listRecord struct {
+	model.List
+	GuestCount int `json:"guest_count"`
+	ItemCount  int `json:"item_count"`
+}
+
+// Open returns a Session whose data is kept in the bbolt file at path,
+// which is created if need be, along with the buckets. A new file starts
+// with model.InitialUsers. Only one process may open the file at a
+// time.
+func Open(path string) (*Session, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("bolt: opening %s: %v", path, err)
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, t := range tables {
+			if _, err := tx.CreateBucketIfNotExists(t.name); err != nil {
+				return err
+			}
+		}
+		if k, _ := tx.Bucket(usersTable.name).Cursor().First(); k != nil {
+			return nil
+		}
+		log.Printf("Bolt backend: starting afresh in %s", path)
+		users := model.InitialUsers()
+		for i := range users {
+			if err := putUser(tx, &users[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("bolt: preparing %s: %v", path, err)
+	}
+	return &Session{db: db}, nil
+}
+
+// Close releases the file. Every change is committed by the call that
+// makes it, so not calling Close loses nothing.
+func (boltSession *Session) Close() error {
+	return boltSession.db.Close()
+}
+
+// get decodes into v the entry at key, and reports whether there is one
+func (t table) get(tx *bbolt.Tx, partition string, key string, v interface{}) (bool, error) {
+	bucket := t.partition(tx, partition)
+	if bucket == nil {
+		return false, nil
+	}
+	data := bucket.Get([]byte(key))
+	if data == nil {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+// put writes v as the entry at key
+func (t table) put(tx *bbolt.Tx, partition string, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	bucket := tx.Bucket(t.name)
+	if t.partitioned {
+		if bucket, err = bucket.CreateBucketIfNotExists([]byte(partition)); err != nil {
+			return err
+		}
+	}
+	return bucket.Put([]byte(key), data)
+}
+
+// remove deletes the entry at key. A partition left empty is deleted
+// too, as DynamoDB has no such thing.
+func (t table) remove(tx *bbolt.Tx, partition string, key string) error {
+	bucket := t.partition(tx, partition)
+	if bucket == nil {
+		return nil
+	}
+	if err := bucket.Delete([]byte(key)); err != nil {
+		return err
+	}
+	if k, _ := bucket.Cursor().First(); t.partitioned && k == nil {
+		return tx.Bucket(t.name).DeleteBucket([]byte(partition))
+	}
+	return nil
+}
+
+// partition returns the bucket holding the entries of partition, if
+// any, or the table's bucket if it is not partitioned
+func (t table) partition(tx *bbolt.Tx, partition string) *bbolt.Bucket {
+	bucket := tx.Bucket(t.name)
+	if !t.partitioned {
+		return bucket
+	}
+	return bucket.Bucket([]byte(partition))
+}
+
+// putUser writes a user and its index entry
+func putUser(tx *bbolt.Tx, u *model.User) error {
+	if err := usersTable.put(tx, "", u.ID, u); err != nil {
+		return err
+	}
+	return usersByEmailIndex.put(tx, u.Email, u.ID, u)
+}
+
+// putList writes a list and its index entry, which leaves out
+// UnderDeletion as lists_by_user_id does
+func putList(tx *bbolt.Tx, l *listRecord) error {
+	if err := listsTable.put(tx, "", l.ID, l); err != nil {
+		return err
+	}
+	return listsByUserIDIndex.put(tx, l.UserID, l.ID, model.List{
+		ID:     l.ID,
+		Title:  l.Title,
+		UserID: l.UserID,
+	})
+}
+
+// deleteList deletes a list and its index entry
+func deleteList(tx *bbolt.Tx, l *listRecord) error {
+	if err := listsTable.remove(tx, "", l.ID); err != nil {
+		return err
+	}
+	return listsByUserIDIndex.remove(tx, l.UserID, l.ID)
+}
+
+// putGuest writes a guest and its index entry
+func putGuest(tx *bbolt.Tx, g *model.Guest) error {
+	if err := guestsTable.put(tx, g.ListID, g.UserID, g); err != nil {
+		return err
+	}
+	return guestsByUserIDIndex.put(tx, g.UserID, g.ListID, g)
+}
+
+// deleteGuest deletes a guest and its index entry
+func deleteGuest(tx *bbolt.Tx, g *model.Guest) error {
+	if err := guestsTable.remove(tx, g.ListID, g.UserID); err != nil {
+		return err
+	}
+	return guestsByUserIDIndex.remove(tx, g.UserID, g.ListID)
+}
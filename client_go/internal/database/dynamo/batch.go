@@ -0,0 +1,195 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+)
+
+// Service limits for a single batch request
+const (
+	maxBatchWriteRequests = 25
+	maxBatchGetKeys       = 100
+)
+
+// Retries of unprocessed entries, with exponential backoff. The delays
+// are variables so that tests need not wait for them.
+const maxBatchAttempts = 8
+
+var (
+	batchBaseDelay = 50 * time.Millisecond
+	batchMaxDelay  = 5 * time.Second
+)
+
+// BatchError is returned when a batch could only be partially applied
+// or retrieved. The unprocessed entries may be resubmitted as they are.
+type BatchError struct {
+	Operation         string
+	UnprocessedWrites map[string][]*dynamodb.WriteRequest
+	UnprocessedKeys   map[string][]map[string]*dynamodb.AttributeValue
+	Err               error
+}
+
+func (e *BatchError) Error() string {
+	unprocessed := 0
+	for _, v := range e.UnprocessedWrites {
+		unprocessed += len(v)
+	}
+	for _, v := range e.UnprocessedKeys {
+		unprocessed += len(v)
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %d unprocessed entries (%v)", e.Operation, unprocessed, e.Err)
+	}
+	return fmt.Sprintf("%s: %d unprocessed entries after %d attempts", e.Operation, unprocessed, maxBatchAttempts)
+}
+
+// Unwrap returns the error that interrupted the batch, if any
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches model.ErrThrottled when entries were left unprocessed
+// after every attempt
+func (e *BatchError) Is(target error) bool {
+	return e.Err == nil && target == model.ErrThrottled
+}
+
+// batchBackoff sleeps before the given retry attempt (1 onwards) and
+// returns early with ctx's error if it is done in the meantime
+func batchBackoff(ctx context.Context, attempt int) error {
+	select {
+	case <-time.After(backoffDelay(batchBaseDelay, batchMaxDelay, attempt)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type tableWriteRequest struct {
+	table   string
+	request *dynamodb.WriteRequest
+}
+
+// batchWrite applies every request, spanning as many BatchWriteItem calls
+// as needed and retrying unprocessed items. A call rejected as a whole has
+// already been retried by the session's retryer, so it ends the batch.
+func (session *connection) batchWrite(ctx context.Context, method string, requests map[string][]*dynamodb.WriteRequest) error {
+	pending := make([]tableWriteRequest, 0)
+	for table, tableRequests := range requests {
+		for _, request := range tableRequests {
+			pending = append(pending, tableWriteRequest{table: table, request: request})
+		}
+	}
+	remaining := func(from int, unprocessed map[string][]*dynamodb.WriteRequest) map[string][]*dynamodb.WriteRequest {
+		if unprocessed == nil {
+			unprocessed = make(map[string][]*dynamodb.WriteRequest)
+		}
+		for _, r := range pending[from:] {
+			unprocessed[r.table] = append(unprocessed[r.table], r.request)
+		}
+		return unprocessed
+	}
+
+	for start := 0; start < len(pending); start += maxBatchWriteRequests {
+		end := start + maxBatchWriteRequests
+		if end > len(pending) {
+			end = len(pending)
+		}
+		chunk := make(map[string][]*dynamodb.WriteRequest)
+		for _, r := range pending[start:end] {
+			chunk[r.table] = append(chunk[r.table], r.request)
+		}
+		for attempt := 0; len(chunk) > 0; attempt++ {
+			if attempt > 0 {
+				if attempt == maxBatchAttempts {
+					return &BatchError{Operation: "BatchWriteItem", UnprocessedWrites: remaining(end, chunk)}
+				}
+				log.Printf("%s BatchWriteItem retry #%d (%d tables pending)", method, attempt, len(chunk))
+				if err := batchBackoff(ctx, attempt); err != nil {
+					return &BatchError{Operation: "BatchWriteItem", UnprocessedWrites: remaining(end, chunk), Err: translateError(method, err)}
+				}
+			}
+			output, err := session.DynamoDBresource.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{
+				ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+				RequestItems:           chunk,
+			})
+			if err != nil {
+				return &BatchError{Operation: "BatchWriteItem", UnprocessedWrites: remaining(end, chunk), Err: translateError(method, err)}
+			}
+			for i, v := range output.ConsumedCapacity {
+				logConsumedCapacity(fmt.Sprintf("%s #%d", method, i), v)
+			}
+			chunk = output.UnprocessedItems
+		}
+	}
+	return nil
+}
+
+// batchGet retrieves every key from table, spanning as many BatchGetItem
+// calls as needed and retrying unprocessed keys. Keys must be unique.
+func (session *connection) batchGet(ctx context.Context, method string, table string, keys []map[string]*dynamodb.AttributeValue) ([]map[string]*dynamodb.AttributeValue, error) {
+	results := make([]map[string]*dynamodb.AttributeValue, 0, len(keys))
+	remaining := func(from int, unprocessed []map[string]*dynamodb.AttributeValue) map[string][]map[string]*dynamodb.AttributeValue {
+		return map[string][]map[string]*dynamodb.AttributeValue{
+			table: append(append([]map[string]*dynamodb.AttributeValue{}, unprocessed...), keys[from:]...),
+		}
+	}
+
+	for start := 0; start < len(keys); start += maxBatchGetKeys {
+		end := start + maxBatchGetKeys
+		if end > len(keys) {
+			end = len(keys)
+		}
+		chunk := keys[start:end]
+		for attempt := 0; len(chunk) > 0; attempt++ {
+			if attempt > 0 {
+				if attempt == maxBatchAttempts {
+					return nil, &BatchError{Operation: "BatchGetItem", UnprocessedKeys: remaining(end, chunk)}
+				}
+				log.Printf("%s BatchGetItem retry #%d (%d keys pending)", method, attempt, len(chunk))
+				if err := batchBackoff(ctx, attempt); err != nil {
+					return nil, &BatchError{Operation: "BatchGetItem", UnprocessedKeys: remaining(end, chunk), Err: translateError(method, err)}
+				}
+			}
+			output, err := session.DynamoDBresource.BatchGetItemWithContext(ctx, &dynamodb.BatchGetItemInput{
+				RequestItems: map[string]*dynamodb.KeysAndAttributes{
+					table: {
+						Keys: chunk,
+					},
+				},
+				ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+			})
+			if err != nil {
+				return nil, &BatchError{Operation: "BatchGetItem", UnprocessedKeys: remaining(end, chunk), Err: translateError(method, err)}
+			}
+			for i, v := range output.ConsumedCapacity {
+				logConsumedCapacity(fmt.Sprintf("%s #%d", method, i), v)
+			}
+			results = append(results, output.Responses[table]...)
+			chunk = nil
+			if unprocessed, ok := output.UnprocessedKeys[table]; ok {
+				chunk = unprocessed.Keys
+			}
+		}
+	}
+	return results, nil
+}
+
+// uniqueStrings drops duplicates, which BatchGetItem rejects
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
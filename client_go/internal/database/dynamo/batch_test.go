@@ -0,0 +1,271 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+)
+
+// stingyBatches processes the batches it is given but for the last entry
+// of each table, which it hands back as unprocessed for its first
+// stingyCalls calls, or for every call if stingyCalls is negative.
+// Entries are keyed by their "id" attribute.
+type stingyBatches struct {
+	dynamodbiface.DynamoDBAPI
+	stingyCalls int
+	calls       int
+	written     map[string][]string
+	read        map[string][]string
+	// cancel, if set, is called along with the first call
+	cancel func()
+	// err, if set, rejects every call
+	err error
+}
+
+func (stub *stingyBatches) stingy() bool {
+	stub.calls++
+	if stub.cancel != nil && stub.calls == 1 {
+		stub.cancel()
+	}
+	return stub.stingyCalls < 0 || stub.calls <= stub.stingyCalls
+}
+
+func (stub *stingyBatches) BatchWriteItemWithContext(ctx aws.Context, input *dynamodb.BatchWriteItemInput, _ ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	stingy := stub.stingy()
+	if stub.err != nil {
+		return nil, stub.err
+	}
+	output := &dynamodb.BatchWriteItemOutput{UnprocessedItems: make(map[string][]*dynamodb.WriteRequest)}
+	for table, requests := range input.RequestItems {
+		if stingy {
+			output.UnprocessedItems[table] = requests[len(requests)-1:]
+			requests = requests[:len(requests)-1]
+		}
+		for _, r := range requests {
+			stub.written[table] = append(stub.written[table], aws.StringValue(r.PutRequest.Item["id"].S))
+		}
+	}
+	return output, nil
+}
+
+func (stub *stingyBatches) BatchGetItemWithContext(ctx aws.Context, input *dynamodb.BatchGetItemInput, _ ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	stingy := stub.stingy()
+	if stub.err != nil {
+		return nil, stub.err
+	}
+	output := &dynamodb.BatchGetItemOutput{
+		Responses:       make(map[string][]map[string]*dynamodb.AttributeValue),
+		UnprocessedKeys: make(map[string]*dynamodb.KeysAndAttributes),
+	}
+	for table, keysAndAttributes := range input.RequestItems {
+		keys := keysAndAttributes.Keys
+		if stingy {
+			output.UnprocessedKeys[table] = &dynamodb.KeysAndAttributes{Keys: keys[len(keys)-1:]}
+			keys = keys[:len(keys)-1]
+		}
+		for _, key := range keys {
+			stub.read[table] = append(stub.read[table], aws.StringValue(key["id"].S))
+			output.Responses[table] = append(output.Responses[table], key)
+		}
+	}
+	return output, nil
+}
+
+// newStingySession returns a session on stub with the batch delays cut
+// down to a millisecond
+func newStingySession(t *testing.T, stub *stingyBatches) *connection {
+	t.Helper()
+	base, max := batchBaseDelay, batchMaxDelay
+	batchBaseDelay, batchMaxDelay = time.Millisecond, time.Millisecond
+	t.Cleanup(func() { batchBaseDelay, batchMaxDelay = base, max })
+	stub.written = make(map[string][]string)
+	stub.read = make(map[string][]string)
+	return &connection{DynamoDBresource: stub}
+}
+
+func batchIDs(prefix string, n int) []string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("%s%02d", prefix, i)
+	}
+	return ids
+}
+
+func idsOf(entries []map[string]*dynamodb.AttributeValue) []string {
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = aws.StringValue(entry["id"].S)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestBatchWrite(t *testing.T) {
+	// 30 puts to two tables take two calls, the first one of 25 requests
+	users, lists := batchIDs("user", 20), batchIDs("list", 10)
+	requests := func() map[string][]*dynamodb.WriteRequest {
+		r := make(map[string][]*dynamodb.WriteRequest)
+		for table, ids := range map[string][]string{"users": users, "lists": lists} {
+			for _, id := range ids {
+				r[table] = append(r[table], &dynamodb.WriteRequest{
+					PutRequest: &dynamodb.PutRequest{Item: map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}}},
+				})
+			}
+		}
+		return r
+	}
+
+	t.Run("Recovers", func(t *testing.T) {
+		stub := &stingyBatches{stingyCalls: 3}
+		session := newStingySession(t, stub)
+		if err := session.batchWrite(context.Background(), "test", requests()); err != nil {
+			t.Fatalf("batchWrite: %v", err)
+		}
+		if !sameStrings(stub.written["users"], users) || !sameStrings(stub.written["lists"], lists) {
+			t.Fatalf("wrote %v, expected %v and %v", stub.written, users, lists)
+		}
+		// The first chunk takes 3 stingy calls and a last one, and the
+		// second chunk a single call
+		if stub.calls != 5 {
+			t.Fatalf("made %d calls, expected 5", stub.calls)
+		}
+	})
+
+	t.Run("GivesUp", func(t *testing.T) {
+		stub := &stingyBatches{stingyCalls: -1}
+		session := newStingySession(t, stub)
+		err := session.batchWrite(context.Background(), "test", requests())
+		var batchErr *BatchError
+		if !errors.As(err, &batchErr) || !errors.Is(err, model.ErrThrottled) {
+			t.Fatalf("expected a BatchError matching ErrThrottled, got %v", err)
+		}
+		if stub.calls != maxBatchAttempts {
+			t.Fatalf("made %d calls, expected %d", stub.calls, maxBatchAttempts)
+		}
+		// Whatever was not written is reported, and nothing else
+		written := make(map[string]bool)
+		for _, ids := range stub.written {
+			for _, id := range ids {
+				written[id] = true
+			}
+		}
+		for table, ids := range map[string][]string{"users": users, "lists": lists} {
+			var left []string
+			for _, id := range ids {
+				if !written[id] {
+					left = append(left, id)
+				}
+			}
+			var unprocessed []string
+			for _, r := range batchErr.UnprocessedWrites[table] {
+				unprocessed = append(unprocessed, aws.StringValue(r.PutRequest.Item["id"].S))
+			}
+			if !sameStrings(unprocessed, left) {
+				t.Fatalf("%s: reported %v as unprocessed, expected %v", table, unprocessed, left)
+			}
+		}
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		// The retryer has already spent its attempts on the call
+		stub := &stingyBatches{err: awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "slow down", nil)}
+		session := newStingySession(t, stub)
+		err := session.batchWrite(context.Background(), "test", requests())
+		var batchErr *BatchError
+		if !errors.As(err, &batchErr) || !errors.Is(err, model.ErrThrottled) {
+			t.Fatalf("expected a BatchError matching ErrThrottled, got %v", err)
+		}
+		if stub.calls != 1 {
+			t.Fatalf("made %d calls, expected the batch to stop at the first", stub.calls)
+		}
+		unprocessed := 0
+		for _, r := range batchErr.UnprocessedWrites {
+			unprocessed += len(r)
+		}
+		if unprocessed != len(users)+len(lists) {
+			t.Fatalf("reported %d unprocessed writes, expected %d", unprocessed, len(users)+len(lists))
+		}
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		stub := &stingyBatches{stingyCalls: -1, cancel: cancel}
+		session := newStingySession(t, stub)
+		err := session.batchWrite(ctx, "test", requests())
+		var batchErr *BatchError
+		if !errors.As(err, &batchErr) || !errors.Is(err, context.Canceled) || errors.Is(err, model.ErrThrottled) {
+			t.Fatalf("expected a BatchError wrapping context.Canceled, got %v", err)
+		}
+		if stub.calls != 1 {
+			t.Fatalf("made %d calls, expected the backoff to stop at the first", stub.calls)
+		}
+		unprocessed := 0
+		for _, r := range batchErr.UnprocessedWrites {
+			unprocessed += len(r)
+		}
+		if expected := len(users) + len(lists) - len(stub.written["users"]) - len(stub.written["lists"]); unprocessed != expected {
+			t.Fatalf("reported %d unprocessed writes, expected %d", unprocessed, expected)
+		}
+	})
+}
+
+func TestBatchGet(t *testing.T) {
+	// 150 keys take two calls, the first one of 100 keys
+	ids := batchIDs("list", 150)
+	keys := make([]map[string]*dynamodb.AttributeValue, len(ids))
+	for i, id := range ids {
+		keys[i] = map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}}
+	}
+
+	t.Run("Recovers", func(t *testing.T) {
+		stub := &stingyBatches{stingyCalls: 3}
+		session := newStingySession(t, stub)
+		results, err := session.batchGet(context.Background(), "test", "lists", keys)
+		if err != nil {
+			t.Fatalf("batchGet: %v", err)
+		}
+		if !sameStrings(idsOf(results), ids) {
+			t.Fatalf("read %v, expected %v", idsOf(results), ids)
+		}
+		if stub.calls != 5 {
+			t.Fatalf("made %d calls, expected 5", stub.calls)
+		}
+	})
+
+	t.Run("GivesUp", func(t *testing.T) {
+		stub := &stingyBatches{stingyCalls: -1}
+		session := newStingySession(t, stub)
+		_, err := session.batchGet(context.Background(), "test", "lists", keys)
+		var batchErr *BatchError
+		if !errors.As(err, &batchErr) || !errors.Is(err, model.ErrThrottled) {
+			t.Fatalf("expected a BatchError matching ErrThrottled, got %v", err)
+		}
+		if stub.calls != maxBatchAttempts {
+			t.Fatalf("made %d calls, expected %d", stub.calls, maxBatchAttempts)
+		}
+		read := make(map[string]bool)
+		for _, id := range stub.read["lists"] {
+			read[id] = true
+		}
+		var left []string
+		for _, id := range ids {
+			if !read[id] {
+				left = append(left, id)
+			}
+		}
+		if unprocessed := idsOf(batchErr.UnprocessedKeys["lists"]); !sameStrings(unprocessed, left) {
+			t.Fatalf("reported %v as unprocessed, expected %v", unprocessed, left)
+		}
+	})
+}
@@ -0,0 +1,206 @@
+package dynamo
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Credentials sources
+const (
+	// CredentialsDefault uses the SDK's default chain (environment,
+	// shared files using Profile, instance role)
+	CredentialsDefault = "default"
+	// CredentialsProfile only uses the shared credentials file
+	CredentialsProfile = "profile"
+	// CredentialsEnv only uses the AWS_ACCESS_KEY_ID family of variables
+	CredentialsEnv = "env"
+	// CredentialsStatic uses AccessKeyID and SecretAccessKey verbatim,
+	// which is what emulators such as DynamoDB Local expect
+	CredentialsStatic = "static"
+)
+
+// Tables holds the logical table names, before prefix and suffix
+type Tables struct {
+	Users  string `json:"users"`
+	Lists  string `json:"lists"`
+	Guests string `json:"guests"`
+	Items  string `json:"items"`
+	Single string `json:"single"`
+}
+
+// Indexes holds the global secondary index names
+type Indexes struct {
+	UsersByEmail   string `json:"users_by_email"`
+	ListsByUserID  string `json:"lists_by_user_id"`
+	GuestsByUserID string `json:"guests_by_user_id"`
+	ItemsByOrder   string `json:"items_by_order"`
+	// GSI1 and GSI2 are the overloaded indexes of the single table
+	GSI1 string `json:"gsi1"`
+	GSI2 string `json:"gsi2"`
+}
+
+// Capacity holds the provisioned throughput of the tables and indexes
+// created by ApplySchema
+type Capacity struct {
+	Read  int64 `json:"read"`
+	Write int64 `json:"write"`
+}
+
+// Config describes where and how to reach DynamoDB
+//
+// Schema is one of SchemaVerify, SchemaApply or SchemaNone.
+type Config struct {
+	Endpoint        string      `json:"endpoint"`
+	Region          string      `json:"region"`
+	Profile         string      `json:"profile"`
+	Credentials     string      `json:"credentials"`
+	AccessKeyID     string      `json:"access_key_id"`
+	SecretAccessKey string      `json:"secret_access_key"`
+	TablePrefix     string      `json:"table_prefix"`
+	TableSuffix     string      `json:"table_suffix"`
+	Tables          Tables      `json:"tables"`
+	Indexes         Indexes     `json:"indexes"`
+	Schema          string      `json:"schema"`
+	Capacity        Capacity    `json:"capacity"`
+	Retry           RetryPolicy `json:"retry"`
+}
+
+// DefaultConfig matches the resources declared in main.tf
+func DefaultConfig() Config {
+	return Config{
+		Region:          "eu-west-2",
+		Profile:         "dynamodb_profile",
+		Credentials:     CredentialsDefault,
+		AccessKeyID:     "local",
+		SecretAccessKey: "local",
+		Tables: Tables{
+			Users:  "users",
+			Lists:  "lists",
+			Guests: "guests",
+			Items:  "items",
+			Single: "todo",
+		},
+		Indexes: Indexes{
+			UsersByEmail:   "users_by_email",
+			ListsByUserID:  "lists_by_user_id",
+			GuestsByUserID: "guests_by_user_id",
+			ItemsByOrder:   "items_by_order",
+			GSI1:           "gsi1",
+			GSI2:           "gsi2",
+		},
+		Schema: SchemaVerify,
+		Capacity: Capacity{
+			Read:  2,
+			Write: 2,
+		},
+		Retry: RetryPolicy{
+			MaxRetries:  8,
+			BaseDelayMS: 50,
+			MaxDelayMS:  5000,
+			Budget:      100,
+		},
+	}
+}
+
+// TableNames returns the physical table names, with prefix and suffix applied
+func (config Config) TableNames() Tables {
+	name := func(table string) string {
+		return config.TablePrefix + table + config.TableSuffix
+	}
+	return Tables{
+		Users:  name(config.Tables.Users),
+		Lists:  name(config.Tables.Lists),
+		Guests: name(config.Tables.Guests),
+		Items:  name(config.Tables.Items),
+		Single: name(config.Tables.Single),
+	}
+}
+
+// NewAWSSession creates the AWS session described by config
+//
+// Its clients retry requests according to config.Retry rather than the
+// SDK's default policy.
+func NewAWSSession(config Config) (*session.Session, error) {
+	retryer := newRetryer(config.Retry)
+	awsConfig := aws.Config{
+		Region:                  aws.String(config.Region),
+		EnforceShouldRetryCheck: aws.Bool(true),
+	}
+	request.WithRetryer(&awsConfig, retryer)
+	if config.Endpoint != "" {
+		awsConfig.Endpoint = aws.String(config.Endpoint)
+	}
+	switch config.Credentials {
+	case CredentialsDefault, "":
+	case CredentialsProfile:
+		awsConfig.Credentials = credentials.NewSharedCredentials("", config.Profile)
+	case CredentialsEnv:
+		awsConfig.Credentials = credentials.NewEnvCredentials()
+	case CredentialsStatic:
+		awsConfig.Credentials = credentials.NewStaticCredentials(config.AccessKeyID, config.SecretAccessKey, "")
+	default:
+		return nil, fmt.Errorf("unknown credentials source %q", config.Credentials)
+	}
+	awsSession, err := session.NewSessionWithOptions(
+		session.Options{
+			Profile: config.Profile,
+			Config:  awsConfig,
+		})
+	if err != nil {
+		return nil, err
+	}
+	awsSession.Handlers.Complete.PushBack(retryer.complete)
+	return awsSession, nil
+}
+
+// New creates a DBSession described by config
+func New(config Config) (*DBSession, error) {
+	awsSession, err := NewAWSSession(config)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithClient(dynamodb.New(awsSession), config), nil
+}
+
+// NewSingleTable creates a SingleTableSession described by config
+func NewSingleTable(config Config) (*SingleTableSession, error) {
+	awsSession, err := NewAWSSession(config)
+	if err != nil {
+		return nil, err
+	}
+	return NewSingleTableWithClient(dynamodb.New(awsSession), config), nil
+}
+
+// NewWithClient creates a DBSession on top of an existing client, which
+// may be a *dynamodb.DynamoDB or a stand-in such as dynamotest.Fake
+func NewWithClient(client dynamodbiface.DynamoDBAPI, config Config) *DBSession {
+	return &DBSession{
+		connection: connection{
+			DynamoDBresource: client,
+			schema:           Schema(config),
+			capacity:         config.Capacity,
+		},
+		tables:  config.TableNames(),
+		indexes: config.Indexes,
+	}
+}
+
+// NewSingleTableWithClient creates a SingleTableSession on top of an
+// existing client
+func NewSingleTableWithClient(client dynamodbiface.DynamoDBAPI, config Config) *SingleTableSession {
+	return &SingleTableSession{
+		connection: connection{
+			DynamoDBresource: client,
+			schema:           []TableSchema{SingleTableSchema(config)},
+			capacity:         config.Capacity,
+		},
+		table:   config.TableNames().Single,
+		indexes: config.Indexes,
+	}
+}
@@ -0,0 +1,211 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+)
+
+// Lists keep the number of their guests and items, which CreateGuest,
+// DeleteGuest, CreateItem and DeleteItem update in the same transaction
+// as the guest or item itself
+const (
+	attributeGuestCount = "guest_count"
+	attributeItemCount  = "item_count"
+)
+
+// repairAttempts bounds how many times the counters of a single list are
+// recomputed while concurrent writes keep changing them
+const repairAttempts = 5
+
+// counterUpdate adds delta to counter on the item at key, provided that
+// condition holds
+func counterUpdate(table string, key map[string]*dynamodb.AttributeValue, counter string, delta int, condition string) *dynamodb.Update {
+	return &dynamodb.Update{
+		TableName:           aws.String(table),
+		Key:                 key,
+		UpdateExpression:    aws.String("ADD #c :d"),
+		ConditionExpression: aws.String(condition),
+		ExpressionAttributeNames: map[string]*string{
+			"#c": aws.String(counter),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":d": {N: aws.String(strconv.Itoa(delta))},
+		},
+	}
+}
+
+// countFunc counts the guests and items of a list using strongly
+// consistent reads
+type countFunc func(ctx context.Context) (guests int, items int, err error)
+
+// repairList recomputes the counters of the list at key. The update is
+// conditional on the counters read beforehand so that a write landing
+// while the children are being counted makes it start over. It reports
+// whether the counters were wrong.
+func (session *connection) repairList(ctx context.Context, method string, table string, key map[string]*dynamodb.AttributeValue, keyAttribute string, count countFunc) (bool, error) {
+	for attempt := 0; attempt < repairAttempts; attempt++ {
+		output, err := session.DynamoDBresource.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+			TableName:              aws.String(table),
+			Key:                    key,
+			ConsistentRead:         aws.Bool(true),
+			ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+		})
+		if err != nil {
+			return false, translateError(method, err)
+		}
+		logConsumedCapacity(method, output.ConsumedCapacity)
+		if len(output.Item) == 0 {
+			// Deleted in the meantime
+			return false, nil
+		}
+		guests, items, err := count(ctx)
+		if err != nil {
+			return false, err
+		}
+
+		condition := "attribute_exists(#k)"
+		names := map[string]*string{
+			"#k": aws.String(keyAttribute),
+			"#g": aws.String(attributeGuestCount),
+			"#i": aws.String(attributeItemCount),
+		}
+		values := map[string]*dynamodb.AttributeValue{
+			":g": {N: aws.String(strconv.Itoa(guests))},
+			":i": {N: aws.String(strconv.Itoa(items))},
+		}
+		upToDate := true
+		for placeholder, counter := range map[string]string{"g": attributeGuestCount, "i": attributeItemCount} {
+			current, ok := output.Item[counter]
+			if !ok {
+				condition += fmt.Sprintf(" AND attribute_not_exists(#%s)", placeholder)
+				upToDate = false
+				continue
+			}
+			condition += fmt.Sprintf(" AND #%s = :old%s", placeholder, placeholder)
+			values[":old"+placeholder] = current
+			if aws.StringValue(current.N) != aws.StringValue(values[":"+placeholder].N) {
+				upToDate = false
+			}
+		}
+		if upToDate {
+			return false, nil
+		}
+
+		log.Printf("%s: setting %s=%d and %s=%d on %v", method, attributeGuestCount, guests, attributeItemCount, items, key)
+		output2, err2 := session.DynamoDBresource.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(table),
+			Key:                       key,
+			UpdateExpression:          aws.String("SET #g = :g, #i = :i"),
+			ConditionExpression:       aws.String(condition),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+			ReturnConsumedCapacity:    aws.String(dynamodb.ReturnConsumedCapacityTotal),
+		})
+		if err2 != nil {
+			if aeer, ok := err2.(awserr.Error); ok {
+				if aeer.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+					log.Printf("%s: counters of %v changed while counting, attempt #%d", method, key, attempt)
+					continue
+				}
+			}
+			return false, translateError(method, err2)
+		}
+		logConsumedCapacity(method, output2.ConsumedCapacity)
+		return true, nil
+	}
+	return false, &model.TransientError{
+		Operation: method,
+		Err:       errors.New("list kept changing while its counters were recomputed"),
+	}
+}
+
+// RepairCounters recomputes the guest and item counters of every list,
+// returning how many were wrong. Lists created before the counters were
+// introduced need it once.
+func (session *DBSession) RepairCounters(ctx context.Context) (int, error) {
+	const method = "RepairCounters"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s", method)
+
+	lists, err := session.scanAll(ctx, method, &dynamodb.ScanInput{
+		TableName:              aws.String(session.tables.Lists),
+		ProjectionExpression:   aws.String("id"),
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	})
+	if err != nil {
+		return 0, err
+	}
+	repaired := 0
+	for _, v := range lists {
+		listID := aws.StringValue(v["id"].S)
+		fixed, err := session.repairList(ctx, method, session.tables.Lists, v, "id", func(ctx context.Context) (int, int, error) {
+			guests, err := session.getGuestsByListID(ctx, listID, true)
+			if err != nil {
+				return 0, 0, err
+			}
+			items, err := session.getItemsByListID(ctx, listID, true)
+			return len(guests), len(items), err
+		})
+		if err != nil {
+			return repaired, err
+		}
+		if fixed {
+			repaired++
+		}
+	}
+	return repaired, nil
+}
+
+// RepairCounters recomputes the guest and item counters of every list,
+// returning how many were wrong
+func (session *SingleTableSession) RepairCounters(ctx context.Context) (int, error) {
+	const method = "RepairCounters"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s", method)
+
+	lists, err := session.scanAll(ctx, method, &dynamodb.ScanInput{
+		TableName:            aws.String(session.table),
+		ProjectionExpression: aws.String("pk, sk"),
+		FilterExpression:     aws.String("sk = :list"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":list": stringAttribute(sortKeyList),
+		},
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	})
+	if err != nil {
+		return 0, err
+	}
+	repaired := 0
+	for _, v := range lists {
+		pk := aws.StringValue(v[attributePK].S)
+		fixed, err := session.repairList(ctx, method, session.table, v, attributePK, func(ctx context.Context) (int, int, error) {
+			input := session.partitionQuery("", pk, "")
+			input.ProjectionExpression = aws.String(attributeSK)
+			input.ConsistentRead = aws.Bool(true)
+			output, err := session.queryAll(ctx, method, input)
+			if err != nil {
+				return 0, 0, err
+			}
+			guests, items := countChildren(output)
+			return guests, items, nil
+		})
+		if err != nil {
+			return repaired, err
+		}
+		if fixed {
+			repaired++
+		}
+	}
+	return repaired, nil
+}
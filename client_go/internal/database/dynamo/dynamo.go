@@ -1,33 +1,51 @@
 package dynamo
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strconv"
-	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
 	"github.com/google/uuid"
 )
 
+// connection holds what the DynamoDB backends have in common: the
+// client, the Slowdown setting and the tables to provision
+type connection struct {
+	DynamoDBresource dynamodbiface.DynamoDBAPI
+	slowdownSeconds  int
+	schema           []TableSchema
+	capacity         Capacity
+}
+
 // DBSession is a type
 type DBSession struct {
-	DynamoDBresource *dynamodb.DynamoDB
-	slowdownSeconds  int
+	connection
+	tables  Tables
+	indexes Indexes
+}
+
+// Backend is implemented by DBSession and SingleTableSession
+type Backend interface {
+	model.Interface
+	DiffSchema(ctx context.Context) ([]string, error)
+	VerifySchema(ctx context.Context) error
+	ApplySchema(ctx context.Context) error
+	PrepareSchema(ctx context.Context, mode string) error
+	RepairCounters(ctx context.Context) (int, error)
 }
 
 func validateQueryOutputCount(count int64, output *dynamodb.QueryOutput) error {
 	if count != -1 {
 		if *output.Count != count {
-			return &model.CustomError{
-				ErrorCode:   model.ErrorInvalidCount,
-				ErrorDetail: fmt.Sprintf("intended=%d, actual=%d", count, *output.Count),
-			}
+			return fmt.Errorf("expected %d results, got %d", count, *output.Count)
 		}
 	}
 	return nil
@@ -44,51 +62,49 @@ func logEnd(method string, start time.Time) {
 	log.Printf("%s Time (%dms)", method, end.Milliseconds())
 }
 
-func slowdown(session *DBSession, method string, description string) {
+// slowdown returns early if ctx is done; the SDK call that follows then
+// fails with the context's error
+func (session *connection) slowdown(ctx context.Context, method string, description string) {
 	if session.slowdownSeconds > 0 {
 		log.Printf("%s (%s) Sleeping for %d seconds", method, description, session.slowdownSeconds)
-		time.Sleep(time.Duration(session.slowdownSeconds) * time.Second)
+		select {
+		case <-time.After(time.Duration(session.slowdownSeconds) * time.Second):
+		case <-ctx.Done():
+		}
 	}
 }
 
 // Slowdown is a method
-func (session *DBSession) Slowdown(seconds int) {
+func (session *connection) Slowdown(seconds int) {
 	session.slowdownSeconds = seconds
 }
 
 // ListUsers is a method
-func (session *DBSession) ListUsers(lastUserID string, max int64) ([]model.User, string, error) {
+func (session *DBSession) ListUsers(ctx context.Context, cursor model.Cursor, max int64) ([]model.User, model.Cursor, error) {
 	const method = "ListUsers"
-	slowdown(session, method, "entry")
+	session.slowdown(ctx, method, "entry")
 	defer logEnd(method, time.Now())
-	log.Printf("%s (lastUserId=%s,max=%d)", method, lastUserID, max)
-	var exclusiveStartKey map[string]*dynamodb.AttributeValue
+	log.Printf("%s (cursor=%s,max=%d)", method, cursor, max)
 
-	if lastUserID != "" {
-		exclusiveStartKey = map[string]*dynamodb.AttributeValue{
-			"id": {
-				S: aws.String(lastUserID),
-			},
-		}
-	} else {
-		exclusiveStartKey = nil
+	if err := checkPageSize(max); err != nil {
+		return nil, "", err
+	}
+	exclusiveStartKey, err := startKey(cursor, "id")
+	if err != nil {
+		return nil, "", err
 	}
 	input := &dynamodb.ScanInput{
-		TableName:              aws.String("users"),
+		TableName:              aws.String(session.tables.Users),
 		Limit:                  aws.Int64(max),
 		ExclusiveStartKey:      exclusiveStartKey,
 		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
 	}
-	output, err := session.DynamoDBresource.Scan(input)
+	output, err := session.DynamoDBresource.ScanWithContext(ctx, input)
 	if err != nil {
-		return nil, "", err
+		return nil, "", translateError(method, err)
 	}
 	logConsumedCapacity(method, output.ConsumedCapacity)
-	if output.LastEvaluatedKey != nil {
-		lastUserID = *output.LastEvaluatedKey["id"].S
-	} else {
-		lastUserID = ""
-	}
+	next := nextCursor(output.LastEvaluatedKey, "id")
 	if *output.Count > 0 {
 		var users = make([]model.User, *output.Count)
 		for index, scanItem := range output.Items {
@@ -97,17 +113,21 @@ func (session *DBSession) ListUsers(lastUserID string, max int64) ([]model.User,
 				return nil, "", err2
 			}
 		}
-		return users, lastUserID, nil
+		return users, next, nil
 	}
-	return []model.User{}, lastUserID, nil
+	return []model.User{}, next, nil
 }
 
 // GetUsersByIDs is a method
-func (session *DBSession) GetUsersByIDs(ids []string) ([]model.User, error) {
+func (session *DBSession) GetUsersByIDs(ctx context.Context, ids []string) ([]model.User, error) {
 	const method = "GetUsersByIDs"
-	slowdown(session, method, "entry")
+	session.slowdown(ctx, method, "entry")
 	defer logEnd(method, time.Now())
 	log.Printf("%s (ids=%v)", method, ids)
+	if len(ids) == 0 {
+		return []model.User{}, nil
+	}
+	ids = uniqueStrings(ids)
 	var keys = make([]map[string]*dynamodb.AttributeValue, len(ids))
 	for i, v := range ids {
 		keys[i] = map[string]*dynamodb.AttributeValue{
@@ -116,23 +136,10 @@ func (session *DBSession) GetUsersByIDs(ids []string) ([]model.User, error) {
 			},
 		}
 	}
-	input := &dynamodb.BatchGetItemInput{
-		RequestItems: map[string]*dynamodb.KeysAndAttributes{
-			"users": {
-				Keys: keys,
-			},
-		},
-		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
-	}
-
-	output, err := session.DynamoDBresource.BatchGetItem(input)
+	usersAttributes, err := session.batchGet(ctx, method, session.tables.Users, keys)
 	if err != nil {
 		return []model.User{}, err
 	}
-	for i, v := range output.ConsumedCapacity {
-		logConsumedCapacity(fmt.Sprintf("%s #%d", method, i), v)
-	}
-	usersAttributes := output.Responses["users"]
 	if len(usersAttributes) > 0 {
 		var users = make([]model.User, len(usersAttributes))
 		for i, v := range usersAttributes {
@@ -146,9 +153,9 @@ func (session *DBSession) GetUsersByIDs(ids []string) ([]model.User, error) {
 }
 
 // GetUserByEmail is a method
-func (session *DBSession) GetUserByEmail(email string) (model.User, error) {
+func (session *DBSession) GetUserByEmail(ctx context.Context, email string) (model.User, error) {
 	const method = "GetUserByEmail"
-	slowdown(session, method, "entry")
+	session.slowdown(ctx, method, "entry")
 	defer logEnd(method, time.Now())
 	log.Printf("%s (email=%s)", method, email)
 
@@ -161,22 +168,22 @@ func (session *DBSession) GetUserByEmail(email string) (model.User, error) {
 		},
 		KeyConditionExpression: aws.String("email = :v1"),
 		ProjectionExpression:   aws.String("id"),
-		TableName:              aws.String("users"),
-		IndexName:              aws.String("users_by_email"),
+		TableName:              aws.String(session.tables.Users),
+		IndexName:              aws.String(session.indexes.UsersByEmail),
 		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
 	}
 
 	// Provide Input and obtain Output and Error
-	output, err := session.DynamoDBresource.Query(input)
+	output, err := session.DynamoDBresource.QueryWithContext(ctx, input)
 	if err != nil {
-		return model.User{}, err
+		return model.User{}, translateError(method, err)
 	}
 	logConsumedCapacity(method, output.ConsumedCapacity)
 
 	if *output.Count == 0 {
-		return model.User{}, &model.CustomError{
-			ErrorCode:   model.ErrorNoMatch,
-			ErrorDetail: email,
+		return model.User{}, &model.NotFoundError{
+			Entity: model.EntityUser,
+			Key:    email,
 		}
 	}
 	if err2 := validateQueryOutputCount(1, output); err2 != nil {
@@ -194,167 +201,242 @@ func (session *DBSession) GetUserByEmail(email string) (model.User, error) {
 
 }
 
-// GetAggregateListsByUserID is a method
-func (session *DBSession) GetAggregateListsByUserID(userID string) ([]model.AggregateList, error) {
-
-	const method = "GetAggregateListsByUserID"
-	slowdown(session, method, "entry")
+// CreateUser is a method
+//
+// Emails are not checked for uniqueness as the email index, being a
+// GSI, cannot enforce it
+func (session *DBSession) CreateUser(ctx context.Context, email string) (string, error) {
+	const method = "CreateUser"
+	session.slowdown(ctx, method, "entry")
 	defer logEnd(method, time.Now())
-	log.Printf("%s (userID=%s)", method, userID)
+	log.Printf("%s (email=%s)", method, email)
 
-	type listsResult struct {
-		result  []model.List
-		asGuest bool
-		err     error
+	uuidString := uuid.New().String()
+
+	userAV, err := dynamodbattribute.MarshalMap(model.User{
+		ID:    uuidString,
+		Email: email,
+	})
+	if err != nil {
+		return "", err
 	}
-	type countResult struct {
-		listID string
-		count  int
-		err    error
+
+	input := &dynamodb.PutItemInput{
+		TableName:              aws.String(session.tables.Users),
+		Item:                   userAV,
+		ConditionExpression:    aws.String("attribute_not_exists(id)"),
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	}
+
+	output, err2 := session.DynamoDBresource.PutItemWithContext(ctx, input)
+	if err2 != nil {
+		if aeer, ok := err2.(awserr.Error); ok {
+			if aeer.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+				return "", &model.AlreadyExistsError{
+					Entity: model.EntityUser,
+					Key:    uuidString,
+				}
+			}
+		}
+		return "", translateError(method, err2)
 	}
+	logConsumedCapacity(method, output.ConsumedCapacity)
+	return uuidString, nil
+}
 
-	var wg sync.WaitGroup
+// GetAggregateListsByUserID is a method
+func (session *DBSession) GetAggregateListsByUserID(ctx context.Context, userID string) ([]model.AggregateList, error) {
 
-	listsChan := make(chan listsResult, 2)
-	guestCountChan := make(chan countResult, 100)
-	itemCountChan := make(chan countResult, 100)
+	const method = "GetAggregateListsByUserID"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (userID=%s)", method, userID)
 
-	countGuests := func(session *DBSession, wg *sync.WaitGroup, listID string) {
-		defer wg.Done()
-		guests, err := session.GetGuestsByListID(listID)
-		guestCountChan <- countResult{
-			listID: listID,
-			count:  len(guests),
-			err:    err,
-		}
+	type listIDsResult struct {
+		listIDs []string
+		err     error
 	}
+	ownedChan := make(chan listIDsResult, 1)
+	guestChan := make(chan listIDsResult, 1)
 
-	countItems := func(session *DBSession, wg *sync.WaitGroup, listID string) {
-		defer wg.Done()
-		items, err := session.GetItemsByListID(listID)
-		itemCountChan <- countResult{
-			listID: listID,
-			count:  len(items),
-			err:    err,
+	// Retrieve the lists owned by the userID
+	go func() {
+		lists, err := session.GetListsByUserID(ctx, userID)
+		listIDs := make([]string, len(lists))
+		for i, l := range lists {
+			listIDs[i] = l.ID
 		}
-	}
+		ownedChan <- listIDsResult{listIDs: listIDs, err: err}
+	}()
 
 	// Retrieve the listIDs where the current userID
 	// is a guest (they don't own the lists)
-
-	wg.Add(1)
-	go func(wg *sync.WaitGroup) {
-		defer wg.Done()
-		guests, err := session.GetGuestsByUserID(userID)
-		if err != nil {
-			listsChan <- listsResult{
-				result: []model.List{},
-				err:    err,
-			}
-			return
-		}
+	go func() {
+		guests, err := session.GetGuestsByUserID(ctx, userID)
 		listIDs := make([]string, len(guests))
 		for i, g := range guests {
 			listIDs[i] = g.ListID
-			wg.Add(2)
-			go countGuests(session, wg, g.ListID)
-			go countItems(session, wg, g.ListID)
 		}
-		wg.Add(1)
-		go func(wg *sync.WaitGroup) {
-			defer wg.Done()
-			lists, err := session.GetListsByIDs(listIDs)
-			listsChan <- listsResult{
-				result:  lists,
-				asGuest: true,
-				err:     err,
-			}
-		}(wg)
-	}(&wg)
+		guestChan <- listIDsResult{listIDs: listIDs, err: err}
+	}()
 
-	// Retrieve the lists owned by the userID
-	wg.Add(1)
-	go func(wg *sync.WaitGroup) {
-		defer wg.Done()
-		list, err := session.GetListsByUserID(userID)
-		listsChan <- listsResult{result: list, err: err}
-		for _, l := range list {
-			wg.Add(2)
-			go countGuests(session, wg, l.ID)
-			go countItems(session, wg, l.ID)
-		}
-	}(&wg)
+	owned, guest := <-ownedChan, <-guestChan
+	if owned.err != nil {
+		return []model.AggregateList{}, owned.err
+	}
+	if guest.err != nil {
+		return []model.AggregateList{}, guest.err
+	}
+	return session.getAggregateLists(ctx, owned.listIDs, guest.listIDs)
+}
 
-	wg.Wait()
-	close(listsChan)
-	close(guestCountChan)
-	close(itemCountChan)
+// Pages of aggregate lists hold the lists owned by the user first, and
+// then those in which the user is a guest
+const (
+	ownedListsPhase = "owned"
+	guestListsPhase = "guest"
+)
 
-	guestCountMap := make(map[string]int)
-	for guestCount := range guestCountChan {
-		if guestCount.err != nil {
-			return []model.AggregateList{}, guestCount.err
-		}
-		guestCountMap[guestCount.listID] = guestCount.count
+// GetAggregateListsByUserIDPage is a method
+func (session *DBSession) GetAggregateListsByUserIDPage(ctx context.Context, userID string, cursor model.Cursor, max int64) ([]model.AggregateList, model.Cursor, error) {
+	const method = "GetAggregateListsByUserIDPage"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (userID=%s,cursor=%s,max=%d)", method, userID, cursor, max)
+
+	// An empty page would hand back the cursor it was given
+	if err := checkPageSize(max); err != nil {
+		return nil, "", err
+	}
+	keys, err := cursor.Keys(2)
+	if err != nil {
+		return nil, "", err
+	}
+	phase, phaseCursor := ownedListsPhase, model.Cursor("")
+	if keys != nil {
+		phase, phaseCursor = keys[0], model.Cursor(keys[1])
 	}
 
-	itemCountMap := make(map[string]int)
-	for itemCount := range itemCountChan {
-		if itemCount.err != nil {
-			return []model.AggregateList{}, itemCount.err
+	ownedIDs := make([]string, 0)
+	guestIDs := make([]string, 0)
+	for phase != "" && int64(len(ownedIDs)+len(guestIDs)) < max {
+		remaining := max - int64(len(ownedIDs)+len(guestIDs))
+		switch phase {
+		case ownedListsPhase:
+			owned, next, err := session.GetListsByUserIDPage(ctx, userID, phaseCursor, remaining)
+			if err != nil {
+				return nil, "", err
+			}
+			for _, l := range owned {
+				ownedIDs = append(ownedIDs, l.ID)
+			}
+			if phaseCursor = next; next == "" {
+				phase = guestListsPhase
+			}
+		case guestListsPhase:
+			guests, next, err := session.GetGuestsByUserIDPage(ctx, userID, phaseCursor, remaining)
+			if err != nil {
+				return nil, "", err
+			}
+			for _, g := range guests {
+				guestIDs = append(guestIDs, g.ListID)
+			}
+			if phaseCursor = next; next == "" {
+				phase = ""
+			}
+		default:
+			return nil, "", &model.ValidationError{
+				Field:  "cursor",
+				Reason: fmt.Sprintf("%q is not a cursor for this listing", cursor),
+			}
 		}
-		itemCountMap[itemCount.listID] = itemCount.count
 	}
 
-	alists := make([]model.AggregateList, 0, 1)
-	for r := range listsChan {
-		if r.err != nil {
-			return []model.AggregateList{}, r.err
+	alists, err := session.getAggregateLists(ctx, ownedIDs, guestIDs)
+	if err != nil {
+		return nil, "", err
+	}
+	if phase == "" {
+		return alists, "", nil
+	}
+	return alists, model.NewCursor(phase, string(phaseCursor)), nil
+}
+
+// getAggregateLists reads the given lists, counters included, with one
+// read per list. Lists deleted since their IDs were read are left out.
+func (session *DBSession) getAggregateLists(ctx context.Context, ownedIDs []string, guestIDs []string) ([]model.AggregateList, error) {
+	const method = "getAggregateLists"
+	alists := make([]model.AggregateList, 0, len(ownedIDs)+len(guestIDs))
+	ids := append(append([]string{}, ownedIDs...), guestIDs...)
+	if len(ids) == 0 {
+		return alists, nil
+	}
+	ids = uniqueStrings(ids)
+	var keys = make([]map[string]*dynamodb.AttributeValue, len(ids))
+	for i, v := range ids {
+		keys[i] = map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(v),
+			},
+		}
+	}
+	listsAttributes, err := session.batchGet(ctx, method, session.tables.Lists, keys)
+	if err != nil {
+		return []model.AggregateList{}, err
+	}
+	// BatchGetItem does not keep the order of the keys
+	byID := make(map[string]model.AggregateList, len(listsAttributes))
+	for _, v := range listsAttributes {
+		var aggregate model.AggregateList
+		if err := dynamodbattribute.UnmarshalMap(v, &aggregate); err != nil {
+			return []model.AggregateList{}, err
 		}
-		for _, l := range r.result {
-			alists = append(alists, model.AggregateList{
-				List:       l,
-				GuestCount: guestCountMap[l.ID],
-				ItemCount:  itemCountMap[l.ID],
-				AsGuest:    r.asGuest,
-			})
+		byID[aggregate.ID] = aggregate
+	}
+	for _, id := range ownedIDs {
+		if l, ok := byID[id]; ok {
+			alists = append(alists, l)
+		}
+	}
+	for _, id := range guestIDs {
+		if l, ok := byID[id]; ok {
+			l.AsGuest = true
+			alists = append(alists, l)
 		}
 	}
-
 	return alists, nil
 }
 
-// GetListsByUserID is a method
-func (session *DBSession) GetListsByUserID(userID string) ([]model.List, error) {
-	const method = "GetListsByUserID"
-	slowdown(session, method, "entry")
-	defer logEnd(method, time.Now())
-	log.Printf("%s (userID=%s)", method, userID)
-	dynamoDB := session.DynamoDBresource
-
-	input := &dynamodb.QueryInput{
+func (session *DBSession) listsByUserIDQuery(userID string) *dynamodb.QueryInput {
+	return &dynamodb.QueryInput{
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
 			":v1": {
 				S: aws.String(userID),
 			},
 		},
 		KeyConditionExpression: aws.String("user_id = :v1"),
-		ProjectionExpression:   aws.String("id,title"),
-		TableName:              aws.String("lists"),
-		IndexName:              aws.String("lists_by_user_id"),
+		ProjectionExpression:   aws.String("id,title,user_id"),
+		TableName:              aws.String(session.tables.Lists),
+		IndexName:              aws.String(session.indexes.ListsByUserID),
 		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
 	}
+}
+
+// GetListsByUserID is a method
+func (session *DBSession) GetListsByUserID(ctx context.Context, userID string) ([]model.List, error) {
+	const method = "GetListsByUserID"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (userID=%s)", method, userID)
 
-	output, err := dynamoDB.Query(input)
+	output, err := session.queryAll(ctx, method, session.listsByUserIDQuery(userID))
 	if err != nil {
 		return []model.List{}, err
 	}
 
-	logConsumedCapacity(method, output.ConsumedCapacity)
-
-	var lists = make([]model.List, *output.Count)
+	var lists = make([]model.List, len(output))
 
-	for i, v := range output.Items {
+	for i, v := range output {
 		if err3 := dynamodbattribute.UnmarshalMap(v, &lists[i]); err3 != nil {
 			return []model.List{}, err3
 		}
@@ -362,11 +444,38 @@ func (session *DBSession) GetListsByUserID(userID string) ([]model.List, error)
 	return lists, nil
 }
 
+// GetListsByUserIDPage is a method
+func (session *DBSession) GetListsByUserIDPage(ctx context.Context, userID string, cursor model.Cursor, max int64) ([]model.List, model.Cursor, error) {
+	const method = "GetListsByUserIDPage"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (userID=%s,cursor=%s,max=%d)", method, userID, cursor, max)
+
+	// Index keys must be accompanied by the table's own key
+	exclusiveStartKey, err := startKey(cursor, "id", "user_id", userID)
+	if err != nil {
+		return nil, "", err
+	}
+	output, lastEvaluatedKey, err := session.queryPage(ctx, method, session.listsByUserIDQuery(userID), exclusiveStartKey, max)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var lists = make([]model.List, len(output))
+
+	for i, v := range output {
+		if err3 := dynamodbattribute.UnmarshalMap(v, &lists[i]); err3 != nil {
+			return nil, "", err3
+		}
+	}
+	return lists, nextCursor(lastEvaluatedKey, "id"), nil
+}
+
 // CreateList is a method
-func (session *DBSession) CreateList(userID string, title string) (string, error) {
+func (session *DBSession) CreateList(ctx context.Context, userID string, title string) (string, error) {
 
 	const method = "CreateList"
-	slowdown(session, method, "entry")
+	session.slowdown(ctx, method, "entry")
 	defer logEnd(method, time.Now())
 	log.Printf("%s (userID=%s,title=%s)", method, userID, title)
 
@@ -383,12 +492,13 @@ func (session *DBSession) CreateList(userID string, title string) (string, error
 	}
 
 	input := &dynamodb.TransactWriteItemsInput{
+		ClientRequestToken:     aws.String(uuid.New().String()),
 		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
 		TransactItems: []*dynamodb.TransactWriteItem{
 			{
 				ConditionCheck: &dynamodb.ConditionCheck{
 
-					TableName: aws.String("users"),
+					TableName: aws.String(session.tables.Users),
 					Key: map[string]*dynamodb.AttributeValue{
 						"id": {
 							S: aws.String(userID),
@@ -399,7 +509,7 @@ func (session *DBSession) CreateList(userID string, title string) (string, error
 			},
 			{
 				Put: &dynamodb.Put{
-					TableName:           aws.String("lists"),
+					TableName:           aws.String(session.tables.Lists),
 					Item:                userAV,
 					ConditionExpression: aws.String("attribute_not_exists(id)"),
 				},
@@ -407,26 +517,21 @@ func (session *DBSession) CreateList(userID string, title string) (string, error
 		},
 	}
 
-	output, err2 := session.DynamoDBresource.TransactWriteItems(input)
+	output, err2 := session.DynamoDBresource.TransactWriteItemsWithContext(ctx, input)
 	if err2 != nil {
-		switch v := err2.(type) {
-		case *dynamodb.TransactionCanceledException:
-			switch {
-			case len(v.CancellationReasons) > 0 && *v.CancellationReasons[0].Code == "ConditionalCheckFailed":
-				return "", &model.CustomError{
-					ErrorCode:   model.ErrorNoMatch,
-					ErrorDetail: fmt.Sprintf("userID=%s", userID),
-				}
-			case len(v.CancellationReasons) > 1 && *v.CancellationReasons[1].Code == "ConditionalCheckFailed":
-				return "", &model.CustomError{
-					ErrorCode:   model.ErrorDuplicateID,
-					ErrorDetail: fmt.Sprintf("listID=%s", uuidString),
-				}
-			default:
-				return "", err2
+		switch {
+		case isConditionFailure(err2, 0):
+			return "", &model.NotFoundError{
+				Entity: model.EntityUser,
+				Key:    userID,
+			}
+		case isConditionFailure(err2, 1):
+			return "", &model.AlreadyExistsError{
+				Entity: model.EntityList,
+				Key:    uuidString,
 			}
 		default:
-			return "", err2
+			return "", translateError(method, err2)
 		}
 	}
 	for i, v := range output.ConsumedCapacity {
@@ -436,9 +541,9 @@ func (session *DBSession) CreateList(userID string, title string) (string, error
 }
 
 // DeleteList is a method
-func (session *DBSession) DeleteList(listID string, userID string) error {
+func (session *DBSession) DeleteList(ctx context.Context, listID string, userID string) error {
 	const method = "DeleteList"
-	slowdown(session, method, "entry")
+	session.slowdown(ctx, method, "entry")
 	defer logEnd(method, time.Now())
 	log.Printf("%s (listID=%s,userID=%s)", method, listID, userID)
 	//
@@ -446,13 +551,14 @@ func (session *DBSession) DeleteList(listID string, userID string) error {
 	// the `under_deletion` attribute
 	//
 	log.Printf("Preparing list %s for deletion", listID)
-	slowdown(session, method, "before setting under_deletion")
+	session.slowdown(ctx, method, "before setting under_deletion")
 	input := &dynamodb.TransactWriteItemsInput{
+		ClientRequestToken:     aws.String(uuid.New().String()),
 		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
 		TransactItems: []*dynamodb.TransactWriteItem{
 			{
 				Update: &dynamodb.Update{
-					TableName: aws.String("lists"),
+					TableName: aws.String(session.tables.Lists),
 					Key: map[string]*dynamodb.AttributeValue{
 						"id": {
 							S: aws.String(listID),
@@ -472,36 +578,57 @@ func (session *DBSession) DeleteList(listID string, userID string) error {
 			},
 		},
 	}
-	output, err := session.DynamoDBresource.TransactWriteItems(input)
+	output, err := session.DynamoDBresource.TransactWriteItemsWithContext(ctx, input)
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			if aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
-				return &model.CustomError{
-					ErrorCode:   model.ErrorNoMatch,
-					ErrorDetail: fmt.Sprintf("listID=%s,userID=%s", listID, userID),
-				}
-			}
-			return err
+		if isConditionFailure(err, 0) {
+			return session.explainListCondition(ctx, method, listID, userID, "delete")
 		}
+		return translateError(method, err)
 	}
 	for i, v := range output.ConsumedCapacity {
 		logConsumedCapacity(fmt.Sprintf("%s #%d", method, i), v)
 	}
 	//
-	// Then proceed to delete to obtain all items in the list
-	// to then delete them
+	// Then obtain all guests and items in the list to delete them
+	// together. Neither CreateGuest nor CreateItem succeed once
+	// `under_deletion` is set, so strongly consistent reads see every
+	// child there is to delete. Should the process stop at this point,
+	// calling DeleteList again resumes the deletion.
 	//
-	items, err := session.GetItemsByListID(listID)
+	guests, err := session.getGuestsByListID(ctx, listID, true)
+	if err != nil {
+		return err
+	}
+	items, err := session.getItemsByListID(ctx, listID, true)
 	if err != nil {
 		return err
 	}
-	if len(items) > 0 {
-		slowdown(session, method, "before deleting items")
-		deleteWriteRequests := make([]*dynamodb.WriteRequest, 0)
+	if len(guests) > 0 || len(items) > 0 {
+		session.slowdown(ctx, method, "before deleting guests and items")
+		guestWriteRequests := make([]*dynamodb.WriteRequest, 0)
+		itemWriteRequests := make([]*dynamodb.WriteRequest, 0)
+
+		for _, guest := range guests {
+			log.Printf("Deleting guest %s", guest.UserID)
+			guestWriteRequests = append(guestWriteRequests,
+				&dynamodb.WriteRequest{
+					DeleteRequest: &dynamodb.DeleteRequest{
+						Key: map[string]*dynamodb.AttributeValue{
+							"list_id": {
+								S: aws.String(guest.ListID),
+							},
+							"user_id": {
+								S: aws.String(guest.UserID),
+							},
+						},
+					},
+				},
+			)
+		}
 
 		for _, item := range items {
 			log.Printf("Deleting item %s (%s)", item.Datetime, item.Description)
-			deleteWriteRequests = append(deleteWriteRequests,
+			itemWriteRequests = append(itemWriteRequests,
 				&dynamodb.WriteRequest{
 					DeleteRequest: &dynamodb.DeleteRequest{
 						Key: map[string]*dynamodb.AttributeValue{
@@ -517,19 +644,12 @@ func (session *DBSession) DeleteList(listID string, userID string) error {
 			)
 		}
 
-		input2 := &dynamodb.BatchWriteItemInput{
-			ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
-			RequestItems: map[string][]*dynamodb.WriteRequest{
-				"items": deleteWriteRequests,
-			},
-		}
-
-		output2, err2 := session.DynamoDBresource.BatchWriteItem(input2)
-		if err2 != nil {
-			return err2
+		requestItems := map[string][]*dynamodb.WriteRequest{
+			session.tables.Guests: guestWriteRequests,
+			session.tables.Items:  itemWriteRequests,
 		}
-		for i, v := range output2.ConsumedCapacity {
-			logConsumedCapacity(fmt.Sprintf("%s #%d", method, i), v)
+		if err := session.batchWrite(ctx, method, requestItems); err != nil {
+			return err
 		}
 	}
 
@@ -537,47 +657,48 @@ func (session *DBSession) DeleteList(listID string, userID string) error {
 	// Finally, delete the list
 	//
 	log.Printf("Deleting list %s", listID)
-	slowdown(session, method, "before deleting list")
+	session.slowdown(ctx, method, "before deleting list")
 	input3 := &dynamodb.DeleteItemInput{
 		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
-		TableName:              aws.String("lists"),
+		TableName:              aws.String(session.tables.Lists),
 		Key: map[string]*dynamodb.AttributeValue{
 			"id": {
 				S: aws.String(listID),
 			},
 		},
-		ConditionExpression: aws.String("attribute_exists(id) AND user_id = :u"),
+		ConditionExpression: aws.String("attribute_exists(id) AND user_id = :u AND under_deletion = :t"),
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
 			":u": {
 				S: aws.String(userID),
 			},
+			":t": {
+				BOOL: aws.Bool(true),
+			},
 		},
 	}
 
-	output3, err3 := session.DynamoDBresource.DeleteItem(input3)
+	output3, err3 := session.DynamoDBresource.DeleteItemWithContext(ctx, input3)
 	if err3 != nil {
 		if aeer, ok := err3.(awserr.Error); ok {
 			if aeer.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
-				return &model.CustomError{
-					ErrorCode:   model.ErrorNoMatch,
-					ErrorDetail: fmt.Sprintf("listID=%s,userID=%s", listID, userID),
-				}
+				// A concurrent call has completed the deletion
+				return session.explainListCondition(ctx, method, listID, userID, "delete")
 			}
 		}
-		return err3
+		return translateError(method, err3)
 	}
 	logConsumedCapacity(method, output3.ConsumedCapacity)
 	return nil
 }
 
 // GetListByListID is blah
-func (session *DBSession) GetListByListID(listID string) (model.List, error) {
+func (session *DBSession) GetListByListID(ctx context.Context, listID string) (model.List, error) {
 	const method = "GetListByListID"
-	slowdown(session, method, "entry")
+	session.slowdown(ctx, method, "entry")
 	defer logEnd(method, time.Now())
 	log.Printf("%s (listID=%s)", method, listID)
 	input := &dynamodb.GetItemInput{
-		TableName: aws.String("lists"),
+		TableName: aws.String(session.tables.Lists),
 		Key: map[string]*dynamodb.AttributeValue{
 			"id": {
 				S: aws.String(listID),
@@ -585,9 +706,9 @@ func (session *DBSession) GetListByListID(listID string) (model.List, error) {
 		},
 		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
 	}
-	output, err := session.DynamoDBresource.GetItem(input)
+	output, err := session.DynamoDBresource.GetItemWithContext(ctx, input)
 	if err != nil {
-		return model.List{}, err
+		return model.List{}, translateError(method, err)
 	}
 	logConsumedCapacity(method, output.ConsumedCapacity)
 	var list model.List
@@ -596,9 +717,9 @@ func (session *DBSession) GetListByListID(listID string) (model.List, error) {
 		return model.List{}, err2
 	}
 	if list.ID == "" {
-		return model.List{}, &model.CustomError{
-			ErrorCode:   model.ErrorNoMatch,
-			ErrorDetail: listID,
+		return model.List{}, &model.NotFoundError{
+			Entity: model.EntityList,
+			Key:    listID,
 		}
 	}
 
@@ -606,11 +727,15 @@ func (session *DBSession) GetListByListID(listID string) (model.List, error) {
 }
 
 // GetListsByIDs is a method
-func (session *DBSession) GetListsByIDs(ids []string) ([]model.List, error) {
+func (session *DBSession) GetListsByIDs(ctx context.Context, ids []string) ([]model.List, error) {
 	const method = "GetListsByIDs"
-	slowdown(session, method, "entry")
+	session.slowdown(ctx, method, "entry")
 	defer logEnd(method, time.Now())
 	log.Printf("%s (ids=%v)", method, ids)
+	if len(ids) == 0 {
+		return []model.List{}, nil
+	}
+	ids = uniqueStrings(ids)
 	var keys = make([]map[string]*dynamodb.AttributeValue, len(ids))
 	for i, v := range ids {
 		keys[i] = map[string]*dynamodb.AttributeValue{
@@ -619,22 +744,10 @@ func (session *DBSession) GetListsByIDs(ids []string) ([]model.List, error) {
 			},
 		}
 	}
-	input := &dynamodb.BatchGetItemInput{
-		RequestItems: map[string]*dynamodb.KeysAndAttributes{
-			"lists": {
-				Keys: keys,
-			},
-		},
-		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
-	}
-	output, err := session.DynamoDBresource.BatchGetItem(input)
+	listsAttributes, err := session.batchGet(ctx, method, session.tables.Lists, keys)
 	if err != nil {
 		return []model.List{}, err
 	}
-	for i, v := range output.ConsumedCapacity {
-		logConsumedCapacity(fmt.Sprintf("%s #%d", method, i), v)
-	}
-	listsAttributes := output.Responses["lists"]
 	if len(listsAttributes) > 0 {
 		var lists = make([]model.List, len(listsAttributes))
 		for i, v := range listsAttributes {
@@ -648,21 +761,43 @@ func (session *DBSession) GetListsByIDs(ids []string) ([]model.List, error) {
 }
 
 // GetAggregateGuestsByListID is a method
-func (session *DBSession) GetAggregateGuestsByListID(listID string) ([]model.AggregateGuest, error) {
+func (session *DBSession) GetAggregateGuestsByListID(ctx context.Context, listID string) ([]model.AggregateGuest, error) {
 	const method = "GetAggregateGuestsByListID"
-	slowdown(session, method, "entry")
+	session.slowdown(ctx, method, "entry")
 	defer logEnd(method, time.Now())
 	log.Printf("%s (listID=%s)", method, listID)
-	guests, err := session.GetGuestsByListID(listID)
+	guests, err := session.GetGuestsByListID(ctx, listID)
 	if err != nil {
 		return []model.AggregateGuest{}, err
 	}
+	return session.aggregateGuests(ctx, guests)
+}
+
+// GetAggregateGuestsByListIDPage is a method
+func (session *DBSession) GetAggregateGuestsByListIDPage(ctx context.Context, listID string, cursor model.Cursor, max int64) ([]model.AggregateGuest, model.Cursor, error) {
+	const method = "GetAggregateGuestsByListIDPage"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (listID=%s,cursor=%s,max=%d)", method, listID, cursor, max)
+	guests, next, err := session.GetGuestsByListIDPage(ctx, listID, cursor, max)
+	if err != nil {
+		return nil, "", err
+	}
+	aggregateGuests, err := session.aggregateGuests(ctx, guests)
+	if err != nil {
+		return nil, "", err
+	}
+	return aggregateGuests, next, nil
+}
+
+// aggregateGuests looks up the email of every guest
+func (session *DBSession) aggregateGuests(ctx context.Context, guests []model.Guest) ([]model.AggregateGuest, error) {
 	if len(guests) > 0 {
 		var userIDs = make([]string, len(guests))
 		for i, v := range guests {
 			userIDs[i] = v.UserID
 		}
-		users, err2 := session.GetUsersByIDs(userIDs)
+		users, err2 := session.GetUsersByIDs(ctx, userIDs)
 		if err2 != nil {
 			return []model.AggregateGuest{}, err2
 		}
@@ -681,64 +816,101 @@ func (session *DBSession) GetAggregateGuestsByListID(listID string) ([]model.Agg
 }
 
 // GetGuestsByListID is a method
-func (session *DBSession) GetGuestsByListID(listID string) ([]model.Guest, error) {
+func (session *DBSession) GetGuestsByListID(ctx context.Context, listID string) ([]model.Guest, error) {
+	return session.getGuestsByListID(ctx, listID, false)
+}
+
+func (session *DBSession) getGuestsByListID(ctx context.Context, listID string, consistentRead bool) ([]model.Guest, error) {
 	const method = "GetGuestsByListID"
-	slowdown(session, method, "entry")
+	session.slowdown(ctx, method, "entry")
 	defer logEnd(method, time.Now())
-	log.Printf("%s (listID=%v)", method, listID)
-	input := &dynamodb.QueryInput{
+	log.Printf("%s (listID=%v,consistentRead=%t)", method, listID, consistentRead)
+	input := session.guestsByListIDQuery(listID)
+	input.ConsistentRead = aws.Bool(consistentRead)
+
+	output, err := session.queryAll(ctx, method, input)
+	if err != nil {
+		return []model.Guest{}, err
+	}
+
+	var guests = make([]model.Guest, len(output))
+
+	for i, v := range output {
+		if err3 := dynamodbattribute.UnmarshalMap(v, &guests[i]); err3 != nil {
+			return []model.Guest{}, err3
+		}
+	}
+	return guests, nil
+}
+
+func (session *DBSession) guestsByListIDQuery(listID string) *dynamodb.QueryInput {
+	return &dynamodb.QueryInput{
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
 			":v1": {
 				S: aws.String(listID),
 			},
 		},
 		KeyConditionExpression: aws.String("list_id = :v1"),
-		TableName:              aws.String("guests"),
+		TableName:              aws.String(session.tables.Guests),
 		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
 	}
+}
+
+// GetGuestsByListIDPage is a method
+func (session *DBSession) GetGuestsByListIDPage(ctx context.Context, listID string, cursor model.Cursor, max int64) ([]model.Guest, model.Cursor, error) {
+	const method = "GetGuestsByListIDPage"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (listID=%s,cursor=%s,max=%d)", method, listID, cursor, max)
 
-	output, err := session.DynamoDBresource.Query(input)
+	exclusiveStartKey, err := startKey(cursor, "user_id", "list_id", listID)
 	if err != nil {
-		return []model.Guest{}, err
+		return nil, "", err
+	}
+	output, lastEvaluatedKey, err := session.queryPage(ctx, method, session.guestsByListIDQuery(listID), exclusiveStartKey, max)
+	if err != nil {
+		return nil, "", err
 	}
-	logConsumedCapacity(method, output.ConsumedCapacity)
 
-	var guests = make([]model.Guest, *output.Count)
+	var guests = make([]model.Guest, len(output))
 
-	for i, v := range output.Items {
+	for i, v := range output {
 		if err3 := dynamodbattribute.UnmarshalMap(v, &guests[i]); err3 != nil {
-			return []model.Guest{}, err3
+			return nil, "", err3
 		}
 	}
-	return guests, nil
+	return guests, nextCursor(lastEvaluatedKey, "user_id"), nil
 }
 
-// GetGuestsByUserID is a method
-func (session *DBSession) GetGuestsByUserID(userID string) ([]model.Guest, error) {
-	const method = "GetGuestsByUserID"
-	slowdown(session, method, "entry")
-	defer logEnd(method, time.Now())
-	log.Printf("%s (userID=%s)", method, userID)
-	input := &dynamodb.QueryInput{
+func (session *DBSession) guestsByUserIDQuery(userID string) *dynamodb.QueryInput {
+	return &dynamodb.QueryInput{
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
 			":v1": {
 				S: aws.String(userID),
 			},
 		},
 		KeyConditionExpression: aws.String("user_id = :v1"),
-		TableName:              aws.String("guests"),
-		IndexName:              aws.String("guests_by_user_id"),
+		TableName:              aws.String(session.tables.Guests),
+		IndexName:              aws.String(session.indexes.GuestsByUserID),
 		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
 	}
-	output, err := session.DynamoDBresource.Query(input)
+}
+
+// GetGuestsByUserID is a method
+func (session *DBSession) GetGuestsByUserID(ctx context.Context, userID string) ([]model.Guest, error) {
+	const method = "GetGuestsByUserID"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (userID=%s)", method, userID)
+
+	output, err := session.queryAll(ctx, method, session.guestsByUserIDQuery(userID))
 	if err != nil {
 		return []model.Guest{}, err
 	}
-	logConsumedCapacity(method, output.ConsumedCapacity)
 
-	var guests = make([]model.Guest, *output.Count)
+	var guests = make([]model.Guest, len(output))
 
-	for i, v := range output.Items {
+	for i, v := range output {
 		if err3 := dynamodbattribute.UnmarshalMap(v, &guests[i]); err3 != nil {
 			return []model.Guest{}, err3
 		}
@@ -746,10 +918,37 @@ func (session *DBSession) GetGuestsByUserID(userID string) ([]model.Guest, error
 	return guests, nil
 }
 
+// GetGuestsByUserIDPage is a method
+func (session *DBSession) GetGuestsByUserIDPage(ctx context.Context, userID string, cursor model.Cursor, max int64) ([]model.Guest, model.Cursor, error) {
+	const method = "GetGuestsByUserIDPage"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (userID=%s,cursor=%s,max=%d)", method, userID, cursor, max)
+
+	// The index and table keys are the same pair of attributes
+	exclusiveStartKey, err := startKey(cursor, "list_id", "user_id", userID)
+	if err != nil {
+		return nil, "", err
+	}
+	output, lastEvaluatedKey, err := session.queryPage(ctx, method, session.guestsByUserIDQuery(userID), exclusiveStartKey, max)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var guests = make([]model.Guest, len(output))
+
+	for i, v := range output {
+		if err3 := dynamodbattribute.UnmarshalMap(v, &guests[i]); err3 != nil {
+			return nil, "", err3
+		}
+	}
+	return guests, nextCursor(lastEvaluatedKey, "list_id"), nil
+}
+
 // IsPresentGuest is a method
-func (session *DBSession) IsPresentGuest(listID string, userID string) (bool, error) {
+func (session *DBSession) IsPresentGuest(ctx context.Context, listID string, userID string) (bool, error) {
 	const method = "IsPresentGuest"
-	slowdown(session, method, "entry")
+	session.slowdown(ctx, method, "entry")
 	defer logEnd(method, time.Now())
 	log.Printf("%s (listID=%s,userID=%s)", method, listID, userID)
 	input := &dynamodb.GetItemInput{
@@ -761,12 +960,12 @@ func (session *DBSession) IsPresentGuest(listID string, userID string) (bool, er
 				S: aws.String(userID),
 			},
 		},
-		TableName:              aws.String("guests"),
+		TableName:              aws.String(session.tables.Guests),
 		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
 	}
-	output, err := session.DynamoDBresource.GetItem(input)
+	output, err := session.DynamoDBresource.GetItemWithContext(ctx, input)
 	if err != nil {
-		return false, err
+		return false, translateError(method, err)
 	}
 	logConsumedCapacity(method, output.ConsumedCapacity)
 	if len(output.Item) == 0 {
@@ -776,9 +975,9 @@ func (session *DBSession) IsPresentGuest(listID string, userID string) (bool, er
 }
 
 // CreateGuest is a method
-func (session *DBSession) CreateGuest(listID string, userID string) error {
+func (session *DBSession) CreateGuest(ctx context.Context, listID string, userID string) error {
 	const method = "CreateGuest"
-	slowdown(session, method, "entry")
+	session.slowdown(ctx, method, "entry")
 	defer logEnd(method, time.Now())
 	log.Printf("%s (listID=%s,userID=%s)", method, listID, userID)
 	guest := model.Guest{
@@ -791,25 +990,20 @@ func (session *DBSession) CreateGuest(listID string, userID string) error {
 	}
 
 	input := &dynamodb.TransactWriteItemsInput{
+		ClientRequestToken:     aws.String(uuid.New().String()),
 		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
 		TransactItems: []*dynamodb.TransactWriteItem{
 			{
-
-				ConditionCheck: &dynamodb.ConditionCheck{
-
-					TableName: aws.String("lists"),
-					Key: map[string]*dynamodb.AttributeValue{
-						"id": {
-							S: aws.String(listID),
-						},
+				Update: counterUpdate(session.tables.Lists, map[string]*dynamodb.AttributeValue{
+					"id": {
+						S: aws.String(listID),
 					},
-					ConditionExpression: aws.String("attribute_exists(id)"),
-				},
+				}, attributeGuestCount, 1, "attribute_exists(id) AND attribute_not_exists(under_deletion)"),
 			},
 			{
 				ConditionCheck: &dynamodb.ConditionCheck{
 
-					TableName: aws.String("users"),
+					TableName: aws.String(session.tables.Users),
 					Key: map[string]*dynamodb.AttributeValue{
 						"id": {
 							S: aws.String(userID),
@@ -820,168 +1014,276 @@ func (session *DBSession) CreateGuest(listID string, userID string) error {
 			},
 			{
 				Put: &dynamodb.Put{
-					TableName:           aws.String("guests"),
+					TableName:           aws.String(session.tables.Guests),
 					Item:                guestAV,
 					ConditionExpression: aws.String("attribute_not_exists(list_id) AND attribute_not_exists(user_id)"),
 				},
 			},
 		},
 	}
-	output, err2 := session.DynamoDBresource.TransactWriteItems(input)
+	output, err2 := session.DynamoDBresource.TransactWriteItemsWithContext(ctx, input)
 	for i, v := range output.ConsumedCapacity {
 		logConsumedCapacity(fmt.Sprintf("%s #%d", method, i), v)
 	}
 	if err2 != nil {
-		switch v := err2.(type) {
-		case *dynamodb.TransactionCanceledException:
-			switch {
-			case len(v.CancellationReasons) > 0 && *v.CancellationReasons[0].Code == "ConditionalCheckFailed":
-				return &model.CustomError{
-					ErrorCode:   model.ErrorNoMatch,
-					ErrorDetail: fmt.Sprintf("listID=%s", listID),
-				}
-			case len(v.CancellationReasons) > 1 && *v.CancellationReasons[1].Code == "ConditionalCheckFailed":
-				return &model.CustomError{
-					ErrorCode:   model.ErrorNoMatch,
-					ErrorDetail: fmt.Sprintf("userID=%s", userID),
-				}
-			case len(v.CancellationReasons) > 2 && *v.CancellationReasons[2].Code == "ConditionalCheckFailed":
-				return &model.CustomError{
-					ErrorCode:   model.ErrorDuplicateID,
-					ErrorDetail: fmt.Sprintf("listID=%s,userID=%s", listID, userID),
-				}
-			default:
-				return err2
+		switch {
+		case isConditionFailure(err2, 0):
+			return session.explainListCondition(ctx, method, listID, "", "")
+		case isConditionFailure(err2, 1):
+			return &model.NotFoundError{
+				Entity: model.EntityUser,
+				Key:    userID,
+			}
+		case isConditionFailure(err2, 2):
+			return &model.AlreadyExistsError{
+				Entity: model.EntityGuest,
+				ListID: listID,
+				Key:    userID,
 			}
 		default:
-			return err2
+			return translateError(method, err2)
 		}
 	}
 	return nil
 }
 
 // DeleteGuest is a method
-func (session *DBSession) DeleteGuest(listID string, userID string) error {
+func (session *DBSession) DeleteGuest(ctx context.Context, listID string, userID string) error {
 
 	const method = "DeleteGuest"
-	slowdown(session, method, "entry")
+	session.slowdown(ctx, method, "entry")
 	defer logEnd(method, time.Now())
 	log.Printf("%s (listID=%s,userID=%s)", method, listID, userID)
 
-	input := &dynamodb.DeleteItemInput{
-		TableName: aws.String("guests"),
-		Key: map[string]*dynamodb.AttributeValue{
-			"list_id": {
-				S: aws.String(listID),
-			},
-			"user_id": {
-				S: aws.String(userID),
-			},
-		},
-		ConditionExpression: aws.String("list_id = :l AND user_id = :u"),
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":l": {
-				S: aws.String(listID),
+	input := &dynamodb.TransactWriteItemsInput{
+		ClientRequestToken:     aws.String(uuid.New().String()),
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Delete: &dynamodb.Delete{
+					TableName: aws.String(session.tables.Guests),
+					Key: map[string]*dynamodb.AttributeValue{
+						"list_id": {
+							S: aws.String(listID),
+						},
+						"user_id": {
+							S: aws.String(userID),
+						},
+					},
+					ConditionExpression: aws.String("list_id = :l AND user_id = :u"),
+					ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+						":l": {
+							S: aws.String(listID),
+						},
+						":u": {
+							S: aws.String(userID),
+						},
+					},
+				},
 			},
-			":u": {
-				S: aws.String(userID),
+			{
+				Update: counterUpdate(session.tables.Lists, map[string]*dynamodb.AttributeValue{
+					"id": {
+						S: aws.String(listID),
+					},
+				}, attributeGuestCount, -1, "attribute_exists(id)"),
 			},
 		},
-		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
 	}
 
-	output, err := session.DynamoDBresource.DeleteItem(input)
+	output, err := session.DynamoDBresource.TransactWriteItemsWithContext(ctx, input)
 
 	if err != nil {
-		if aeer, ok := err.(awserr.Error); ok {
-			if aeer.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
-				return &model.CustomError{
-					ErrorCode:   model.ErrorNoMatch,
-					ErrorDetail: fmt.Sprintf("listID=%s,userID=%s", listID, userID),
-				}
+		switch {
+		case isConditionFailure(err, 0):
+			return &model.NotFoundError{
+				Entity: model.EntityGuest,
+				ListID: listID,
+				Key:    userID,
 			}
+		case isConditionFailure(err, 1):
+			return session.explainListCondition(ctx, method, listID, "", "")
+		default:
+			return translateError(method, err)
 		}
-		return err
 	}
-	logConsumedCapacity(method, output.ConsumedCapacity)
+	for i, v := range output.ConsumedCapacity {
+		logConsumedCapacity(fmt.Sprintf("%s #%d", method, i), v)
+	}
 	return nil
 }
 
 // GetItemsByListID is a method
-func (session *DBSession) GetItemsByListID(listID string) ([]model.Item, error) {
+func (session *DBSession) GetItemsByListID(ctx context.Context, listID string) ([]model.Item, error) {
+	return session.getItemsByListID(ctx, listID, false)
+}
+
+func (session *DBSession) getItemsByListID(ctx context.Context, listID string, consistentRead bool) ([]model.Item, error) {
 
 	const method = "GetItemsByListID"
-	slowdown(session, method, "entry")
+	session.slowdown(ctx, method, "entry")
 	defer logEnd(method, time.Now())
-	log.Printf("%s (listID=%s)", method, listID)
+	log.Printf("%s (listID=%s,consistentRead=%t)", method, listID, consistentRead)
+	input := session.itemsByListIDQuery(listID)
+	input.ConsistentRead = aws.Bool(consistentRead)
 
-	input := &dynamodb.QueryInput{
+	output, err := session.queryAll(ctx, method, input)
+	if err != nil {
+		return []model.Item{}, err
+	}
+
+	var items = make([]model.Item, len(output))
+
+	for i, v := range output {
+		if err3 := dynamodbattribute.UnmarshalMap(v, &items[i]); err3 != nil {
+			return []model.Item{}, err3
+		}
+	}
+	model.SortItems(items)
+	return items, nil
+}
+
+func (session *DBSession) itemsByListIDQuery(listID string) *dynamodb.QueryInput {
+	return &dynamodb.QueryInput{
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
 			":list_id": {
 				S: aws.String(listID),
 			},
 		},
 		KeyConditionExpression: aws.String("list_id = :list_id"),
-		TableName:              aws.String("items"),
+		TableName:              aws.String(session.tables.Items),
 		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
 	}
+}
+
+// GetItemsByListIDPage is a method
+//
+// It queries items_by_order which, being an index, may show an item where
+// it was until a moment ago. The index is keyed on the order alone, so
+// items sharing one, such as those created with the constant 10 before
+// moves existed, come in no guaranteed order rather than by datetime as
+// in the other backends, though paging still visits each of them once.
+func (session *DBSession) GetItemsByListIDPage(ctx context.Context, listID string, cursor model.Cursor, max int64) ([]model.Item, model.Cursor, error) {
+	const method = "GetItemsByListIDPage"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (listID=%s,cursor=%s,max=%d)", method, listID, cursor, max)
 
-	output, err := session.DynamoDBresource.Query(input)
+	// The keys of items_by_order are followed by those of the table
+	var exclusiveStartKey map[string]*dynamodb.AttributeValue
+	last, err := model.ItemCursorKey(cursor)
 	if err != nil {
-		return []model.Item{}, err
+		return nil, "", err
+	}
+	if last != nil {
+		exclusiveStartKey = map[string]*dynamodb.AttributeValue{
+			"list_id":  {S: aws.String(listID)},
+			"order":    {N: aws.String(strconv.FormatFloat(last.Order, 'g', -1, 64))},
+			"datetime": {S: aws.String(last.Datetime)},
+		}
+	}
+	input := session.itemsByListIDQuery(listID)
+	input.IndexName = aws.String(session.indexes.ItemsByOrder)
+	output, lastEvaluatedKey, err := session.queryPage(ctx, method, input, exclusiveStartKey, max)
+	if err != nil {
+		return nil, "", err
 	}
 
-	logConsumedCapacity(method, output.ConsumedCapacity)
-
-	var items = make([]model.Item, *output.Count)
+	var items = make([]model.Item, len(output))
 
-	for i, v := range output.Items {
+	for i, v := range output {
 		if err3 := dynamodbattribute.UnmarshalMap(v, &items[i]); err3 != nil {
-			return []model.Item{}, err3
+			return nil, "", err3
 		}
 	}
-	return items, nil
+	if len(lastEvaluatedKey) == 0 {
+		return items, "", nil
+	}
+	var lastItem model.Item
+	if err := dynamodbattribute.UnmarshalMap(lastEvaluatedKey, &lastItem); err != nil {
+		return nil, "", err
+	}
+	return items, model.ItemCursor(lastItem), nil
+}
+
+// GetItem is a method
+//
+// It reads the items table consistently, unlike GetItemsByListIDPage,
+// so an item is as its last completed update left it.
+func (session *DBSession) GetItem(ctx context.Context, listID string, datetime string) (model.Item, error) {
+	const method = "GetItem"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (listID=%s,datetime=%s)", method, listID, datetime)
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(session.tables.Items),
+		Key: map[string]*dynamodb.AttributeValue{
+			"list_id": {
+				S: aws.String(listID),
+			},
+			"datetime": {
+				S: aws.String(datetime),
+			},
+		},
+		ConsistentRead:         aws.Bool(true),
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	}
+	output, err := session.DynamoDBresource.GetItemWithContext(ctx, input)
+	if err != nil {
+		return model.Item{}, translateError(method, err)
+	}
+	logConsumedCapacity(method, output.ConsumedCapacity)
+	if len(output.Item) == 0 {
+		return model.Item{}, &model.NotFoundError{
+			Entity: model.EntityItem,
+			ListID: listID,
+			Key:    datetime,
+		}
+	}
+	var item model.Item
+	if err := dynamodbattribute.UnmarshalMap(output.Item, &item); err != nil {
+		return model.Item{}, err
+	}
+	return item, nil
 }
 
 // CreateItem is a method
-func (session *DBSession) CreateItem(listID string, description string) error {
+func (session *DBSession) CreateItem(ctx context.Context, listID string, description string) (string, error) {
 
 	const method = "CreateItem"
-	slowdown(session, method, "entry")
+	session.slowdown(ctx, method, "entry")
 	defer logEnd(method, time.Now())
 	log.Printf("%s (listID=%s,description=%s)", method, listID, description)
 
-	datetime := time.Now().Format("2006-01-02T15:04:05.999999")
+	now := time.Now()
+	datetime := now.Format("2006-01-02T15:04:05.999999")
 
 	item := model.Item{
 		ListID:      listID,
 		Datetime:    datetime,
 		Description: description,
 		Done:        false,
-		Order:       10,
+		Order:       model.NewItemOrder(now),
 	}
 
 	itemAV, err := dynamodbattribute.MarshalMap(item)
 	if err != nil {
-		return err
+		return "", err
 	}
 	input := &dynamodb.TransactWriteItemsInput{
+		ClientRequestToken:     aws.String(uuid.New().String()),
 		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
 		TransactItems: []*dynamodb.TransactWriteItem{
 			{
-				ConditionCheck: &dynamodb.ConditionCheck{
-					TableName: aws.String("lists"),
-					Key: map[string]*dynamodb.AttributeValue{
-						"id": {
-							S: aws.String(listID),
-						},
+				Update: counterUpdate(session.tables.Lists, map[string]*dynamodb.AttributeValue{
+					"id": {
+						S: aws.String(listID),
 					},
-					ConditionExpression: aws.String("attribute_exists(id) AND attribute_not_exists(under_deletion)"),
-				},
+				}, attributeItemCount, 1, "attribute_exists(id) AND attribute_not_exists(under_deletion)"),
 			},
 			{
 				Put: &dynamodb.Put{
-					TableName:           aws.String("items"),
+					TableName:           aws.String(session.tables.Items),
 					Item:                itemAV,
 					ConditionExpression: aws.String("attribute_not_exists(list_id) AND attribute_not_exists(#d)"),
 					ExpressionAttributeNames: map[string]*string{
@@ -992,88 +1294,100 @@ func (session *DBSession) CreateItem(listID string, description string) error {
 		},
 	}
 
-	output, err2 := session.DynamoDBresource.TransactWriteItems(input)
+	output, err2 := session.DynamoDBresource.TransactWriteItemsWithContext(ctx, input)
 	if err2 != nil {
-		switch v := err2.(type) {
-		case *dynamodb.TransactionCanceledException:
-			switch {
-			case len(v.CancellationReasons) > 0 && *v.CancellationReasons[0].Code == "ConditionalCheckFailed":
-				return &model.CustomError{
-					ErrorCode:   model.ErrorNoMatch,
-					ErrorDetail: fmt.Sprintf("listID=%s", listID),
-				}
-			case len(v.CancellationReasons) > 1 && *v.CancellationReasons[1].Code == "ConditionalCheckFailed":
-				return &model.CustomError{
-					ErrorCode:   model.ErrorDuplicateID,
-					ErrorDetail: fmt.Sprintf("listID=%s,datetime=%s", listID, datetime),
-				}
-			default:
-				return err2
+		switch {
+		case isConditionFailure(err2, 0):
+			return "", session.explainListCondition(ctx, method, listID, "", "")
+		case isConditionFailure(err2, 1):
+			return "", &model.AlreadyExistsError{
+				Entity: model.EntityItem,
+				ListID: listID,
+				Key:    datetime,
 			}
 		default:
-			return err2
+			return "", translateError(method, err2)
 		}
 	}
 	for i, v := range output.ConsumedCapacity {
 		logConsumedCapacity(fmt.Sprintf("%s #%d", method, i), v)
 	}
-	return nil
+	return datetime, nil
 }
 
 // DeleteItem is a method
-func (session *DBSession) DeleteItem(listID string, datetime string) error {
+func (session *DBSession) DeleteItem(ctx context.Context, listID string, datetime string) error {
 
-	const method = "CreateList"
-	slowdown(session, method, "entry")
+	const method = "DeleteItem"
+	session.slowdown(ctx, method, "entry")
 	defer logEnd(method, time.Now())
-	log.Printf("%s (listID=%s,listID=%s)", method, listID, datetime)
+	log.Printf("%s (listID=%s,datetime=%s)", method, listID, datetime)
 
-	input := &dynamodb.DeleteItemInput{
-		TableName: aws.String("items"),
-		Key: map[string]*dynamodb.AttributeValue{
-			"list_id": {
-				S: aws.String(listID),
-			},
-			"datetime": {
-				S: aws.String(datetime),
-			},
-		},
-		ConditionExpression: aws.String("list_id = :l AND #d = :u"),
-		ExpressionAttributeNames: map[string]*string{
-			"#d": aws.String("datetime"),
-		},
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":l": {
-				S: aws.String(listID),
+	input := &dynamodb.TransactWriteItemsInput{
+		ClientRequestToken:     aws.String(uuid.New().String()),
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Delete: &dynamodb.Delete{
+					TableName: aws.String(session.tables.Items),
+					Key: map[string]*dynamodb.AttributeValue{
+						"list_id": {
+							S: aws.String(listID),
+						},
+						"datetime": {
+							S: aws.String(datetime),
+						},
+					},
+					ConditionExpression: aws.String("list_id = :l AND #d = :u"),
+					ExpressionAttributeNames: map[string]*string{
+						"#d": aws.String("datetime"),
+					},
+					ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+						":l": {
+							S: aws.String(listID),
+						},
+						":u": {
+							S: aws.String(datetime),
+						},
+					},
+				},
 			},
-			":u": {
-				S: aws.String(datetime),
+			{
+				Update: counterUpdate(session.tables.Lists, map[string]*dynamodb.AttributeValue{
+					"id": {
+						S: aws.String(listID),
+					},
+				}, attributeItemCount, -1, "attribute_exists(id)"),
 			},
 		},
-		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
 	}
-	output, err := session.DynamoDBresource.DeleteItem(input)
+	output, err := session.DynamoDBresource.TransactWriteItemsWithContext(ctx, input)
 
 	if err != nil {
-		if aeer, ok := err.(awserr.Error); ok {
-			if aeer.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
-				return &model.CustomError{
-					ErrorCode:   model.ErrorNoMatch,
-					ErrorDetail: fmt.Sprintf("listID=%s,datetime=%s", listID, datetime),
-				}
+		switch {
+		case isConditionFailure(err, 0):
+			return &model.NotFoundError{
+				Entity: model.EntityItem,
+				ListID: listID,
+				Key:    datetime,
 			}
+		case isConditionFailure(err, 1):
+			return session.explainListCondition(ctx, method, listID, "", "")
+		default:
+			return translateError(method, err)
 		}
-		return err
 	}
-	logConsumedCapacity(method, output.ConsumedCapacity)
+	for i, v := range output.ConsumedCapacity {
+		logConsumedCapacity(fmt.Sprintf("%s #%d", method, i), v)
+	}
 	return nil
 }
 
 // UpdateItem is a method
-func (session *DBSession) UpdateItem(listID string, datetime string, version int, description *string, done *bool) (int, error) {
+func (session *DBSession) UpdateItem(ctx context.Context, listID string, datetime string, version int, description *string, done *bool) (int, error) {
 
 	const method = "UpdateItem"
-	slowdown(session, method, "entry")
+	session.slowdown(ctx, method, "entry")
 	defer logEnd(method, time.Now())
 	log.Printf("%s (listID=%s,datetime=%s)", method, listID, datetime)
 
@@ -1097,7 +1411,7 @@ func (session *DBSession) UpdateItem(listID string, datetime string, version int
 	}
 
 	input := &dynamodb.UpdateItemInput{
-		TableName: aws.String("items"),
+		TableName: aws.String(session.tables.Items),
 		Key: map[string]*dynamodb.AttributeValue{
 			"list_id": {
 				S: aws.String(listID),
@@ -1107,31 +1421,25 @@ func (session *DBSession) UpdateItem(listID string, datetime string, version int
 			},
 		},
 		UpdateExpression:          aws.String(updateExpression),
-		ConditionExpression:       aws.String("version = :v"),
+		ConditionExpression:       aws.String("attribute_exists(list_id) AND version = :v"),
 		ExpressionAttributeValues: expressionAttributeValues,
 		ReturnValues:              aws.String(dynamodb.ReturnValueUpdatedNew),
 		ReturnConsumedCapacity:    aws.String(dynamodb.ReturnConsumedCapacityTotal),
 	}
 
-	output, err := session.DynamoDBresource.UpdateItem(input)
+	output, err := session.DynamoDBresource.UpdateItemWithContext(ctx, input)
 	if err != nil {
 		if aeer, ok := err.(awserr.Error); ok {
 			if aeer.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
-				return 0, &model.CustomError{
-					ErrorCode:   model.ErrorNoMatch,
-					ErrorDetail: fmt.Sprintf("listID=%s,datetime=%s,version=%d", listID, datetime, version),
-				}
+				return 0, session.updateItemConflict(ctx, listID, datetime, version)
 			}
 		}
-		return 0, err
+		return 0, translateError(method, err)
 	}
 	logConsumedCapacity(method, output.ConsumedCapacity)
 	v := output.Attributes["version"]
 	if v == nil {
-		return 0, &model.CustomError{
-			ErrorCode:   model.ErrorMissingAttribute,
-			ErrorDetail: "version",
-		}
+		return 0, fmt.Errorf("%s: version missing from the response", method)
 	}
 	newVersion, err2 := strconv.Atoi(*v.N)
 	if err2 != nil {
@@ -1140,3 +1448,76 @@ func (session *DBSession) UpdateItem(listID string, datetime string, version int
 
 	return newVersion, nil
 }
+
+// MoveItem is a method
+func (session *DBSession) MoveItem(ctx context.Context, listID string, datetime string, version int, position int) (int, error) {
+
+	const method = "MoveItem"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (listID=%s,datetime=%s,position=%d)", method, listID, datetime, position)
+
+	items, err := session.getItemsByListID(ctx, listID, true)
+	if err != nil {
+		return 0, err
+	}
+	moves, err := model.PlanMove(items, listID, datetime, version, position)
+	if err != nil {
+		return 0, err
+	}
+	update := func(move model.Move) *dynamodb.Update {
+		return orderUpdate(session.tables.Items, map[string]*dynamodb.AttributeValue{
+			"list_id": {
+				S: aws.String(listID),
+			},
+			"datetime": {
+				S: aws.String(move.Datetime),
+			},
+		}, move)
+	}
+	return session.moveItem(ctx, method, update, moves, func(ctx context.Context) error {
+		return session.updateItemConflict(ctx, listID, datetime, version)
+	})
+}
+
+// updateItemConflict tells a missing item from a stale version once the
+// condition of UpdateItem has failed
+func (session *DBSession) updateItemConflict(ctx context.Context, listID string, datetime string, version int) error {
+	const method = "UpdateItem"
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(session.tables.Items),
+		Key: map[string]*dynamodb.AttributeValue{
+			"list_id": {
+				S: aws.String(listID),
+			},
+			"datetime": {
+				S: aws.String(datetime),
+			},
+		},
+		ProjectionExpression:   aws.String("version"),
+		ConsistentRead:         aws.Bool(true),
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	}
+	output, err := session.DynamoDBresource.GetItemWithContext(ctx, input)
+	if err != nil {
+		return translateError(method, err)
+	}
+	logConsumedCapacity(method, output.ConsumedCapacity)
+	if len(output.Item) == 0 {
+		return &model.NotFoundError{
+			Entity: model.EntityItem,
+			ListID: listID,
+			Key:    datetime,
+		}
+	}
+	var item model.Item
+	if err := dynamodbattribute.UnmarshalMap(output.Item, &item); err != nil {
+		return err
+	}
+	return &model.VersionConflictError{
+		ListID:   listID,
+		Datetime: datetime,
+		Version:  version,
+		Current:  item.Version,
+	}
+}
@@ -0,0 +1,270 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/database/dynamo/dynamotest"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model/modeltest"
+)
+
+// testUsers are seeded so that the conformance suite has users to work with
+var testUsers = []model.User{
+	{ID: "7c2be6b9-746c-44be-bb33-78fb402ce6b8", Email: "gwalker@hotmail.com"},
+	{ID: "a10f9a38-f6dc-4e8a-ac1c-180486389697", Email: "wdean@gmail.com"},
+	{ID: "d5fc9ce9-5a5d-4ffc-9cc1-20a5c865bcc7", Email: "millsshawn@henry.com"},
+}
+
+// createTestTables provisions the declared schema and seeds testUsers
+func createTestTables(t *testing.T, client dynamodbiface.DynamoDBAPI, config Config) {
+	t.Helper()
+	if err := NewWithClient(client, config).ApplySchema(context.Background()); err != nil {
+		t.Fatalf("ApplySchema: %v", err)
+	}
+	names := config.TableNames()
+	for _, u := range testUsers {
+		_, err := client.PutItem(&dynamodb.PutItemInput{
+			TableName: aws.String(names.Users),
+			Item: map[string]*dynamodb.AttributeValue{
+				"id":    {S: aws.String(u.ID)},
+				"email": {S: aws.String(u.Email)},
+			},
+		})
+		if err != nil {
+			t.Fatalf("PutItem(%s): %v", u.ID, err)
+		}
+	}
+}
+
+// endpointClient returns a client of the DynamoDB stand-in, such as
+// DynamoDB Local, that DYNAMODB_ENDPOINT points at, e.g.
+// DYNAMODB_ENDPOINT=http://localhost:8000 go test ./...
+func endpointClient(t *testing.T) (dynamodbiface.DynamoDBAPI, Config) {
+	endpoint := os.Getenv("DYNAMODB_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_ENDPOINT not set")
+	}
+	config := DefaultConfig()
+	config.Endpoint = endpoint
+	config.Credentials = CredentialsStatic
+	config.TablePrefix = "conformance_"
+	awsSession, err := NewAWSSession(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dynamodb.New(awsSession), config
+}
+
+// TestConformance runs against DYNAMODB_ENDPOINT
+func TestConformance(t *testing.T) {
+	client, config := endpointClient(t)
+	runConformance(t, client, config)
+}
+
+// TestFakeConformance runs the same suite against dynamotest.Fake, which
+// needs no DynamoDB at all
+func TestFakeConformance(t *testing.T) {
+	runConformance(t, dynamotest.New(), DefaultConfig())
+}
+
+func runConformance(t *testing.T, client dynamodbiface.DynamoDBAPI, config Config) {
+	createTestTables(t, client, config)
+	modeltest.Run(t, func(t *testing.T) model.Interface {
+		return NewWithClient(client, config)
+	})
+	t.Run("ResumeDeletion", func(t *testing.T) {
+		modeltest.RunResumeDeletion(t, NewWithClient(client, config), func(t *testing.T, listID string) {
+			_, err := client.UpdateItem(&dynamodb.UpdateItemInput{
+				TableName: aws.String(config.TableNames().Lists),
+				Key: map[string]*dynamodb.AttributeValue{
+					"id": {S: aws.String(listID)},
+				},
+				UpdateExpression: aws.String("SET under_deletion = :t"),
+				ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+					":t": {BOOL: aws.Bool(true)},
+				},
+			})
+			if err != nil {
+				t.Fatalf("UpdateItem: %v", err)
+			}
+		})
+	})
+}
+
+// TestSingleTableConformance runs the same suite against
+// SingleTableSession
+func TestSingleTableConformance(t *testing.T) {
+	client, config := endpointClient(t)
+	runSingleTableConformance(t, client, config)
+}
+
+// TestFakeSingleTableConformance runs it against dynamotest.Fake
+func TestFakeSingleTableConformance(t *testing.T) {
+	runSingleTableConformance(t, dynamotest.New(), DefaultConfig())
+}
+
+func runSingleTableConformance(t *testing.T, client dynamodbiface.DynamoDBAPI, config Config) {
+	if err := NewSingleTableWithClient(client, config).ApplySchema(context.Background()); err != nil {
+		t.Fatalf("ApplySchema: %v", err)
+	}
+	for _, u := range testUsers {
+		item, err := record(u,
+			attributePK, prefixUser+u.ID,
+			attributeSK, sortKeyUser,
+			attributeGSI1PK, prefixEmail+u.Email,
+			attributeGSI1SK, sortKeyUser,
+			attributeGSI2PK, sortKeyUser,
+			attributeGSI2SK, u.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.PutItem(&dynamodb.PutItemInput{
+			TableName: aws.String(config.TableNames().Single),
+			Item:      item,
+		}); err != nil {
+			t.Fatalf("PutItem(%s): %v", u.ID, err)
+		}
+	}
+	modeltest.Run(t, func(t *testing.T) model.Interface {
+		return NewSingleTableWithClient(client, config)
+	})
+	t.Run("ResumeDeletion", func(t *testing.T) {
+		modeltest.RunResumeDeletion(t, NewSingleTableWithClient(client, config), func(t *testing.T, listID string) {
+			_, err := client.UpdateItem(&dynamodb.UpdateItemInput{
+				TableName:        aws.String(config.TableNames().Single),
+				Key:              listKey(listID),
+				UpdateExpression: aws.String("SET under_deletion = :t"),
+				ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+					":t": {BOOL: aws.Bool(true)},
+				},
+			})
+			if err != nil {
+				t.Fatalf("UpdateItem: %v", err)
+			}
+		})
+	})
+}
+
+// TestFakeApplySchema checks that ApplySchema adds an index missing from
+// an existing table
+func TestFakeApplySchema(t *testing.T) {
+	ctx := context.Background()
+	client := dynamotest.New()
+	config := DefaultConfig()
+	session := NewWithClient(client, config)
+	users := Schema(config)[0]
+	bare := users
+	bare.Indexes = nil
+	if _, err := client.CreateTable(bare.CreateTableInput(session.throughput())); err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+
+	differences, err := session.DiffSchema(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing := fmt.Sprintf("table %s is missing index %s", users.Name, users.Indexes[0].Name)
+	if len(differences) == 0 || differences[0] != missing {
+		t.Fatalf("DiffSchema = %v, expected %q first", differences, missing)
+	}
+	if err := session.ApplySchema(ctx); err != nil {
+		t.Fatalf("ApplySchema: %v", err)
+	}
+	if err := session.VerifySchema(ctx); err != nil {
+		t.Fatalf("VerifySchema: %v", err)
+	}
+}
+
+// TestFakeRepairCounters checks that RepairCounters puts right the
+// counters of the lists whose counters are wrong, and only those
+func TestFakeRepairCounters(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultConfig()
+	tests := []struct {
+		name    string
+		backend func(client dynamodbiface.DynamoDBAPI) Backend
+		key     func(listID string) (string, map[string]*dynamodb.AttributeValue)
+	}{
+		{
+			name: "DBSession",
+			backend: func(client dynamodbiface.DynamoDBAPI) Backend {
+				return NewWithClient(client, config)
+			},
+			key: func(listID string) (string, map[string]*dynamodb.AttributeValue) {
+				return config.TableNames().Lists, map[string]*dynamodb.AttributeValue{"id": {S: aws.String(listID)}}
+			},
+		},
+		{
+			name: "SingleTableSession",
+			backend: func(client dynamodbiface.DynamoDBAPI) Backend {
+				return NewSingleTableWithClient(client, config)
+			},
+			key: func(listID string) (string, map[string]*dynamodb.AttributeValue) {
+				return config.TableNames().Single, listKey(listID)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := dynamotest.New()
+			backend := test.backend(client)
+			if err := backend.ApplySchema(ctx); err != nil {
+				t.Fatalf("ApplySchema: %v", err)
+			}
+			userID, err := backend.CreateUser(ctx, "repair@example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var listIDs []string
+			for _, title := range []string{"Right", "Wrong"} {
+				listID, err := backend.CreateList(ctx, userID, title)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := backend.CreateItem(ctx, listID, "Milk"); err != nil {
+					t.Fatal(err)
+				}
+				listIDs = append(listIDs, listID)
+			}
+			// Lists get a guest counter once they have had a guest, or
+			// once repaired
+			if _, err := backend.RepairCounters(ctx); err != nil {
+				t.Fatalf("RepairCounters: %v", err)
+			}
+			table, key := test.key(listIDs[1])
+			if _, err := client.UpdateItem(&dynamodb.UpdateItemInput{
+				TableName:        aws.String(table),
+				Key:              key,
+				UpdateExpression: aws.String("SET item_count = :n"),
+				ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+					":n": {N: aws.String("7")},
+				},
+			}); err != nil {
+				t.Fatalf("UpdateItem: %v", err)
+			}
+
+			repaired, err := backend.RepairCounters(ctx)
+			if err != nil {
+				t.Fatalf("RepairCounters: %v", err)
+			}
+			if repaired != 1 {
+				t.Fatalf("RepairCounters = %d, expected 1", repaired)
+			}
+			lists, err := backend.GetAggregateListsByUserID(ctx, userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, list := range lists {
+				if list.ItemCount != 1 {
+					t.Fatalf("list %s has item count %d, expected 1", list.Title, list.ItemCount)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package dynamotest
+
+import (
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// maxListTables is the most table names ListTables returns at once
+const maxListTables = 100
+
+// DeleteTable is a method
+func (f *Fake) DeleteTable(input *dynamodb.DeleteTableInput) (*dynamodb.DeleteTableOutput, error) {
+	return f.DeleteTableWithContext(aws.BackgroundContext(), input)
+}
+
+// DeleteTableWithContext deletes a table and its items at once. The
+// description it returns is the last one, in the DELETING state.
+func (f *Fake) DeleteTableWithContext(ctx aws.Context, input *dynamodb.DeleteTableInput, _ ...request.Option) (*dynamodb.DeleteTableOutput, error) {
+	if err := check(ctx, input); err != nil {
+		return &dynamodb.DeleteTableOutput{}, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	t, err := f.table(input.TableName)
+	if err != nil {
+		return &dynamodb.DeleteTableOutput{}, resourceNotFound("Requested resource not found: Table: " + aws.StringValue(input.TableName) + " not found")
+	}
+	description := t.describe()
+	description.TableStatus = aws.String(dynamodb.TableStatusDeleting)
+	delete(f.tables, t.name())
+	return &dynamodb.DeleteTableOutput{TableDescription: description}, nil
+}
+
+// ListTables is a method
+func (f *Fake) ListTables(input *dynamodb.ListTablesInput) (*dynamodb.ListTablesOutput, error) {
+	return f.ListTablesWithContext(aws.BackgroundContext(), input)
+}
+
+// ListTablesWithContext returns the names of the tables in order, a page
+// at a time
+func (f *Fake) ListTablesWithContext(ctx aws.Context, input *dynamodb.ListTablesInput, _ ...request.Option) (*dynamodb.ListTablesOutput, error) {
+	if err := check(ctx, input); err != nil {
+		return &dynamodb.ListTablesOutput{}, err
+	}
+	limit := maxListTables
+	if input.Limit != nil {
+		if *input.Limit > maxListTables {
+			return &dynamodb.ListTablesOutput{}, validationError("1 validation error detected: Value at 'limit' failed to satisfy constraint: Member must have value less than or equal to 100")
+		}
+		limit = int(*input.Limit)
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var names []string
+	for name := range f.tables {
+		if input.ExclusiveStartTableName == nil || name > *input.ExclusiveStartTableName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	output := &dynamodb.ListTablesOutput{TableNames: aws.StringSlice([]string{})}
+	if len(names) > limit {
+		names = names[:limit]
+		output.LastEvaluatedTableName = aws.String(names[limit-1])
+	}
+	output.TableNames = append(output.TableNames, aws.StringSlice(names)...)
+	return output, nil
+}
+
+// DescribeTimeToLive is a method
+func (f *Fake) DescribeTimeToLive(input *dynamodb.DescribeTimeToLiveInput) (*dynamodb.DescribeTimeToLiveOutput, error) {
+	return f.DescribeTimeToLiveWithContext(aws.BackgroundContext(), input)
+}
+
+// DescribeTimeToLiveWithContext reports time to live as disabled, since
+// the fake never expires items
+func (f *Fake) DescribeTimeToLiveWithContext(ctx aws.Context, input *dynamodb.DescribeTimeToLiveInput, _ ...request.Option) (*dynamodb.DescribeTimeToLiveOutput, error) {
+	if err := check(ctx, input); err != nil {
+		return &dynamodb.DescribeTimeToLiveOutput{}, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if _, err := f.table(input.TableName); err != nil {
+		return &dynamodb.DescribeTimeToLiveOutput{}, err
+	}
+	return &dynamodb.DescribeTimeToLiveOutput{
+		TimeToLiveDescription: &dynamodb.TimeToLiveDescription{TimeToLiveStatus: aws.String(dynamodb.TimeToLiveStatusDisabled)},
+	}, nil
+}
+
+// DescribeContinuousBackups is a method
+func (f *Fake) DescribeContinuousBackups(input *dynamodb.DescribeContinuousBackupsInput) (*dynamodb.DescribeContinuousBackupsOutput, error) {
+	return f.DescribeContinuousBackupsWithContext(aws.BackgroundContext(), input)
+}
+
+// DescribeContinuousBackupsWithContext reports point in time recovery as
+// disabled, since the fake keeps no backups
+func (f *Fake) DescribeContinuousBackupsWithContext(ctx aws.Context, input *dynamodb.DescribeContinuousBackupsInput, _ ...request.Option) (*dynamodb.DescribeContinuousBackupsOutput, error) {
+	if err := check(ctx, input); err != nil {
+		return &dynamodb.DescribeContinuousBackupsOutput{}, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if _, err := f.table(input.TableName); err != nil {
+		return &dynamodb.DescribeContinuousBackupsOutput{}, &dynamodb.TableNotFoundException{
+			RespMetadata: metadata(),
+			Message_:     aws.String("Table not found: " + aws.StringValue(input.TableName)),
+		}
+	}
+	return &dynamodb.DescribeContinuousBackupsOutput{
+		ContinuousBackupsDescription: &dynamodb.ContinuousBackupsDescription{
+			ContinuousBackupsStatus: aws.String(dynamodb.ContinuousBackupsStatusEnabled),
+			PointInTimeRecoveryDescription: &dynamodb.PointInTimeRecoveryDescription{
+				PointInTimeRecoveryStatus: aws.String(dynamodb.PointInTimeRecoveryStatusDisabled),
+			},
+		},
+	}, nil
+}
+
+// tableByARN returns the table an ARN names
+func (f *Fake) tableByARN(arn *string) (*table, error) {
+	for name, t := range f.tables {
+		if tableARN(name) == aws.StringValue(arn) {
+			return t, nil
+		}
+	}
+	return nil, resourceNotFound("Requested resource not found: ResourceArn: " + aws.StringValue(arn) + " not found")
+}
+
+// ListTagsOfResource is a method
+func (f *Fake) ListTagsOfResource(input *dynamodb.ListTagsOfResourceInput) (*dynamodb.ListTagsOfResourceOutput, error) {
+	return f.ListTagsOfResourceWithContext(aws.BackgroundContext(), input)
+}
+
+// ListTagsOfResourceWithContext returns the tags of a table, sorted by
+// key, all at once
+func (f *Fake) ListTagsOfResourceWithContext(ctx aws.Context, input *dynamodb.ListTagsOfResourceInput, _ ...request.Option) (*dynamodb.ListTagsOfResourceOutput, error) {
+	if err := check(ctx, input); err != nil {
+		return &dynamodb.ListTagsOfResourceOutput{}, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	t, err := f.tableByARN(input.ResourceArn)
+	if err != nil {
+		return &dynamodb.ListTagsOfResourceOutput{}, err
+	}
+	tags := []*dynamodb.Tag{}
+	for _, key := range sortedKeys(t.tags) {
+		tags = append(tags, &dynamodb.Tag{Key: aws.String(key), Value: aws.String(t.tags[key])})
+	}
+	return &dynamodb.ListTagsOfResourceOutput{Tags: tags}, nil
+}
+
+// TagResource is a method
+func (f *Fake) TagResource(input *dynamodb.TagResourceInput) (*dynamodb.TagResourceOutput, error) {
+	return f.TagResourceWithContext(aws.BackgroundContext(), input)
+}
+
+// TagResourceWithContext adds tags to a table, or changes their values
+func (f *Fake) TagResourceWithContext(ctx aws.Context, input *dynamodb.TagResourceInput, _ ...request.Option) (*dynamodb.TagResourceOutput, error) {
+	if err := check(ctx, input); err != nil {
+		return &dynamodb.TagResourceOutput{}, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	t, err := f.tableByARN(input.ResourceArn)
+	if err != nil {
+		return &dynamodb.TagResourceOutput{}, err
+	}
+	for _, tag := range input.Tags {
+		t.tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return &dynamodb.TagResourceOutput{}, nil
+}
+
+// UntagResource is a method
+func (f *Fake) UntagResource(input *dynamodb.UntagResourceInput) (*dynamodb.UntagResourceOutput, error) {
+	return f.UntagResourceWithContext(aws.BackgroundContext(), input)
+}
+
+// UntagResourceWithContext removes tags from a table
+func (f *Fake) UntagResourceWithContext(ctx aws.Context, input *dynamodb.UntagResourceInput, _ ...request.Option) (*dynamodb.UntagResourceOutput, error) {
+	if err := check(ctx, input); err != nil {
+		return &dynamodb.UntagResourceOutput{}, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	t, err := f.tableByARN(input.ResourceArn)
+	if err != nil {
+		return &dynamodb.UntagResourceOutput{}, err
+	}
+	for _, key := range input.TagKeys {
+		delete(t.tags, aws.StringValue(key))
+	}
+	return &dynamodb.UntagResourceOutput{}, nil
+}
@@ -0,0 +1,396 @@
+package dynamotest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// item is a DynamoDB item, or the key of one
+type item = map[string]*dynamodb.AttributeValue
+
+// typeOf returns the name DynamoDB gives the type of v, such as "S" or
+// "BOOL", or "" if v holds nothing
+func typeOf(v *dynamodb.AttributeValue) string {
+	switch {
+	case v == nil:
+		return ""
+	case v.S != nil:
+		return dynamodb.ScalarAttributeTypeS
+	case v.N != nil:
+		return dynamodb.ScalarAttributeTypeN
+	case v.B != nil:
+		return dynamodb.ScalarAttributeTypeB
+	case v.BOOL != nil:
+		return "BOOL"
+	case v.NULL != nil:
+		return "NULL"
+	case v.M != nil:
+		return "M"
+	case v.L != nil:
+		return "L"
+	case v.SS != nil:
+		return "SS"
+	case v.NS != nil:
+		return "NS"
+	case v.BS != nil:
+		return "BS"
+	}
+	return ""
+}
+
+// countTypes returns the number of types v holds, which is 1 for a valid
+// value
+func countTypes(v *dynamodb.AttributeValue) int {
+	n := 0
+	for _, set := range []bool{v.S != nil, v.N != nil, v.B != nil, v.BOOL != nil, v.NULL != nil,
+		v.M != nil, v.L != nil, v.SS != nil, v.NS != nil, v.BS != nil} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// validateValue checks v the way DynamoDB checks the values it is sent
+func validateValue(v *dynamodb.AttributeValue) error {
+	if v == nil || countTypes(v) != 1 {
+		return validationError("Supplied AttributeValue has more than one datatypes set, must contain exactly one of the supported datatypes")
+	}
+	switch typeOf(v) {
+	case dynamodb.ScalarAttributeTypeN:
+		if _, err := parseNumber(*v.N); err != nil {
+			return err
+		}
+	case "NULL":
+		if !*v.NULL {
+			return validationError("One or more parameter values were invalid: Null attribute value types must have the value of true")
+		}
+	case "SS":
+		if err := validateSet("string", len(v.SS), func(i int) string { return *v.SS[i] }); err != nil {
+			return err
+		}
+	case "NS":
+		for _, n := range v.NS {
+			if _, err := parseNumber(*n); err != nil {
+				return err
+			}
+		}
+		if err := validateSet("number", len(v.NS), func(i int) string { return normalizeNumber(*v.NS[i]) }); err != nil {
+			return err
+		}
+	case "BS":
+		if err := validateSet("binary", len(v.BS), func(i int) string { return string(v.BS[i]) }); err != nil {
+			return err
+		}
+	case "M":
+		for _, element := range v.M {
+			if err := validateValue(element); err != nil {
+				return err
+			}
+		}
+	case "L":
+		for _, element := range v.L {
+			if err := validateValue(element); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateSet rejects empty sets and sets holding the same element twice
+func validateSet(kind string, n int, element func(i int) string) error {
+	if n == 0 {
+		return validationError(fmt.Sprintf("One or more parameter values were invalid: A %s set may not be empty", kind))
+	}
+	seen := make(map[string]bool, n)
+	for i := 0; i < n; i++ {
+		if seen[element(i)] {
+			return validationError("One or more parameter values were invalid: Input collection contains duplicates")
+		}
+		seen[element(i)] = true
+	}
+	return nil
+}
+
+// parseNumber parses a number attribute exactly, as DynamoDB keeps up to
+// 38 digits
+func parseNumber(s string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return nil, validationError(fmt.Sprintf("A value provided cannot be converted into a number: %q", s))
+	}
+	return r, nil
+}
+
+// formatNumber writes r without exponent or trailing zeros
+func formatNumber(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	s := strings.TrimRight(r.FloatString(38), "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// normalizeNumber returns the canonical form of a valid number, so that
+// "1.0" and "1" compare equal
+func normalizeNumber(s string) string {
+	r, err := parseNumber(s)
+	if err != nil {
+		return s
+	}
+	return formatNumber(r)
+}
+
+// equal compares a and b the way the = comparator does. Sets are equal
+// if they hold the same elements in any order.
+func equal(a *dynamodb.AttributeValue, b *dynamodb.AttributeValue) bool {
+	if a == nil || b == nil || typeOf(a) != typeOf(b) {
+		return false
+	}
+	switch typeOf(a) {
+	case dynamodb.ScalarAttributeTypeS:
+		return *a.S == *b.S
+	case dynamodb.ScalarAttributeTypeN:
+		return normalizeNumber(*a.N) == normalizeNumber(*b.N)
+	case dynamodb.ScalarAttributeTypeB:
+		return bytes.Equal(a.B, b.B)
+	case "BOOL":
+		return *a.BOOL == *b.BOOL
+	case "NULL":
+		return true
+	case "SS", "NS", "BS":
+		elementsA, elementsB := setElements(a), setElements(b)
+		if len(elementsA) != len(elementsB) {
+			return false
+		}
+		for i := range elementsA {
+			if elementsA[i] != elementsB[i] {
+				return false
+			}
+		}
+		return true
+	case "L":
+		if len(a.L) != len(b.L) {
+			return false
+		}
+		for i := range a.L {
+			if !equal(a.L[i], b.L[i]) {
+				return false
+			}
+		}
+		return true
+	case "M":
+		if len(a.M) != len(b.M) {
+			return false
+		}
+		for name, element := range a.M {
+			if !equal(element, b.M[name]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// setElements returns the elements of a set in a canonical form, sorted
+func setElements(v *dynamodb.AttributeValue) []string {
+	var elements []string
+	switch typeOf(v) {
+	case "SS":
+		for _, s := range v.SS {
+			elements = append(elements, elementString(&dynamodb.AttributeValue{S: s}))
+		}
+	case "NS":
+		for _, n := range v.NS {
+			elements = append(elements, elementString(&dynamodb.AttributeValue{N: n}))
+		}
+	case "BS":
+		for _, b := range v.BS {
+			elements = append(elements, elementString(&dynamodb.AttributeValue{B: b}))
+		}
+	}
+	sort.Strings(elements)
+	return elements
+}
+
+// elementString returns the canonical form of a string, number or binary
+// as an element of a set
+func elementString(v *dynamodb.AttributeValue) string {
+	switch typeOf(v) {
+	case dynamodb.ScalarAttributeTypeS:
+		return *v.S
+	case dynamodb.ScalarAttributeTypeN:
+		return normalizeNumber(*v.N)
+	case dynamodb.ScalarAttributeTypeB:
+		return string(v.B)
+	}
+	return ""
+}
+
+// isSet reports whether v is a string, number or binary set
+func isSet(v *dynamodb.AttributeValue) bool {
+	switch typeOf(v) {
+	case "SS", "NS", "BS":
+		return true
+	}
+	return false
+}
+
+// compare orders a and b, which must both be strings, numbers or
+// binaries. It reports false if they cannot be ordered.
+func compare(a *dynamodb.AttributeValue, b *dynamodb.AttributeValue) (int, bool) {
+	if a == nil || b == nil || typeOf(a) != typeOf(b) {
+		return 0, false
+	}
+	switch typeOf(a) {
+	case dynamodb.ScalarAttributeTypeS:
+		return strings.Compare(*a.S, *b.S), true
+	case dynamodb.ScalarAttributeTypeN:
+		ra, errA := parseNumber(*a.N)
+		rb, errB := parseNumber(*b.N)
+		if errA != nil || errB != nil {
+			return 0, false
+		}
+		return ra.Cmp(rb), true
+	case dynamodb.ScalarAttributeTypeB:
+		return bytes.Compare(a.B, b.B), true
+	}
+	return 0, false
+}
+
+// size implements the size function: the length of a string or binary,
+// or the number of elements of a set, list or map
+func size(v *dynamodb.AttributeValue) (int, bool) {
+	switch typeOf(v) {
+	case dynamodb.ScalarAttributeTypeS:
+		return len(*v.S), true
+	case dynamodb.ScalarAttributeTypeB:
+		return len(v.B), true
+	case "SS":
+		return len(v.SS), true
+	case "NS":
+		return len(v.NS), true
+	case "BS":
+		return len(v.BS), true
+	case "L":
+		return len(v.L), true
+	case "M":
+		return len(v.M), true
+	}
+	return 0, false
+}
+
+// itemSize approximates the size DynamoDB bills item for
+func itemSize(item item) int {
+	n := 0
+	for name, v := range item {
+		n += len(name) + valueSize(v)
+	}
+	return n
+}
+
+func valueSize(v *dynamodb.AttributeValue) int {
+	switch typeOf(v) {
+	case dynamodb.ScalarAttributeTypeS:
+		return len(*v.S)
+	case dynamodb.ScalarAttributeTypeN:
+		return len(*v.N)/2 + 1
+	case dynamodb.ScalarAttributeTypeB:
+		return len(v.B)
+	case "SS", "NS", "BS":
+		n := 0
+		for _, element := range setElements(v) {
+			n += len(element)
+		}
+		return n
+	case "L":
+		n := 3
+		for _, element := range v.L {
+			n += 1 + valueSize(element)
+		}
+		return n
+	case "M":
+		return 3 + itemSize(v.M)
+	}
+	return 1
+}
+
+// copyItem returns a deep copy of item, which the fake hands out so that
+// callers cannot change what it stores
+func copyItem(item item) item {
+	if item == nil {
+		return nil
+	}
+	copied := make(map[string]*dynamodb.AttributeValue, len(item))
+	for name, v := range item {
+		copied[name] = copyValue(v)
+	}
+	return copied
+}
+
+func copyValue(v *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	if v == nil {
+		return nil
+	}
+	copied := &dynamodb.AttributeValue{}
+	if v.S != nil {
+		copied.S = aws.String(*v.S)
+	}
+	if v.N != nil {
+		copied.N = aws.String(*v.N)
+	}
+	if v.B != nil {
+		copied.B = append([]byte{}, v.B...)
+	}
+	if v.BOOL != nil {
+		copied.BOOL = aws.Bool(*v.BOOL)
+	}
+	if v.NULL != nil {
+		copied.NULL = aws.Bool(*v.NULL)
+	}
+	if v.SS != nil {
+		copied.SS = aws.StringSlice(aws.StringValueSlice(v.SS))
+	}
+	if v.NS != nil {
+		copied.NS = aws.StringSlice(aws.StringValueSlice(v.NS))
+	}
+	if v.BS != nil {
+		copied.BS = make([][]byte, len(v.BS))
+		for i, b := range v.BS {
+			copied.BS[i] = append([]byte{}, b...)
+		}
+	}
+	if v.M != nil {
+		copied.M = copyItem(v.M)
+	}
+	if v.L != nil {
+		copied.L = make([]*dynamodb.AttributeValue, len(v.L))
+		for i, element := range v.L {
+			copied.L[i] = copyValue(element)
+		}
+	}
+	return copied
+}
+
+// keyString encodes the value of a key attribute so that equal keys, and
+// only those, have the same encoding
+func keyString(v *dynamodb.AttributeValue) string {
+	switch typeOf(v) {
+	case dynamodb.ScalarAttributeTypeS:
+		return "S" + *v.S
+	case dynamodb.ScalarAttributeTypeN:
+		return "N" + normalizeNumber(*v.N)
+	case dynamodb.ScalarAttributeTypeB:
+		return "B" + base64.StdEncoding.EncodeToString(v.B)
+	}
+	return ""
+}
@@ -0,0 +1,1120 @@
+package dynamotest
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// The expressions of a request share their placeholders, which DynamoDB
+// requires to be defined and used. Reserved words are not checked.
+
+// expressions parses the expressions of a request against its
+// ExpressionAttributeNames and ExpressionAttributeValues
+type expressions struct {
+	names      map[string]*string
+	values     map[string]*dynamodb.AttributeValue
+	usedNames  map[string]bool
+	usedValues map[string]bool
+}
+
+func newExpressions(names map[string]*string, values map[string]*dynamodb.AttributeValue) (*expressions, error) {
+	if names != nil && len(names) == 0 {
+		return nil, validationError("ExpressionAttributeNames must not be empty")
+	}
+	if values != nil && len(values) == 0 {
+		return nil, validationError("ExpressionAttributeValues must not be empty")
+	}
+	for _, key := range sortedKeys(values) {
+		if err := validateValue(values[key]); err != nil {
+			return nil, validationError(fmt.Sprintf("ExpressionAttributeValues contains invalid value: %s for key %s", message(err), key))
+		}
+	}
+	return &expressions{
+		names:      names,
+		values:     values,
+		usedNames:  make(map[string]bool),
+		usedValues: make(map[string]bool),
+	}, nil
+}
+
+// condition parses a condition, filter or key condition expression. A
+// nil text yields a nil condition, which holds for any item.
+func (e *expressions) condition(kind string, text *string) (condition, error) {
+	if text == nil {
+		return nil, nil
+	}
+	p, err := e.parser(kind, *text)
+	if err != nil {
+		return nil, err
+	}
+	c, err := p.condition()
+	if err != nil {
+		return nil, err
+	}
+	return c, p.end()
+}
+
+// update parses an update expression, which may be nil
+func (e *expressions) update(text *string) (*update, error) {
+	if text == nil {
+		return &update{}, nil
+	}
+	p, err := e.parser("UpdateExpression", *text)
+	if err != nil {
+		return nil, err
+	}
+	return p.update()
+}
+
+// projection parses a projection expression. A nil text yields nil,
+// which stands for every attribute.
+func (e *expressions) projection(text *string) ([]path, error) {
+	if text == nil {
+		return nil, nil
+	}
+	p, err := e.parser("ProjectionExpression", *text)
+	if err != nil {
+		return nil, err
+	}
+	var paths []path
+	for {
+		element, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, element)
+		if !p.symbol(",") {
+			break
+		}
+	}
+	if err := p.end(); err != nil {
+		return nil, err
+	}
+	return paths, checkOverlap("ProjectionExpression", paths)
+}
+
+// checkUnused rejects placeholders that no expression used
+func (e *expressions) checkUnused() error {
+	for _, name := range sortedKeys(e.names) {
+		if !e.usedNames[name] {
+			return validationError(fmt.Sprintf("Value provided in ExpressionAttributeNames unused in expressions: keys: {%s}", name))
+		}
+	}
+	for _, value := range sortedKeys(e.values) {
+		if !e.usedValues[value] {
+			return validationError(fmt.Sprintf("Value provided in ExpressionAttributeValues unused in expressions: keys: {%s}", value))
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]*string:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]*dynamodb.AttributeValue:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]string:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	// tokenWord is an attribute name, a keyword or a function
+	tokenWord
+	// tokenName is a #name placeholder
+	tokenName
+	// tokenValue is a :value placeholder
+	tokenValue
+	// tokenNumber is a list index
+	tokenNumber
+	tokenSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// lex splits text into tokens, the last of which is a tokenEnd
+func lex(kind string, text string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(text); {
+		c := text[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '#' || c == ':':
+			i++
+			for i < len(text) && isWordByte(text[i]) {
+				i++
+			}
+			if i == start+1 {
+				return nil, validationError(fmt.Sprintf("Invalid %s: Syntax error; token: %q, near: %q", kind, text[start:i], text[start:]))
+			}
+			tokenKind := tokenName
+			if c == ':' {
+				tokenKind = tokenValue
+			}
+			tokens = append(tokens, token{kind: tokenKind, text: text[start:i]})
+		case isDigit(c):
+			for i < len(text) && isDigit(text[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: text[start:i]})
+		case isWordByte(c):
+			for i < len(text) && isWordByte(text[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: text[start:i]})
+		case strings.HasPrefix(text[i:], "<>"), strings.HasPrefix(text[i:], "<="), strings.HasPrefix(text[i:], ">="):
+			i += 2
+			tokens = append(tokens, token{kind: tokenSymbol, text: text[start:i]})
+		case strings.IndexByte("(),.[]=<>+-", c) >= 0:
+			i++
+			tokens = append(tokens, token{kind: tokenSymbol, text: text[start:i]})
+		default:
+			return nil, validationError(fmt.Sprintf("Invalid %s: Syntax error; token: %q, near: %q", kind, text[start:start+1], text[start:]))
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, validationError(fmt.Sprintf("Invalid %s: The expression can not be empty;", kind))
+	}
+	return append(tokens, token{kind: tokenEnd}), nil
+}
+
+// parser is a recursive descent parser of the expression grammar
+type parser struct {
+	kind   string
+	tokens []token
+	pos    int
+	scope  *expressions
+}
+
+func (e *expressions) parser(kind string, text string) (*parser, error) {
+	tokens, err := lex(kind, text)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{kind: kind, tokens: tokens, scope: e}, nil
+}
+
+func (p *parser) peek(ahead int) token {
+	if p.pos+ahead >= len(p.tokens) {
+		return token{kind: tokenEnd}
+	}
+	return p.tokens[p.pos+ahead]
+}
+
+func (p *parser) next() token {
+	t := p.peek(0)
+	if t.kind != tokenEnd {
+		p.pos++
+	}
+	return t
+}
+
+// keyword consumes the next token if it is word, in any case
+func (p *parser) keyword(word string) bool {
+	if t := p.peek(0); t.kind == tokenWord && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// symbol consumes the next token if it is s
+func (p *parser) symbol(s string) bool {
+	if t := p.peek(0); t.kind == tokenSymbol && t.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// call reports whether a function call starts at the next token
+func (p *parser) call() bool {
+	next := p.peek(1)
+	return p.peek(0).kind == tokenWord && next.kind == tokenSymbol && next.text == "("
+}
+
+func (p *parser) expect(s string) error {
+	if !p.symbol(s) {
+		return p.syntaxError(p.next())
+	}
+	return nil
+}
+
+func (p *parser) end() error {
+	if t := p.next(); t.kind != tokenEnd {
+		return p.syntaxError(t)
+	}
+	return nil
+}
+
+func (p *parser) syntaxError(t token) error {
+	text := t.text
+	if t.kind == tokenEnd {
+		text = "<EOF>"
+	}
+	return validationError(fmt.Sprintf("Invalid %s: Syntax error; token: %q", p.kind, text))
+}
+
+func (p *parser) path() (path, error) {
+	first, err := p.pathName()
+	if err != nil {
+		return nil, err
+	}
+	result := path{first}
+	for {
+		switch {
+		case p.symbol("."):
+			element, err := p.pathName()
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, element)
+		case p.symbol("["):
+			t := p.next()
+			if t.kind != tokenNumber {
+				return nil, p.syntaxError(t)
+			}
+			index, err := strconv.Atoi(t.text)
+			if err != nil {
+				return nil, p.syntaxError(t)
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			result = append(result, pathElement{index: index, isIndex: true})
+		default:
+			return result, nil
+		}
+	}
+}
+
+func (p *parser) pathName() (pathElement, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenWord:
+		return pathElement{name: t.text}, nil
+	case tokenName:
+		name, ok := p.scope.names[t.text]
+		if !ok || name == nil {
+			return pathElement{}, validationError(fmt.Sprintf("Invalid %s: An expression attribute name used in the document path is not defined; attribute name: %s", p.kind, t.text))
+		}
+		p.scope.usedNames[t.text] = true
+		return pathElement{name: *name}, nil
+	}
+	return pathElement{}, p.syntaxError(t)
+}
+
+func (p *parser) value(t token) (*dynamodb.AttributeValue, error) {
+	v, ok := p.scope.values[t.text]
+	if !ok {
+		return nil, validationError(fmt.Sprintf("Invalid %s: An expression attribute value used in expression is not defined; attribute value: %s", p.kind, t.text))
+	}
+	p.scope.usedValues[t.text] = true
+	return v, nil
+}
+
+// condition is a parsed condition expression
+type condition interface {
+	eval(item item) bool
+}
+
+// holds reports whether item, which is nil if missing, meets c
+func holds(c condition, item item) bool {
+	return c == nil || c.eval(item)
+}
+
+type andCondition struct {
+	left  condition
+	right condition
+}
+
+func (c andCondition) eval(item item) bool {
+	return c.left.eval(item) && c.right.eval(item)
+}
+
+type orCondition struct {
+	left  condition
+	right condition
+}
+
+func (c orCondition) eval(item item) bool {
+	return c.left.eval(item) || c.right.eval(item)
+}
+
+type notCondition struct {
+	condition condition
+}
+
+func (c notCondition) eval(item item) bool {
+	return !c.condition.eval(item)
+}
+
+// comparison compares two operands with =, <>, <, <=, > or >=. Values of
+// different types are never equal and cannot be ordered.
+type comparison struct {
+	operator string
+	left     operand
+	right    operand
+}
+
+func (c comparison) eval(item item) bool {
+	a, b := c.left.value(item), c.right.value(item)
+	switch c.operator {
+	case "=":
+		return equal(a, b)
+	case "<>":
+		return !equal(a, b)
+	}
+	order, ok := compare(a, b)
+	if !ok {
+		return false
+	}
+	switch c.operator {
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	}
+	return order >= 0
+}
+
+type between struct {
+	operand operand
+	low     operand
+	high    operand
+}
+
+func (c between) eval(item item) bool {
+	v := c.operand.value(item)
+	low, okLow := compare(v, c.low.value(item))
+	high, okHigh := compare(v, c.high.value(item))
+	return okLow && okHigh && low >= 0 && high <= 0
+}
+
+type in struct {
+	operand operand
+	list    []operand
+}
+
+func (c in) eval(item item) bool {
+	v := c.operand.value(item)
+	for _, candidate := range c.list {
+		if equal(v, candidate.value(item)) {
+			return true
+		}
+	}
+	return false
+}
+
+// function is a call to one of the functions that make conditions
+type function struct {
+	name string
+	path path
+	arg  operand
+}
+
+func (c function) eval(item item) bool {
+	v := c.path.get(item)
+	switch c.name {
+	case "attribute_exists":
+		return v != nil
+	case "attribute_not_exists":
+		return v == nil
+	case "attribute_type":
+		t := c.arg.value(item)
+		return v != nil && t != nil && t.S != nil && typeOf(v) == *t.S
+	case "begins_with":
+		prefix := c.arg.value(item)
+		switch {
+		case typeOf(v) == dynamodb.ScalarAttributeTypeS && typeOf(prefix) == dynamodb.ScalarAttributeTypeS:
+			return strings.HasPrefix(*v.S, *prefix.S)
+		case typeOf(v) == dynamodb.ScalarAttributeTypeB && typeOf(prefix) == dynamodb.ScalarAttributeTypeB:
+			return bytes.HasPrefix(v.B, prefix.B)
+		}
+		return false
+	case "contains":
+		return contains(v, c.arg.value(item))
+	}
+	return false
+}
+
+// contains implements the contains function: a substring of a string, an
+// element of a set or of a list
+func contains(v *dynamodb.AttributeValue, element *dynamodb.AttributeValue) bool {
+	if v == nil || element == nil {
+		return false
+	}
+	switch typeOf(v) {
+	case dynamodb.ScalarAttributeTypeS:
+		return element.S != nil && strings.Contains(*v.S, *element.S)
+	case dynamodb.ScalarAttributeTypeB:
+		return element.B != nil && bytes.Contains(v.B, element.B)
+	case "SS", "NS", "BS":
+		if typeOf(v) != typeOf(element)+"S" {
+			return false
+		}
+		for _, e := range setElements(v) {
+			if e == elementString(element) {
+				return true
+			}
+		}
+	case "L":
+		for _, e := range v.L {
+			if equal(e, element) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// operand yields a value, or nil if it refers to a missing attribute
+type operand interface {
+	value(item item) *dynamodb.AttributeValue
+}
+
+type pathOperand struct {
+	path path
+}
+
+func (o pathOperand) value(item item) *dynamodb.AttributeValue {
+	return o.path.get(item)
+}
+
+type valueOperand struct {
+	v *dynamodb.AttributeValue
+}
+
+func (o valueOperand) value(item item) *dynamodb.AttributeValue {
+	return o.v
+}
+
+type sizeOperand struct {
+	path path
+}
+
+func (o sizeOperand) value(item item) *dynamodb.AttributeValue {
+	n, ok := size(o.path.get(item))
+	if !ok {
+		return nil
+	}
+	return &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(n))}
+}
+
+var comparators = map[string]bool{"=": true, "<>": true, "<": true, "<=": true, ">": true, ">=": true}
+
+var typeNames = map[string]bool{"S": true, "N": true, "B": true, "BOOL": true, "NULL": true,
+	"M": true, "L": true, "SS": true, "NS": true, "BS": true}
+
+func (p *parser) condition() (condition, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = orCondition{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (condition, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = andCondition{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) not() (condition, error) {
+	if p.keyword("NOT") {
+		c, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return notCondition{condition: c}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (condition, error) {
+	if p.symbol("(") {
+		c, err := p.condition()
+		if err != nil {
+			return nil, err
+		}
+		return c, p.expect(")")
+	}
+	if p.call() && p.peek(0).text != "size" {
+		name := p.next().text
+		p.next()
+		return p.function(name)
+	}
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.keyword("BETWEEN"):
+		low, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword("AND") {
+			return nil, p.syntaxError(p.next())
+		}
+		high, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		lowValue, lowConstant := low.(valueOperand)
+		highValue, highConstant := high.(valueOperand)
+		if lowConstant && highConstant {
+			if order, ok := compare(lowValue.v, highValue.v); ok && order > 0 {
+				return nil, validationError(fmt.Sprintf("Invalid %s: The BETWEEN operator requires upper bound to be greater than or equal to lower bound", p.kind))
+			}
+		}
+		return between{operand: left, low: low, high: high}, nil
+	case p.keyword("IN"):
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		var list []operand
+		for {
+			o, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, o)
+			if !p.symbol(",") {
+				break
+			}
+		}
+		return in{operand: left, list: list}, p.expect(")")
+	}
+	t := p.next()
+	if t.kind != tokenSymbol || !comparators[t.text] {
+		return nil, p.syntaxError(t)
+	}
+	right, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	return comparison{operator: t.text, left: left, right: right}, nil
+}
+
+// function parses the arguments of the named function, whose opening
+// parenthesis has been read
+func (p *parser) function(name string) (condition, error) {
+	switch name {
+	case "attribute_exists", "attribute_not_exists", "attribute_type", "begins_with", "contains":
+	default:
+		return nil, validationError(fmt.Sprintf("Invalid %s: Invalid function name; function: %s", p.kind, name))
+	}
+	f := function{name: name}
+	var err error
+	if f.path, err = p.path(); err != nil {
+		return nil, err
+	}
+	if name != "attribute_exists" && name != "attribute_not_exists" {
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		if f.arg, err = p.operand(); err != nil {
+			return nil, err
+		}
+		if t, ok := f.arg.(valueOperand); ok && name == "attribute_type" && (t.v.S == nil || !typeNames[*t.v.S]) {
+			return nil, validationError(fmt.Sprintf("Invalid %s: Invalid attribute type name found; type: %s", p.kind, aws.StringValue(t.v.S)))
+		}
+	}
+	return f, p.expect(")")
+}
+
+func (p *parser) operand() (operand, error) {
+	t := p.peek(0)
+	switch {
+	case t.kind == tokenValue:
+		p.next()
+		v, err := p.value(t)
+		if err != nil {
+			return nil, err
+		}
+		return valueOperand{v: v}, nil
+	case p.call() && t.text == "size":
+		p.next()
+		p.next()
+		target, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		return sizeOperand{path: target}, p.expect(")")
+	case p.call():
+		return nil, validationError(fmt.Sprintf("Invalid %s: The function is not allowed to be used this way in an expression; function: %s", p.kind, t.text))
+	case t.kind == tokenWord || t.kind == tokenName:
+		target, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		return pathOperand{path: target}, nil
+	}
+	return nil, p.syntaxError(p.next())
+}
+
+// keyCondition checks that c is an equality on hashKey, possibly along
+// with a condition on rangeKey, as Query requires
+func keyCondition(c condition, hashKey string, rangeKey string) error {
+	parts := []condition{c}
+	if and, ok := c.(andCondition); ok {
+		parts = []condition{and.left, and.right}
+	}
+	hashFound, rangeFound := false, false
+	for _, part := range parts {
+		name, equality, ok := keyPart(part)
+		switch {
+		case !ok:
+			return validationError("Query key condition not supported")
+		case name == hashKey && equality && !hashFound:
+			hashFound = true
+		case name == rangeKey && rangeKey != "" && !rangeFound:
+			rangeFound = true
+		default:
+			return validationError("Query key condition not supported")
+		}
+	}
+	if !hashFound {
+		return validationError("Query condition missed key schema element: " + hashKey)
+	}
+	return nil
+}
+
+// keyPart returns the attribute a condition allowed in key conditions is
+// on, and whether it is an equality
+func keyPart(c condition) (string, bool, bool) {
+	attribute := func(o operand) (string, bool) {
+		if o, ok := o.(pathOperand); ok && len(o.path) == 1 {
+			return o.path[0].name, true
+		}
+		return "", false
+	}
+	constant := func(o operand) bool {
+		_, ok := o.(valueOperand)
+		return ok
+	}
+	switch c := c.(type) {
+	case comparison:
+		name, ok := attribute(c.left)
+		return name, c.operator == "=", ok && constant(c.right) && c.operator != "<>"
+	case between:
+		name, ok := attribute(c.operand)
+		return name, false, ok && constant(c.low) && constant(c.high)
+	case function:
+		return c.path[0].name, false, c.name == "begins_with" && len(c.path) == 1 && constant(c.arg)
+	}
+	return "", false, false
+}
+
+// update is a parsed update expression
+type update struct {
+	set    []setAction
+	remove []path
+	add    []addAction
+	delete []addAction
+}
+
+type setAction struct {
+	path  path
+	value setValue
+}
+
+type addAction struct {
+	path  path
+	value *dynamodb.AttributeValue
+}
+
+// setValue is what SET assigns: an operand, a sum or difference of two,
+// or the result of if_not_exists or list_append
+type setValue interface {
+	value(item item) (*dynamodb.AttributeValue, error)
+}
+
+type operandValue struct {
+	operand operand
+}
+
+func (v operandValue) value(item item) (*dynamodb.AttributeValue, error) {
+	result := v.operand.value(item)
+	if result == nil {
+		return nil, validationError("The provided expression refers to an attribute that does not exist in the item")
+	}
+	return result, nil
+}
+
+type arithmetic struct {
+	operator string
+	left     setValue
+	right    setValue
+}
+
+func (v arithmetic) value(item item) (*dynamodb.AttributeValue, error) {
+	a, err := v.left.value(item)
+	if err != nil {
+		return nil, err
+	}
+	b, err := v.right.value(item)
+	if err != nil {
+		return nil, err
+	}
+	return addNumbers(a, b, v.operator == "-")
+}
+
+// addNumbers returns a + b, or a - b if subtract is set
+func addNumbers(a *dynamodb.AttributeValue, b *dynamodb.AttributeValue, subtract bool) (*dynamodb.AttributeValue, error) {
+	if a.N == nil || b.N == nil {
+		return nil, incorrectOperand()
+	}
+	ra, err := parseNumber(*a.N)
+	if err != nil {
+		return nil, err
+	}
+	rb, err := parseNumber(*b.N)
+	if err != nil {
+		return nil, err
+	}
+	if subtract {
+		rb.Neg(rb)
+	}
+	return &dynamodb.AttributeValue{N: aws.String(formatNumber(ra.Add(ra, rb)))}, nil
+}
+
+type ifNotExists struct {
+	path     path
+	fallback setValue
+}
+
+func (v ifNotExists) value(item item) (*dynamodb.AttributeValue, error) {
+	if current := v.path.get(item); current != nil {
+		return current, nil
+	}
+	return v.fallback.value(item)
+}
+
+type listAppend struct {
+	first  setValue
+	second setValue
+}
+
+func (v listAppend) value(item item) (*dynamodb.AttributeValue, error) {
+	a, err := v.first.value(item)
+	if err != nil {
+		return nil, err
+	}
+	b, err := v.second.value(item)
+	if err != nil {
+		return nil, err
+	}
+	if a.L == nil || b.L == nil {
+		return nil, incorrectOperand()
+	}
+	list := append(append([]*dynamodb.AttributeValue{}, a.L...), b.L...)
+	return &dynamodb.AttributeValue{L: list}, nil
+}
+
+func incorrectOperand() error {
+	return validationError("An operand in the update expression has an incorrect data type")
+}
+
+func (p *parser) update() (*update, error) {
+	u := &update{}
+	seen := make(map[string]bool)
+	for p.peek(0).kind != tokenEnd {
+		t := p.next()
+		clause := strings.ToUpper(t.text)
+		if t.kind != tokenWord || (clause != "SET" && clause != "REMOVE" && clause != "ADD" && clause != "DELETE") {
+			return nil, p.syntaxError(t)
+		}
+		if seen[clause] {
+			return nil, validationError(fmt.Sprintf("Invalid UpdateExpression: The %q section can only be used once in an update expression;", clause))
+		}
+		seen[clause] = true
+		for {
+			target, err := p.path()
+			if err != nil {
+				return nil, err
+			}
+			switch clause {
+			case "SET":
+				if err := p.expect("="); err != nil {
+					return nil, err
+				}
+				value, err := p.setValue()
+				if err != nil {
+					return nil, err
+				}
+				u.set = append(u.set, setAction{path: target, value: value})
+			case "REMOVE":
+				u.remove = append(u.remove, target)
+			default:
+				t := p.next()
+				if t.kind != tokenValue {
+					return nil, p.syntaxError(t)
+				}
+				value, err := p.value(t)
+				if err != nil {
+					return nil, err
+				}
+				valueType := typeOf(value)
+				if !isSet(value) && (clause == "DELETE" || valueType != dynamodb.ScalarAttributeTypeN) {
+					return nil, validationError(fmt.Sprintf("Invalid UpdateExpression: Incorrect operand type for operator or function; operator: %s, operand type: %s", clause, valueType))
+				}
+				if clause == "ADD" {
+					u.add = append(u.add, addAction{path: target, value: value})
+				} else {
+					u.delete = append(u.delete, addAction{path: target, value: value})
+				}
+			}
+			if !p.symbol(",") {
+				break
+			}
+		}
+	}
+	if len(seen) == 0 {
+		return nil, p.syntaxError(p.next())
+	}
+	return u, checkOverlap("UpdateExpression", u.paths(true))
+}
+
+func (p *parser) setValue() (setValue, error) {
+	left, err := p.setOperand()
+	if err != nil {
+		return nil, err
+	}
+	for _, operator := range []string{"+", "-"} {
+		if p.symbol(operator) {
+			right, err := p.setOperand()
+			if err != nil {
+				return nil, err
+			}
+			return arithmetic{operator: operator, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) setOperand() (setValue, error) {
+	if !p.call() {
+		o, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return operandValue{operand: o}, nil
+	}
+	name := p.next().text
+	p.next()
+	switch name {
+	case "if_not_exists":
+		target, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		fallback, err := p.setOperand()
+		if err != nil {
+			return nil, err
+		}
+		return ifNotExists{path: target, fallback: fallback}, p.expect(")")
+	case "list_append":
+		first, err := p.setOperand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		second, err := p.setOperand()
+		if err != nil {
+			return nil, err
+		}
+		return listAppend{first: first, second: second}, p.expect(")")
+	}
+	return nil, validationError(fmt.Sprintf("Invalid UpdateExpression: Invalid function name; function: %s", name))
+}
+
+// paths returns the paths u changes, including those it removes if
+// removed is set
+func (u *update) paths(removed bool) []path {
+	var paths []path
+	for _, action := range u.set {
+		paths = append(paths, action.path)
+	}
+	if removed {
+		paths = append(paths, u.remove...)
+	}
+	for _, action := range append(append([]addAction{}, u.add...), u.delete...) {
+		paths = append(paths, action.path)
+	}
+	return paths
+}
+
+// apply changes item in place. The values SET assigns are worked out
+// from item as it was before any change, as DynamoDB does.
+func (u *update) apply(item item) error {
+	values := make([]*dynamodb.AttributeValue, len(u.set))
+	for i, action := range u.set {
+		v, err := action.value.value(item)
+		if err != nil {
+			return err
+		}
+		values[i] = copyValue(v)
+	}
+	for i, action := range u.set {
+		if err := action.path.set(item, values[i]); err != nil {
+			return err
+		}
+	}
+	// Removing list elements from the last keeps the indexes of the
+	// others valid
+	removals := append([]path{}, u.remove...)
+	sort.SliceStable(removals, func(i, j int) bool {
+		return lastIndex(removals[i]) > lastIndex(removals[j])
+	})
+	for _, target := range removals {
+		target.remove(item)
+	}
+	for _, action := range u.add {
+		current := action.path.get(item)
+		var result *dynamodb.AttributeValue
+		switch {
+		case current == nil:
+			result = action.value
+		case typeOf(current) != typeOf(action.value):
+			return incorrectOperand()
+		case current.N != nil:
+			sum, err := addNumbers(current, action.value, false)
+			if err != nil {
+				return err
+			}
+			result = sum
+		default:
+			result = combineSets(current, action.value, true)
+		}
+		if err := action.path.set(item, copyValue(result)); err != nil {
+			return err
+		}
+	}
+	for _, action := range u.delete {
+		current := action.path.get(item)
+		switch {
+		case current == nil:
+		case typeOf(current) != typeOf(action.value):
+			return incorrectOperand()
+		default:
+			result := combineSets(current, action.value, false)
+			if result == nil {
+				action.path.remove(item)
+			} else if err := action.path.set(item, result); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func lastIndex(p path) int {
+	if last := p[len(p)-1]; last.isIndex {
+		return last.index
+	}
+	return -1
+}
+
+// combineSets returns the union of two sets of the same type, or the
+// elements of a missing from b, which is nil if there are none
+func combineSets(a *dynamodb.AttributeValue, b *dynamodb.AttributeValue, union bool) *dynamodb.AttributeValue {
+	inB := make(map[string]bool)
+	for _, e := range setElements(b) {
+		inB[e] = true
+	}
+	result := &dynamodb.AttributeValue{}
+	keep := func(e string) bool {
+		return !inB[e]
+	}
+	switch typeOf(a) {
+	case "SS":
+		for _, s := range a.SS {
+			if keep(*s) {
+				result.SS = append(result.SS, aws.String(*s))
+			}
+		}
+		if union {
+			result.SS = append(result.SS, b.SS...)
+		}
+	case "NS":
+		for _, n := range a.NS {
+			if keep(normalizeNumber(*n)) {
+				result.NS = append(result.NS, aws.String(*n))
+			}
+		}
+		if union {
+			result.NS = append(result.NS, b.NS...)
+		}
+	case "BS":
+		for _, bytes := range a.BS {
+			if keep(string(bytes)) {
+				result.BS = append(result.BS, bytes)
+			}
+		}
+		if union {
+			result.BS = append(result.BS, b.BS...)
+		}
+	}
+	if countTypes(result) == 0 {
+		return nil
+	}
+	return result
+}
@@ -0,0 +1,578 @@
+// Package dynamotest provides Fake, an in-process stand-in for DynamoDB,
+// so that the dynamo backends can be tested without AWS or DynamoDB
+// Local. cmd/emulator serves it over HTTP to other tools.
+package dynamotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/private/protocol"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/google/uuid"
+)
+
+// Fake implements dynamodbiface.DynamoDBAPI in memory for the operations
+// the dynamo package uses: CreateTable, DescribeTable, UpdateTable,
+// GetItem, PutItem, UpdateItem, DeleteItem, Query, Scan, BatchGetItem,
+// BatchWriteItem and TransactWriteItems, with or without a context, and
+// the WaitUntilTableExists waiter. It also implements the table
+// management calls that tools such as Terraform make: DeleteTable,
+// ListTables, DescribeTimeToLive, DescribeContinuousBackups,
+// ListTagsOfResource, TagResource and UntagResource. Calling any other
+// operation panics. Like the SDK's client, it returns an empty output
+// along with errors.
+//
+// Requests are validated and answered as DynamoDB does, errors included,
+// with these simplifications: tables and indexes are active as soon as
+// they are created, every read is consistent, pages are bounded by Limit
+// alone rather than also by the 1 MB DynamoDB reads at most, and reserved
+// words may be used as attribute names.
+type Fake struct {
+	dynamodbiface.DynamoDBAPI
+
+	mutex  sync.Mutex
+	tables map[string]*table
+	// tokens remembers the transactions that came with a
+	// ClientRequestToken, so that retrying them does nothing
+	tokens map[string]transaction
+}
+
+// transaction is what Fake remembers of a transaction it committed
+type transaction struct {
+	fingerprint string
+	time        time.Time
+}
+
+// tokenLifetime is how long a ClientRequestToken stays valid
+const tokenLifetime = 10 * time.Minute
+
+// New creates a Fake without any table
+func New() *Fake {
+	return &Fake{
+		tables: make(map[string]*table),
+		tokens: make(map[string]transaction),
+	}
+}
+
+// table holds the description, the tags and the items of a table, by
+// key
+type table struct {
+	description *dynamodb.TableDescription
+	tags        map[string]string
+	items       map[string]item
+}
+
+func requestID() string {
+	return strings.ToUpper(strings.Replace(uuid.New().String(), "-", "", -1))
+}
+
+func metadata() protocol.ResponseMetadata {
+	return protocol.ResponseMetadata{StatusCode: http.StatusBadRequest, RequestID: requestID()}
+}
+
+func validationError(message string) error {
+	return awserr.NewRequestFailure(awserr.New("ValidationException", message, nil), http.StatusBadRequest, requestID())
+}
+
+func resourceNotFound(message string) error {
+	return &dynamodb.ResourceNotFoundException{RespMetadata: metadata(), Message_: aws.String(message)}
+}
+
+func tableNotFound() error {
+	return resourceNotFound("Requested resource not found")
+}
+
+func conditionalCheckFailed() error {
+	return &dynamodb.ConditionalCheckFailedException{RespMetadata: metadata(), Message_: aws.String("The conditional request failed")}
+}
+
+// message returns the message of an SDK error without its code
+func message(err error) string {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Message()
+	}
+	return err.Error()
+}
+
+// check fails as the SDK does if ctx is done or input lacks required
+// parameters, before anything is sent
+func check(ctx aws.Context, input interface{ Validate() error }) error {
+	if err := ctx.Err(); err != nil {
+		return awserr.New(request.CanceledErrorCode, "request context canceled", err)
+	}
+	return input.Validate()
+}
+
+func (f *Fake) table(name *string) (*table, error) {
+	t, ok := f.tables[aws.StringValue(name)]
+	if !ok {
+		return nil, tableNotFound()
+	}
+	return t, nil
+}
+
+// keySchema returns the attributes making up a key
+func keySchema(elements []*dynamodb.KeySchemaElement) (string, string) {
+	var hashKey, rangeKey string
+	for _, element := range elements {
+		switch aws.StringValue(element.KeyType) {
+		case dynamodb.KeyTypeHash:
+			hashKey = aws.StringValue(element.AttributeName)
+		case dynamodb.KeyTypeRange:
+			rangeKey = aws.StringValue(element.AttributeName)
+		}
+	}
+	return hashKey, rangeKey
+}
+
+// checkKeySchema requires a hash key, optionally followed by a range key,
+// made of defined attributes
+func checkKeySchema(elements []*dynamodb.KeySchemaElement, types map[string]string) error {
+	if len(elements) == 0 || len(elements) > 2 ||
+		aws.StringValue(elements[0].KeyType) != dynamodb.KeyTypeHash ||
+		(len(elements) == 2 && aws.StringValue(elements[1].KeyType) != dynamodb.KeyTypeRange) {
+		return validationError("Invalid KeySchema: The first KeySchemaElement is not a HASH key type")
+	}
+	for _, element := range elements {
+		if _, ok := types[aws.StringValue(element.AttributeName)]; !ok {
+			return validationError(fmt.Sprintf("One or more parameter values were invalid: Some index key attributes are not defined in AttributeDefinitions. Keys: [%s]",
+				aws.StringValue(element.AttributeName)))
+		}
+	}
+	return nil
+}
+
+// checkDefinitions requires attribute definitions to be those of the key
+// attributes of the table and its indexes, no more and no less
+func checkDefinitions(definitions []*dynamodb.AttributeDefinition, schemas ...[]*dynamodb.KeySchemaElement) error {
+	types := make(map[string]string)
+	for _, definition := range definitions {
+		name, t := aws.StringValue(definition.AttributeName), aws.StringValue(definition.AttributeType)
+		if _, ok := types[name]; ok {
+			return validationError("Cannot have two attributes with the same name: " + name)
+		}
+		if t != dynamodb.ScalarAttributeTypeS && t != dynamodb.ScalarAttributeTypeN && t != dynamodb.ScalarAttributeTypeB {
+			return validationError(fmt.Sprintf("Member must satisfy enum value set: [B, N, S]; attribute: %s", name))
+		}
+		types[name] = t
+	}
+	used := make(map[string]bool)
+	for _, schema := range schemas {
+		if err := checkKeySchema(schema, types); err != nil {
+			return err
+		}
+		for _, element := range schema {
+			used[aws.StringValue(element.AttributeName)] = true
+		}
+	}
+	for name := range types {
+		if !used[name] {
+			return validationError("One or more parameter values were invalid: Some AttributeDefinitions are not used. AttributeDefinitions: " + name)
+		}
+	}
+	return nil
+}
+
+func checkProjection(projection *dynamodb.Projection) error {
+	if projection == nil {
+		return validationError("One or more parameter values were invalid: Projection is required for a global secondary index")
+	}
+	switch aws.StringValue(projection.ProjectionType) {
+	case dynamodb.ProjectionTypeAll, dynamodb.ProjectionTypeKeysOnly:
+		if len(projection.NonKeyAttributes) > 0 {
+			return validationError("One or more parameter values were invalid: ProjectionType is " +
+				aws.StringValue(projection.ProjectionType) + ", but NonKeyAttributes is specified")
+		}
+	case dynamodb.ProjectionTypeInclude:
+		if len(projection.NonKeyAttributes) == 0 {
+			return validationError("One or more parameter values were invalid: ProjectionType is INCLUDE, but NonKeyAttributes is not specified")
+		}
+	default:
+		return validationError("One or more parameter values were invalid: Unknown ProjectionType")
+	}
+	return nil
+}
+
+// checkThroughput requires provisioned throughput exactly when the
+// billing mode is PROVISIONED
+func checkThroughput(billingMode string, throughput *dynamodb.ProvisionedThroughput, what string) error {
+	if billingMode == dynamodb.BillingModePayPerRequest {
+		if throughput != nil {
+			return validationError("One or more parameter values were invalid: Neither ReadCapacityUnits nor WriteCapacityUnits can be specified when BillingMode is PAY_PER_REQUEST" + what)
+		}
+		return nil
+	}
+	if throughput == nil {
+		return validationError("One or more parameter values were invalid: ReadCapacityUnits and WriteCapacityUnits must both be specified when BillingMode is PROVISIONED" + what)
+	}
+	return nil
+}
+
+func describeThroughput(throughput *dynamodb.ProvisionedThroughput) *dynamodb.ProvisionedThroughputDescription {
+	description := &dynamodb.ProvisionedThroughputDescription{
+		NumberOfDecreasesToday: aws.Int64(0),
+		ReadCapacityUnits:      aws.Int64(0),
+		WriteCapacityUnits:     aws.Int64(0),
+	}
+	if throughput != nil {
+		description.ReadCapacityUnits = aws.Int64(aws.Int64Value(throughput.ReadCapacityUnits))
+		description.WriteCapacityUnits = aws.Int64(aws.Int64Value(throughput.WriteCapacityUnits))
+	}
+	return description
+}
+
+func tableARN(name string) string {
+	return "arn:aws:dynamodb:local:000000000000:table/" + name
+}
+
+func describeIndex(table string, index *dynamodb.GlobalSecondaryIndex) *dynamodb.GlobalSecondaryIndexDescription {
+	return &dynamodb.GlobalSecondaryIndexDescription{
+		IndexName:             aws.String(aws.StringValue(index.IndexName)),
+		IndexArn:              aws.String(tableARN(table) + "/index/" + aws.StringValue(index.IndexName)),
+		IndexStatus:           aws.String(dynamodb.IndexStatusActive),
+		KeySchema:             index.KeySchema,
+		Projection:            index.Projection,
+		ProvisionedThroughput: describeThroughput(index.ProvisionedThroughput),
+	}
+}
+
+func indexSchemas(description *dynamodb.TableDescription) [][]*dynamodb.KeySchemaElement {
+	schemas := [][]*dynamodb.KeySchemaElement{description.KeySchema}
+	for _, index := range description.GlobalSecondaryIndexes {
+		schemas = append(schemas, index.KeySchema)
+	}
+	return schemas
+}
+
+// CreateTable is a method
+func (f *Fake) CreateTable(input *dynamodb.CreateTableInput) (*dynamodb.CreateTableOutput, error) {
+	return f.CreateTableWithContext(aws.BackgroundContext(), input)
+}
+
+// CreateTableWithContext creates a table, which is active at once
+func (f *Fake) CreateTableWithContext(ctx aws.Context, input *dynamodb.CreateTableInput, _ ...request.Option) (*dynamodb.CreateTableOutput, error) {
+	if err := check(ctx, input); err != nil {
+		return &dynamodb.CreateTableOutput{}, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	name := aws.StringValue(input.TableName)
+	if _, ok := f.tables[name]; ok {
+		return &dynamodb.CreateTableOutput{}, &dynamodb.ResourceInUseException{RespMetadata: metadata(), Message_: aws.String("Table already exists: " + name)}
+	}
+	if len(input.LocalSecondaryIndexes) > 0 {
+		return &dynamodb.CreateTableOutput{}, validationError("dynamotest: local secondary indexes are not supported")
+	}
+	billingMode := aws.StringValue(input.BillingMode)
+	if billingMode == "" {
+		billingMode = dynamodb.BillingModeProvisioned
+	}
+	if err := checkThroughput(billingMode, input.ProvisionedThroughput, ""); err != nil {
+		return &dynamodb.CreateTableOutput{}, err
+	}
+	// The SDK reads timestamps to the second, so the fake keeps no more
+	description := &dynamodb.TableDescription{
+		TableName:             aws.String(name),
+		TableArn:              aws.String(tableARN(name)),
+		TableId:               aws.String(uuid.New().String()),
+		TableStatus:           aws.String(dynamodb.TableStatusActive),
+		CreationDateTime:      aws.Time(time.Now().UTC().Truncate(time.Second)),
+		AttributeDefinitions:  input.AttributeDefinitions,
+		KeySchema:             input.KeySchema,
+		ProvisionedThroughput: describeThroughput(input.ProvisionedThroughput),
+	}
+	if billingMode == dynamodb.BillingModePayPerRequest {
+		description.BillingModeSummary = &dynamodb.BillingModeSummary{BillingMode: aws.String(billingMode)}
+	}
+	indexNames := make(map[string]bool)
+	for _, index := range input.GlobalSecondaryIndexes {
+		indexName := aws.StringValue(index.IndexName)
+		if indexNames[indexName] {
+			return &dynamodb.CreateTableOutput{}, validationError("One or more parameter values were invalid: Duplicate index name: " + indexName)
+		}
+		indexNames[indexName] = true
+		if err := checkProjection(index.Projection); err != nil {
+			return &dynamodb.CreateTableOutput{}, err
+		}
+		if err := checkThroughput(billingMode, index.ProvisionedThroughput, " for index "+indexName); err != nil {
+			return &dynamodb.CreateTableOutput{}, err
+		}
+		description.GlobalSecondaryIndexes = append(description.GlobalSecondaryIndexes, describeIndex(name, index))
+	}
+	if err := checkDefinitions(description.AttributeDefinitions, indexSchemas(description)...); err != nil {
+		return &dynamodb.CreateTableOutput{}, err
+	}
+
+	t := &table{description: clone(description), tags: make(map[string]string), items: make(map[string]item)}
+	for _, tag := range input.Tags {
+		t.tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	f.tables[name] = t
+	return &dynamodb.CreateTableOutput{TableDescription: t.describe()}, nil
+}
+
+// clone returns a deep copy of description, so that the fake neither
+// keeps nor hands out what its callers may change
+func clone(description *dynamodb.TableDescription) *dynamodb.TableDescription {
+	encoded, err := json.Marshal(description)
+	if err != nil {
+		panic(err)
+	}
+	var copied dynamodb.TableDescription
+	if err := json.Unmarshal(encoded, &copied); err != nil {
+		panic(err)
+	}
+	return &copied
+}
+
+// describe returns a copy of the table's description, with its item
+// counts and sizes
+func (t *table) describe() *dynamodb.TableDescription {
+	description := clone(t.description)
+	var count, bytes int64
+	for _, stored := range t.items {
+		count++
+		bytes += int64(itemSize(stored))
+	}
+	description.ItemCount = aws.Int64(count)
+	description.TableSizeBytes = aws.Int64(bytes)
+	for _, index := range description.GlobalSecondaryIndexes {
+		v := t.indexView(index)
+		count, bytes = 0, 0
+		for _, stored := range v.items() {
+			count++
+			bytes += int64(itemSize(stored))
+		}
+		index.ItemCount = aws.Int64(count)
+		index.IndexSizeBytes = aws.Int64(bytes)
+	}
+	return description
+}
+
+// DescribeTable is a method
+func (f *Fake) DescribeTable(input *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+	return f.DescribeTableWithContext(aws.BackgroundContext(), input)
+}
+
+// DescribeTableWithContext is a method
+func (f *Fake) DescribeTableWithContext(ctx aws.Context, input *dynamodb.DescribeTableInput, _ ...request.Option) (*dynamodb.DescribeTableOutput, error) {
+	if err := check(ctx, input); err != nil {
+		return &dynamodb.DescribeTableOutput{}, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	t, err := f.table(input.TableName)
+	if err != nil {
+		return &dynamodb.DescribeTableOutput{}, resourceNotFound("Requested resource not found: Table: " + aws.StringValue(input.TableName) + " not found")
+	}
+	return &dynamodb.DescribeTableOutput{Table: t.describe()}, nil
+}
+
+// WaitUntilTableExists is a method
+func (f *Fake) WaitUntilTableExists(input *dynamodb.DescribeTableInput) error {
+	return f.WaitUntilTableExistsWithContext(aws.BackgroundContext(), input)
+}
+
+// WaitUntilTableExistsWithContext returns at once, as tables are created
+// active, unless the table does not exist
+func (f *Fake) WaitUntilTableExistsWithContext(ctx aws.Context, input *dynamodb.DescribeTableInput, _ ...request.WaiterOption) error {
+	if _, err := f.DescribeTableWithContext(ctx, input); err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeResourceNotFoundException {
+			return awserr.New(request.WaiterResourceNotReadyErrorCode, "exceeded wait attempts", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// UpdateTable is a method
+func (f *Fake) UpdateTable(input *dynamodb.UpdateTableInput) (*dynamodb.UpdateTableOutput, error) {
+	return f.UpdateTableWithContext(aws.BackgroundContext(), input)
+}
+
+// UpdateTableWithContext changes the billing mode or throughput of a
+// table, or creates, updates or deletes one of its indexes. New indexes
+// are active at once.
+func (f *Fake) UpdateTableWithContext(ctx aws.Context, input *dynamodb.UpdateTableInput, _ ...request.Option) (*dynamodb.UpdateTableOutput, error) {
+	if err := check(ctx, input); err != nil {
+		return &dynamodb.UpdateTableOutput{}, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	t, err := f.table(input.TableName)
+	if err != nil {
+		return &dynamodb.UpdateTableOutput{}, err
+	}
+	if input.BillingMode == nil && input.ProvisionedThroughput == nil && len(input.GlobalSecondaryIndexUpdates) == 0 {
+		return &dynamodb.UpdateTableOutput{}, validationError("At least one of ProvisionedThroughput, BillingMode, UpdateStreamEnabled, GlobalSecondaryIndexUpdates or SSESpecification or ReplicaUpdates is required")
+	}
+	description := clone(t.description)
+	billingMode := dynamodb.BillingModeProvisioned
+	if description.BillingModeSummary != nil {
+		billingMode = aws.StringValue(description.BillingModeSummary.BillingMode)
+	}
+	if input.BillingMode != nil {
+		billingMode = aws.StringValue(input.BillingMode)
+		description.BillingModeSummary = &dynamodb.BillingModeSummary{BillingMode: aws.String(billingMode)}
+		if billingMode == dynamodb.BillingModePayPerRequest {
+			description.ProvisionedThroughput = describeThroughput(nil)
+		}
+	}
+	if input.ProvisionedThroughput != nil || (input.BillingMode != nil && billingMode == dynamodb.BillingModeProvisioned) {
+		if err := checkThroughput(billingMode, input.ProvisionedThroughput, ""); err != nil {
+			return &dynamodb.UpdateTableOutput{}, err
+		}
+		description.ProvisionedThroughput = describeThroughput(input.ProvisionedThroughput)
+	}
+
+	online := 0
+	for _, update := range input.GlobalSecondaryIndexUpdates {
+		switch {
+		case update.Create != nil:
+			online++
+			create := update.Create
+			for _, index := range description.GlobalSecondaryIndexes {
+				if aws.StringValue(index.IndexName) == aws.StringValue(create.IndexName) {
+					return &dynamodb.UpdateTableOutput{}, validationError("One or more parameter values were invalid: Index with name: " + aws.StringValue(create.IndexName) + " already exists")
+				}
+			}
+			if err := checkProjection(create.Projection); err != nil {
+				return &dynamodb.UpdateTableOutput{}, err
+			}
+			if err := checkThroughput(billingMode, create.ProvisionedThroughput, " for index "+aws.StringValue(create.IndexName)); err != nil {
+				return &dynamodb.UpdateTableOutput{}, err
+			}
+			description.GlobalSecondaryIndexes = append(description.GlobalSecondaryIndexes, describeIndex(aws.StringValue(input.TableName), &dynamodb.GlobalSecondaryIndex{
+				IndexName:             create.IndexName,
+				KeySchema:             create.KeySchema,
+				Projection:            create.Projection,
+				ProvisionedThroughput: create.ProvisionedThroughput,
+			}))
+		case update.Delete != nil:
+			online++
+			indexes := description.GlobalSecondaryIndexes[:0]
+			for _, index := range description.GlobalSecondaryIndexes {
+				if aws.StringValue(index.IndexName) != aws.StringValue(update.Delete.IndexName) {
+					indexes = append(indexes, index)
+				}
+			}
+			if len(indexes) == len(description.GlobalSecondaryIndexes) {
+				return &dynamodb.UpdateTableOutput{}, resourceNotFound("Requested resource not found: Index: " + aws.StringValue(update.Delete.IndexName))
+			}
+			description.GlobalSecondaryIndexes = indexes
+		case update.Update != nil:
+			found := false
+			for _, index := range description.GlobalSecondaryIndexes {
+				if aws.StringValue(index.IndexName) == aws.StringValue(update.Update.IndexName) {
+					index.ProvisionedThroughput = describeThroughput(update.Update.ProvisionedThroughput)
+					found = true
+				}
+			}
+			if !found {
+				return &dynamodb.UpdateTableOutput{}, resourceNotFound("Requested resource not found: Index: " + aws.StringValue(update.Update.IndexName))
+			}
+		}
+	}
+	if online > 1 {
+		return &dynamodb.UpdateTableOutput{}, &dynamodb.LimitExceededException{RespMetadata: metadata(),
+			Message_: aws.String("Subscriber limit exceeded: Only 1 online index can be created or deleted simultaneously per table")}
+	}
+
+	// The definitions given are added to those of the table, and those
+	// no key uses any more are dropped
+	definitions := make(map[string]*dynamodb.AttributeDefinition)
+	for _, definition := range append(description.AttributeDefinitions, input.AttributeDefinitions...) {
+		definitions[aws.StringValue(definition.AttributeName)] = definition
+	}
+	used := make(map[string]bool)
+	for _, schema := range indexSchemas(description) {
+		for _, element := range schema {
+			used[aws.StringValue(element.AttributeName)] = true
+		}
+	}
+	description.AttributeDefinitions = nil
+	for _, name := range sortedKeys(definitionTypes(definitions)) {
+		if used[name] {
+			description.AttributeDefinitions = append(description.AttributeDefinitions, definitions[name])
+		}
+	}
+	if err := checkDefinitions(description.AttributeDefinitions, indexSchemas(description)...); err != nil {
+		return &dynamodb.UpdateTableOutput{}, err
+	}
+	for _, index := range description.GlobalSecondaryIndexes {
+		if err := t.checkIndexKeys(description, index); err != nil {
+			return &dynamodb.UpdateTableOutput{}, err
+		}
+	}
+
+	t.description = clone(description)
+	return &dynamodb.UpdateTableOutput{TableDescription: t.describe()}, nil
+}
+
+func definitionTypes(definitions map[string]*dynamodb.AttributeDefinition) map[string]*string {
+	types := make(map[string]*string, len(definitions))
+	for name, definition := range definitions {
+		types[name] = definition.AttributeType
+	}
+	return types
+}
+
+// checkIndexKeys fails if an item holds a key attribute of index with
+// another type than the one it is now defined with
+func (t *table) checkIndexKeys(description *dynamodb.TableDescription, index *dynamodb.GlobalSecondaryIndexDescription) error {
+	types := attributeTypes(description)
+	for _, stored := range t.items {
+		for _, element := range index.KeySchema {
+			name := aws.StringValue(element.AttributeName)
+			if v, ok := stored[name]; ok && typeOf(v) != types[name] {
+				return validationError(fmt.Sprintf("One or more parameter values were invalid: Type mismatch for Index Key %s Expected: %s Actual: %s IndexName: %s",
+					name, types[name], typeOf(v), aws.StringValue(index.IndexName)))
+			}
+		}
+	}
+	return nil
+}
+
+func attributeTypes(description *dynamodb.TableDescription) map[string]string {
+	types := make(map[string]string)
+	for _, definition := range description.AttributeDefinitions {
+		types[aws.StringValue(definition.AttributeName)] = aws.StringValue(definition.AttributeType)
+	}
+	return types
+}
+
+// tableNames returns the names of the tables a batch request is about,
+// or of those held by the fake, sorted so that the outcome does not
+// depend on the order of a map
+func tableNames(m interface{}) []string {
+	var names []string
+	switch m := m.(type) {
+	case map[string]*table:
+		for name := range m {
+			names = append(names, name)
+		}
+	case map[string]*dynamodb.KeysAndAttributes:
+		for name := range m {
+			names = append(names, name)
+		}
+	case map[string][]*dynamodb.WriteRequest:
+		for name := range m {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,358 @@
+package dynamotest
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func s(v string) *dynamodb.AttributeValue {
+	return &dynamodb.AttributeValue{S: aws.String(v)}
+}
+
+func n(v string) *dynamodb.AttributeValue {
+	return &dynamodb.AttributeValue{N: aws.String(v)}
+}
+
+func code(err error) string {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code()
+	}
+	return ""
+}
+
+func TestCondition(t *testing.T) {
+	stored := item{
+		"id":    s("a"),
+		"count": n("10"),
+		"tags":  {SS: aws.StringSlice([]string{"x", "y"})},
+		"owner": {M: item{"name": s("Ann"), "ids": {L: []*dynamodb.AttributeValue{n("1"), n("2")}}}},
+	}
+	values := item{":a": s("a"), ":b": s("b"), ":five": n("5"), ":ten": n("10.0"), ":x": s("x"), ":S": s("SS")}
+	tests := []struct {
+		expression string
+		expected   bool
+	}{
+		{"id = :a", true},
+		{"id <> :a", false},
+		{"missing <> :a", true},
+		{"count > :five AND count <= :ten", true},
+		{"count < :a", false},
+		{"count BETWEEN :five AND :ten", true},
+		{"id IN (:b, :a)", true},
+		{"NOT (id = :a OR id = :b)", false},
+		{"attribute_exists(owner.#n) AND attribute_not_exists(owner.age)", true},
+		{"owner.ids[1] = :five", false},
+		{"size(tags) < :five", true},
+		{"contains(tags, :x) AND begins_with(owner.#n, :x)", false},
+		{"attribute_type(tags, :S)", true},
+	}
+	for _, test := range tests {
+		e, err := newExpressions(map[string]*string{"#n": aws.String("name")}, values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := e.condition("ConditionExpression", aws.String(test.expression))
+		if err != nil {
+			t.Fatalf("%s: %v", test.expression, err)
+		}
+		if got := c.eval(stored); got != test.expected {
+			t.Errorf("%s = %v, expected %v", test.expression, got, test.expected)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	stored := item{
+		"id":      s("a"),
+		"version": n("1"),
+		"tags":    {SS: aws.StringSlice([]string{"x", "y"})},
+		"list":    {L: []*dynamodb.AttributeValue{n("1"), n("2"), n("3")}},
+		"old":     s("gone"),
+	}
+	e, err := newExpressions(map[string]*string{"#v": aws.String("version")}, item{
+		":one":  n("1"),
+		":half": n("0.5"),
+		":y":    {SS: aws.StringSlice([]string{"y"})},
+		":l":    {L: []*dynamodb.AttributeValue{n("4")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := e.update(aws.String("SET #v = #v + :one, total = if_not_exists(total, :half) - :one, list = list_append(list, :l) REMOVE old ADD counter :one DELETE tags :y"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.checkUnused(); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.apply(stored); err != nil {
+		t.Fatal(err)
+	}
+	expected := item{
+		"id":      s("a"),
+		"version": n("2"),
+		"total":   n("-0.5"),
+		"tags":    {SS: aws.StringSlice([]string{"x"})},
+		"list":    {L: []*dynamodb.AttributeValue{n("1"), n("2"), n("3"), n("4")}},
+		"counter": n("1"),
+	}
+	if !reflect.DeepEqual(stored, expected) {
+		t.Fatalf("updated item %v, expected %v", stored, expected)
+	}
+}
+
+func TestExpressionErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		values     item
+	}{
+		{"syntax", "id = ", item{":a": s("a")}},
+		{"undefined name", "#x = :a", item{":a": s("a")}},
+		{"undefined value", "id = :b", item{":a": s("a")}},
+		{"unused value", "id = :a", item{":a": s("a"), ":b": s("b")}},
+		{"unknown function", "starts_with(id, :a)", item{":a": s("a")}},
+		{"empty values", "attribute_exists(id)", item{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseCondition(nil, test.values, aws.String(test.expression))
+			if code(err) != "ValidationException" {
+				t.Fatalf("%s: got %v, expected a ValidationException", test.expression, err)
+			}
+		})
+	}
+}
+
+// newTestTable creates a table keyed on (pk,sk) with an index on
+// (gpk,gsk) projecting only its keys
+func newTestTable(t *testing.T) *Fake {
+	t.Helper()
+	f := New()
+	_, err := f.CreateTable(&dynamodb.CreateTableInput{
+		TableName:   aws.String("test"),
+		BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{AttributeName: aws.String("pk"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+			{AttributeName: aws.String("sk"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeN)},
+			{AttributeName: aws.String("gpk"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+			{AttributeName: aws.String("gsk"), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("pk"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+			{AttributeName: aws.String("sk"), KeyType: aws.String(dynamodb.KeyTypeRange)},
+		},
+		GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndex{{
+			IndexName: aws.String("by_g"),
+			KeySchema: []*dynamodb.KeySchemaElement{
+				{AttributeName: aws.String("gpk"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+				{AttributeName: aws.String("gsk"), KeyType: aws.String(dynamodb.KeyTypeRange)},
+			},
+			Projection: &dynamodb.Projection{ProjectionType: aws.String(dynamodb.ProjectionTypeKeysOnly)},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+	return f
+}
+
+func TestQuery(t *testing.T) {
+	f := newTestTable(t)
+	// Numeric sort keys sort as numbers, and the item without gsk stays
+	// out of the index
+	for _, v := range []item{
+		{"pk": s("p"), "sk": n("10"), "gpk": s("g"), "gsk": s("b"), "data": s("ten")},
+		{"pk": s("p"), "sk": n("9"), "gpk": s("g"), "gsk": s("a"), "data": s("nine")},
+		{"pk": s("p"), "sk": n("11"), "gpk": s("g")},
+		{"pk": s("q"), "sk": n("1"), "gpk": s("g"), "gsk": s("c")},
+	} {
+		if _, err := f.PutItem(&dynamodb.PutItemInput{TableName: aws.String("test"), Item: v}); err != nil {
+			t.Fatalf("PutItem: %v", err)
+		}
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String("test"),
+		KeyConditionExpression:    aws.String("pk = :p AND sk > :n"),
+		ExpressionAttributeValues: item{":p": s("p"), ":n": n("8")},
+		ProjectionExpression:      aws.String("sk"),
+		Limit:                     aws.Int64(2),
+	}
+	var pages [][]item
+	for {
+		output, err := f.Query(input)
+		if err != nil {
+			t.Fatalf("Query: %v", err)
+		}
+		pages = append(pages, output.Items)
+		if output.LastEvaluatedKey == nil {
+			break
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+	expected := [][]item{{{"sk": n("9")}, {"sk": n("10")}}, {{"sk": n("11")}}}
+	if !reflect.DeepEqual(pages, expected) {
+		t.Fatalf("Query pages %v, expected %v", pages, expected)
+	}
+
+	output, err := f.Query(&dynamodb.QueryInput{
+		TableName:                 aws.String("test"),
+		IndexName:                 aws.String("by_g"),
+		KeyConditionExpression:    aws.String("gpk = :g AND begins_with(gsk, :a)"),
+		ExpressionAttributeValues: item{":g": s("g"), ":a": s("a")},
+	})
+	if err != nil {
+		t.Fatalf("Query by_g: %v", err)
+	}
+	keysOnly := []item{{"pk": s("p"), "sk": n("9"), "gpk": s("g"), "gsk": s("a")}}
+	if !reflect.DeepEqual(output.Items, keysOnly) {
+		t.Fatalf("Query by_g %v, expected %v", output.Items, keysOnly)
+	}
+
+	_, err = f.Query(&dynamodb.QueryInput{
+		TableName:                 aws.String("test"),
+		KeyConditionExpression:    aws.String("sk = :n"),
+		ExpressionAttributeValues: item{":n": n("8")},
+	})
+	if code(err) != "ValidationException" {
+		t.Fatalf("Query without the partition key: got %v, expected a ValidationException", err)
+	}
+}
+
+func TestTransactWriteItems(t *testing.T) {
+	f := newTestTable(t)
+	key := item{"pk": s("p"), "sk": n("1")}
+	if _, err := f.PutItem(&dynamodb.PutItemInput{TableName: aws.String("test"), Item: item{"pk": s("p"), "sk": n("1"), "v": n("1")}}); err != nil {
+		t.Fatalf("PutItem: %v", err)
+	}
+	input := func(version string) *dynamodb.TransactWriteItemsInput {
+		return &dynamodb.TransactWriteItemsInput{
+			ClientRequestToken: aws.String("token-" + version),
+			TransactItems: []*dynamodb.TransactWriteItem{
+				{Put: &dynamodb.Put{
+					TableName: aws.String("test"),
+					Item:      item{"pk": s("p"), "sk": n("2")},
+				}},
+				{Update: &dynamodb.Update{
+					TableName:                 aws.String("test"),
+					Key:                       key,
+					UpdateExpression:          aws.String("ADD v :one"),
+					ConditionExpression:       aws.String("v = :v"),
+					ExpressionAttributeValues: item{":one": n("1"), ":v": n(version)},
+				}},
+			},
+		}
+	}
+
+	_, err := f.TransactWriteItems(input("0"))
+	cancelled, ok := err.(*dynamodb.TransactionCanceledException)
+	if !ok {
+		t.Fatalf("TransactWriteItems: got %v, expected a TransactionCanceledException", err)
+	}
+	var codes []string
+	for _, reason := range cancelled.CancellationReasons {
+		codes = append(codes, aws.StringValue(reason.Code))
+	}
+	if !reflect.DeepEqual(codes, []string{"None", "ConditionalCheckFailed"}) {
+		t.Fatalf("CancellationReasons %v", codes)
+	}
+	output, err := f.GetItem(&dynamodb.GetItemInput{TableName: aws.String("test"), Key: item{"pk": s("p"), "sk": n("2")}})
+	if err != nil || output.Item != nil {
+		t.Fatalf("cancelled transaction wrote %v (%v)", output.Item, err)
+	}
+
+	// Retrying with the same token does not apply the transaction twice
+	for i := 0; i < 2; i++ {
+		if _, err := f.TransactWriteItems(input("1")); err != nil {
+			t.Fatalf("TransactWriteItems #%d: %v", i, err)
+		}
+	}
+	output, err = f.GetItem(&dynamodb.GetItemInput{TableName: aws.String("test"), Key: key})
+	if err != nil || !equal(output.Item["v"], n("2")) {
+		t.Fatalf("GetItem = %v (%v), expected v = 2", output.Item, err)
+	}
+	retry := input("1")
+	retry.TransactItems = retry.TransactItems[:1]
+	if _, err := f.TransactWriteItems(retry); code(err) != dynamodb.ErrCodeIdempotentParameterMismatchException {
+		t.Fatalf("TransactWriteItems with a reused token: got %v", err)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	f := newTestTable(t)
+	put := func(sk string) *dynamodb.WriteRequest {
+		return &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: item{"pk": s("p"), "sk": n(sk)}}}
+	}
+	_, err := f.BatchWriteItem(&dynamodb.BatchWriteItemInput{
+		RequestItems: map[string][]*dynamodb.WriteRequest{"test": {put("1"), put("1.0")}},
+	})
+	if code(err) != "ValidationException" {
+		t.Fatalf("BatchWriteItem with duplicates: got %v, expected a ValidationException", err)
+	}
+	if _, err := f.BatchWriteItem(&dynamodb.BatchWriteItemInput{
+		RequestItems: map[string][]*dynamodb.WriteRequest{"test": {put("1"), put("2")}},
+	}); err != nil {
+		t.Fatalf("BatchWriteItem: %v", err)
+	}
+	output, err := f.BatchGetItem(&dynamodb.BatchGetItemInput{
+		RequestItems: map[string]*dynamodb.KeysAndAttributes{"test": {
+			Keys: []map[string]*dynamodb.AttributeValue{
+				{"pk": s("p"), "sk": n("2")},
+				{"pk": s("p"), "sk": n("3")},
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("BatchGetItem: %v", err)
+	}
+	if got := output.Responses["test"]; len(got) != 1 || !equal(got[0]["sk"], n("2")) {
+		t.Fatalf("BatchGetItem = %v", got)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	f := newTestTable(t)
+	stored := item{
+		"pk":   s("p"),
+		"sk":   n("1"),
+		"data": {B: []byte{0, 1, 2}},
+		"list": {L: []*dynamodb.AttributeValue{{NULL: aws.Bool(true)}, {NS: aws.StringSlice([]string{"1", "2"})}}},
+	}
+	if _, err := f.PutItem(&dynamodb.PutItemInput{TableName: aws.String("test"), Item: stored}); err != nil {
+		t.Fatalf("PutItem: %v", err)
+	}
+	if _, err := f.TagResource(&dynamodb.TagResourceInput{
+		ResourceArn: aws.String(tableARN("test")),
+		Tags:        []*dynamodb.Tag{{Key: aws.String("env"), Value: aws.String("test")}},
+	}); err != nil {
+		t.Fatalf("TagResource: %v", err)
+	}
+	var saved bytes.Buffer
+	if err := f.Save(&saved); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded := New()
+	if err := loaded.Load(&saved); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	output, err := loaded.GetItem(&dynamodb.GetItemInput{TableName: aws.String("test"), Key: item{"pk": s("p"), "sk": n("1")}})
+	if err != nil || !reflect.DeepEqual(output.Item, stored) {
+		t.Fatalf("GetItem = %v (%v), expected %v", output.Item, err, stored)
+	}
+	original, _ := f.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String("test")})
+	restored, err := loaded.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String("test")})
+	if err != nil || !reflect.DeepEqual(restored.Table, original.Table) {
+		t.Fatalf("DescribeTable = %v (%v), expected %v", restored.Table, err, original.Table)
+	}
+	tags, err := loaded.ListTagsOfResource(&dynamodb.ListTagsOfResourceInput{ResourceArn: aws.String(tableARN("test"))})
+	if err != nil || len(tags.Tags) != 1 || aws.StringValue(tags.Tags[0].Value) != "test" {
+		t.Fatalf("ListTagsOfResource = %v (%v)", tags.Tags, err)
+	}
+}
@@ -0,0 +1,832 @@
+package dynamotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// maxTransactItems is the number of actions TransactWriteItems takes at
+// most
+const maxTransactItems = 25
+
+// GetItem is a method
+func (f *Fake) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return f.GetItemWithContext(aws.BackgroundContext(), input)
+}
+
+// GetItemWithContext is a method
+func (f *Fake) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, _ ...request.Option) (*dynamodb.GetItemOutput, error) {
+	if err := check(ctx, input); err != nil {
+		return &dynamodb.GetItemOutput{}, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	t, err := f.table(input.TableName)
+	if err != nil {
+		return &dynamodb.GetItemOutput{}, err
+	}
+	if err := t.checkKey(input.Key); err != nil {
+		return &dynamodb.GetItemOutput{}, err
+	}
+	e, err := newExpressions(input.ExpressionAttributeNames, nil)
+	if err != nil {
+		return &dynamodb.GetItemOutput{}, err
+	}
+	paths, err := e.projection(input.ProjectionExpression)
+	if err != nil {
+		return &dynamodb.GetItemOutput{}, err
+	}
+	if err := e.checkUnused(); err != nil {
+		return &dynamodb.GetItemOutput{}, err
+	}
+
+	output := &dynamodb.GetItemOutput{}
+	bytes := 0
+	if stored, ok := t.items[t.keyString(input.Key)]; ok {
+		bytes = itemSize(stored)
+		if paths != nil {
+			output.Item = project(stored, paths)
+		} else {
+			output.Item = copyItem(stored)
+		}
+	}
+	output.ConsumedCapacity = capacity(input.ReturnConsumedCapacity, t.name(), readUnits(bytes, aws.BoolValue(input.ConsistentRead)))
+	return output, nil
+}
+
+// PutItem is a method
+func (f *Fake) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	return f.PutItemWithContext(aws.BackgroundContext(), input)
+}
+
+// PutItemWithContext is a method
+func (f *Fake) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, _ ...request.Option) (*dynamodb.PutItemOutput, error) {
+	if err := check(ctx, input); err != nil {
+		return &dynamodb.PutItemOutput{}, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	t, err := f.table(input.TableName)
+	if err != nil {
+		return &dynamodb.PutItemOutput{}, err
+	}
+	if err := t.checkItem(input.Item); err != nil {
+		return &dynamodb.PutItemOutput{}, err
+	}
+	c, err := parseCondition(input.ExpressionAttributeNames, input.ExpressionAttributeValues, input.ConditionExpression)
+	if err != nil {
+		return &dynamodb.PutItemOutput{}, err
+	}
+	if err := checkReturnValues(input.ReturnValues, dynamodb.ReturnValueNone, dynamodb.ReturnValueAllOld); err != nil {
+		return &dynamodb.PutItemOutput{}, err
+	}
+
+	key := t.keyString(input.Item)
+	old := t.items[key]
+	if !holds(c, old) {
+		return &dynamodb.PutItemOutput{}, conditionalCheckFailed()
+	}
+	t.items[key] = copyItem(input.Item)
+	output := &dynamodb.PutItemOutput{
+		ConsumedCapacity: capacity(input.ReturnConsumedCapacity, t.name(), writeUnits(maxInt(itemSize(old), itemSize(input.Item)))),
+	}
+	if aws.StringValue(input.ReturnValues) == dynamodb.ReturnValueAllOld {
+		output.Attributes = copyItem(old)
+	}
+	return output, nil
+}
+
+// DeleteItem is a method
+func (f *Fake) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	return f.DeleteItemWithContext(aws.BackgroundContext(), input)
+}
+
+// DeleteItemWithContext is a method
+func (f *Fake) DeleteItemWithContext(ctx aws.Context, input *dynamodb.DeleteItemInput, _ ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	if err := check(ctx, input); err != nil {
+		return &dynamodb.DeleteItemOutput{}, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	t, err := f.table(input.TableName)
+	if err != nil {
+		return &dynamodb.DeleteItemOutput{}, err
+	}
+	if err := t.checkKey(input.Key); err != nil {
+		return &dynamodb.DeleteItemOutput{}, err
+	}
+	c, err := parseCondition(input.ExpressionAttributeNames, input.ExpressionAttributeValues, input.ConditionExpression)
+	if err != nil {
+		return &dynamodb.DeleteItemOutput{}, err
+	}
+	if err := checkReturnValues(input.ReturnValues, dynamodb.ReturnValueNone, dynamodb.ReturnValueAllOld); err != nil {
+		return &dynamodb.DeleteItemOutput{}, err
+	}
+
+	key := t.keyString(input.Key)
+	old := t.items[key]
+	if !holds(c, old) {
+		return &dynamodb.DeleteItemOutput{}, conditionalCheckFailed()
+	}
+	delete(t.items, key)
+	output := &dynamodb.DeleteItemOutput{
+		ConsumedCapacity: capacity(input.ReturnConsumedCapacity, t.name(), writeUnits(itemSize(old))),
+	}
+	if aws.StringValue(input.ReturnValues) == dynamodb.ReturnValueAllOld {
+		output.Attributes = copyItem(old)
+	}
+	return output, nil
+}
+
+// UpdateItem is a method
+func (f *Fake) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	return f.UpdateItemWithContext(aws.BackgroundContext(), input)
+}
+
+// UpdateItemWithContext is a method
+func (f *Fake) UpdateItemWithContext(ctx aws.Context, input *dynamodb.UpdateItemInput, _ ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	if err := check(ctx, input); err != nil {
+		return &dynamodb.UpdateItemOutput{}, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	t, err := f.table(input.TableName)
+	if err != nil {
+		return &dynamodb.UpdateItemOutput{}, err
+	}
+	if err := t.checkKey(input.Key); err != nil {
+		return &dynamodb.UpdateItemOutput{}, err
+	}
+	e, err := newExpressions(input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	if err != nil {
+		return &dynamodb.UpdateItemOutput{}, err
+	}
+	u, err := e.update(input.UpdateExpression)
+	if err != nil {
+		return &dynamodb.UpdateItemOutput{}, err
+	}
+	c, err := e.condition("ConditionExpression", input.ConditionExpression)
+	if err != nil {
+		return &dynamodb.UpdateItemOutput{}, err
+	}
+	if err := e.checkUnused(); err != nil {
+		return &dynamodb.UpdateItemOutput{}, err
+	}
+	if err := t.checkKeyUpdate(u); err != nil {
+		return &dynamodb.UpdateItemOutput{}, err
+	}
+	if err := checkReturnValues(input.ReturnValues, dynamodb.ReturnValueNone, dynamodb.ReturnValueAllOld,
+		dynamodb.ReturnValueUpdatedOld, dynamodb.ReturnValueAllNew, dynamodb.ReturnValueUpdatedNew); err != nil {
+		return &dynamodb.UpdateItemOutput{}, err
+	}
+
+	key := t.keyString(input.Key)
+	old := t.items[key]
+	if !holds(c, old) {
+		return &dynamodb.UpdateItemOutput{}, conditionalCheckFailed()
+	}
+	updated, err := t.applyUpdate(input.Key, old, u)
+	if err != nil {
+		return &dynamodb.UpdateItemOutput{}, err
+	}
+	t.items[key] = updated
+
+	output := &dynamodb.UpdateItemOutput{
+		ConsumedCapacity: capacity(input.ReturnConsumedCapacity, t.name(), writeUnits(maxInt(itemSize(old), itemSize(updated)))),
+	}
+	switch aws.StringValue(input.ReturnValues) {
+	case dynamodb.ReturnValueAllOld:
+		output.Attributes = copyItem(old)
+	case dynamodb.ReturnValueAllNew:
+		output.Attributes = copyItem(updated)
+	case dynamodb.ReturnValueUpdatedOld:
+		output.Attributes = project(old, u.paths(true))
+	case dynamodb.ReturnValueUpdatedNew:
+		output.Attributes = project(updated, u.paths(false))
+	}
+	if len(output.Attributes) == 0 {
+		output.Attributes = nil
+	}
+	return output, nil
+}
+
+// applyUpdate returns the item at key once u has changed it, which is
+// created if old is missing
+func (t *table) applyUpdate(key item, old item, u *update) (item, error) {
+	updated := copyItem(old)
+	if updated == nil {
+		updated = copyItem(key)
+	}
+	if err := u.apply(updated); err != nil {
+		return nil, err
+	}
+	if err := t.checkItem(updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// parseCondition parses the condition of a request that has no other
+// expression
+func parseCondition(names map[string]*string, values map[string]*dynamodb.AttributeValue, text *string) (condition, error) {
+	e, err := newExpressions(names, values)
+	if err != nil {
+		return nil, err
+	}
+	c, err := e.condition("ConditionExpression", text)
+	if err != nil {
+		return nil, err
+	}
+	return c, e.checkUnused()
+}
+
+func checkReturnValues(returnValues *string, allowed ...string) error {
+	if returnValues == nil {
+		return nil
+	}
+	for _, value := range allowed {
+		if *returnValues == value {
+			return nil
+		}
+	}
+	return validationError("Return values set to invalid value")
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Query is a method
+func (f *Fake) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	return f.QueryWithContext(aws.BackgroundContext(), input)
+}
+
+// QueryWithContext is a method
+func (f *Fake) QueryWithContext(ctx aws.Context, input *dynamodb.QueryInput, _ ...request.Option) (*dynamodb.QueryOutput, error) {
+	if err := check(ctx, input); err != nil {
+		return &dynamodb.QueryOutput{}, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	t, err := f.table(input.TableName)
+	if err != nil {
+		return &dynamodb.QueryOutput{}, err
+	}
+	v, err := t.view(input.IndexName)
+	if err != nil {
+		return &dynamodb.QueryOutput{}, err
+	}
+	if v.index != nil && aws.BoolValue(input.ConsistentRead) {
+		return &dynamodb.QueryOutput{}, validationError("Consistent reads are not supported on global secondary indexes")
+	}
+	if input.KeyConditionExpression == nil {
+		return &dynamodb.QueryOutput{}, validationError("Either the KeyConditions or KeyConditionExpression parameter must be specified in the request.")
+	}
+	e, err := newExpressions(input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	if err != nil {
+		return &dynamodb.QueryOutput{}, err
+	}
+	keyCondition, err := e.keyCondition(input.KeyConditionExpression, v)
+	if err != nil {
+		return &dynamodb.QueryOutput{}, err
+	}
+	filter, err := e.condition("FilterExpression", input.FilterExpression)
+	if err != nil {
+		return &dynamodb.QueryOutput{}, err
+	}
+	paths, err := e.projection(input.ProjectionExpression)
+	if err != nil {
+		return &dynamodb.QueryOutput{}, err
+	}
+	if err := e.checkUnused(); err != nil {
+		return &dynamodb.QueryOutput{}, err
+	}
+	countOnly, err := checkSelect(input.Select, v, paths)
+	if err != nil {
+		return &dynamodb.QueryOutput{}, err
+	}
+
+	var items []item
+	for _, stored := range v.items() {
+		if keyCondition.eval(stored) {
+			items = append(items, stored)
+		}
+	}
+	forward := input.ScanIndexForward == nil || *input.ScanIndexForward
+	if !forward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	result, err := v.read(items, input.ExclusiveStartKey, forward, input.Limit, filter, paths, countOnly)
+	if err != nil {
+		return &dynamodb.QueryOutput{}, err
+	}
+	return &dynamodb.QueryOutput{
+		Items:            result.items,
+		Count:            aws.Int64(result.count),
+		ScannedCount:     aws.Int64(result.scanned),
+		LastEvaluatedKey: result.lastKey,
+		ConsumedCapacity: capacity(input.ReturnConsumedCapacity, t.name(), readUnits(result.bytes, aws.BoolValue(input.ConsistentRead))),
+	}, nil
+}
+
+// keyCondition parses a key condition expression on the keys of v
+func (e *expressions) keyCondition(text *string, v view) (condition, error) {
+	c, err := e.condition("KeyConditionExpression", text)
+	if err != nil {
+		return nil, err
+	}
+	return c, keyCondition(c, v.hashKey, v.rangeKey)
+}
+
+// checkSelect checks Select against the view and projection expression
+// of a request, and reports whether only a count is wanted
+func checkSelect(selection *string, v view, paths []path) (bool, error) {
+	switch aws.StringValue(selection) {
+	case "":
+		return false, nil
+	case dynamodb.SelectCount:
+		if paths != nil {
+			return false, validationError("Cannot specify the ProjectionExpression when choosing to get COUNT")
+		}
+		return true, nil
+	case dynamodb.SelectSpecificAttributes:
+		if paths == nil {
+			return false, validationError("SPECIFIC_ATTRIBUTES requires a ProjectionExpression")
+		}
+	case dynamodb.SelectAllAttributes:
+		if paths != nil {
+			return false, validationError("Cannot specify the ProjectionExpression when choosing to get ALL_ATTRIBUTES")
+		}
+		if v.index != nil && aws.StringValue(v.index.Projection.ProjectionType) != dynamodb.ProjectionTypeAll {
+			return false, validationError(fmt.Sprintf("One or more parameter values were invalid: Select type ALL_ATTRIBUTES is not supported for global secondary index %s because its projection type is not ALL",
+				aws.StringValue(v.index.IndexName)))
+		}
+	case dynamodb.SelectAllProjectedAttributes:
+		if v.index == nil {
+			return false, validationError("ALL_PROJECTED_ATTRIBUTES can be used only when Querying using an IndexName")
+		}
+		if paths != nil {
+			return false, validationError("Cannot specify the ProjectionExpression when choosing to get ALL_PROJECTED_ATTRIBUTES")
+		}
+	}
+	return false, nil
+}
+
+// Scan is a method
+func (f *Fake) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	return f.ScanWithContext(aws.BackgroundContext(), input)
+}
+
+// ScanWithContext reads the items of a table or index in key order,
+// which DynamoDB does not guarantee but which keeps tests repeatable
+func (f *Fake) ScanWithContext(ctx aws.Context, input *dynamodb.ScanInput, _ ...request.Option) (*dynamodb.ScanOutput, error) {
+	if err := check(ctx, input); err != nil {
+		return &dynamodb.ScanOutput{}, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	t, err := f.table(input.TableName)
+	if err != nil {
+		return &dynamodb.ScanOutput{}, err
+	}
+	v, err := t.view(input.IndexName)
+	if err != nil {
+		return &dynamodb.ScanOutput{}, err
+	}
+	if v.index != nil && aws.BoolValue(input.ConsistentRead) {
+		return &dynamodb.ScanOutput{}, validationError("Consistent reads are not supported on global secondary indexes")
+	}
+	if (input.Segment == nil) != (input.TotalSegments == nil) {
+		return &dynamodb.ScanOutput{}, validationError("The Segment parameter is required but was not present in the request when parameter TotalSegments is present")
+	}
+	if input.Segment != nil && *input.Segment >= *input.TotalSegments {
+		return &dynamodb.ScanOutput{}, validationError("The Segment parameter is zero-based and must be less than parameter TotalSegments")
+	}
+	e, err := newExpressions(input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	if err != nil {
+		return &dynamodb.ScanOutput{}, err
+	}
+	filter, err := e.condition("FilterExpression", input.FilterExpression)
+	if err != nil {
+		return &dynamodb.ScanOutput{}, err
+	}
+	paths, err := e.projection(input.ProjectionExpression)
+	if err != nil {
+		return &dynamodb.ScanOutput{}, err
+	}
+	if err := e.checkUnused(); err != nil {
+		return &dynamodb.ScanOutput{}, err
+	}
+	countOnly, err := checkSelect(input.Select, v, paths)
+	if err != nil {
+		return &dynamodb.ScanOutput{}, err
+	}
+
+	items := v.items()
+	if input.Segment != nil {
+		items = v.segment(items, *input.Segment, *input.TotalSegments)
+	}
+	result, err := v.read(items, input.ExclusiveStartKey, true, input.Limit, filter, paths, countOnly)
+	if err != nil {
+		return &dynamodb.ScanOutput{}, err
+	}
+	return &dynamodb.ScanOutput{
+		Items:            result.items,
+		Count:            aws.Int64(result.count),
+		ScannedCount:     aws.Int64(result.scanned),
+		LastEvaluatedKey: result.lastKey,
+		ConsumedCapacity: capacity(input.ReturnConsumedCapacity, t.name(), readUnits(result.bytes, aws.BoolValue(input.ConsistentRead))),
+	}, nil
+}
+
+// BatchGetItem is a method
+func (f *Fake) BatchGetItem(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+	return f.BatchGetItemWithContext(aws.BackgroundContext(), input)
+}
+
+// BatchGetItemWithContext reads every key asked for, so that
+// UnprocessedKeys is always empty
+func (f *Fake) BatchGetItemWithContext(ctx aws.Context, input *dynamodb.BatchGetItemInput, _ ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	if err := check(ctx, input); err != nil {
+		return &dynamodb.BatchGetItemOutput{}, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	// The whole request is checked before anything is read
+	total := 0
+	projections := make(map[string][]path)
+	for _, name := range tableNames(input.RequestItems) {
+		request := input.RequestItems[name]
+		t, err := f.table(aws.String(name))
+		if err != nil {
+			return &dynamodb.BatchGetItemOutput{}, err
+		}
+		seen := make(map[string]bool)
+		for _, key := range request.Keys {
+			if err := t.checkKey(key); err != nil {
+				return &dynamodb.BatchGetItemOutput{}, err
+			}
+			if seen[t.keyString(key)] {
+				return &dynamodb.BatchGetItemOutput{}, validationError("Provided list of item keys contains duplicates")
+			}
+			seen[t.keyString(key)] = true
+		}
+		total += len(request.Keys)
+		e, err := newExpressions(request.ExpressionAttributeNames, nil)
+		if err != nil {
+			return &dynamodb.BatchGetItemOutput{}, err
+		}
+		if projections[name], err = e.projection(request.ProjectionExpression); err != nil {
+			return &dynamodb.BatchGetItemOutput{}, err
+		}
+		if err := e.checkUnused(); err != nil {
+			return &dynamodb.BatchGetItemOutput{}, err
+		}
+	}
+	if total > 100 {
+		return &dynamodb.BatchGetItemOutput{}, validationError("Too many items requested for the BatchGetItem call")
+	}
+
+	output := &dynamodb.BatchGetItemOutput{
+		Responses:       make(map[string][]map[string]*dynamodb.AttributeValue),
+		UnprocessedKeys: make(map[string]*dynamodb.KeysAndAttributes),
+	}
+	for _, name := range tableNames(input.RequestItems) {
+		request := input.RequestItems[name]
+		t := f.tables[name]
+		found := make([]map[string]*dynamodb.AttributeValue, 0, len(request.Keys))
+		units := 0.0
+		for _, key := range request.Keys {
+			stored, ok := t.items[t.keyString(key)]
+			if !ok {
+				continue
+			}
+			units += readUnits(itemSize(stored), aws.BoolValue(request.ConsistentRead))
+			if paths := projections[name]; paths != nil {
+				found = append(found, project(stored, paths))
+			} else {
+				found = append(found, copyItem(stored))
+			}
+		}
+		output.Responses[name] = found
+		if c := capacity(input.ReturnConsumedCapacity, name, units); c != nil {
+			output.ConsumedCapacity = append(output.ConsumedCapacity, c)
+		}
+	}
+	return output, nil
+}
+
+// BatchWriteItem is a method
+func (f *Fake) BatchWriteItem(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+	return f.BatchWriteItemWithContext(aws.BackgroundContext(), input)
+}
+
+// BatchWriteItemWithContext writes every request, so that
+// UnprocessedItems is always empty
+func (f *Fake) BatchWriteItemWithContext(ctx aws.Context, input *dynamodb.BatchWriteItemInput, _ ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	if err := check(ctx, input); err != nil {
+		return &dynamodb.BatchWriteItemOutput{}, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	// The whole request is checked before anything is written
+	total := 0
+	for _, name := range tableNames(input.RequestItems) {
+		t, err := f.table(aws.String(name))
+		if err != nil {
+			return &dynamodb.BatchWriteItemOutput{}, err
+		}
+		seen := make(map[string]bool)
+		for _, request := range input.RequestItems[name] {
+			var key item
+			switch {
+			case request.PutRequest != nil && request.DeleteRequest != nil, request.PutRequest == nil && request.DeleteRequest == nil:
+				return &dynamodb.BatchWriteItemOutput{}, validationError("Supplied WriteRequest must contain exactly one of PutRequest or DeleteRequest")
+			case request.PutRequest != nil:
+				if err := t.checkItem(request.PutRequest.Item); err != nil {
+					return &dynamodb.BatchWriteItemOutput{}, err
+				}
+				key = request.PutRequest.Item
+			default:
+				if err := t.checkKey(request.DeleteRequest.Key); err != nil {
+					return &dynamodb.BatchWriteItemOutput{}, err
+				}
+				key = request.DeleteRequest.Key
+			}
+			if seen[t.keyString(key)] {
+				return &dynamodb.BatchWriteItemOutput{}, validationError("Provided list of item keys contains duplicates")
+			}
+			seen[t.keyString(key)] = true
+		}
+		total += len(input.RequestItems[name])
+	}
+	if total > 25 {
+		return &dynamodb.BatchWriteItemOutput{}, validationError("Too many items requested for the BatchWriteItem call")
+	}
+
+	output := &dynamodb.BatchWriteItemOutput{
+		UnprocessedItems: make(map[string][]*dynamodb.WriteRequest),
+	}
+	for _, name := range tableNames(input.RequestItems) {
+		t := f.tables[name]
+		units := 0.0
+		for _, request := range input.RequestItems[name] {
+			if request.PutRequest != nil {
+				key := t.keyString(request.PutRequest.Item)
+				units += writeUnits(maxInt(itemSize(t.items[key]), itemSize(request.PutRequest.Item)))
+				t.items[key] = copyItem(request.PutRequest.Item)
+			} else {
+				key := t.keyString(request.DeleteRequest.Key)
+				units += writeUnits(itemSize(t.items[key]))
+				delete(t.items, key)
+			}
+		}
+		if c := capacity(input.ReturnConsumedCapacity, name, units); c != nil {
+			output.ConsumedCapacity = append(output.ConsumedCapacity, c)
+		}
+	}
+	return output, nil
+}
+
+// action is an item of a transaction, ready to be checked and applied
+type action struct {
+	table     *table
+	key       item
+	condition condition
+	// write returns what the item becomes, or nil to delete it, and is
+	// nil for a condition check
+	write     func(old item) (item, error)
+	returnOld bool
+}
+
+// TransactWriteItems is a method
+func (f *Fake) TransactWriteItems(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+	return f.TransactWriteItemsWithContext(aws.BackgroundContext(), input)
+}
+
+// TransactWriteItemsWithContext applies all the actions of a transaction
+// or, if any of them fails, none. It then fails with a
+// TransactionCanceledException giving the reason for each action, which
+// is "None" for those that would have succeeded. A transaction repeated
+// with the same ClientRequestToken within 10 minutes is not applied again.
+func (f *Fake) TransactWriteItemsWithContext(ctx aws.Context, input *dynamodb.TransactWriteItemsInput, _ ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	if err := check(ctx, input); err != nil {
+		return &dynamodb.TransactWriteItemsOutput{}, err
+	}
+	if len(input.TransactItems) > maxTransactItems {
+		return &dynamodb.TransactWriteItemsOutput{}, validationError(fmt.Sprintf("1 validation error detected: Value at 'transactItems' failed to satisfy constraint: Member must have length less than or equal to %d", maxTransactItems))
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	actions := make([]action, len(input.TransactItems))
+	seen := make(map[string]bool)
+	for i, transactItem := range input.TransactItems {
+		a, err := f.transactAction(transactItem)
+		if err != nil {
+			return &dynamodb.TransactWriteItemsOutput{}, err
+		}
+		id := a.table.name() + "\x00" + a.table.keyString(a.key)
+		if seen[id] {
+			return &dynamodb.TransactWriteItemsOutput{}, validationError("Transaction request cannot include multiple operations on one item")
+		}
+		seen[id] = true
+		actions[i] = a
+	}
+
+	var fingerprint string
+	if input.ClientRequestToken != nil {
+		encoded, err := json.Marshal(input.TransactItems)
+		if err != nil {
+			return &dynamodb.TransactWriteItemsOutput{}, err
+		}
+		fingerprint = string(encoded)
+		token := *input.ClientRequestToken
+		if previous, ok := f.tokens[token]; ok && time.Since(previous.time) < tokenLifetime {
+			if previous.fingerprint != fingerprint {
+				return &dynamodb.TransactWriteItemsOutput{}, &dynamodb.IdempotentParameterMismatchException{RespMetadata: metadata(),
+					Message_: aws.String("Request token " + token + " was used with different parameters")}
+			}
+			return &dynamodb.TransactWriteItemsOutput{}, nil
+		}
+	}
+
+	reasons := make([]*dynamodb.CancellationReason, len(actions))
+	results := make([]item, len(actions))
+	codes := make([]string, len(actions))
+	cancelled := false
+	for i, a := range actions {
+		old := a.table.items[a.table.keyString(a.key)]
+		reasons[i] = &dynamodb.CancellationReason{Code: aws.String("None")}
+		if !holds(a.condition, old) {
+			reasons[i] = &dynamodb.CancellationReason{
+				Code:    aws.String("ConditionalCheckFailed"),
+				Message: aws.String("The conditional request failed"),
+			}
+			if a.returnOld {
+				reasons[i].Item = copyItem(old)
+			}
+			cancelled = true
+		} else if a.write != nil {
+			result, err := a.write(old)
+			if err != nil {
+				reasons[i] = &dynamodb.CancellationReason{
+					Code:    aws.String("ValidationError"),
+					Message: aws.String(message(err)),
+				}
+				cancelled = true
+			}
+			results[i] = result
+		}
+		codes[i] = aws.StringValue(reasons[i].Code)
+	}
+	if cancelled {
+		return &dynamodb.TransactWriteItemsOutput{}, &dynamodb.TransactionCanceledException{
+			RespMetadata:        metadata(),
+			Message_:            aws.String(fmt.Sprintf("Transaction cancelled, please refer cancellation reasons for specific reasons [%s]", strings.Join(codes, ", "))),
+			CancellationReasons: reasons,
+		}
+	}
+
+	units := make(map[string]float64)
+	var names []string
+	for i, a := range actions {
+		key := a.table.keyString(a.key)
+		if _, ok := units[a.table.name()]; !ok {
+			names = append(names, a.table.name())
+		}
+		units[a.table.name()] += 2 * writeUnits(maxInt(itemSize(a.table.items[key]), itemSize(results[i])))
+		switch {
+		case a.write == nil:
+		case results[i] == nil:
+			delete(a.table.items, key)
+		default:
+			a.table.items[key] = results[i]
+		}
+	}
+	if input.ClientRequestToken != nil {
+		f.tokens[*input.ClientRequestToken] = transaction{fingerprint: fingerprint, time: time.Now()}
+	}
+	output := &dynamodb.TransactWriteItemsOutput{}
+	for _, name := range names {
+		if c := capacity(input.ReturnConsumedCapacity, name, units[name]); c != nil {
+			output.ConsumedCapacity = append(output.ConsumedCapacity, c)
+		}
+	}
+	return output, nil
+}
+
+// transactAction checks an item of a transaction and turns it into an
+// action
+func (f *Fake) transactAction(transactItem *dynamodb.TransactWriteItem) (action, error) {
+	var a action
+	set := 0
+	for _, present := range []bool{transactItem.ConditionCheck != nil, transactItem.Put != nil, transactItem.Delete != nil, transactItem.Update != nil} {
+		if present {
+			set++
+		}
+	}
+	if set != 1 {
+		return a, validationError("TransactItems can only contain one of Check, Put, Update or Delete")
+	}
+
+	var err error
+	switch {
+	case transactItem.ConditionCheck != nil:
+		check := transactItem.ConditionCheck
+		if a.table, err = f.table(check.TableName); err != nil {
+			return a, err
+		}
+		if err := a.table.checkKey(check.Key); err != nil {
+			return a, err
+		}
+		a.key = check.Key
+		if a.condition, err = parseCondition(check.ExpressionAttributeNames, check.ExpressionAttributeValues, check.ConditionExpression); err != nil {
+			return a, err
+		}
+		a.returnOld = aws.StringValue(check.ReturnValuesOnConditionCheckFailure) == dynamodb.ReturnValuesOnConditionCheckFailureAllOld
+	case transactItem.Put != nil:
+		put := transactItem.Put
+		if a.table, err = f.table(put.TableName); err != nil {
+			return a, err
+		}
+		if err := a.table.checkItem(put.Item); err != nil {
+			return a, err
+		}
+		a.key = a.table.keyOf(put.Item)
+		if a.condition, err = parseCondition(put.ExpressionAttributeNames, put.ExpressionAttributeValues, put.ConditionExpression); err != nil {
+			return a, err
+		}
+		a.write = func(old item) (item, error) {
+			return copyItem(put.Item), nil
+		}
+		a.returnOld = aws.StringValue(put.ReturnValuesOnConditionCheckFailure) == dynamodb.ReturnValuesOnConditionCheckFailureAllOld
+	case transactItem.Delete != nil:
+		del := transactItem.Delete
+		if a.table, err = f.table(del.TableName); err != nil {
+			return a, err
+		}
+		if err := a.table.checkKey(del.Key); err != nil {
+			return a, err
+		}
+		a.key = del.Key
+		if a.condition, err = parseCondition(del.ExpressionAttributeNames, del.ExpressionAttributeValues, del.ConditionExpression); err != nil {
+			return a, err
+		}
+		a.write = func(old item) (item, error) {
+			return nil, nil
+		}
+		a.returnOld = aws.StringValue(del.ReturnValuesOnConditionCheckFailure) == dynamodb.ReturnValuesOnConditionCheckFailureAllOld
+	default:
+		update := transactItem.Update
+		if a.table, err = f.table(update.TableName); err != nil {
+			return a, err
+		}
+		if err := a.table.checkKey(update.Key); err != nil {
+			return a, err
+		}
+		a.key = update.Key
+		e, err := newExpressions(update.ExpressionAttributeNames, update.ExpressionAttributeValues)
+		if err != nil {
+			return a, err
+		}
+		u, err := e.update(update.UpdateExpression)
+		if err != nil {
+			return a, err
+		}
+		if a.condition, err = e.condition("ConditionExpression", update.ConditionExpression); err != nil {
+			return a, err
+		}
+		if err := e.checkUnused(); err != nil {
+			return a, err
+		}
+		if err := a.table.checkKeyUpdate(u); err != nil {
+			return a, err
+		}
+		t := a.table
+		a.write = func(old item) (item, error) {
+			return t.applyUpdate(update.Key, old, u)
+		}
+		a.returnOld = aws.StringValue(update.ReturnValuesOnConditionCheckFailure) == dynamodb.ReturnValuesOnConditionCheckFailureAllOld
+	}
+	return a, nil
+}
@@ -0,0 +1,178 @@
+package dynamotest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// pathElement is a step of a document path: an attribute name or, for
+// lists, an index
+type pathElement struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+// path is a document path such as a.b[2].c, with placeholders resolved.
+// The first element is always a name.
+type path []pathElement
+
+func (p path) String() string {
+	var b strings.Builder
+	for i, e := range p {
+		switch {
+		case e.isIndex:
+			fmt.Fprintf(&b, "[%d]", e.index)
+		case i > 0:
+			b.WriteString("." + e.name)
+		default:
+			b.WriteString(e.name)
+		}
+	}
+	return b.String()
+}
+
+// hasPrefix reports whether p is, or is within, prefix
+func (p path) hasPrefix(prefix path) bool {
+	if len(prefix) > len(p) {
+		return false
+	}
+	for i := range prefix {
+		if p[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkOverlap rejects paths that are the same or within one another, as
+// DynamoDB does
+func checkOverlap(kind string, paths []path) error {
+	for i := range paths {
+		for j := range paths {
+			if i != j && paths[i].hasPrefix(paths[j]) {
+				return validationError(fmt.Sprintf("Invalid %s: Two document paths overlap with each other; must remove or rewrite one of these paths; path one: [%s], path two: [%s]",
+					kind, paths[j], paths[i]))
+			}
+		}
+	}
+	return nil
+}
+
+// get returns the value at p, or nil if there is none
+func (p path) get(item item) *dynamodb.AttributeValue {
+	v := item[p[0].name]
+	for _, e := range p[1:] {
+		switch {
+		case v == nil:
+			return nil
+		case e.isIndex:
+			if e.index >= len(v.L) {
+				return nil
+			}
+			v = v.L[e.index]
+		default:
+			if v.M == nil {
+				return nil
+			}
+			v = v.M[e.name]
+		}
+	}
+	return v
+}
+
+// set puts v at p, which must lead to an existing map or list unless it
+// is a top level attribute. An index past the end of a list appends to
+// it.
+func (p path) set(item item, v *dynamodb.AttributeValue) error {
+	if len(p) == 1 {
+		item[p[0].name] = v
+		return nil
+	}
+	parent := p[:len(p)-1].get(item)
+	last := p[len(p)-1]
+	switch {
+	case last.isIndex && parent != nil && parent.L != nil:
+		if last.index >= len(parent.L) {
+			parent.L = append(parent.L, v)
+		} else {
+			parent.L[last.index] = v
+		}
+	case !last.isIndex && parent != nil && parent.M != nil:
+		parent.M[last.name] = v
+	default:
+		return validationError("The document path provided in the update expression is invalid for update")
+	}
+	return nil
+}
+
+// remove deletes the value at p, if any
+func (p path) remove(item item) {
+	if len(p) == 1 {
+		delete(item, p[0].name)
+		return
+	}
+	parent := p[:len(p)-1].get(item)
+	last := p[len(p)-1]
+	switch {
+	case parent == nil:
+	case last.isIndex && last.index < len(parent.L):
+		parent.L = append(parent.L[:last.index], parent.L[last.index+1:]...)
+	case !last.isIndex && parent.M != nil:
+		delete(parent.M, last.name)
+	}
+}
+
+// project returns the parts of item at paths. Like DynamoDB, it packs
+// the list elements it keeps, so that a[1] and a[3] make a list of two.
+func project(item item, paths []path) item {
+	projected := make(map[string]*dynamodb.AttributeValue)
+	// containers holds the maps and lists made so far, by path
+	containers := make(map[string]*dynamodb.AttributeValue)
+	for _, p := range paths {
+		v := p.get(item)
+		if v == nil {
+			continue
+		}
+		v = copyValue(v)
+		if len(p) == 1 {
+			projected[p[0].name] = v
+			continue
+		}
+		parent, ok := containers[p[:1].String()]
+		if !ok {
+			parent = container(p[1])
+			projected[p[0].name] = parent
+			containers[p[:1].String()] = parent
+		}
+		for i := 1; i < len(p)-1; i++ {
+			child, ok := containers[p[:i+1].String()]
+			if !ok {
+				child = container(p[i+1])
+				attach(parent, p[i], child)
+				containers[p[:i+1].String()] = child
+			}
+			parent = child
+		}
+		attach(parent, p[len(p)-1], v)
+	}
+	return projected
+}
+
+// container returns an empty map, or an empty list if next is an index
+func container(next pathElement) *dynamodb.AttributeValue {
+	if next.isIndex {
+		return &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{}}
+	}
+	return &dynamodb.AttributeValue{M: map[string]*dynamodb.AttributeValue{}}
+}
+
+func attach(parent *dynamodb.AttributeValue, e pathElement, v *dynamodb.AttributeValue) {
+	if e.isIndex {
+		parent.L = append(parent.L, v)
+	} else {
+		parent.M[e.name] = v
+	}
+}
@@ -0,0 +1,86 @@
+package dynamotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// snapshot is what Save writes: every table, with its items in key order.
+// It is encoded like the requests and responses of DynamoDB, so that
+// items read as they do on the wire.
+type snapshot struct {
+	Tables []*tableSnapshot `locationName:"tables"`
+}
+
+type tableSnapshot struct {
+	Description *dynamodb.TableDescription `locationName:"description"`
+	Tags        map[string]*string         `locationName:"tags"`
+	Items       []item                     `locationName:"items"`
+}
+
+// Save writes the tables of f and their items to w, for Load to read
+// back. The tokens of past transactions are not saved.
+func (f *Fake) Save(w io.Writer) error {
+	f.mutex.Lock()
+	var s snapshot
+	for _, name := range tableNames(f.tables) {
+		t := f.tables[name]
+		saved := &tableSnapshot{Description: t.description, Tags: aws.StringMap(t.tags), Items: []item{}}
+		keys := make([]string, 0, len(t.items))
+		for key := range t.items {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			saved.Items = append(saved.Items, t.items[key])
+		}
+		s.Tables = append(s.Tables, saved)
+	}
+	// Encoding happens under the lock, as the tables are shared
+	encoded, err := jsonutil.BuildJSON(&s)
+	f.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, encoded, "", " "); err != nil {
+		return err
+	}
+	_, err = indented.WriteTo(w)
+	return err
+}
+
+// Load replaces the tables of f with those Save wrote to r
+func (f *Fake) Load(r io.Reader) error {
+	var s snapshot
+	if err := jsonutil.UnmarshalJSON(&s, r); err != nil {
+		return fmt.Errorf("dynamotest: reading snapshot: %v", err)
+	}
+	tables := make(map[string]*table)
+	for _, saved := range s.Tables {
+		if saved.Description == nil || saved.Description.TableName == nil {
+			return fmt.Errorf("dynamotest: reading snapshot: table without a name")
+		}
+		t := &table{description: saved.Description, tags: aws.StringValueMap(saved.Tags), items: make(map[string]item)}
+		for _, stored := range saved.Items {
+			if err := t.checkItem(stored); err != nil {
+				return fmt.Errorf("dynamotest: reading snapshot: table %s: %s", t.name(), message(err))
+			}
+			t.items[t.keyString(stored)] = stored
+		}
+		tables[t.name()] = t
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.tables = tables
+	f.tokens = make(map[string]transaction)
+	return nil
+}
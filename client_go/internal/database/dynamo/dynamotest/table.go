@@ -0,0 +1,327 @@
+package dynamotest
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// maxItemSize is the size of the largest item DynamoDB stores
+const maxItemSize = 400 * 1024
+
+func (t *table) name() string {
+	return aws.StringValue(t.description.TableName)
+}
+
+func (t *table) keyAttributes() (string, string) {
+	return keySchema(t.description.KeySchema)
+}
+
+// keyOf returns the key attributes of item
+func (t *table) keyOf(item item) item {
+	hashKey, rangeKey := t.keyAttributes()
+	key := map[string]*dynamodb.AttributeValue{hashKey: item[hashKey]}
+	if rangeKey != "" {
+		key[rangeKey] = item[rangeKey]
+	}
+	return key
+}
+
+// keyString encodes the key of item, which is how the table finds it
+func (t *table) keyString(item item) string {
+	hashKey, rangeKey := t.keyAttributes()
+	s := keyString(item[hashKey])
+	if rangeKey != "" {
+		s += "\x00" + keyString(item[rangeKey])
+	}
+	return s
+}
+
+// checkKey requires key to hold the key attributes of the table, with
+// their types, and nothing else
+func (t *table) checkKey(key item) error {
+	hashKey, rangeKey := t.keyAttributes()
+	want := 1
+	if rangeKey != "" {
+		want = 2
+	}
+	types := attributeTypes(t.description)
+	if len(key) != want {
+		return validationError("The provided key element does not match the schema")
+	}
+	for name, v := range key {
+		if (name != hashKey && name != rangeKey) || v == nil || countTypes(v) != 1 || typeOf(v) != types[name] {
+			return validationError("The provided key element does not match the schema")
+		}
+		if size, ok := size(v); ok && size == 0 {
+			return validationError("One or more parameter values are not valid. The AttributeValue for a key attribute cannot contain an empty string value. Key: " + name)
+		}
+	}
+	return nil
+}
+
+// checkItem validates an item about to be stored: its values, its key
+// and those of the indexes it belongs to, and its size
+func (t *table) checkItem(item item) error {
+	for _, name := range sortedKeys(item) {
+		if err := validateValue(item[name]); err != nil {
+			return err
+		}
+	}
+	types := attributeTypes(t.description)
+	hashKey, rangeKey := t.keyAttributes()
+	for _, name := range []string{hashKey, rangeKey} {
+		if name == "" {
+			continue
+		}
+		v, ok := item[name]
+		switch {
+		case !ok:
+			return validationError("One or more parameter values were invalid: Missing the key " + name + " in the item")
+		case typeOf(v) != types[name]:
+			return validationError(fmt.Sprintf("One or more parameter values were invalid: Type mismatch for key %s expected: %s actual: %s", name, types[name], typeOf(v)))
+		}
+		if size, ok := size(v); ok && size == 0 {
+			return validationError("One or more parameter values are not valid. The AttributeValue for a key attribute cannot contain an empty string value. Key: " + name)
+		}
+	}
+	for _, index := range t.description.GlobalSecondaryIndexes {
+		for _, element := range index.KeySchema {
+			name := aws.StringValue(element.AttributeName)
+			v, ok := item[name]
+			if !ok {
+				continue
+			}
+			if typeOf(v) != types[name] {
+				return validationError(fmt.Sprintf("One or more parameter values were invalid: Type mismatch for Index Key %s Expected: %s Actual: %s IndexName: %s",
+					name, types[name], typeOf(v), aws.StringValue(index.IndexName)))
+			}
+			if size, ok := size(v); ok && size == 0 {
+				return validationError(fmt.Sprintf("One or more parameter values are not valid. A value specified for a secondary index key is not supported. The AttributeValue for a key attribute cannot contain an empty string value. IndexName: %s, IndexKey: %s",
+					aws.StringValue(index.IndexName), name))
+			}
+		}
+	}
+	if itemSize(item) > maxItemSize {
+		return validationError("Item size has exceeded the maximum allowed size")
+	}
+	return nil
+}
+
+// checkKeyUpdate rejects updates to key attributes
+func (t *table) checkKeyUpdate(u *update) error {
+	hashKey, rangeKey := t.keyAttributes()
+	for _, p := range u.paths(true) {
+		if name := p[0].name; name == hashKey || name == rangeKey {
+			return validationError("One or more parameter values were invalid: Cannot update attribute " + name + ". This attribute is part of the key")
+		}
+	}
+	return nil
+}
+
+// view is what Query and Scan read: a table, or one of its indexes
+type view struct {
+	table    *table
+	hashKey  string
+	rangeKey string
+	// index is nil for the table itself
+	index *dynamodb.GlobalSecondaryIndexDescription
+}
+
+// view returns the table, or its index called name if name is set
+func (t *table) view(name *string) (view, error) {
+	if name == nil {
+		hashKey, rangeKey := t.keyAttributes()
+		return view{table: t, hashKey: hashKey, rangeKey: rangeKey}, nil
+	}
+	for _, index := range t.description.GlobalSecondaryIndexes {
+		if aws.StringValue(index.IndexName) == aws.StringValue(name) {
+			return t.indexView(index), nil
+		}
+	}
+	return view{}, validationError(fmt.Sprintf("The table does not have the specified index: %s", aws.StringValue(name)))
+}
+
+func (t *table) indexView(index *dynamodb.GlobalSecondaryIndexDescription) view {
+	hashKey, rangeKey := keySchema(index.KeySchema)
+	return view{table: t, hashKey: hashKey, rangeKey: rangeKey, index: index}
+}
+
+// keyNames returns the attributes that order the view: its own key and,
+// for an index, that of the table
+func (v view) keyNames() []string {
+	names := []string{v.hashKey}
+	if v.rangeKey != "" {
+		names = append(names, v.rangeKey)
+	}
+	if v.index != nil {
+		hashKey, rangeKey := v.table.keyAttributes()
+		for _, name := range []string{hashKey, rangeKey} {
+			if name != "" && name != v.hashKey && name != v.rangeKey {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+func (v view) less(a item, b item) bool {
+	for _, name := range v.keyNames() {
+		if order, _ := compare(a[name], b[name]); order != 0 {
+			return order < 0
+		}
+	}
+	return false
+}
+
+// items returns the items of the view, sorted, as stored. The items of a
+// sparse index are those that hold its key attributes.
+func (v view) items() []item {
+	items := make([]item, 0, len(v.table.items))
+	for _, stored := range v.table.items {
+		if stored[v.hashKey] == nil || (v.rangeKey != "" && stored[v.rangeKey] == nil) {
+			continue
+		}
+		items = append(items, stored)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return v.less(items[i], items[j])
+	})
+	return items
+}
+
+// project returns a copy of the attributes of stored the view holds
+func (v view) project(stored item) item {
+	if v.index == nil || aws.StringValue(v.index.Projection.ProjectionType) == dynamodb.ProjectionTypeAll {
+		return copyItem(stored)
+	}
+	var paths []path
+	for _, name := range append(v.keyNames(), aws.StringValueSlice(v.index.Projection.NonKeyAttributes)...) {
+		paths = append(paths, path{{name: name}})
+	}
+	return project(stored, paths)
+}
+
+// lastKey returns the key that a page ending with stored continues from
+func (v view) lastKey(stored item) item {
+	key := make(map[string]*dynamodb.AttributeValue)
+	for _, name := range v.keyNames() {
+		key[name] = copyValue(stored[name])
+	}
+	return key
+}
+
+// checkStartKey requires an ExclusiveStartKey to be made of the
+// attributes lastKey returns
+func (v view) checkStartKey(start item) error {
+	types := attributeTypes(v.table.description)
+	names := v.keyNames()
+	if len(start) != len(names) {
+		return validationError("The provided starting key is invalid: The provided key element does not match the schema")
+	}
+	for _, name := range names {
+		if value, ok := start[name]; !ok || countTypes(value) != 1 || typeOf(value) != types[name] {
+			return validationError("The provided starting key is invalid: The provided key element does not match the schema")
+		}
+	}
+	return nil
+}
+
+// segment returns the items of the given segment out of total, by the
+// hash of their partition key, as a parallel Scan reads them
+func (v view) segment(items []item, segment int64, total int64) []item {
+	var kept []item
+	for _, stored := range items {
+		h := fnv.New32a()
+		h.Write([]byte(keyString(stored[v.hashKey])))
+		if int64(h.Sum32())%total == segment {
+			kept = append(kept, stored)
+		}
+	}
+	return kept
+}
+
+// page is what a Query or Scan returns
+type page struct {
+	items   []item
+	count   int64
+	scanned int64
+	lastKey item
+	bytes   int
+}
+
+// read evaluates items, which are sorted in the order they are read in,
+// starting after start if set and stopping after limit items if set.
+// Those meeting filter are returned with the attributes at paths, or not
+// at all if countOnly is set.
+func (v view) read(items []item, start item, forward bool, limit *int64, filter condition, paths []path, countOnly bool) (page, error) {
+	var result page
+	if start != nil {
+		if err := v.checkStartKey(start); err != nil {
+			return result, err
+		}
+		first := sort.Search(len(items), func(i int) bool {
+			if forward {
+				return v.less(start, items[i])
+			}
+			return v.less(items[i], start)
+		})
+		items = items[first:]
+	}
+	for _, stored := range items {
+		if limit != nil && result.scanned == *limit {
+			break
+		}
+		result.scanned++
+		projected := v.project(stored)
+		result.bytes += itemSize(projected)
+		// Like DynamoDB, a full page has a LastEvaluatedKey even if
+		// nothing follows it
+		if limit != nil && result.scanned == *limit {
+			result.lastKey = v.lastKey(stored)
+		}
+		if !holds(filter, projected) {
+			continue
+		}
+		result.count++
+		if countOnly {
+			continue
+		}
+		if paths != nil {
+			projected = project(projected, paths)
+		}
+		result.items = append(result.items, projected)
+	}
+	if result.items == nil && !countOnly {
+		result.items = []item{}
+	}
+	return result, nil
+}
+
+// capacity reports the units consumed on table, if mode asks for them
+func capacity(mode *string, table string, units float64) *dynamodb.ConsumedCapacity {
+	switch aws.StringValue(mode) {
+	case dynamodb.ReturnConsumedCapacityTotal, dynamodb.ReturnConsumedCapacityIndexes:
+		return &dynamodb.ConsumedCapacity{TableName: aws.String(table), CapacityUnits: aws.Float64(units)}
+	}
+	return nil
+}
+
+// readUnits returns the capacity reading bytes takes: a unit per 4 KB,
+// halved for eventually consistent reads
+func readUnits(bytes int, consistent bool) float64 {
+	units := math.Max(1, math.Ceil(float64(bytes)/4096))
+	if !consistent {
+		units /= 2
+	}
+	return units
+}
+
+// writeUnits returns the capacity writing bytes takes: a unit per KB
+func writeUnits(bytes int) float64 {
+	return math.Max(1, math.Ceil(float64(bytes)/1024))
+}
@@ -0,0 +1,126 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+)
+
+// translateError maps SDK errors onto the model's error types. Cancelled
+// requests yield the context's error, and any other error is left as is.
+func translateError(operation string, err error) error {
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return err
+	}
+	switch aerr.Code() {
+	case request.CanceledErrorCode:
+		if aerr.OrigErr() != nil {
+			return fmt.Errorf("%s: %w", operation, aerr.OrigErr())
+		}
+		return fmt.Errorf("%s: %w", operation, context.Canceled)
+	case dynamodb.ErrCodeProvisionedThroughputExceededException,
+		dynamodb.ErrCodeRequestLimitExceeded,
+		"ThrottlingException":
+		return &model.ThrottledError{Operation: operation, Err: err}
+	case "ValidationException",
+		// Parameters rejected by the SDK before sending the request
+		request.InvalidParameterErrCode:
+		return &model.ValidationError{Reason: aerr.Message(), Err: err}
+	case dynamodb.ErrCodeInternalServerError,
+		dynamodb.ErrCodeTransactionConflictException,
+		dynamodb.ErrCodeTransactionInProgressException,
+		"ServiceUnavailable",
+		request.ErrCodeRequestError,
+		request.ErrCodeResponseTimeout,
+		request.ErrCodeRead:
+		return &model.TransientError{Operation: operation, Err: err}
+	case dynamodb.ErrCodeTransactionCanceledException:
+		// Condition failures are handled by the caller, whereas the
+		// following reasons apply to any transaction
+		if v, ok := err.(*dynamodb.TransactionCanceledException); ok {
+			for _, reason := range v.CancellationReasons {
+				switch aws.StringValue(reason.Code) {
+				case "ThrottlingError", "ProvisionedThroughputExceeded":
+					return &model.ThrottledError{Operation: operation, Err: err}
+				case "TransactionConflict":
+					return &model.TransientError{Operation: operation, Err: err}
+				}
+			}
+		}
+	}
+	if rerr, ok := err.(awserr.RequestFailure); ok && rerr.StatusCode() >= 500 {
+		return &model.TransientError{Operation: operation, Err: err}
+	}
+	return err
+}
+
+// isConditionFailure reports whether the transaction item at index was
+// the reason for err
+func isConditionFailure(err error, index int) bool {
+	if v, ok := err.(*dynamodb.TransactionCanceledException); ok {
+		return len(v.CancellationReasons) > index && aws.StringValue(v.CancellationReasons[index].Code) == "ConditionalCheckFailed"
+	}
+	return false
+}
+
+// explainListCondition reads the list back once a condition on it has
+// failed, to tell which of the model's errors applies. userID is checked
+// against the list's owner unless empty, in which case the list is
+// expected not to be under deletion.
+func (session *DBSession) explainListCondition(ctx context.Context, method string, listID string, userID string, action string) error {
+	input := &dynamodb.GetItemInput{
+		TableName: aws.String(session.tables.Lists),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(listID),
+			},
+		},
+		ConsistentRead:         aws.Bool(true),
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	}
+	output, err := session.DynamoDBresource.GetItemWithContext(ctx, input)
+	if err != nil {
+		return translateError(method, err)
+	}
+	logConsumedCapacity(method, output.ConsumedCapacity)
+	return explainList(method, output.Item, listID, userID, action)
+}
+
+// explainList picks the error explaining a failed condition from the
+// list as read back, which is empty if the list does not exist
+func explainList(method string, item map[string]*dynamodb.AttributeValue, listID string, userID string, action string) error {
+	if len(item) == 0 {
+		return &model.NotFoundError{
+			Entity: model.EntityList,
+			Key:    listID,
+		}
+	}
+	owner := ""
+	if v := item["user_id"]; v != nil {
+		owner = aws.StringValue(v.S)
+	}
+	underDeletion := item["under_deletion"] != nil
+	switch {
+	case userID != "" && owner != userID:
+		return &model.ForbiddenError{
+			UserID: userID,
+			ListID: listID,
+			Action: action,
+		}
+	case userID == "" && underDeletion:
+		return &model.UnderDeletionError{
+			ListID: listID,
+		}
+	}
+	return &model.TransientError{
+		Operation: method,
+		Err:       errors.New("list changed while its conditions were checked"),
+	}
+}
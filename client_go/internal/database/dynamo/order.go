@@ -0,0 +1,106 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+	"github.com/google/uuid"
+)
+
+// maxTransactItems is the number of actions TransactWriteItems takes at
+// most
+const maxTransactItems = 25
+
+// orderUpdate sets the order of the item at key and bumps its version,
+// provided that it is still at the version the move was planned from
+func orderUpdate(table string, key map[string]*dynamodb.AttributeValue, move model.Move) *dynamodb.Update {
+	return &dynamodb.Update{
+		TableName:           aws.String(table),
+		Key:                 key,
+		UpdateExpression:    aws.String("SET #r = :r, version = version + :o"),
+		ConditionExpression: aws.String("version = :v"),
+		ExpressionAttributeNames: map[string]*string{
+			// ORDER is a reserved word
+			"#r": aws.String("order"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":r": {N: aws.String(strconv.FormatFloat(move.Order, 'g', -1, 64))},
+			":v": {N: aws.String(strconv.Itoa(move.Version))},
+			":o": {N: aws.String("1")},
+		},
+	}
+}
+
+// moveItem applies the moves planned by model.PlanMove, each written by
+// the update returned by update, and returns the new version of the
+// moved item. A single move is a plain conditional update; when the
+// list is renumbered, the moves are written in transactions of up to
+// maxTransactItems, so that a list changing halfway through may be left
+// partly renumbered, although still in a valid order. conflict explains
+// why the condition on the moved item failed.
+func (session *connection) moveItem(ctx context.Context, method string, update func(move model.Move) *dynamodb.Update, moves []model.Move, conflict func(ctx context.Context) error) (int, error) {
+	if len(moves) == 1 {
+		update := update(moves[0])
+		output, err := session.DynamoDBresource.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 update.TableName,
+			Key:                       update.Key,
+			UpdateExpression:          update.UpdateExpression,
+			ConditionExpression:       update.ConditionExpression,
+			ExpressionAttributeNames:  update.ExpressionAttributeNames,
+			ExpressionAttributeValues: update.ExpressionAttributeValues,
+			ReturnConsumedCapacity:    aws.String(dynamodb.ReturnConsumedCapacityTotal),
+		})
+		if err != nil {
+			if aeer, ok := err.(awserr.Error); ok {
+				if aeer.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+					return 0, conflict(ctx)
+				}
+			}
+			return 0, translateError(method, err)
+		}
+		logConsumedCapacity(method, output.ConsumedCapacity)
+		return moves[0].Version + 1, nil
+	}
+
+	log.Printf("%s renumbering %d items", method, len(moves))
+	for start := 0; start < len(moves); start += maxTransactItems {
+		end := start + maxTransactItems
+		if end > len(moves) {
+			end = len(moves)
+		}
+		transactItems := make([]*dynamodb.TransactWriteItem, 0, end-start)
+		for _, move := range moves[start:end] {
+			transactItems = append(transactItems, &dynamodb.TransactWriteItem{
+				Update: update(move),
+			})
+		}
+		output, err := session.DynamoDBresource.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+			ClientRequestToken:     aws.String(uuid.New().String()),
+			ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+			TransactItems:          transactItems,
+		})
+		if err != nil {
+			if start == 0 && isConditionFailure(err, 0) {
+				return 0, conflict(ctx)
+			}
+			if _, ok := err.(*dynamodb.TransactionCanceledException); ok {
+				return 0, &model.TransientError{
+					Operation: method,
+					Err:       errors.New("the list changed while its items were being renumbered"),
+				}
+			}
+			return 0, translateError(method, err)
+		}
+		for i, v := range output.ConsumedCapacity {
+			logConsumedCapacity(fmt.Sprintf("%s #%d", method, i), v)
+		}
+	}
+	return moves[0].Version + 1, nil
+}
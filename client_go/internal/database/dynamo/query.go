@@ -0,0 +1,102 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+)
+
+// queryAll follows LastEvaluatedKey until the whole result set, which
+// may exceed the 1 MB a single Query returns, has been read
+func (session *connection) queryAll(ctx context.Context, method string, input *dynamodb.QueryInput) ([]map[string]*dynamodb.AttributeValue, error) {
+	items := make([]map[string]*dynamodb.AttributeValue, 0)
+	for page := 0; ; page++ {
+		output, err := session.DynamoDBresource.QueryWithContext(ctx, input)
+		if err != nil {
+			return nil, translateError(method, err)
+		}
+		logConsumedCapacity(fmt.Sprintf("%s page #%d", method, page), output.ConsumedCapacity)
+		items = append(items, output.Items...)
+		if len(output.LastEvaluatedKey) == 0 {
+			return items, nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+// checkPageSize rejects a max that no page can honour
+func checkPageSize(max int64) error {
+	if max < 1 {
+		return &model.ValidationError{
+			Field:  "max",
+			Reason: fmt.Sprintf("%d is not a positive page size", max),
+		}
+	}
+	return nil
+}
+
+// queryPage reads up to max items starting after exclusiveStartKey. The
+// returned key is nil once there are no further pages; like Scan, it may
+// also be set when the last page happens to be full.
+func (session *connection) queryPage(ctx context.Context, method string, input *dynamodb.QueryInput, exclusiveStartKey map[string]*dynamodb.AttributeValue, max int64) ([]map[string]*dynamodb.AttributeValue, map[string]*dynamodb.AttributeValue, error) {
+	if err := checkPageSize(max); err != nil {
+		return nil, nil, err
+	}
+	input.ExclusiveStartKey = exclusiveStartKey
+	input.Limit = aws.Int64(max)
+	output, err := session.DynamoDBresource.QueryWithContext(ctx, input)
+	if err != nil {
+		return nil, nil, translateError(method, err)
+	}
+	logConsumedCapacity(method, output.ConsumedCapacity)
+	if len(output.LastEvaluatedKey) == 0 {
+		return output.Items, nil, nil
+	}
+	return output.Items, output.LastEvaluatedKey, nil
+}
+
+// startKey turns a cursor made by nextCursor back into an
+// ExclusiveStartKey, adding the partition key given as attribute/value
+// pairs. The zero Cursor yields nil, meaning the first page.
+func startKey(cursor model.Cursor, attribute string, partitionKey ...string) (map[string]*dynamodb.AttributeValue, error) {
+	keys, err := cursor.Keys(1)
+	if err != nil || keys == nil {
+		return nil, err
+	}
+	key := map[string]*dynamodb.AttributeValue{
+		attribute: {S: aws.String(keys[0])},
+	}
+	for i := 0; i+1 < len(partitionKey); i += 2 {
+		key[partitionKey[i]] = &dynamodb.AttributeValue{S: aws.String(partitionKey[i+1])}
+	}
+	return key, nil
+}
+
+// nextCursor encodes the sort key attribute of a LastEvaluatedKey; the
+// partition key is known to the caller and is left out
+func nextCursor(key map[string]*dynamodb.AttributeValue, attribute string) model.Cursor {
+	if v, ok := key[attribute]; ok && v.S != nil {
+		return model.NewCursor(*v.S)
+	}
+	return ""
+}
+
+// scanAll is the counterpart of queryAll for Scan
+func (session *connection) scanAll(ctx context.Context, method string, input *dynamodb.ScanInput) ([]map[string]*dynamodb.AttributeValue, error) {
+	items := make([]map[string]*dynamodb.AttributeValue, 0)
+	for page := 0; ; page++ {
+		output, err := session.DynamoDBresource.ScanWithContext(ctx, input)
+		if err != nil {
+			return nil, translateError(method, err)
+		}
+		logConsumedCapacity(fmt.Sprintf("%s page #%d", method, page), output.ConsumedCapacity)
+		items = append(items, output.Items...)
+		if len(output.LastEvaluatedKey) == 0 {
+			return items, nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
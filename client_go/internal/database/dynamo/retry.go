@@ -0,0 +1,144 @@
+package dynamo
+
+import (
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// RetryPolicy decides how requests that failed for lack of capacity, a
+// transaction conflict or a transient fault are retried
+//
+// Delays grow exponentially from BaseDelayMS up to MaxDelayMS, with
+// jitter. Every retry spends a token from a budget of Budget tokens
+// shared by the whole session, and every successful request gives one
+// back, so that retries stop piling up once most requests fail. A Budget
+// of zero or less disables it.
+//
+// Transactions carry a ClientRequestToken, which makes retrying one that
+// did succeed, but whose response was lost, a no-op.
+type RetryPolicy struct {
+	MaxRetries  int `json:"max_retries"`
+	BaseDelayMS int `json:"base_delay_ms"`
+	MaxDelayMS  int `json:"max_delay_ms"`
+	Budget      int `json:"budget"`
+}
+
+// backoffDelay returns the delay before the given retry attempt (1
+// onwards)
+func backoffDelay(base time.Duration, max time.Duration, attempt int) time.Duration {
+	delay := base << uint(attempt-1)
+	if delay > max || delay <= 0 {
+		delay = max
+	}
+	// Equal jitter: half the delay plus a random share of the other half
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// isTransactionConflict reports whether a transaction was cancelled
+// only because of conflicting writes or lack of capacity, in which case
+// it can be submitted again as it is
+func isTransactionConflict(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case dynamodb.ErrCodeTransactionConflictException,
+			dynamodb.ErrCodeTransactionInProgressException:
+			return true
+		}
+	}
+	v, ok := err.(*dynamodb.TransactionCanceledException)
+	if !ok {
+		return false
+	}
+	conflict := false
+	for _, reason := range v.CancellationReasons {
+		switch aws.StringValue(reason.Code) {
+		case "None":
+		case "TransactionConflict", "ThrottlingError", "ProvisionedThroughputExceeded":
+			conflict = true
+		default:
+			return false
+		}
+	}
+	return conflict
+}
+
+// retryer implements request.Retryer according to a RetryPolicy
+type retryer struct {
+	policy RetryPolicy
+	mutex  sync.Mutex
+	tokens int
+}
+
+func newRetryer(policy RetryPolicy) *retryer {
+	return &retryer{
+		policy: policy,
+		tokens: policy.Budget,
+	}
+}
+
+// MaxRetries is a method
+func (r *retryer) MaxRetries() int {
+	return r.policy.MaxRetries
+}
+
+// ShouldRetry is a method
+func (r *retryer) ShouldRetry(req *request.Request) bool {
+	// The SDK's handlers have already ruled out cancelled requests
+	if req.Retryable != nil && !*req.Retryable {
+		return false
+	}
+	if req.RetryCount >= r.policy.MaxRetries {
+		return false
+	}
+	if !aws.BoolValue(req.Retryable) && !req.IsErrorRetryable() && !req.IsErrorThrottle() && !isTransactionConflict(req.Error) {
+		return false
+	}
+	if r.policy.Budget <= 0 {
+		return true
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.tokens == 0 {
+		log.Printf("%s retry budget exhausted: %v", req.Operation.Name, req.Error)
+		return false
+	}
+	r.tokens--
+	return true
+}
+
+// RetryRules is a method
+func (r *retryer) RetryRules(req *request.Request) time.Duration {
+	delay := backoffDelay(
+		time.Duration(r.policy.BaseDelayMS)*time.Millisecond,
+		time.Duration(r.policy.MaxDelayMS)*time.Millisecond,
+		req.RetryCount+1)
+	log.Printf("%s retry #%d in %dms: %v", req.Operation.Name, req.RetryCount+1, delay.Milliseconds(), req.Error)
+	return delay
+}
+
+// complete returns a token to the budget after a successful request and
+// logs how many retries it took
+func (r *retryer) complete(req *request.Request) {
+	if req.RetryCount > 0 {
+		if req.Error != nil {
+			log.Printf("%s failed after %d retries", req.Operation.Name, req.RetryCount)
+		} else {
+			log.Printf("%s succeeded after %d retries", req.Operation.Name, req.RetryCount)
+		}
+	}
+	if req.Error != nil || r.policy.Budget <= 0 {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.tokens < r.policy.Budget {
+		r.tokens++
+	}
+}
@@ -0,0 +1,120 @@
+package dynamo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+)
+
+// flakyEndpoint answers every request with the given error until
+// failures have been served, and with an empty item afterwards
+type flakyEndpoint struct {
+	mutex    sync.Mutex
+	code     string
+	failures int
+	requests int
+	tokens   map[string]bool
+}
+
+func (e *flakyEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.requests++
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	if e.requests <= e.failures {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"__type":"com.amazonaws.dynamodb.v20120810#%s","message":"try again"}`, e.code)
+		return
+	}
+	fmt.Fprint(w, `{}`)
+}
+
+func newFlakySession(t *testing.T, endpoint *flakyEndpoint, policy RetryPolicy) *DBSession {
+	t.Helper()
+	server := httptest.NewServer(endpoint)
+	t.Cleanup(server.Close)
+	config := DefaultConfig()
+	config.Endpoint = server.URL
+	config.Credentials = CredentialsStatic
+	config.Retry = policy
+	awsSession, err := NewAWSSession(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewWithClient(dynamodb.New(awsSession), config)
+}
+
+func TestRetryThrottling(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 3, BaseDelayMS: 1, MaxDelayMS: 2, Budget: 10}
+	tests := []struct {
+		name     string
+		failures int
+		budget   int
+		requests int
+		expected error
+	}{
+		{"Recovers", 3, policy.Budget, 4, nil},
+		{"GivesUp", 4, policy.Budget, 4, model.ErrThrottled},
+		{"Budget", 4, 1, 2, model.ErrThrottled},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			endpoint := &flakyEndpoint{code: dynamodb.ErrCodeProvisionedThroughputExceededException, failures: test.failures}
+			policy := policy
+			policy.Budget = test.budget
+			session := newFlakySession(t, endpoint, policy)
+			_, err := session.GetListByListID(context.Background(), "list")
+			if test.expected == nil && !errors.Is(err, model.ErrNotFound) {
+				t.Fatalf("expected the empty response to yield ErrNotFound, got %v", err)
+			}
+			if test.expected != nil && !errors.Is(err, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, err)
+			}
+			if endpoint.requests != test.requests {
+				t.Fatalf("expected %d requests, got %d", test.requests, endpoint.requests)
+			}
+		})
+	}
+}
+
+func TestRetryValidation(t *testing.T) {
+	endpoint := &flakyEndpoint{code: "ValidationException", failures: 1}
+	session := newFlakySession(t, endpoint, RetryPolicy{MaxRetries: 3, BaseDelayMS: 1, MaxDelayMS: 2})
+	_, err := session.GetListByListID(context.Background(), "list")
+	if !errors.Is(err, model.ErrValidation) || endpoint.requests != 1 {
+		t.Fatalf("expected a single failed request, got %d requests and %v", endpoint.requests, err)
+	}
+}
+
+func TestIsTransactionConflict(t *testing.T) {
+	reasons := func(codes ...string) error {
+		err := &dynamodb.TransactionCanceledException{}
+		for _, code := range codes {
+			code := code
+			err.CancellationReasons = append(err.CancellationReasons, &dynamodb.CancellationReason{Code: &code})
+		}
+		return err
+	}
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{reasons("None", "TransactionConflict"), true},
+		{reasons("ThrottlingError", "None"), true},
+		{reasons("ConditionalCheckFailed", "TransactionConflict"), false},
+		{reasons("None", "None"), false},
+		{errors.New("other"), false},
+	}
+	for i, test := range tests {
+		if actual := isTransactionConflict(test.err); actual != test.expected {
+			t.Errorf("#%d: expected %t, got %t", i, test.expected, actual)
+		}
+	}
+}
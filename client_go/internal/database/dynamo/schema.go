@@ -0,0 +1,460 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Schema modes, deciding what the applications do with the schema on
+// startup
+const (
+	// SchemaVerify fails unless the tables match the declaration
+	SchemaVerify = "verify"
+	// SchemaApply creates missing tables and indexes, then verifies
+	SchemaApply = "apply"
+	// SchemaNone leaves the tables alone
+	SchemaNone = "none"
+)
+
+// indexPollInterval is how often ApplySchema checks on an index being
+// created, which may take minutes on a populated table
+const indexPollInterval = 5 * time.Second
+
+// TableSchema declares a table along with its global secondary indexes.
+// Every key attribute is a string, but for the range keys of indexes
+// declaring another type.
+type TableSchema struct {
+	Name     string
+	HashKey  string
+	RangeKey string
+	Indexes  []IndexSchema
+}
+
+// IndexSchema declares a global secondary index. RangeKeyType is one of
+// the dynamodb.ScalarAttributeType values, the empty string standing for
+// a string.
+type IndexSchema struct {
+	Name             string
+	HashKey          string
+	RangeKey         string
+	RangeKeyType     string
+	ProjectionType   string
+	NonKeyAttributes []string
+}
+
+// SchemaError lists the differences between the live tables and the
+// declaration
+type SchemaError struct {
+	Differences []string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("DynamoDB tables do not match the schema: %s", strings.Join(e.Differences, "; "))
+}
+
+// Schema declares the tables described by config, as main.tf does
+func Schema(config Config) []TableSchema {
+	names := config.TableNames()
+	return []TableSchema{
+		{
+			Name:    names.Users,
+			HashKey: "id",
+			Indexes: []IndexSchema{
+				{
+					Name:             config.Indexes.UsersByEmail,
+					HashKey:          "email",
+					ProjectionType:   dynamodb.ProjectionTypeInclude,
+					NonKeyAttributes: []string{"email"},
+				},
+			},
+		},
+		{
+			Name:    names.Lists,
+			HashKey: "id",
+			Indexes: []IndexSchema{
+				{
+					Name:             config.Indexes.ListsByUserID,
+					HashKey:          "user_id",
+					ProjectionType:   dynamodb.ProjectionTypeInclude,
+					NonKeyAttributes: []string{"id", "title"},
+				},
+			},
+		},
+		{
+			Name:     names.Guests,
+			HashKey:  "list_id",
+			RangeKey: "user_id",
+			Indexes: []IndexSchema{
+				{
+					Name:           config.Indexes.GuestsByUserID,
+					HashKey:        "user_id",
+					RangeKey:       "list_id",
+					ProjectionType: dynamodb.ProjectionTypeKeysOnly,
+				},
+			},
+		},
+		{
+			Name:     names.Items,
+			HashKey:  "list_id",
+			RangeKey: "datetime",
+			Indexes: []IndexSchema{
+				{
+					Name:           config.Indexes.ItemsByOrder,
+					HashKey:        "list_id",
+					RangeKey:       "order",
+					RangeKeyType:   dynamodb.ScalarAttributeTypeN,
+					ProjectionType: dynamodb.ProjectionTypeAll,
+				},
+			},
+		},
+	}
+}
+
+// SingleTableSchema declares the table of SingleTableSession, whose
+// overloaded indexes are described in singletable.go
+func SingleTableSchema(config Config) TableSchema {
+	return TableSchema{
+		Name:     config.TableNames().Single,
+		HashKey:  attributePK,
+		RangeKey: attributeSK,
+		Indexes: []IndexSchema{
+			{
+				Name:           config.Indexes.GSI1,
+				HashKey:        attributeGSI1PK,
+				RangeKey:       attributeGSI1SK,
+				ProjectionType: dynamodb.ProjectionTypeAll,
+			},
+			{
+				Name:             config.Indexes.GSI2,
+				HashKey:          attributeGSI2PK,
+				RangeKey:         attributeGSI2SK,
+				ProjectionType:   dynamodb.ProjectionTypeInclude,
+				NonKeyAttributes: []string{"id", "email"},
+			},
+		},
+	}
+}
+
+func keySchema(hashKey string, rangeKey string) []*dynamodb.KeySchemaElement {
+	elements := []*dynamodb.KeySchemaElement{
+		{
+			AttributeName: aws.String(hashKey),
+			KeyType:       aws.String(dynamodb.KeyTypeHash),
+		},
+	}
+	if rangeKey != "" {
+		elements = append(elements, &dynamodb.KeySchemaElement{
+			AttributeName: aws.String(rangeKey),
+			KeyType:       aws.String(dynamodb.KeyTypeRange),
+		})
+	}
+	return elements
+}
+
+// describeKeySchema returns the hash and range key attributes
+func describeKeySchema(elements []*dynamodb.KeySchemaElement) (string, string) {
+	var hashKey, rangeKey string
+	for _, element := range elements {
+		switch aws.StringValue(element.KeyType) {
+		case dynamodb.KeyTypeHash:
+			hashKey = aws.StringValue(element.AttributeName)
+		case dynamodb.KeyTypeRange:
+			rangeKey = aws.StringValue(element.AttributeName)
+		}
+	}
+	return hashKey, rangeKey
+}
+
+// attributeDefinitions declares the key attributes of the table and of
+// the given indexes
+func (table TableSchema) attributeDefinitions(indexes []IndexSchema) []*dynamodb.AttributeDefinition {
+	seen := make(map[string]bool)
+	definitions := make([]*dynamodb.AttributeDefinition, 0)
+	add := func(name string, attributeType string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			if attributeType == "" {
+				attributeType = dynamodb.ScalarAttributeTypeS
+			}
+			definitions = append(definitions, &dynamodb.AttributeDefinition{
+				AttributeName: aws.String(name),
+				AttributeType: aws.String(attributeType),
+			})
+		}
+	}
+	add(table.HashKey, "")
+	add(table.RangeKey, "")
+	for _, index := range indexes {
+		add(index.HashKey, "")
+		add(index.RangeKey, index.RangeKeyType)
+	}
+	return definitions
+}
+
+func (index IndexSchema) projection() *dynamodb.Projection {
+	projection := &dynamodb.Projection{
+		ProjectionType: aws.String(index.ProjectionType),
+	}
+	if len(index.NonKeyAttributes) > 0 {
+		projection.NonKeyAttributes = aws.StringSlice(index.NonKeyAttributes)
+	}
+	return projection
+}
+
+// CreateTableInput provisions the table and its indexes with the given
+// capacity
+func (table TableSchema) CreateTableInput(throughput *dynamodb.ProvisionedThroughput) *dynamodb.CreateTableInput {
+	input := &dynamodb.CreateTableInput{
+		TableName:             aws.String(table.Name),
+		AttributeDefinitions:  table.attributeDefinitions(table.Indexes),
+		KeySchema:             keySchema(table.HashKey, table.RangeKey),
+		ProvisionedThroughput: throughput,
+	}
+	for _, index := range table.Indexes {
+		input.GlobalSecondaryIndexes = append(input.GlobalSecondaryIndexes, &dynamodb.GlobalSecondaryIndex{
+			IndexName:             aws.String(index.Name),
+			KeySchema:             keySchema(index.HashKey, index.RangeKey),
+			Projection:            index.projection(),
+			ProvisionedThroughput: throughput,
+		})
+	}
+	return input
+}
+
+// Diff compares a DescribeTable output against the declaration. A nil
+// description stands for a missing table. Indexes that are not declared
+// are ignored.
+func (table TableSchema) Diff(description *dynamodb.TableDescription) []string {
+	if description == nil {
+		return []string{fmt.Sprintf("table %s is missing", table.Name)}
+	}
+	differences := make([]string, 0)
+	if status := aws.StringValue(description.TableStatus); status != dynamodb.TableStatusActive {
+		differences = append(differences, fmt.Sprintf("table %s is %s", table.Name, status))
+	}
+	hashKey, rangeKey := describeKeySchema(description.KeySchema)
+	if hashKey != table.HashKey || rangeKey != table.RangeKey {
+		differences = append(differences, fmt.Sprintf("table %s is keyed on (%s,%s), expected (%s,%s)",
+			table.Name, hashKey, rangeKey, table.HashKey, table.RangeKey))
+	}
+	types := make(map[string]string)
+	for _, definition := range description.AttributeDefinitions {
+		types[aws.StringValue(definition.AttributeName)] = aws.StringValue(definition.AttributeType)
+	}
+	for _, definition := range table.attributeDefinitions(table.Indexes) {
+		name, expected := aws.StringValue(definition.AttributeName), aws.StringValue(definition.AttributeType)
+		if t, ok := types[name]; ok && t != expected {
+			differences = append(differences, fmt.Sprintf("table %s declares attribute %s as %s, expected %s",
+				table.Name, name, t, expected))
+		}
+	}
+
+	live := make(map[string]*dynamodb.GlobalSecondaryIndexDescription)
+	for _, index := range description.GlobalSecondaryIndexes {
+		live[aws.StringValue(index.IndexName)] = index
+	}
+	for _, index := range table.Indexes {
+		liveIndex, ok := live[index.Name]
+		if !ok {
+			differences = append(differences, fmt.Sprintf("table %s is missing index %s", table.Name, index.Name))
+			continue
+		}
+		if status := aws.StringValue(liveIndex.IndexStatus); status != dynamodb.IndexStatusActive {
+			differences = append(differences, fmt.Sprintf("index %s.%s is %s", table.Name, index.Name, status))
+		}
+		hashKey, rangeKey := describeKeySchema(liveIndex.KeySchema)
+		if hashKey != index.HashKey || rangeKey != index.RangeKey {
+			differences = append(differences, fmt.Sprintf("index %s.%s is keyed on (%s,%s), expected (%s,%s)",
+				table.Name, index.Name, hashKey, rangeKey, index.HashKey, index.RangeKey))
+		}
+		var projectionType string
+		var nonKeyAttributes []string
+		if liveIndex.Projection != nil {
+			projectionType = aws.StringValue(liveIndex.Projection.ProjectionType)
+			nonKeyAttributes = aws.StringValueSlice(liveIndex.Projection.NonKeyAttributes)
+		}
+		if projectionType != index.ProjectionType || !sameStrings(nonKeyAttributes, index.NonKeyAttributes) {
+			differences = append(differences, fmt.Sprintf("index %s.%s projects %s %v, expected %s %v",
+				table.Name, index.Name, projectionType, nonKeyAttributes, index.ProjectionType, index.NonKeyAttributes))
+		}
+	}
+	return differences
+}
+
+// sameStrings compares a and b as sets
+func sameStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sortedA := append([]string{}, a...)
+	sortedB := append([]string{}, b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+	for i := range sortedA {
+		if sortedA[i] != sortedB[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// describeTable returns nil, and no error, if the table does not exist
+func (session *connection) describeTable(ctx context.Context, name string) (*dynamodb.TableDescription, error) {
+	output, err := session.DynamoDBresource.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(name),
+	})
+	if err != nil {
+		if aeer, ok := err.(awserr.Error); ok {
+			if aeer.Code() == dynamodb.ErrCodeResourceNotFoundException {
+				return nil, nil
+			}
+		}
+		return nil, translateError("DescribeTable", err)
+	}
+	return output.Table, nil
+}
+
+// DiffSchema lists the differences between the live tables and the
+// declaration, which are none if the slice is empty
+func (session *connection) DiffSchema(ctx context.Context) ([]string, error) {
+	differences := make([]string, 0)
+	for _, table := range session.schema {
+		description, err := session.describeTable(ctx, table.Name)
+		if err != nil {
+			return nil, err
+		}
+		differences = append(differences, table.Diff(description)...)
+	}
+	return differences, nil
+}
+
+// VerifySchema returns a *SchemaError unless the live tables match the
+// declaration
+func (session *connection) VerifySchema(ctx context.Context) error {
+	differences, err := session.DiffSchema(ctx)
+	if err != nil {
+		return err
+	}
+	if len(differences) > 0 {
+		return &SchemaError{Differences: differences}
+	}
+	return nil
+}
+
+// ApplySchema creates the missing tables and indexes, waiting for them
+// to become active, and then verifies the schema. Differences in keys
+// or projections cannot be fixed in place and are left to the operator.
+func (session *connection) ApplySchema(ctx context.Context) error {
+	const method = "ApplySchema"
+	defer logEnd(method, time.Now())
+	for _, table := range session.schema {
+		description, err := session.describeTable(ctx, table.Name)
+		if err != nil {
+			return err
+		}
+		if description == nil {
+			log.Printf("%s (creating table %s)", method, table.Name)
+			input := table.CreateTableInput(session.throughput())
+			if _, err := session.DynamoDBresource.CreateTableWithContext(ctx, input); err != nil {
+				return translateError("CreateTable", err)
+			}
+			err := session.DynamoDBresource.WaitUntilTableExistsWithContext(ctx, &dynamodb.DescribeTableInput{
+				TableName: aws.String(table.Name),
+			})
+			if err != nil {
+				return translateError("DescribeTable", err)
+			}
+			continue
+		}
+
+		live := make(map[string]bool)
+		for _, index := range description.GlobalSecondaryIndexes {
+			live[aws.StringValue(index.IndexName)] = true
+		}
+		for _, index := range table.Indexes {
+			if live[index.Name] {
+				continue
+			}
+			// DynamoDB creates a single index per UpdateTable call
+			if err := session.createIndex(ctx, table, index, description.BillingModeSummary); err != nil {
+				return err
+			}
+		}
+	}
+	return session.VerifySchema(ctx)
+}
+
+// createIndex adds index to an existing table and waits for it to be
+// backfilled
+func (session *connection) createIndex(ctx context.Context, table TableSchema, index IndexSchema, billing *dynamodb.BillingModeSummary) error {
+	const method = "ApplySchema"
+	log.Printf("%s (creating index %s.%s)", method, table.Name, index.Name)
+	create := &dynamodb.CreateGlobalSecondaryIndexAction{
+		IndexName:  aws.String(index.Name),
+		KeySchema:  keySchema(index.HashKey, index.RangeKey),
+		Projection: index.projection(),
+	}
+	if billing == nil || aws.StringValue(billing.BillingMode) != dynamodb.BillingModePayPerRequest {
+		create.ProvisionedThroughput = session.throughput()
+	}
+	_, err := session.DynamoDBresource.UpdateTableWithContext(ctx, &dynamodb.UpdateTableInput{
+		TableName:            aws.String(table.Name),
+		AttributeDefinitions: table.attributeDefinitions([]IndexSchema{index}),
+		GlobalSecondaryIndexUpdates: []*dynamodb.GlobalSecondaryIndexUpdate{
+			{Create: create},
+		},
+	})
+	if err != nil {
+		return translateError("UpdateTable", err)
+	}
+	for {
+		description, err := session.describeTable(ctx, table.Name)
+		if err != nil {
+			return err
+		}
+		if description == nil {
+			return fmt.Errorf("table %s disappeared while creating index %s", table.Name, index.Name)
+		}
+		for _, liveIndex := range description.GlobalSecondaryIndexes {
+			if aws.StringValue(liveIndex.IndexName) == index.Name &&
+				aws.StringValue(liveIndex.IndexStatus) == dynamodb.IndexStatusActive {
+				return nil
+			}
+		}
+		log.Printf("%s (waiting for index %s.%s)", method, table.Name, index.Name)
+		select {
+		case <-time.After(indexPollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// PrepareSchema does what mode asks for on startup
+func (session *connection) PrepareSchema(ctx context.Context, mode string) error {
+	switch mode {
+	case SchemaNone:
+		return nil
+	case SchemaVerify, "":
+		return session.VerifySchema(ctx)
+	case SchemaApply:
+		return session.ApplySchema(ctx)
+	default:
+		return fmt.Errorf("unknown schema mode %q", mode)
+	}
+}
+
+func (session *connection) throughput() *dynamodb.ProvisionedThroughput {
+	return &dynamodb.ProvisionedThroughput{
+		ReadCapacityUnits:  aws.Int64(session.capacity.Read),
+		WriteCapacityUnits: aws.Int64(session.capacity.Write),
+	}
+}
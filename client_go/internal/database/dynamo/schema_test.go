@@ -0,0 +1,95 @@
+package dynamo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// describe builds the TableDescription DynamoDB would return for the
+// table created from input
+func describe(input *dynamodb.CreateTableInput) *dynamodb.TableDescription {
+	description := &dynamodb.TableDescription{
+		TableName:            input.TableName,
+		TableStatus:          aws.String(dynamodb.TableStatusActive),
+		AttributeDefinitions: input.AttributeDefinitions,
+		KeySchema:            input.KeySchema,
+	}
+	for _, index := range input.GlobalSecondaryIndexes {
+		description.GlobalSecondaryIndexes = append(description.GlobalSecondaryIndexes, &dynamodb.GlobalSecondaryIndexDescription{
+			IndexName:   index.IndexName,
+			IndexStatus: aws.String(dynamodb.IndexStatusActive),
+			KeySchema:   index.KeySchema,
+			Projection:  index.Projection,
+		})
+	}
+	return description
+}
+
+func TestSchemaDiff(t *testing.T) {
+	config := DefaultConfig()
+	config.TablePrefix = "test_"
+	schema := Schema(config)
+	throughput := &dynamodb.ProvisionedThroughput{
+		ReadCapacityUnits:  aws.Int64(1),
+		WriteCapacityUnits: aws.Int64(1),
+	}
+
+	for _, table := range append(schema, SingleTableSchema(config)) {
+		if differences := table.Diff(describe(table.CreateTableInput(throughput))); len(differences) != 0 {
+			t.Fatalf("%s: unexpected differences %v", table.Name, differences)
+		}
+	}
+
+	users, lists, guests, items := schema[0], schema[1], schema[2], schema[3]
+	tests := []struct {
+		name        string
+		table       TableSchema
+		description func() *dynamodb.TableDescription
+		expected    string
+	}{
+		{"MissingTable", users, func() *dynamodb.TableDescription { return nil }, "table test_users is missing"},
+		{"MissingIndex", users, func() *dynamodb.TableDescription {
+			description := describe(users.CreateTableInput(throughput))
+			description.GlobalSecondaryIndexes = nil
+			return description
+		}, "table test_users is missing index users_by_email"},
+		{"Keys", guests, func() *dynamodb.TableDescription {
+			description := describe(guests.CreateTableInput(throughput))
+			description.KeySchema = keySchema("list_id", "")
+			return description
+		}, "table test_guests is keyed on (list_id,), expected (list_id,user_id)"},
+		{"Projection", lists, func() *dynamodb.TableDescription {
+			description := describe(lists.CreateTableInput(throughput))
+			description.GlobalSecondaryIndexes[0].Projection = &dynamodb.Projection{
+				ProjectionType: aws.String(dynamodb.ProjectionTypeKeysOnly),
+			}
+			return description
+		}, "index test_lists.lists_by_user_id projects KEYS_ONLY [], expected INCLUDE [id title]"},
+		{"AttributeType", items, func() *dynamodb.TableDescription {
+			description := describe(items.CreateTableInput(throughput))
+			for _, definition := range description.AttributeDefinitions {
+				definition.AttributeType = aws.String(dynamodb.ScalarAttributeTypeS)
+			}
+			return description
+		}, "table test_items declares attribute order as S, expected N"},
+		{"Creating", lists, func() *dynamodb.TableDescription {
+			description := describe(lists.CreateTableInput(throughput))
+			description.TableStatus = aws.String(dynamodb.TableStatusCreating)
+			return description
+		}, "table test_lists is CREATING"},
+	}
+	for _, tc := range tests {
+		differences := tc.table.Diff(tc.description())
+		if len(differences) != 1 || differences[0] != tc.expected {
+			t.Errorf("%s: expected %q, got %v", tc.name, tc.expected, differences)
+		}
+	}
+
+	err := &SchemaError{Differences: []string{"a", "b"}}
+	if !strings.HasSuffix(err.Error(), "a; b") {
+		t.Errorf("unexpected message %q", err)
+	}
+}
@@ -0,0 +1,1220 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+	"github.com/google/uuid"
+)
+
+// The single table keeps every entity under composite keys:
+//
+//	entity  pk            sk             gsi1pk        gsi1sk         gsi2pk  gsi2sk
+//	user    USER#<id>     USER           EMAIL#<email>  USER           USER    <id>
+//	list    LIST#<id>     LIST           USER#<owner>   LIST#<id>
+//	guest   LIST#<list>   GUEST#<user>   USER#<user>    GUEST#<list>
+//	item    LIST#<list>   ITEM#<date>    LIST#<list>   ITEM#<order>#<date>
+//
+// A list's partition therefore holds the list along with its guests and
+// items, and the user's partition in gsi1 holds the lists they own or
+// are a guest of. The list's partition in gsi1 holds its items in the
+// order set by MoveItem, <order> being encoded by itemOrderKey. Items
+// sharing an Order, such as those created with the constant 10 before
+// moves existed, sort by <date> as they do in the other backends. This is
+// unlike DBSession's items_by_order, which is keyed on the order alone
+// and returns such ties in no guaranteed order. Guests carry the list's
+// title and owner, and the guest's email, so that dashboards need no
+// further lookups.
+const (
+	attributePK     = "pk"
+	attributeSK     = "sk"
+	attributeGSI1PK = "gsi1pk"
+	attributeGSI1SK = "gsi1sk"
+	attributeGSI2PK = "gsi2pk"
+	attributeGSI2SK = "gsi2sk"
+
+	prefixUser  = "USER#"
+	prefixEmail = "EMAIL#"
+	prefixList  = "LIST#"
+	prefixGuest = "GUEST#"
+	prefixItem  = "ITEM#"
+
+	sortKeyUser = "USER"
+	sortKeyList = "LIST"
+)
+
+// SingleTableSession is a type
+//
+// It implements model.Interface on top of a single table, declared by
+// SingleTableSchema, instead of the four used by DBSession.
+type SingleTableSession struct {
+	connection
+	table   string
+	indexes Indexes
+}
+
+// guestRecord is the layout of a guest in the single table
+type guestRecord struct {
+	ListID  string `dynamodbav:"list_id"`
+	UserID  string `dynamodbav:"user_id"`
+	Email   string `dynamodbav:"email"`
+	Title   string `dynamodbav:"title"`
+	OwnerID string `dynamodbav:"owner_id"`
+}
+
+func stringAttribute(value string) *dynamodb.AttributeValue {
+	return &dynamodb.AttributeValue{S: aws.String(value)}
+}
+
+func tableKey(pk string, sk string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		attributePK: stringAttribute(pk),
+		attributeSK: stringAttribute(sk),
+	}
+}
+
+func userKey(userID string) map[string]*dynamodb.AttributeValue {
+	return tableKey(prefixUser+userID, sortKeyUser)
+}
+
+func listKey(listID string) map[string]*dynamodb.AttributeValue {
+	return tableKey(prefixList+listID, sortKeyList)
+}
+
+func guestKey(listID string, userID string) map[string]*dynamodb.AttributeValue {
+	return tableKey(prefixList+listID, prefixGuest+userID)
+}
+
+func itemKey(listID string, datetime string) map[string]*dynamodb.AttributeValue {
+	return tableKey(prefixList+listID, prefixItem+datetime)
+}
+
+// itemOrderKey returns the gsi1 sort key of an item, in which Order is
+// encoded so that the keys sort as the items do with model.SortItems:
+// the bits of a float64 sort as unsigned integers once those of negative
+// numbers are flipped, and the sign bit of the others is set
+func itemOrderKey(order float64, datetime string) string {
+	bits := math.Float64bits(order)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	return fmt.Sprintf("%s%016x#%s", prefixItem, bits, datetime)
+}
+
+// record marshals entity and adds the given attribute/value pairs
+func record(entity interface{}, attributes ...string) (map[string]*dynamodb.AttributeValue, error) {
+	av, err := dynamodbattribute.MarshalMap(entity)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i+1 < len(attributes); i += 2 {
+		av[attributes[i]] = stringAttribute(attributes[i+1])
+	}
+	return av, nil
+}
+
+// keyCursor encodes the given attributes of a LastEvaluatedKey, which
+// for an index include the table's key
+func keyCursor(key map[string]*dynamodb.AttributeValue, attributes ...string) model.Cursor {
+	if len(key) == 0 {
+		return ""
+	}
+	values := make([]string, len(attributes))
+	for i, attribute := range attributes {
+		if v, ok := key[attribute]; ok {
+			values[i] = aws.StringValue(v.S)
+		}
+	}
+	return model.NewCursor(values...)
+}
+
+// cursorKey turns a cursor made by keyCursor back into an
+// ExclusiveStartKey. The zero Cursor yields nil, meaning the first page.
+func cursorKey(cursor model.Cursor, attributes ...string) (map[string]*dynamodb.AttributeValue, error) {
+	values, err := cursor.Keys(len(attributes))
+	if err != nil || values == nil {
+		return nil, err
+	}
+	key := make(map[string]*dynamodb.AttributeValue, len(attributes))
+	for i, attribute := range attributes {
+		key[attribute] = stringAttribute(values[i])
+	}
+	return key, nil
+}
+
+// partitionQuery reads the pk partition, restricted to sort keys
+// starting with prefix unless it is empty
+func (session *SingleTableSession) partitionQuery(index string, pk string, prefix string) *dynamodb.QueryInput {
+	pkAttribute, skAttribute := attributePK, attributeSK
+	switch index {
+	case session.indexes.GSI1:
+		pkAttribute, skAttribute = attributeGSI1PK, attributeGSI1SK
+	case session.indexes.GSI2:
+		pkAttribute, skAttribute = attributeGSI2PK, attributeGSI2SK
+	}
+	input := &dynamodb.QueryInput{
+		TableName: aws.String(session.table),
+		ExpressionAttributeNames: map[string]*string{
+			"#pk": aws.String(pkAttribute),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pk": stringAttribute(pk),
+		},
+		KeyConditionExpression: aws.String("#pk = :pk"),
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	}
+	if index != "" {
+		input.IndexName = aws.String(index)
+	}
+	if prefix != "" {
+		input.ExpressionAttributeNames["#sk"] = aws.String(skAttribute)
+		input.ExpressionAttributeValues[":prefix"] = stringAttribute(prefix)
+		input.KeyConditionExpression = aws.String("#pk = :pk AND begins_with(#sk, :prefix)")
+	}
+	return input
+}
+
+// Keys of each kind of page, in the order kept in cursors
+var (
+	tableKeyAttributes = []string{attributePK, attributeSK}
+	gsi1KeyAttributes  = []string{attributePK, attributeSK, attributeGSI1PK, attributeGSI1SK}
+	gsi2KeyAttributes  = []string{attributePK, attributeSK, attributeGSI2PK, attributeGSI2SK}
+)
+
+// ListUsers is a method
+func (session *SingleTableSession) ListUsers(ctx context.Context, cursor model.Cursor, max int64) ([]model.User, model.Cursor, error) {
+	const method = "ListUsers"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (cursor=%s,max=%d)", method, cursor, max)
+
+	exclusiveStartKey, err := cursorKey(cursor, gsi2KeyAttributes...)
+	if err != nil {
+		return nil, "", err
+	}
+	output, lastEvaluatedKey, err := session.queryPage(ctx, method, session.partitionQuery(session.indexes.GSI2, sortKeyUser, ""), exclusiveStartKey, max)
+	if err != nil {
+		return nil, "", err
+	}
+	users := make([]model.User, len(output))
+	for i, v := range output {
+		if err := dynamodbattribute.UnmarshalMap(v, &users[i]); err != nil {
+			return nil, "", err
+		}
+	}
+	return users, keyCursor(lastEvaluatedKey, gsi2KeyAttributes...), nil
+}
+
+// GetUsersByIDs is a method
+func (session *SingleTableSession) GetUsersByIDs(ctx context.Context, ids []string) ([]model.User, error) {
+	const method = "GetUsersByIDs"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (ids=%v)", method, ids)
+	if len(ids) == 0 {
+		return []model.User{}, nil
+	}
+	ids = uniqueStrings(ids)
+	keys := make([]map[string]*dynamodb.AttributeValue, len(ids))
+	for i, v := range ids {
+		keys[i] = userKey(v)
+	}
+	usersAttributes, err := session.batchGet(ctx, method, session.table, keys)
+	if err != nil {
+		return []model.User{}, err
+	}
+	users := make([]model.User, len(usersAttributes))
+	for i, v := range usersAttributes {
+		if err := dynamodbattribute.UnmarshalMap(v, &users[i]); err != nil {
+			return []model.User{}, err
+		}
+	}
+	return users, nil
+}
+
+// GetUserByEmail is a method
+func (session *SingleTableSession) GetUserByEmail(ctx context.Context, email string) (model.User, error) {
+	const method = "GetUserByEmail"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (email=%s)", method, email)
+
+	output, err := session.queryAll(ctx, method, session.partitionQuery(session.indexes.GSI1, prefixEmail+email, ""))
+	if err != nil {
+		return model.User{}, err
+	}
+	if len(output) == 0 {
+		return model.User{}, &model.NotFoundError{
+			Entity: model.EntityUser,
+			Key:    email,
+		}
+	}
+	if len(output) > 1 {
+		return model.User{}, fmt.Errorf("expected 1 result, got %d", len(output))
+	}
+	var user model.User
+	if err := dynamodbattribute.UnmarshalMap(output[0], &user); err != nil {
+		return model.User{}, err
+	}
+	return user, nil
+}
+
+// CreateUser is a method
+//
+// Emails are not checked for uniqueness, as in DBSession
+func (session *SingleTableSession) CreateUser(ctx context.Context, email string) (string, error) {
+	const method = "CreateUser"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (email=%s)", method, email)
+
+	uuidString := uuid.New().String()
+	userAV, err := record(model.User{ID: uuidString, Email: email},
+		attributePK, prefixUser+uuidString,
+		attributeSK, sortKeyUser,
+		attributeGSI1PK, prefixEmail+email,
+		attributeGSI1SK, sortKeyUser,
+		attributeGSI2PK, sortKeyUser,
+		attributeGSI2SK, uuidString)
+	if err != nil {
+		return "", err
+	}
+	input := &dynamodb.PutItemInput{
+		TableName:              aws.String(session.table),
+		Item:                   userAV,
+		ConditionExpression:    aws.String("attribute_not_exists(pk)"),
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	}
+	output, err2 := session.DynamoDBresource.PutItemWithContext(ctx, input)
+	if err2 != nil {
+		if aeer, ok := err2.(awserr.Error); ok {
+			if aeer.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+				return "", &model.AlreadyExistsError{
+					Entity: model.EntityUser,
+					Key:    uuidString,
+				}
+			}
+		}
+		return "", translateError(method, err2)
+	}
+	logConsumedCapacity(method, output.ConsumedCapacity)
+	return uuidString, nil
+}
+
+// GetListByListID is a method
+func (session *SingleTableSession) GetListByListID(ctx context.Context, listID string) (model.List, error) {
+	const method = "GetListByListID"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (listID=%s)", method, listID)
+	output, err := session.DynamoDBresource.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:              aws.String(session.table),
+		Key:                    listKey(listID),
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	})
+	if err != nil {
+		return model.List{}, translateError(method, err)
+	}
+	logConsumedCapacity(method, output.ConsumedCapacity)
+	if len(output.Item) == 0 {
+		return model.List{}, &model.NotFoundError{
+			Entity: model.EntityList,
+			Key:    listID,
+		}
+	}
+	var list model.List
+	if err := dynamodbattribute.UnmarshalMap(output.Item, &list); err != nil {
+		return model.List{}, err
+	}
+	return list, nil
+}
+
+// GetListContents reads a list along with its guests and items using a
+// single Query
+func (session *SingleTableSession) GetListContents(ctx context.Context, listID string) (model.List, []model.AggregateGuest, []model.Item, error) {
+	const method = "GetListContents"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (listID=%s)", method, listID)
+
+	output, err := session.queryAll(ctx, method, session.partitionQuery("", prefixList+listID, ""))
+	if err != nil {
+		return model.List{}, nil, nil, err
+	}
+	var list model.List
+	guests := make([]model.AggregateGuest, 0)
+	items := make([]model.Item, 0)
+	for _, v := range output {
+		sk := aws.StringValue(v[attributeSK].S)
+		switch {
+		case sk == sortKeyList:
+			err = dynamodbattribute.UnmarshalMap(v, &list)
+		case strings.HasPrefix(sk, prefixGuest):
+			var guest model.AggregateGuest
+			err = dynamodbattribute.UnmarshalMap(v, &guest)
+			guests = append(guests, guest)
+		case strings.HasPrefix(sk, prefixItem):
+			var item model.Item
+			err = dynamodbattribute.UnmarshalMap(v, &item)
+			items = append(items, item)
+		}
+		if err != nil {
+			return model.List{}, nil, nil, err
+		}
+	}
+	if list.ID == "" {
+		return model.List{}, nil, nil, &model.NotFoundError{
+			Entity: model.EntityList,
+			Key:    listID,
+		}
+	}
+	return list, guests, items, nil
+}
+
+// aggregateList maps an entry of the user's gsi1 partition, which is
+// either a list they own, counters included, or a guest record
+func aggregateList(v map[string]*dynamodb.AttributeValue) (model.AggregateList, error) {
+	var aggregate model.AggregateList
+	if aws.StringValue(v[attributeSK].S) == sortKeyList {
+		err := dynamodbattribute.UnmarshalMap(v, &aggregate)
+		return aggregate, err
+	}
+	var guest guestRecord
+	if err := dynamodbattribute.UnmarshalMap(v, &guest); err != nil {
+		return aggregate, err
+	}
+	aggregate.List = model.List{
+		ID:     guest.ListID,
+		Title:  guest.Title,
+		UserID: guest.OwnerID,
+	}
+	aggregate.AsGuest = true
+	return aggregate, nil
+}
+
+// GetAggregateListsByUserID is a method
+func (session *SingleTableSession) GetAggregateListsByUserID(ctx context.Context, userID string) ([]model.AggregateList, error) {
+	const method = "GetAggregateListsByUserID"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (userID=%s)", method, userID)
+
+	output, err := session.queryAll(ctx, method, session.partitionQuery(session.indexes.GSI1, prefixUser+userID, ""))
+	if err != nil {
+		return []model.AggregateList{}, err
+	}
+	return session.aggregateLists(ctx, output)
+}
+
+// GetAggregateListsByUserIDPage is a method
+func (session *SingleTableSession) GetAggregateListsByUserIDPage(ctx context.Context, userID string, cursor model.Cursor, max int64) ([]model.AggregateList, model.Cursor, error) {
+	const method = "GetAggregateListsByUserIDPage"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (userID=%s,cursor=%s,max=%d)", method, userID, cursor, max)
+
+	exclusiveStartKey, err := cursorKey(cursor, gsi1KeyAttributes...)
+	if err != nil {
+		return nil, "", err
+	}
+	output, lastEvaluatedKey, err := session.queryPage(ctx, method, session.partitionQuery(session.indexes.GSI1, prefixUser+userID, ""), exclusiveStartKey, max)
+	if err != nil {
+		return nil, "", err
+	}
+	alists, err := session.aggregateLists(ctx, output)
+	if err != nil {
+		return nil, "", err
+	}
+	return alists, keyCursor(lastEvaluatedKey, gsi1KeyAttributes...), nil
+}
+
+// aggregateLists maps the entries of the user's gsi1 partition. Guest
+// records do not carry the counters, which are read from the lists
+// themselves, one read per list.
+func (session *SingleTableSession) aggregateLists(ctx context.Context, output []map[string]*dynamodb.AttributeValue) ([]model.AggregateList, error) {
+	const method = "aggregateLists"
+	alists := make([]model.AggregateList, 0, len(output))
+	keys := make([]map[string]*dynamodb.AttributeValue, 0)
+	for _, v := range output {
+		aggregate, err := aggregateList(v)
+		if err != nil {
+			return []model.AggregateList{}, err
+		}
+		alists = append(alists, aggregate)
+		if aggregate.AsGuest {
+			keys = append(keys, listKey(aggregate.ID))
+		}
+	}
+	if len(keys) == 0 {
+		return alists, nil
+	}
+	listsAttributes, err := session.batchGet(ctx, method, session.table, keys)
+	if err != nil {
+		return []model.AggregateList{}, err
+	}
+	byID := make(map[string]model.AggregateList, len(listsAttributes))
+	for _, v := range listsAttributes {
+		var aggregate model.AggregateList
+		if err := dynamodbattribute.UnmarshalMap(v, &aggregate); err != nil {
+			return []model.AggregateList{}, err
+		}
+		byID[aggregate.ID] = aggregate
+	}
+	// Lists deleted since the guest records were read are left out
+	result := alists[:0]
+	for _, aggregate := range alists {
+		if aggregate.AsGuest {
+			list, ok := byID[aggregate.ID]
+			if !ok {
+				continue
+			}
+			list.AsGuest = true
+			aggregate = list
+		}
+		result = append(result, aggregate)
+	}
+	return result, nil
+}
+
+// countChildren counts the guests and items among the entries of a
+// list's partition
+func countChildren(output []map[string]*dynamodb.AttributeValue) (int, int) {
+	guests, items := 0, 0
+	for _, v := range output {
+		sk := aws.StringValue(v[attributeSK].S)
+		switch {
+		case strings.HasPrefix(sk, prefixGuest):
+			guests++
+		case strings.HasPrefix(sk, prefixItem):
+			items++
+		}
+	}
+	return guests, items
+}
+
+// GetListsByUserID is a method
+func (session *SingleTableSession) GetListsByUserID(ctx context.Context, userID string) ([]model.List, error) {
+	const method = "GetListsByUserID"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (userID=%s)", method, userID)
+
+	output, err := session.queryAll(ctx, method, session.partitionQuery(session.indexes.GSI1, prefixUser+userID, prefixList))
+	if err != nil {
+		return []model.List{}, err
+	}
+	lists := make([]model.List, len(output))
+	for i, v := range output {
+		if err := dynamodbattribute.UnmarshalMap(v, &lists[i]); err != nil {
+			return []model.List{}, err
+		}
+	}
+	return lists, nil
+}
+
+// CreateList is a method
+func (session *SingleTableSession) CreateList(ctx context.Context, userID string, title string) (string, error) {
+	const method = "CreateList"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (userID=%s,title=%s)", method, userID, title)
+
+	uuidString := uuid.New().String()
+	listAV, err := record(model.List{ID: uuidString, Title: title, UserID: userID},
+		attributePK, prefixList+uuidString,
+		attributeSK, sortKeyList,
+		attributeGSI1PK, prefixUser+userID,
+		attributeGSI1SK, prefixList+uuidString)
+	if err != nil {
+		return "", err
+	}
+	input := &dynamodb.TransactWriteItemsInput{
+		ClientRequestToken:     aws.String(uuid.New().String()),
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				ConditionCheck: &dynamodb.ConditionCheck{
+					TableName:           aws.String(session.table),
+					Key:                 userKey(userID),
+					ConditionExpression: aws.String("attribute_exists(pk)"),
+				},
+			},
+			{
+				Put: &dynamodb.Put{
+					TableName:           aws.String(session.table),
+					Item:                listAV,
+					ConditionExpression: aws.String("attribute_not_exists(pk)"),
+				},
+			},
+		},
+	}
+	output, err2 := session.DynamoDBresource.TransactWriteItemsWithContext(ctx, input)
+	if err2 != nil {
+		switch {
+		case isConditionFailure(err2, 0):
+			return "", &model.NotFoundError{
+				Entity: model.EntityUser,
+				Key:    userID,
+			}
+		case isConditionFailure(err2, 1):
+			return "", &model.AlreadyExistsError{
+				Entity: model.EntityList,
+				Key:    uuidString,
+			}
+		default:
+			return "", translateError(method, err2)
+		}
+	}
+	for i, v := range output.ConsumedCapacity {
+		logConsumedCapacity(fmt.Sprintf("%s #%d", method, i), v)
+	}
+	return uuidString, nil
+}
+
+// explainListCondition reads the list back once a condition on it has
+// failed, as DBSession.explainListCondition does
+func (session *SingleTableSession) explainListCondition(ctx context.Context, method string, listID string, userID string, action string) error {
+	output, err := session.DynamoDBresource.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:              aws.String(session.table),
+		Key:                    listKey(listID),
+		ConsistentRead:         aws.Bool(true),
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	})
+	if err != nil {
+		return translateError(method, err)
+	}
+	logConsumedCapacity(method, output.ConsumedCapacity)
+	return explainList(method, output.Item, listID, userID, action)
+}
+
+// DeleteList is a method
+//
+// It follows the same three steps as DBSession.DeleteList, except that
+// the guests and items to delete are read from the list's partition.
+func (session *SingleTableSession) DeleteList(ctx context.Context, listID string, userID string) error {
+	const method = "DeleteList"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (listID=%s,userID=%s)", method, listID, userID)
+
+	log.Printf("Preparing list %s for deletion", listID)
+	session.slowdown(ctx, method, "before setting under_deletion")
+	output, err := session.DynamoDBresource.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(session.table),
+		Key:                 listKey(listID),
+		UpdateExpression:    aws.String("SET under_deletion = :t"),
+		ConditionExpression: aws.String("attribute_exists(pk) AND user_id = :u"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":u": stringAttribute(userID),
+			":t": {BOOL: aws.Bool(true)},
+		},
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	})
+	if err != nil {
+		if aeer, ok := err.(awserr.Error); ok {
+			if aeer.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+				return session.explainListCondition(ctx, method, listID, userID, "delete")
+			}
+		}
+		return translateError(method, err)
+	}
+	logConsumedCapacity(method, output.ConsumedCapacity)
+
+	// Neither CreateGuest nor CreateItem succeed once under_deletion is
+	// set, so a strongly consistent read sees every child to delete
+	input := session.partitionQuery("", prefixList+listID, "")
+	input.ProjectionExpression = aws.String("pk, sk")
+	input.ConsistentRead = aws.Bool(true)
+	children, err := session.queryAll(ctx, method, input)
+	if err != nil {
+		return err
+	}
+	requests := make([]*dynamodb.WriteRequest, 0, len(children))
+	for _, v := range children {
+		if aws.StringValue(v[attributeSK].S) == sortKeyList {
+			continue
+		}
+		log.Printf("Deleting %s", aws.StringValue(v[attributeSK].S))
+		requests = append(requests, &dynamodb.WriteRequest{
+			DeleteRequest: &dynamodb.DeleteRequest{Key: v},
+		})
+	}
+	if len(requests) > 0 {
+		session.slowdown(ctx, method, "before deleting guests and items")
+		if err := session.batchWrite(ctx, method, map[string][]*dynamodb.WriteRequest{session.table: requests}); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Deleting list %s", listID)
+	session.slowdown(ctx, method, "before deleting list")
+	output3, err3 := session.DynamoDBresource.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(session.table),
+		Key:                 listKey(listID),
+		ConditionExpression: aws.String("attribute_exists(pk) AND user_id = :u AND under_deletion = :t"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":u": stringAttribute(userID),
+			":t": {BOOL: aws.Bool(true)},
+		},
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	})
+	if err3 != nil {
+		if aeer, ok := err3.(awserr.Error); ok {
+			if aeer.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+				// A concurrent call has completed the deletion
+				return session.explainListCondition(ctx, method, listID, userID, "delete")
+			}
+		}
+		return translateError(method, err3)
+	}
+	logConsumedCapacity(method, output3.ConsumedCapacity)
+	return nil
+}
+
+// GetAggregateGuestsByListID is a method
+func (session *SingleTableSession) GetAggregateGuestsByListID(ctx context.Context, listID string) ([]model.AggregateGuest, error) {
+	const method = "GetAggregateGuestsByListID"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (listID=%s)", method, listID)
+
+	output, err := session.queryAll(ctx, method, session.partitionQuery("", prefixList+listID, prefixGuest))
+	if err != nil {
+		return []model.AggregateGuest{}, err
+	}
+	guests := make([]model.AggregateGuest, len(output))
+	for i, v := range output {
+		if err := dynamodbattribute.UnmarshalMap(v, &guests[i]); err != nil {
+			return []model.AggregateGuest{}, err
+		}
+	}
+	return guests, nil
+}
+
+// GetAggregateGuestsByListIDPage is a method
+func (session *SingleTableSession) GetAggregateGuestsByListIDPage(ctx context.Context, listID string, cursor model.Cursor, max int64) ([]model.AggregateGuest, model.Cursor, error) {
+	const method = "GetAggregateGuestsByListIDPage"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (listID=%s,cursor=%s,max=%d)", method, listID, cursor, max)
+
+	exclusiveStartKey, err := cursorKey(cursor, tableKeyAttributes...)
+	if err != nil {
+		return nil, "", err
+	}
+	output, lastEvaluatedKey, err := session.queryPage(ctx, method, session.partitionQuery("", prefixList+listID, prefixGuest), exclusiveStartKey, max)
+	if err != nil {
+		return nil, "", err
+	}
+	guests := make([]model.AggregateGuest, len(output))
+	for i, v := range output {
+		if err := dynamodbattribute.UnmarshalMap(v, &guests[i]); err != nil {
+			return nil, "", err
+		}
+	}
+	return guests, keyCursor(lastEvaluatedKey, tableKeyAttributes...), nil
+}
+
+// GetGuestsByListID is a method
+func (session *SingleTableSession) GetGuestsByListID(ctx context.Context, listID string) ([]model.Guest, error) {
+	const method = "GetGuestsByListID"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (listID=%s)", method, listID)
+
+	output, err := session.queryAll(ctx, method, session.partitionQuery("", prefixList+listID, prefixGuest))
+	if err != nil {
+		return []model.Guest{}, err
+	}
+	guests := make([]model.Guest, len(output))
+	for i, v := range output {
+		if err := dynamodbattribute.UnmarshalMap(v, &guests[i]); err != nil {
+			return []model.Guest{}, err
+		}
+	}
+	return guests, nil
+}
+
+// GetGuestsByUserID is a method
+func (session *SingleTableSession) GetGuestsByUserID(ctx context.Context, userID string) ([]model.Guest, error) {
+	const method = "GetGuestsByUserID"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (userID=%s)", method, userID)
+
+	output, err := session.queryAll(ctx, method, session.partitionQuery(session.indexes.GSI1, prefixUser+userID, prefixGuest))
+	if err != nil {
+		return []model.Guest{}, err
+	}
+	guests := make([]model.Guest, len(output))
+	for i, v := range output {
+		if err := dynamodbattribute.UnmarshalMap(v, &guests[i]); err != nil {
+			return []model.Guest{}, err
+		}
+	}
+	return guests, nil
+}
+
+// IsPresentGuest is a method
+func (session *SingleTableSession) IsPresentGuest(ctx context.Context, listID string, userID string) (bool, error) {
+	const method = "IsPresentGuest"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (listID=%s,userID=%s)", method, listID, userID)
+	output, err := session.DynamoDBresource.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:              aws.String(session.table),
+		Key:                    guestKey(listID, userID),
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	})
+	if err != nil {
+		return false, translateError(method, err)
+	}
+	logConsumedCapacity(method, output.ConsumedCapacity)
+	return len(output.Item) > 0, nil
+}
+
+// CreateGuest is a method
+//
+// The list and the user are read first, as the guest carries the list's
+// title and owner and the user's email. The transaction then checks
+// that neither has gone away in the meantime.
+func (session *SingleTableSession) CreateGuest(ctx context.Context, listID string, userID string) error {
+	const method = "CreateGuest"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (listID=%s,userID=%s)", method, listID, userID)
+
+	list, err := session.GetListByListID(ctx, listID)
+	if err != nil {
+		return err
+	}
+	if list.UnderDeletion {
+		return &model.UnderDeletionError{ListID: listID}
+	}
+	users, err := session.GetUsersByIDs(ctx, []string{userID})
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return &model.NotFoundError{
+			Entity: model.EntityUser,
+			Key:    userID,
+		}
+	}
+
+	guestAV, err := record(guestRecord{
+		ListID:  listID,
+		UserID:  userID,
+		Email:   users[0].Email,
+		Title:   list.Title,
+		OwnerID: list.UserID,
+	},
+		attributePK, prefixList+listID,
+		attributeSK, prefixGuest+userID,
+		attributeGSI1PK, prefixUser+userID,
+		attributeGSI1SK, prefixGuest+listID)
+	if err != nil {
+		return err
+	}
+	input := &dynamodb.TransactWriteItemsInput{
+		ClientRequestToken:     aws.String(uuid.New().String()),
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Update: counterUpdate(session.table, listKey(listID), attributeGuestCount, 1, "attribute_exists(pk) AND attribute_not_exists(under_deletion)"),
+			},
+			{
+				ConditionCheck: &dynamodb.ConditionCheck{
+					TableName:           aws.String(session.table),
+					Key:                 userKey(userID),
+					ConditionExpression: aws.String("attribute_exists(pk)"),
+				},
+			},
+			{
+				Put: &dynamodb.Put{
+					TableName:           aws.String(session.table),
+					Item:                guestAV,
+					ConditionExpression: aws.String("attribute_not_exists(pk)"),
+				},
+			},
+		},
+	}
+	output, err2 := session.DynamoDBresource.TransactWriteItemsWithContext(ctx, input)
+	if err2 != nil {
+		switch {
+		case isConditionFailure(err2, 0):
+			return session.explainListCondition(ctx, method, listID, "", "")
+		case isConditionFailure(err2, 1):
+			return &model.NotFoundError{
+				Entity: model.EntityUser,
+				Key:    userID,
+			}
+		case isConditionFailure(err2, 2):
+			return &model.AlreadyExistsError{
+				Entity: model.EntityGuest,
+				ListID: listID,
+				Key:    userID,
+			}
+		default:
+			return translateError(method, err2)
+		}
+	}
+	for i, v := range output.ConsumedCapacity {
+		logConsumedCapacity(fmt.Sprintf("%s #%d", method, i), v)
+	}
+	return nil
+}
+
+// DeleteGuest is a method
+func (session *SingleTableSession) DeleteGuest(ctx context.Context, listID string, userID string) error {
+	const method = "DeleteGuest"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (listID=%s,userID=%s)", method, listID, userID)
+
+	input := &dynamodb.TransactWriteItemsInput{
+		ClientRequestToken:     aws.String(uuid.New().String()),
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Delete: &dynamodb.Delete{
+					TableName:           aws.String(session.table),
+					Key:                 guestKey(listID, userID),
+					ConditionExpression: aws.String("attribute_exists(pk)"),
+				},
+			},
+			{
+				Update: counterUpdate(session.table, listKey(listID), attributeGuestCount, -1, "attribute_exists(pk)"),
+			},
+		},
+	}
+	output, err := session.DynamoDBresource.TransactWriteItemsWithContext(ctx, input)
+	if err != nil {
+		switch {
+		case isConditionFailure(err, 0):
+			return &model.NotFoundError{
+				Entity: model.EntityGuest,
+				ListID: listID,
+				Key:    userID,
+			}
+		case isConditionFailure(err, 1):
+			return session.explainListCondition(ctx, method, listID, "", "")
+		default:
+			return translateError(method, err)
+		}
+	}
+	for i, v := range output.ConsumedCapacity {
+		logConsumedCapacity(fmt.Sprintf("%s #%d", method, i), v)
+	}
+	return nil
+}
+
+// GetItemsByListID is a method
+func (session *SingleTableSession) GetItemsByListID(ctx context.Context, listID string) ([]model.Item, error) {
+	return session.getItemsByListID(ctx, listID, false)
+}
+
+func (session *SingleTableSession) getItemsByListID(ctx context.Context, listID string, consistentRead bool) ([]model.Item, error) {
+	const method = "GetItemsByListID"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (listID=%s,consistentRead=%t)", method, listID, consistentRead)
+
+	input := session.partitionQuery("", prefixList+listID, prefixItem)
+	input.ConsistentRead = aws.Bool(consistentRead)
+	output, err := session.queryAll(ctx, method, input)
+	if err != nil {
+		return []model.Item{}, err
+	}
+	items := make([]model.Item, len(output))
+	for i, v := range output {
+		if err := dynamodbattribute.UnmarshalMap(v, &items[i]); err != nil {
+			return []model.Item{}, err
+		}
+	}
+	model.SortItems(items)
+	return items, nil
+}
+
+// GetItemsByListIDPage is a method
+func (session *SingleTableSession) GetItemsByListIDPage(ctx context.Context, listID string, cursor model.Cursor, max int64) ([]model.Item, model.Cursor, error) {
+	const method = "GetItemsByListIDPage"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (listID=%s,cursor=%s,max=%d)", method, listID, cursor, max)
+
+	exclusiveStartKey, err := cursorKey(cursor, gsi1KeyAttributes...)
+	if err != nil {
+		return nil, "", err
+	}
+	output, lastEvaluatedKey, err := session.queryPage(ctx, method, session.partitionQuery(session.indexes.GSI1, prefixList+listID, prefixItem), exclusiveStartKey, max)
+	if err != nil {
+		return nil, "", err
+	}
+	items := make([]model.Item, len(output))
+	for i, v := range output {
+		if err := dynamodbattribute.UnmarshalMap(v, &items[i]); err != nil {
+			return nil, "", err
+		}
+	}
+	return items, keyCursor(lastEvaluatedKey, gsi1KeyAttributes...), nil
+}
+
+// GetItem is a method
+//
+// It reads the item's own entry consistently, rather than its copy in
+// gsi1 which GetItemsByListIDPage queries.
+func (session *SingleTableSession) GetItem(ctx context.Context, listID string, datetime string) (model.Item, error) {
+	const method = "GetItem"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (listID=%s,datetime=%s)", method, listID, datetime)
+
+	output, err := session.DynamoDBresource.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:              aws.String(session.table),
+		Key:                    itemKey(listID, datetime),
+		ConsistentRead:         aws.Bool(true),
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	})
+	if err != nil {
+		return model.Item{}, translateError(method, err)
+	}
+	logConsumedCapacity(method, output.ConsumedCapacity)
+	if len(output.Item) == 0 {
+		return model.Item{}, &model.NotFoundError{
+			Entity: model.EntityItem,
+			ListID: listID,
+			Key:    datetime,
+		}
+	}
+	var item model.Item
+	if err := dynamodbattribute.UnmarshalMap(output.Item, &item); err != nil {
+		return model.Item{}, err
+	}
+	return item, nil
+}
+
+// CreateItem is a method
+func (session *SingleTableSession) CreateItem(ctx context.Context, listID string, description string) (string, error) {
+	const method = "CreateItem"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (listID=%s,description=%s)", method, listID, description)
+
+	now := time.Now()
+	datetime := now.Format("2006-01-02T15:04:05.999999")
+	order := model.NewItemOrder(now)
+	itemAV, err := record(model.Item{
+		ListID:      listID,
+		Datetime:    datetime,
+		Description: description,
+		Done:        false,
+		Order:       order,
+	},
+		attributePK, prefixList+listID,
+		attributeSK, prefixItem+datetime,
+		attributeGSI1PK, prefixList+listID,
+		attributeGSI1SK, itemOrderKey(order, datetime))
+	if err != nil {
+		return "", err
+	}
+	input := &dynamodb.TransactWriteItemsInput{
+		ClientRequestToken:     aws.String(uuid.New().String()),
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Update: counterUpdate(session.table, listKey(listID), attributeItemCount, 1, "attribute_exists(pk) AND attribute_not_exists(under_deletion)"),
+			},
+			{
+				Put: &dynamodb.Put{
+					TableName:           aws.String(session.table),
+					Item:                itemAV,
+					ConditionExpression: aws.String("attribute_not_exists(pk)"),
+				},
+			},
+		},
+	}
+	output, err2 := session.DynamoDBresource.TransactWriteItemsWithContext(ctx, input)
+	if err2 != nil {
+		switch {
+		case isConditionFailure(err2, 0):
+			return "", session.explainListCondition(ctx, method, listID, "", "")
+		case isConditionFailure(err2, 1):
+			return "", &model.AlreadyExistsError{
+				Entity: model.EntityItem,
+				ListID: listID,
+				Key:    datetime,
+			}
+		default:
+			return "", translateError(method, err2)
+		}
+	}
+	for i, v := range output.ConsumedCapacity {
+		logConsumedCapacity(fmt.Sprintf("%s #%d", method, i), v)
+	}
+	return datetime, nil
+}
+
+// DeleteItem is a method
+func (session *SingleTableSession) DeleteItem(ctx context.Context, listID string, datetime string) error {
+	const method = "DeleteItem"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (listID=%s,datetime=%s)", method, listID, datetime)
+
+	input := &dynamodb.TransactWriteItemsInput{
+		ClientRequestToken:     aws.String(uuid.New().String()),
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Delete: &dynamodb.Delete{
+					TableName:           aws.String(session.table),
+					Key:                 itemKey(listID, datetime),
+					ConditionExpression: aws.String("attribute_exists(pk)"),
+				},
+			},
+			{
+				Update: counterUpdate(session.table, listKey(listID), attributeItemCount, -1, "attribute_exists(pk)"),
+			},
+		},
+	}
+	output, err := session.DynamoDBresource.TransactWriteItemsWithContext(ctx, input)
+	if err != nil {
+		switch {
+		case isConditionFailure(err, 0):
+			return &model.NotFoundError{
+				Entity: model.EntityItem,
+				ListID: listID,
+				Key:    datetime,
+			}
+		case isConditionFailure(err, 1):
+			return session.explainListCondition(ctx, method, listID, "", "")
+		default:
+			return translateError(method, err)
+		}
+	}
+	for i, v := range output.ConsumedCapacity {
+		logConsumedCapacity(fmt.Sprintf("%s #%d", method, i), v)
+	}
+	return nil
+}
+
+// UpdateItem is a method
+func (session *SingleTableSession) UpdateItem(ctx context.Context, listID string, datetime string, version int, description *string, done *bool) (int, error) {
+	const method = "UpdateItem"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (listID=%s,datetime=%s)", method, listID, datetime)
+
+	updateExpression := "SET version = version + :o"
+	expressionAttributeValues := map[string]*dynamodb.AttributeValue{
+		":v": {N: aws.String(strconv.Itoa(version))},
+		":o": {N: aws.String("1")},
+	}
+	if description != nil {
+		updateExpression = updateExpression + ", description = :d"
+		expressionAttributeValues[":d"] = &dynamodb.AttributeValue{S: description}
+	}
+	if done != nil {
+		updateExpression = updateExpression + ", done = :n"
+		expressionAttributeValues[":n"] = &dynamodb.AttributeValue{BOOL: done}
+	}
+
+	output, err := session.DynamoDBresource.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(session.table),
+		Key:                       itemKey(listID, datetime),
+		UpdateExpression:          aws.String(updateExpression),
+		ConditionExpression:       aws.String("attribute_exists(pk) AND version = :v"),
+		ExpressionAttributeValues: expressionAttributeValues,
+		ReturnValues:              aws.String(dynamodb.ReturnValueUpdatedNew),
+		ReturnConsumedCapacity:    aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	})
+	if err != nil {
+		if aeer, ok := err.(awserr.Error); ok {
+			if aeer.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+				return 0, session.updateItemConflict(ctx, listID, datetime, version)
+			}
+		}
+		return 0, translateError(method, err)
+	}
+	logConsumedCapacity(method, output.ConsumedCapacity)
+	v := output.Attributes["version"]
+	if v == nil {
+		return 0, fmt.Errorf("%s: version missing from the response", method)
+	}
+	return strconv.Atoi(aws.StringValue(v.N))
+}
+
+// MoveItem is a method
+func (session *SingleTableSession) MoveItem(ctx context.Context, listID string, datetime string, version int, position int) (int, error) {
+	const method = "MoveItem"
+	session.slowdown(ctx, method, "entry")
+	defer logEnd(method, time.Now())
+	log.Printf("%s (listID=%s,datetime=%s,position=%d)", method, listID, datetime, position)
+
+	items, err := session.getItemsByListID(ctx, listID, true)
+	if err != nil {
+		return 0, err
+	}
+	moves, err := model.PlanMove(items, listID, datetime, version, position)
+	if err != nil {
+		return 0, err
+	}
+	// The item's gsi1 sort key follows its order
+	update := func(move model.Move) *dynamodb.Update {
+		update := orderUpdate(session.table, itemKey(listID, move.Datetime), move)
+		update.UpdateExpression = aws.String(aws.StringValue(update.UpdateExpression) + ", #s = :s")
+		update.ExpressionAttributeNames["#s"] = aws.String(attributeGSI1SK)
+		update.ExpressionAttributeValues[":s"] = stringAttribute(itemOrderKey(move.Order, move.Datetime))
+		return update
+	}
+	return session.moveItem(ctx, method, update, moves, func(ctx context.Context) error {
+		return session.updateItemConflict(ctx, listID, datetime, version)
+	})
+}
+
+// updateItemConflict tells a missing item from a stale version once the
+// condition of UpdateItem has failed
+func (session *SingleTableSession) updateItemConflict(ctx context.Context, listID string, datetime string, version int) error {
+	const method = "UpdateItem"
+	output, err := session.DynamoDBresource.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:              aws.String(session.table),
+		Key:                    itemKey(listID, datetime),
+		ProjectionExpression:   aws.String("version"),
+		ConsistentRead:         aws.Bool(true),
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	})
+	if err != nil {
+		return translateError(method, err)
+	}
+	logConsumedCapacity(method, output.ConsumedCapacity)
+	if len(output.Item) == 0 {
+		return &model.NotFoundError{
+			Entity: model.EntityItem,
+			ListID: listID,
+			Key:    datetime,
+		}
+	}
+	var item model.Item
+	if err := dynamodbattribute.UnmarshalMap(output.Item, &item); err != nil {
+		return err
+	}
+	return &model.VersionConflictError{
+		ListID:   listID,
+		Datetime: datetime,
+		Version:  version,
+		Current:  item.Version,
+	}
+}
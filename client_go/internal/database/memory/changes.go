@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+)
+
+// change is a write to the data set. A session opened on a directory
+// records each in its log before making it, so that Open can make it
+// again after a restart.
+type change struct {
+	Op    string       `json:"op"`
+	User  *model.User  `json:"user,omitempty"`
+	List  *model.List  `json:"list,omitempty"`
+	Guest *model.Guest `json:"guest,omitempty"`
+	Item  *model.Item  `json:"item,omitempty"`
+}
+
+// Kinds of change. Puts create or replace an entity, found by its key;
+// deletes only need the key.
+const (
+	opPutUser    = "put_user"
+	opPutList    = "put_list"
+	opDeleteList = "delete_list"
+	// opDeleteListContent deletes the guests and items of a list
+	opDeleteListContent = "delete_list_content"
+	opPutGuest          = "put_guest"
+	opDeleteGuest       = "delete_guest"
+	opPutItem           = "put_item"
+	opDeleteItem        = "delete_item"
+)
+
+// commit records changes in the log, if the session has one, then makes
+// them all. Nothing is made if they cannot be recorded. It must be
+// called with the mutex held.
+func (memorySession *Session) commit(changes ...change) error {
+	if memorySession.store != nil {
+		if err := memorySession.store.append(changes); err != nil {
+			return err
+		}
+	}
+	for _, c := range changes {
+		if err := memorySession.apply(c); err != nil {
+			// The methods of Session only make valid changes
+			panic(err)
+		}
+	}
+	if memorySession.store != nil && memorySession.store.due() {
+		// The log still holds everything should compaction fail
+		if err := memorySession.compact(); err != nil {
+			log.Printf("Compaction failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// apply makes a change. It must be called with the mutex held.
+func (memorySession *Session) apply(c change) error {
+	switch {
+	case c.Op == opPutUser && c.User != nil:
+		for i, u := range memorySession.users {
+			if u.ID == c.User.ID {
+				memorySession.users[i] = *c.User
+				return nil
+			}
+		}
+		memorySession.users = append(memorySession.users, *c.User)
+	case c.Op == opPutList && c.List != nil:
+		if l, ok := memorySession.findList(c.List.ID); ok {
+			*l = *c.List
+			return nil
+		}
+		l := *c.List
+		memorySession.lists = append(memorySession.lists, &l)
+	case c.Op == opDeleteList && c.List != nil:
+		for i, l := range memorySession.lists {
+			if l.ID == c.List.ID {
+				memorySession.lists = append(memorySession.lists[:i], memorySession.lists[i+1:]...)
+				break
+			}
+		}
+	case c.Op == opDeleteListContent && c.List != nil:
+		guests := make([]model.Guest, 0, len(memorySession.guests))
+		for _, g := range memorySession.guests {
+			if g.ListID != c.List.ID {
+				guests = append(guests, g)
+			}
+		}
+		memorySession.guests = guests
+		items := make([]model.Item, 0, len(memorySession.items))
+		for _, item := range memorySession.items {
+			if item.ListID != c.List.ID {
+				items = append(items, item)
+			}
+		}
+		memorySession.items = items
+	case c.Op == opPutGuest && c.Guest != nil:
+		if i, ok := memorySession.findGuest(c.Guest.ListID, c.Guest.UserID); ok {
+			memorySession.guests[i] = *c.Guest
+			return nil
+		}
+		memorySession.guests = append(memorySession.guests, *c.Guest)
+	case c.Op == opDeleteGuest && c.Guest != nil:
+		if i, ok := memorySession.findGuest(c.Guest.ListID, c.Guest.UserID); ok {
+			memorySession.guests = append(memorySession.guests[:i], memorySession.guests[i+1:]...)
+		}
+	case c.Op == opPutItem && c.Item != nil:
+		if i, ok := memorySession.findItem(c.Item.ListID, c.Item.Datetime); ok {
+			memorySession.items[i] = *c.Item
+			return nil
+		}
+		memorySession.items = append(memorySession.items, *c.Item)
+	case c.Op == opDeleteItem && c.Item != nil:
+		if i, ok := memorySession.findItem(c.Item.ListID, c.Item.Datetime); ok {
+			memorySession.items = append(memorySession.items[:i], memorySession.items[i+1:]...)
+		}
+	default:
+		return fmt.Errorf("memory: invalid change %q", c.Op)
+	}
+	return nil
+}
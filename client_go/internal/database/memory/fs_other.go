@@ -0,0 +1,16 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package memory
+
+import "os"
+
+// lockDir only opens path: directories are not locked on this platform
+func lockDir(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+}
+
+// syncDir does nothing, as directories cannot be synced on this platform
+func syncDir(dir string) error {
+	return nil
+}
@@ -1,75 +1,183 @@
 package memory
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+	"github.com/google/uuid"
 )
 
 // Session is a type
+//
+// Its data is lost on exit, unless it was made by Open, in which case
+// every change is also kept on disk.
 type Session struct {
-	users []model.User
+	mutex           sync.RWMutex
+	slowdownSeconds int
+	users           []model.User
+	lists           []*model.List
+	guests          []model.Guest
+	items           []model.Item
+	// store is nil unless the session was made by Open
+	store *store
 }
 
 // New initialises a dummy data set
 func New() *Session {
 	return &Session{
-		users: []model.User{
-			{
-				ID:    "7c2be6b9-746c-44be-bb33-78fb402ce6b8",
-				Email: "gwalker@hotmail.com",
-			},
-			{
-				ID:    "a10f9a38-f6dc-4e8a-ac1c-180486389697",
-				Email: "wdean@gmail.com",
-			},
-			{
-				ID:    "d5fc9ce9-5a5d-4ffc-9cc1-20a5c865bcc7",
-				Email: "millsshawn@henry.com",
-			},
-		},
+		users:  model.InitialUsers(),
+		lists:  make([]*model.List, 0),
+		guests: make([]model.Guest, 0),
+		items:  make([]model.Item, 0),
 	}
 }
 
-// ListUsers is a method
-func (memorySession *Session) ListUsers(lastUserID string, max int64) ([]model.User, string, error) {
-	var users = make([]model.User, 0)
-	var counter int64 = 0
-	collecting := false
-	lastIndex := 0
-	lastSeenUserID := ""
-
-	if lastUserID == "" {
-		collecting = true
-	}
-	for i, v := range memorySession.users {
-		if collecting {
-			users = append(users, v)
-			lastSeenUserID = v.ID
-			lastIndex = i
-			counter++
-			if counter >= (max) {
-				break
-			}
+// slowdown sleeps as requested via Slowdown and reports whether ctx
+// was done either beforehand or in the meantime
+func slowdown(ctx context.Context, memorySession *Session, method string, description string) error {
+	memorySession.mutex.RLock()
+	seconds := memorySession.slowdownSeconds
+	memorySession.mutex.RUnlock()
+	if seconds > 0 {
+		log.Printf("%s (%s) Sleeping for %d seconds", method, description, seconds)
+		select {
+		case <-time.After(time.Duration(seconds) * time.Second):
+		case <-ctx.Done():
+		}
+	}
+	return ctx.Err()
+}
+
+// findUser must be called with the mutex held
+func (memorySession *Session) findUser(userID string) (model.User, bool) {
+	for _, u := range memorySession.users {
+		if u.ID == userID {
+			return u, true
+		}
+	}
+	return model.User{}, false
+}
+
+// findList must be called with the mutex held
+func (memorySession *Session) findList(listID string) (*model.List, bool) {
+	for _, l := range memorySession.lists {
+		if l.ID == listID {
+			return l, true
+		}
+	}
+	return nil, false
+}
+
+// checkWritableList reports whether guests and items may be added to the
+// list. It must be called with the mutex held.
+func (memorySession *Session) checkWritableList(listID string) error {
+	l, ok := memorySession.findList(listID)
+	if !ok {
+		return &model.NotFoundError{
+			Entity: model.EntityList,
+			Key:    listID,
+		}
+	}
+	if l.UnderDeletion {
+		return &model.UnderDeletionError{
+			ListID: listID,
+		}
+	}
+	return nil
+}
+
+// findGuest must be called with the mutex held
+func (memorySession *Session) findGuest(listID string, userID string) (int, bool) {
+	for i, g := range memorySession.guests {
+		if g.ListID == listID && g.UserID == userID {
+			return i, true
 		}
-		if v.ID == lastUserID {
-			collecting = true
+	}
+	return -1, false
+}
+
+// findItem must be called with the mutex held
+func (memorySession *Session) findItem(listID string, datetime string) (int, bool) {
+	for i, item := range memorySession.items {
+		if item.ListID == listID && item.Datetime == datetime {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// page locates the entries that follow cursor among count entries sorted
+// by key, as a DynamoDB Query does with the table's sort key
+func page(count int, key func(i int) string, cursor model.Cursor, max int64) (int, int, model.Cursor, error) {
+	if max < 1 {
+		return 0, 0, "", &model.ValidationError{
+			Field:  "max",
+			Reason: fmt.Sprintf("%d is not a positive page size", max),
 		}
 	}
-	if lastIndex == len(memorySession.users)-1 {
-		lastSeenUserID = ""
+	keys, err := cursor.Keys(1)
+	if err != nil {
+		return 0, 0, "", err
+	}
+	start := 0
+	if keys != nil {
+		start = sort.Search(count, func(i int) bool {
+			return key(i) > keys[0]
+		})
+	}
+	end := count
+	if int64(end-start) > max {
+		end = start + int(max)
+	}
+	if end == count {
+		return start, end, "", nil
+	}
+	return start, end, model.NewCursor(key(end - 1)), nil
+}
+
+// ListUsers is a method
+func (memorySession *Session) ListUsers(ctx context.Context, cursor model.Cursor, max int64) ([]model.User, model.Cursor, error) {
+	if err := slowdown(ctx, memorySession, "ListUsers", "entry"); err != nil {
+		return nil, "", err
+	}
+	memorySession.mutex.RLock()
+	defer memorySession.mutex.RUnlock()
+	// Users are kept in the order they were created, and paged by ID
+	users := make([]model.User, len(memorySession.users))
+	copy(users, memorySession.users)
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+	start, end, next, err := page(len(users), func(i int) string {
+		return users[i].ID
+	}, cursor, max)
+	if err != nil {
+		return nil, "", err
 	}
-	return users, lastSeenUserID, nil
+	return users[start:end], next, nil
 }
 
 // Slowdown is a method
 func (memorySession *Session) Slowdown(seconds int) {
-
+	memorySession.mutex.Lock()
+	defer memorySession.mutex.Unlock()
+	memorySession.slowdownSeconds = seconds
 }
 
 // GetUsersByIDs is a method
-func (memorySession *Session) GetUsersByIDs(ids []string) ([]model.User, error) {
-	users := make([]model.User, 1)
+func (memorySession *Session) GetUsersByIDs(ctx context.Context, ids []string) ([]model.User, error) {
+	if err := slowdown(ctx, memorySession, "GetUsersByIDs", "entry"); err != nil {
+		return []model.User{}, err
+	}
+	memorySession.mutex.RLock()
+	defer memorySession.mutex.RUnlock()
+	// Unknown IDs are skipped, as BatchGetItem does
+	users := make([]model.User, 0, len(ids))
 	for _, u := range memorySession.users {
 		for _, id := range ids {
 			if u.ID == id {
@@ -77,144 +185,581 @@ func (memorySession *Session) GetUsersByIDs(ids []string) ([]model.User, error)
 			}
 		}
 	}
-	if len(users) > 0 {
-		return users, nil
-	}
-	return []model.User{}, &model.CustomError{
-		ErrorCode:   model.ErrorNoMatch,
-		ErrorDetail: fmt.Sprintf("%v", ids),
-	}
+	return users, nil
 }
 
 // GetUserByEmail is a method
-func (memorySession *Session) GetUserByEmail(email string) (model.User, error) {
+func (memorySession *Session) GetUserByEmail(ctx context.Context, email string) (model.User, error) {
+	if err := slowdown(ctx, memorySession, "GetUserByEmail", "entry"); err != nil {
+		return model.User{}, err
+	}
+	memorySession.mutex.RLock()
+	defer memorySession.mutex.RUnlock()
 	for _, v := range memorySession.users {
 		if v.Email == email {
 			return v, nil
 		}
 	}
-	return model.User{}, &model.CustomError{
-		ErrorCode:   model.ErrorNoMatch,
-		ErrorDetail: email,
+	return model.User{}, &model.NotFoundError{
+		Entity: model.EntityUser,
+		Key:    email,
+	}
+}
+
+// CreateUser is a method
+//
+// Emails are not checked for uniqueness, just as in the DynamoDB
+// backend, whose email index cannot enforce it.
+func (memorySession *Session) CreateUser(ctx context.Context, email string) (string, error) {
+	if err := slowdown(ctx, memorySession, "CreateUser", "entry"); err != nil {
+		return "", err
+	}
+	memorySession.mutex.Lock()
+	defer memorySession.mutex.Unlock()
+	uuidString := uuid.New().String()
+	if _, ok := memorySession.findUser(uuidString); ok {
+		return "", &model.AlreadyExistsError{
+			Entity: model.EntityUser,
+			Key:    uuidString,
+		}
+	}
+	if err := memorySession.commit(change{Op: opPutUser, User: &model.User{
+		ID:    uuidString,
+		Email: email,
+	}}); err != nil {
+		return "", err
 	}
+	return uuidString, nil
 }
 
 // GetAggregateListsByUserID is a method
-func (memorySession *Session) GetAggregateListsByUserID(lastListID string) ([]model.AggregateList, error) {
-	return []model.AggregateList{}, &model.CustomError{
-		ErrorCode:   model.ErrorUnimplemented,
-		ErrorDetail: "Interface.GetAggregateListsByUserID",
+func (memorySession *Session) GetAggregateListsByUserID(ctx context.Context, userID string) ([]model.AggregateList, error) {
+	if err := slowdown(ctx, memorySession, "GetAggregateListsByUserID", "entry"); err != nil {
+		return []model.AggregateList{}, err
 	}
+	memorySession.mutex.RLock()
+	defer memorySession.mutex.RUnlock()
+	return memorySession.aggregateLists(userID), nil
+}
+
+// GetAggregateListsByUserIDPage is a method
+func (memorySession *Session) GetAggregateListsByUserIDPage(ctx context.Context, userID string, cursor model.Cursor, max int64) ([]model.AggregateList, model.Cursor, error) {
+	if err := slowdown(ctx, memorySession, "GetAggregateListsByUserIDPage", "entry"); err != nil {
+		return nil, "", err
+	}
+	memorySession.mutex.RLock()
+	defer memorySession.mutex.RUnlock()
+	alists := memorySession.aggregateLists(userID)
+	// Owned lists come first, as in the DynamoDB backend
+	key := func(i int) string {
+		if alists[i].AsGuest {
+			return "1" + alists[i].ID
+		}
+		return "0" + alists[i].ID
+	}
+	sort.Slice(alists, func(i, j int) bool {
+		return key(i) < key(j)
+	})
+	start, end, next, err := page(len(alists), key, cursor, max)
+	if err != nil {
+		return nil, "", err
+	}
+	return alists[start:end], next, nil
+}
+
+// aggregateLists must be called with the mutex held
+func (memorySession *Session) aggregateLists(userID string) []model.AggregateList {
+	count := func(listID string) (int, int) {
+		guestCount := 0
+		for _, g := range memorySession.guests {
+			if g.ListID == listID {
+				guestCount++
+			}
+		}
+		itemCount := 0
+		for _, item := range memorySession.items {
+			if item.ListID == listID {
+				itemCount++
+			}
+		}
+		return guestCount, itemCount
+	}
+
+	alists := make([]model.AggregateList, 0, 1)
+	for _, g := range memorySession.guests {
+		if g.UserID != userID {
+			continue
+		}
+		if l, ok := memorySession.findList(g.ListID); ok {
+			guestCount, itemCount := count(l.ID)
+			alists = append(alists, model.AggregateList{
+				List:       *l,
+				GuestCount: guestCount,
+				ItemCount:  itemCount,
+				AsGuest:    true,
+			})
+		}
+	}
+	for _, l := range memorySession.lists {
+		if l.UserID != userID {
+			continue
+		}
+		guestCount, itemCount := count(l.ID)
+		alists = append(alists, model.AggregateList{
+			List:       *l,
+			GuestCount: guestCount,
+			ItemCount:  itemCount,
+		})
+	}
+	return alists
 }
 
 // GetListsByUserID is a method
-func (memorySession *Session) GetListsByUserID(lastListID string) ([]model.List, error) {
-	return []model.List{}, &model.CustomError{
-		ErrorCode:   model.ErrorUnimplemented,
-		ErrorDetail: "Interface.GetListsByUserID",
+func (memorySession *Session) GetListsByUserID(ctx context.Context, userID string) ([]model.List, error) {
+	if err := slowdown(ctx, memorySession, "GetListsByUserID", "entry"); err != nil {
+		return []model.List{}, err
+	}
+	memorySession.mutex.RLock()
+	defer memorySession.mutex.RUnlock()
+	lists := make([]model.List, 0)
+	for _, l := range memorySession.lists {
+		if l.UserID == userID {
+			lists = append(lists, *l)
+		}
 	}
+	return lists, nil
 }
 
 // CreateList is a method
-func (memorySession *Session) CreateList(userID string, title string) (string, error) {
-	return "", &model.CustomError{
-		ErrorCode:   model.ErrorUnimplemented,
-		ErrorDetail: "Interface.CreateList",
+func (memorySession *Session) CreateList(ctx context.Context, userID string, title string) (string, error) {
+	if err := slowdown(ctx, memorySession, "CreateList", "entry"); err != nil {
+		return "", err
+	}
+	memorySession.mutex.Lock()
+	defer memorySession.mutex.Unlock()
+	if _, ok := memorySession.findUser(userID); !ok {
+		return "", &model.NotFoundError{
+			Entity: model.EntityUser,
+			Key:    userID,
+		}
+	}
+	uuidString := uuid.New().String()
+	if _, ok := memorySession.findList(uuidString); ok {
+		return "", &model.AlreadyExistsError{
+			Entity: model.EntityList,
+			Key:    uuidString,
+		}
+	}
+	if err := memorySession.commit(change{Op: opPutList, List: &model.List{
+		ID:     uuidString,
+		Title:  title,
+		UserID: userID,
+	}}); err != nil {
+		return "", err
 	}
+	return uuidString, nil
 }
 
 // DeleteList is a method
-func (memorySession *Session) DeleteList(listID string, userID string) error {
-	return &model.CustomError{
-		ErrorCode:   model.ErrorUnimplemented,
-		ErrorDetail: "Interface.DeleteList",
+//
+// The deletion is carried out in the same three steps used by the
+// DynamoDB backend so that the under_deletion semantics can be observed
+// when combined with Slowdown.
+func (memorySession *Session) DeleteList(ctx context.Context, listID string, userID string) error {
+	const method = "DeleteList"
+	if err := slowdown(ctx, memorySession, method, "entry"); err != nil {
+		return err
+	}
+
+	//
+	// First mark the list as being deleted
+	//
+	if err := slowdown(ctx, memorySession, method, "before setting under_deletion"); err != nil {
+		return err
+	}
+	memorySession.mutex.Lock()
+	l, ok := memorySession.findList(listID)
+	if !ok {
+		memorySession.mutex.Unlock()
+		return &model.NotFoundError{
+			Entity: model.EntityList,
+			Key:    listID,
+		}
+	}
+	if l.UserID != userID {
+		memorySession.mutex.Unlock()
+		return &model.ForbiddenError{
+			UserID: userID,
+			ListID: listID,
+			Action: "delete",
+		}
+	}
+	marked := *l
+	marked.UnderDeletion = true
+	err := memorySession.commit(change{Op: opPutList, List: &marked})
+	memorySession.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	//
+	// Then delete the list's guests and items. Should the deletion be
+	// interrupted here, calling DeleteList again resumes it.
+	//
+	if err := slowdown(ctx, memorySession, method, "before deleting guests and items"); err != nil {
+		return err
+	}
+	memorySession.mutex.Lock()
+	err = memorySession.commit(change{Op: opDeleteListContent, List: &model.List{ID: listID}})
+	memorySession.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	//
+	// Finally, delete the list
+	//
+	if err := slowdown(ctx, memorySession, method, "before deleting list"); err != nil {
+		return err
+	}
+	memorySession.mutex.Lock()
+	defer memorySession.mutex.Unlock()
+	for _, l := range memorySession.lists {
+		if l.ID == listID && l.UserID == userID && l.UnderDeletion {
+			return memorySession.commit(change{Op: opDeleteList, List: &model.List{ID: listID}})
+		}
+	}
+	// A concurrent call has completed the deletion
+	return &model.NotFoundError{
+		Entity: model.EntityList,
+		Key:    listID,
 	}
 }
 
 // GetListByListID is a method
-func (memorySession *Session) GetListByListID(listID string) (model.List, error) {
-	return model.List{}, &model.CustomError{
-		ErrorCode:   model.ErrorUnimplemented,
-		ErrorDetail: "Interface.GetListByListID",
+func (memorySession *Session) GetListByListID(ctx context.Context, listID string) (model.List, error) {
+	if err := slowdown(ctx, memorySession, "GetListByListID", "entry"); err != nil {
+		return model.List{}, err
+	}
+	memorySession.mutex.RLock()
+	defer memorySession.mutex.RUnlock()
+	if l, ok := memorySession.findList(listID); ok {
+		return *l, nil
+	}
+	return model.List{}, &model.NotFoundError{
+		Entity: model.EntityList,
+		Key:    listID,
 	}
 }
 
 // GetAggregateGuestsByListID is a method
-func (memorySession *Session) GetAggregateGuestsByListID(listID string) ([]model.AggregateGuest, error) {
-	return []model.AggregateGuest{}, &model.CustomError{
-		ErrorCode:   model.ErrorUnimplemented,
-		ErrorDetail: "Interface.GetAggregateGuestsByUserID",
+func (memorySession *Session) GetAggregateGuestsByListID(ctx context.Context, listID string) ([]model.AggregateGuest, error) {
+	if err := slowdown(ctx, memorySession, "GetAggregateGuestsByListID", "entry"); err != nil {
+		return []model.AggregateGuest{}, err
+	}
+	memorySession.mutex.RLock()
+	defer memorySession.mutex.RUnlock()
+	return memorySession.aggregateGuests(listID), nil
+}
+
+// GetAggregateGuestsByListIDPage is a method
+func (memorySession *Session) GetAggregateGuestsByListIDPage(ctx context.Context, listID string, cursor model.Cursor, max int64) ([]model.AggregateGuest, model.Cursor, error) {
+	if err := slowdown(ctx, memorySession, "GetAggregateGuestsByListIDPage", "entry"); err != nil {
+		return nil, "", err
+	}
+	memorySession.mutex.RLock()
+	defer memorySession.mutex.RUnlock()
+	aggregateGuests := memorySession.aggregateGuests(listID)
+	// Mimic the ordering provided by the guests table's sort key
+	sort.Slice(aggregateGuests, func(i, j int) bool {
+		return aggregateGuests[i].UserID < aggregateGuests[j].UserID
+	})
+	start, end, next, err := page(len(aggregateGuests), func(i int) string {
+		return aggregateGuests[i].UserID
+	}, cursor, max)
+	if err != nil {
+		return nil, "", err
+	}
+	return aggregateGuests[start:end], next, nil
+}
+
+// aggregateGuests must be called with the mutex held
+func (memorySession *Session) aggregateGuests(listID string) []model.AggregateGuest {
+	aggregateGuests := make([]model.AggregateGuest, 0)
+	for _, g := range memorySession.guests {
+		if g.ListID == listID {
+			user, _ := memorySession.findUser(g.UserID)
+			aggregateGuests = append(aggregateGuests, model.AggregateGuest{
+				Guest: g,
+				Email: user.Email,
+			})
+		}
 	}
+	return aggregateGuests
 }
 
 // GetGuestsByListID is a method
-func (memorySession *Session) GetGuestsByListID(listID string) ([]model.Guest, error) {
-	return []model.Guest{}, &model.CustomError{
-		ErrorCode:   model.ErrorUnimplemented,
-		ErrorDetail: "Interface.GetGuestsByListID",
+func (memorySession *Session) GetGuestsByListID(ctx context.Context, listID string) ([]model.Guest, error) {
+	if err := slowdown(ctx, memorySession, "GetGuestsByListID", "entry"); err != nil {
+		return []model.Guest{}, err
 	}
+	memorySession.mutex.RLock()
+	defer memorySession.mutex.RUnlock()
+	guests := make([]model.Guest, 0)
+	for _, g := range memorySession.guests {
+		if g.ListID == listID {
+			guests = append(guests, g)
+		}
+	}
+	return guests, nil
 }
 
 // GetGuestsByUserID is a method
-func (memorySession *Session) GetGuestsByUserID(userID string) ([]model.Guest, error) {
-	return []model.Guest{}, &model.CustomError{
-		ErrorCode:   model.ErrorUnimplemented,
-		ErrorDetail: "Interface.GetGuestsByUserID",
+func (memorySession *Session) GetGuestsByUserID(ctx context.Context, userID string) ([]model.Guest, error) {
+	if err := slowdown(ctx, memorySession, "GetGuestsByUserID", "entry"); err != nil {
+		return []model.Guest{}, err
 	}
+	memorySession.mutex.RLock()
+	defer memorySession.mutex.RUnlock()
+	guests := make([]model.Guest, 0)
+	for _, g := range memorySession.guests {
+		if g.UserID == userID {
+			guests = append(guests, g)
+		}
+	}
+	return guests, nil
 }
 
 // CreateGuest is a method
-func (memorySession *Session) CreateGuest(listID string, userID string) error {
-	return &model.CustomError{
-		ErrorCode:   model.ErrorUnimplemented,
-		ErrorDetail: "Interface.CreateGuest",
+func (memorySession *Session) CreateGuest(ctx context.Context, listID string, userID string) error {
+	if err := slowdown(ctx, memorySession, "CreateGuest", "entry"); err != nil {
+		return err
+	}
+	memorySession.mutex.Lock()
+	defer memorySession.mutex.Unlock()
+	if err := memorySession.checkWritableList(listID); err != nil {
+		return err
+	}
+	if _, ok := memorySession.findUser(userID); !ok {
+		return &model.NotFoundError{
+			Entity: model.EntityUser,
+			Key:    userID,
+		}
+	}
+	if _, ok := memorySession.findGuest(listID, userID); ok {
+		return &model.AlreadyExistsError{
+			Entity: model.EntityGuest,
+			ListID: listID,
+			Key:    userID,
+		}
 	}
+	return memorySession.commit(change{Op: opPutGuest, Guest: &model.Guest{
+		ListID: listID,
+		UserID: userID,
+	}})
 }
 
 // DeleteGuest is a method
-func (memorySession *Session) DeleteGuest(listID string, userID string) error {
-	return &model.CustomError{
-		ErrorCode:   model.ErrorUnimplemented,
-		ErrorDetail: "Interface.DeleteGuest",
+func (memorySession *Session) DeleteGuest(ctx context.Context, listID string, userID string) error {
+	if err := slowdown(ctx, memorySession, "DeleteGuest", "entry"); err != nil {
+		return err
 	}
+	memorySession.mutex.Lock()
+	defer memorySession.mutex.Unlock()
+	if _, ok := memorySession.findGuest(listID, userID); !ok {
+		return &model.NotFoundError{
+			Entity: model.EntityGuest,
+			ListID: listID,
+			Key:    userID,
+		}
+	}
+	return memorySession.commit(change{Op: opDeleteGuest, Guest: &model.Guest{
+		ListID: listID,
+		UserID: userID,
+	}})
 }
 
 // IsPresentGuest is a method
-func (memorySession *Session) IsPresentGuest(listID string, userID string) (bool, error) {
-	return false, &model.CustomError{
-		ErrorCode:   model.ErrorUnimplemented,
-		ErrorDetail: "Interface.IsPresentGuest",
+func (memorySession *Session) IsPresentGuest(ctx context.Context, listID string, userID string) (bool, error) {
+	if err := slowdown(ctx, memorySession, "IsPresentGuest", "entry"); err != nil {
+		return false, err
 	}
+	memorySession.mutex.RLock()
+	defer memorySession.mutex.RUnlock()
+	_, ok := memorySession.findGuest(listID, userID)
+	return ok, nil
 }
 
 // GetItemsByListID is a method
-func (memorySession *Session) GetItemsByListID(listID string) ([]model.Item, error) {
-	return []model.Item{}, &model.CustomError{
-		ErrorCode:   model.ErrorUnimplemented,
-		ErrorDetail: "Interface.GetItemsByListID",
+func (memorySession *Session) GetItemsByListID(ctx context.Context, listID string) ([]model.Item, error) {
+	if err := slowdown(ctx, memorySession, "GetItemsByListID", "entry"); err != nil {
+		return []model.Item{}, err
+	}
+	memorySession.mutex.RLock()
+	defer memorySession.mutex.RUnlock()
+	items := memorySession.itemsByListID(listID)
+	model.SortItems(items)
+	return items, nil
+}
+
+// GetItemsByListIDPage is a method
+func (memorySession *Session) GetItemsByListIDPage(ctx context.Context, listID string, cursor model.Cursor, max int64) ([]model.Item, model.Cursor, error) {
+	if err := slowdown(ctx, memorySession, "GetItemsByListIDPage", "entry"); err != nil {
+		return nil, "", err
+	}
+	memorySession.mutex.RLock()
+	defer memorySession.mutex.RUnlock()
+	items := memorySession.itemsByListID(listID)
+	model.SortItems(items)
+	return model.PageItems(items, cursor, max)
+}
+
+// GetItem is a method
+func (memorySession *Session) GetItem(ctx context.Context, listID string, datetime string) (model.Item, error) {
+	if err := slowdown(ctx, memorySession, "GetItem", "entry"); err != nil {
+		return model.Item{}, err
+	}
+	memorySession.mutex.RLock()
+	defer memorySession.mutex.RUnlock()
+	i, ok := memorySession.findItem(listID, datetime)
+	if !ok {
+		return model.Item{}, &model.NotFoundError{
+			Entity: model.EntityItem,
+			ListID: listID,
+			Key:    datetime,
+		}
+	}
+	return memorySession.items[i], nil
+}
+
+// itemsByListID must be called with the mutex held
+func (memorySession *Session) itemsByListID(listID string) []model.Item {
+	items := make([]model.Item, 0)
+	for _, item := range memorySession.items {
+		if item.ListID == listID {
+			items = append(items, item)
+		}
 	}
+	// Mimic the ordering provided by the items table's sort key
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Datetime < items[j].Datetime
+	})
+	return items
 }
 
 // CreateItem is a method
-func (memorySession *Session) CreateItem(listID string, description string) error {
-	return &model.CustomError{
-		ErrorCode:   model.ErrorUnimplemented,
-		ErrorDetail: "Interface.CreateItem",
+func (memorySession *Session) CreateItem(ctx context.Context, listID string, description string) (string, error) {
+	if err := slowdown(ctx, memorySession, "CreateItem", "entry"); err != nil {
+		return "", err
 	}
+	memorySession.mutex.Lock()
+	defer memorySession.mutex.Unlock()
+
+	now := time.Now()
+	datetime := now.Format("2006-01-02T15:04:05.999999")
+
+	if err := memorySession.checkWritableList(listID); err != nil {
+		return "", err
+	}
+	if _, ok := memorySession.findItem(listID, datetime); ok {
+		return "", &model.AlreadyExistsError{
+			Entity: model.EntityItem,
+			ListID: listID,
+			Key:    datetime,
+		}
+	}
+	if err := memorySession.commit(change{Op: opPutItem, Item: &model.Item{
+		ListID:      listID,
+		Datetime:    datetime,
+		Description: description,
+		Done:        false,
+		Order:       model.NewItemOrder(now),
+	}}); err != nil {
+		return "", err
+	}
+	return datetime, nil
 }
 
 // DeleteItem is a method
-func (memorySession *Session) DeleteItem(listID string, datetime string) error {
-	return &model.CustomError{
-		ErrorCode:   model.ErrorUnimplemented,
-		ErrorDetail: "Interface.DeleteItem",
+func (memorySession *Session) DeleteItem(ctx context.Context, listID string, datetime string) error {
+	if err := slowdown(ctx, memorySession, "DeleteItem", "entry"); err != nil {
+		return err
 	}
+	memorySession.mutex.Lock()
+	defer memorySession.mutex.Unlock()
+	if _, ok := memorySession.findItem(listID, datetime); !ok {
+		return &model.NotFoundError{
+			Entity: model.EntityItem,
+			ListID: listID,
+			Key:    datetime,
+		}
+	}
+	return memorySession.commit(change{Op: opDeleteItem, Item: &model.Item{
+		ListID:   listID,
+		Datetime: datetime,
+	}})
 }
 
 // UpdateItem is a method
-func (memorySession *Session) UpdateItem(listID string, datetime string, version int, description *string, done *bool) (int, error) {
-	return 0, &model.CustomError{
-		ErrorCode:   model.ErrorUnimplemented,
-		ErrorDetail: "Interface.UpdateItem",
+func (memorySession *Session) UpdateItem(ctx context.Context, listID string, datetime string, version int, description *string, done *bool) (int, error) {
+	if err := slowdown(ctx, memorySession, "UpdateItem", "entry"); err != nil {
+		return 0, err
+	}
+	memorySession.mutex.Lock()
+	defer memorySession.mutex.Unlock()
+	i, ok := memorySession.findItem(listID, datetime)
+	if !ok {
+		return 0, &model.NotFoundError{
+			Entity: model.EntityItem,
+			ListID: listID,
+			Key:    datetime,
+		}
+	}
+	if memorySession.items[i].Version != version {
+		return 0, &model.VersionConflictError{
+			ListID:   listID,
+			Datetime: datetime,
+			Version:  version,
+			Current:  memorySession.items[i].Version,
+		}
+	}
+	item := memorySession.items[i]
+	item.Version++
+	if description != nil {
+		item.Description = *description
+	}
+	if done != nil {
+		item.Done = *done
+	}
+	if err := memorySession.commit(change{Op: opPutItem, Item: &item}); err != nil {
+		return 0, err
+	}
+	return item.Version, nil
+}
+
+// MoveItem is a method
+func (memorySession *Session) MoveItem(ctx context.Context, listID string, datetime string, version int, position int) (int, error) {
+	if err := slowdown(ctx, memorySession, "MoveItem", "entry"); err != nil {
+		return 0, err
+	}
+	memorySession.mutex.Lock()
+	defer memorySession.mutex.Unlock()
+	items := memorySession.itemsByListID(listID)
+	model.SortItems(items)
+	moves, err := model.PlanMove(items, listID, datetime, version, position)
+	if err != nil {
+		return 0, err
+	}
+	changes := make([]change, 0, len(moves))
+	for _, move := range moves {
+		i, _ := memorySession.findItem(listID, move.Datetime)
+		item := memorySession.items[i]
+		item.Order = move.Order
+		item.Version++
+		changes = append(changes, change{Op: opPutItem, Item: &item})
+	}
+	if err := memorySession.commit(changes...); err != nil {
+		return 0, err
 	}
+	return version + 1, nil
 }
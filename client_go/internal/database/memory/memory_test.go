@@ -0,0 +1,222 @@
+package memory
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model/modeltest"
+)
+
+// Users made by New
+const (
+	ownerID = "7c2be6b9-746c-44be-bb33-78fb402ce6b8"
+	guestID = "a10f9a38-f6dc-4e8a-ac1c-180486389697"
+)
+
+func TestConformance(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T) model.Interface {
+		return New()
+	})
+}
+
+func TestResumeDeletion(t *testing.T) {
+	session := New()
+	modeltest.RunResumeDeletion(t, session, func(t *testing.T, listID string) {
+		session.mutex.Lock()
+		defer session.mutex.Unlock()
+		l, ok := session.findList(listID)
+		if !ok {
+			t.Fatalf("list %s not found", listID)
+		}
+		l.UnderDeletion = true
+	})
+}
+
+// tempDir returns a data directory removed at the end of the test
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func open(t *testing.T, dir string) *Session {
+	t.Helper()
+	session, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	return session
+}
+
+// crash abandons session as a killed process would, without compacting
+func crash(session *Session) {
+	session.store.log.Close()
+	session.store.lock.Close()
+	session.store.log = nil
+}
+
+// populate makes every kind of change, in 14 calls
+func populate(t *testing.T, session *Session) {
+	t.Helper()
+	ctx := context.Background()
+	userID, err := session.CreateUser(ctx, "durable@example.invalid")
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	listID, err := session.CreateList(ctx, userID, "Groceries")
+	if err != nil {
+		t.Fatalf("CreateList: %v", err)
+	}
+	if err := session.CreateGuest(ctx, listID, ownerID); err != nil {
+		t.Fatalf("CreateGuest: %v", err)
+	}
+	var datetimes []string
+	for _, description := range []string{"milk", "eggs", "bread"} {
+		datetime, err := session.CreateItem(ctx, listID, description)
+		if err != nil {
+			t.Fatalf("CreateItem: %v", err)
+		}
+		datetimes = append(datetimes, datetime)
+		time.Sleep(time.Millisecond)
+	}
+	done := true
+	if _, err := session.UpdateItem(ctx, listID, datetimes[0], 0, nil, &done); err != nil {
+		t.Fatalf("UpdateItem: %v", err)
+	}
+	if _, err := session.MoveItem(ctx, listID, datetimes[2], 0, 0); err != nil {
+		t.Fatalf("MoveItem: %v", err)
+	}
+	if err := session.DeleteItem(ctx, listID, datetimes[1]); err != nil {
+		t.Fatalf("DeleteItem: %v", err)
+	}
+
+	deletedID, err := session.CreateList(ctx, ownerID, "Deleted")
+	if err != nil {
+		t.Fatalf("CreateList: %v", err)
+	}
+	if _, err := session.CreateItem(ctx, deletedID, "gone"); err != nil {
+		t.Fatalf("CreateItem: %v", err)
+	}
+	if err := session.DeleteList(ctx, deletedID, ownerID); err != nil {
+		t.Fatalf("DeleteList: %v", err)
+	}
+}
+
+// assertSameData fails unless both sessions hold the same data, in the
+// same order
+func assertSameData(t *testing.T, expected *Session, actual *Session) {
+	t.Helper()
+	if !reflect.DeepEqual(expected.users, actual.users) || !reflect.DeepEqual(expected.lists, actual.lists) ||
+		!reflect.DeepEqual(expected.guests, actual.guests) || !reflect.DeepEqual(expected.items, actual.items) {
+		t.Fatalf("data differs:\nexpected %v %v %v %v\nactual   %v %v %v %v",
+			expected.users, expected.lists, expected.guests, expected.items,
+			actual.users, actual.lists, actual.guests, actual.items)
+	}
+}
+
+func TestDurableConformance(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T) model.Interface {
+		session := open(t, tempDir(t))
+		t.Cleanup(func() { session.Close() })
+		return session
+	})
+}
+
+func TestRecovery(t *testing.T) {
+	dir := tempDir(t)
+	session := open(t, dir)
+	populate(t, session)
+	crash(session)
+
+	recovered := open(t, dir)
+	assertSameData(t, session, recovered)
+
+	// A change cut short by the crash is dropped
+	populate(t, recovered)
+	crash(recovered)
+	file, err := os.OpenFile(filepath.Join(dir, logFile), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`0badcafe {"seq":99,"chan`)
+	file.Close()
+	again := open(t, dir)
+	assertSameData(t, recovered, again)
+
+	// The session is still usable, and so is its directory
+	if _, err := again.CreateUser(context.Background(), "after@example.invalid"); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if err := again.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	assertSameData(t, again, open(t, dir))
+}
+
+func TestCorruptLog(t *testing.T) {
+	dir := tempDir(t)
+	session := open(t, dir)
+	populate(t, session)
+	crash(session)
+
+	path := filepath.Join(dir, logFile)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Damaging an entry that others follow is not mistaken for a crash
+	data[20] ^= 1
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(dir); err == nil || !strings.Contains(err.Error(), "line 1: checksum mismatch") {
+		t.Fatalf("Open: got %v, expected a checksum mismatch", err)
+	}
+}
+
+func TestCompaction(t *testing.T) {
+	dir := tempDir(t)
+	session := open(t, dir)
+	session.store.compactAfter = 4
+	populate(t, session)
+	if session.store.entries != 2 {
+		t.Fatalf("%d entries in the log, expected 2", session.store.entries)
+	}
+
+	// Entries already in the snapshot are skipped, as happens when a
+	// crash interrupts compaction before the log is emptied
+	path := filepath.Join(dir, logFile)
+	before, err := ioutil.ReadFile(path)
+	if err != nil || len(before) == 0 {
+		t.Fatalf("reading the log: %d bytes (%v)", len(before), err)
+	}
+	session.mutex.Lock()
+	if err := session.compact(); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	session.mutex.Unlock()
+	crash(session)
+	if err := ioutil.WriteFile(path, before, 0600); err != nil {
+		t.Fatal(err)
+	}
+	assertSameData(t, session, open(t, dir))
+}
+
+func TestOpenLocked(t *testing.T) {
+	dir := tempDir(t)
+	session := open(t, dir)
+	defer session.Close()
+	if _, err := Open(dir); err == nil {
+		t.Fatal("Open: expected the directory to be in use")
+	}
+}
@@ -0,0 +1,300 @@
+package memory
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+)
+
+// Files of a data directory
+const (
+	snapshotFile = "snapshot.json"
+	logFile      = "changes.log"
+	lockFile     = "lock"
+)
+
+// defaultCompactAfter is how many entries the log grows to before they
+// are folded into a new snapshot
+const defaultCompactAfter = 1000
+
+var errClosed = errors.New("memory: session closed")
+
+// store keeps the data of a Session in a directory, as a snapshot of the
+// whole data set and a log of the changes made since. Each line of the
+// log is an entry: the CRC-32 of its JSON, in hexadecimal, a space, and
+// the JSON itself.
+type store struct {
+	dir  string
+	lock *os.File
+	// log is nil once the store is closed
+	log  *os.File
+	size int64
+	// seq numbers the last entry written
+	seq          uint64
+	entries      int
+	compactAfter int
+	// err is set when the log could not be restored after a failed
+	// write, and fails every write that follows
+	err error
+}
+
+// entry is a line of the log: the changes made by a call, which are
+// replayed together or not at all
+type entry struct {
+	Seq     uint64   `json:"seq"`
+	Changes []change `json:"changes"`
+}
+
+// snapshot is the data set as of the entry numbered Seq. Entries up to
+// Seq may still be in the log, should compaction have been interrupted,
+// and are then skipped.
+type snapshot struct {
+	Seq    uint64        `json:"seq"`
+	Users  []model.User  `json:"users"`
+	Lists  []model.List  `json:"lists"`
+	Guests []model.Guest `json:"guests"`
+	Items  []model.Item  `json:"items"`
+}
+
+// Open returns a Session whose data persists in dir, which is created if
+// need be, starting with the users of New. Every change is written to
+// the log and synced before the call making it returns, so that the
+// data survives crashes; Open replays the changes found in the log,
+// dropping the last one if it was only partly written. The log is folded
+// into a new snapshot when the session is opened and closed, and after
+// every thousand writes in between. Only one process may open dir at a
+// time.
+func Open(dir string) (*Session, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	lock, err := lockDir(filepath.Join(dir, lockFile))
+	if err != nil {
+		return nil, err
+	}
+	memorySession := New()
+	memorySession.store = &store{dir: dir, lock: lock, compactAfter: defaultCompactAfter}
+	if err := memorySession.recover(); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	memorySession.store.log, err = os.OpenFile(filepath.Join(dir, logFile), os.O_WRONLY|os.O_CREATE, 0600)
+	if err == nil {
+		err = memorySession.compact()
+	}
+	if err != nil {
+		if memorySession.store.log != nil {
+			memorySession.store.log.Close()
+		}
+		lock.Close()
+		return nil, err
+	}
+	return memorySession, nil
+}
+
+// Close folds the log into a new snapshot and releases the directory.
+// Sessions made by New have nothing to close. Since every change is
+// already on disk, not calling Close loses nothing.
+func (memorySession *Session) Close() error {
+	memorySession.mutex.Lock()
+	defer memorySession.mutex.Unlock()
+	s := memorySession.store
+	if s == nil || s.log == nil {
+		return nil
+	}
+	err := memorySession.compact()
+	if closeErr := s.log.Close(); err == nil {
+		err = closeErr
+	}
+	s.log = nil
+	s.lock.Close()
+	return err
+}
+
+// recover loads the snapshot, if any, then replays the log
+func (memorySession *Session) recover() error {
+	s := memorySession.store
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, snapshotFile))
+	switch {
+	case os.IsNotExist(err):
+		log.Printf("Memory backend: starting afresh in %s", s.dir)
+	case err != nil:
+		return err
+	default:
+		var saved snapshot
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return fmt.Errorf("memory: reading %s: %v", snapshotFile, err)
+		}
+		memorySession.users = append(make([]model.User, 0, len(saved.Users)), saved.Users...)
+		memorySession.lists = make([]*model.List, 0, len(saved.Lists))
+		for i := range saved.Lists {
+			memorySession.lists = append(memorySession.lists, &saved.Lists[i])
+		}
+		memorySession.guests = append(make([]model.Guest, 0, len(saved.Guests)), saved.Guests...)
+		memorySession.items = append(make([]model.Item, 0, len(saved.Items)), saved.Items...)
+		s.seq = saved.Seq
+	}
+
+	data, err = ioutil.ReadFile(filepath.Join(s.dir, logFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	replayed := 0
+	for line := 1; len(data) > 0; line++ {
+		end := bytes.IndexByte(data, '\n')
+		e, err := decodeEntry(data, end)
+		if err != nil {
+			if end == -1 || end == len(data)-1 {
+				// Compaction discards the torn entry
+				log.Printf("Memory backend: ignoring the last change, which was not fully written: %v", err)
+				break
+			}
+			return fmt.Errorf("memory: %s line %d: %v", logFile, line, err)
+		}
+		data = data[end+1:]
+		if e.Seq <= s.seq {
+			continue
+		}
+		if e.Seq != s.seq+1 {
+			return fmt.Errorf("memory: %s line %d: expected change %d, found %d", logFile, line, s.seq+1, e.Seq)
+		}
+		for _, c := range e.Changes {
+			if err := memorySession.apply(c); err != nil {
+				return fmt.Errorf("memory: %s line %d: %v", logFile, line, err)
+			}
+		}
+		s.seq = e.Seq
+		replayed++
+	}
+	if replayed > 0 {
+		log.Printf("Memory backend: replayed %d changes from %s", replayed, s.dir)
+	}
+	return nil
+}
+
+// decodeEntry decodes the line of data ending at end, which is -1 if the
+// line has no end
+func decodeEntry(data []byte, end int) (entry, error) {
+	var e entry
+	if end == -1 {
+		return e, errors.New("line not terminated")
+	}
+	line := data[:end]
+	space := bytes.IndexByte(line, ' ')
+	if space == -1 {
+		return e, errors.New("checksum missing")
+	}
+	checksum, err := strconv.ParseUint(string(line[:space]), 16, 32)
+	if err != nil {
+		return e, fmt.Errorf("invalid checksum: %v", err)
+	}
+	if uint32(checksum) != crc32.ChecksumIEEE(line[space+1:]) {
+		return e, errors.New("checksum mismatch")
+	}
+	if err := json.Unmarshal(line[space+1:], &e); err != nil {
+		return e, err
+	}
+	return e, nil
+}
+
+// append writes changes to the log as an entry, and syncs it
+func (s *store) append(changes []change) error {
+	if s.log == nil {
+		return errClosed
+	}
+	if s.err != nil {
+		return s.err
+	}
+	encoded, err := json.Marshal(entry{Seq: s.seq + 1, Changes: changes})
+	if err != nil {
+		return err
+	}
+	line := fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(encoded), encoded)
+	_, err = s.log.WriteAt([]byte(line), s.size)
+	if err == nil {
+		err = s.log.Sync()
+	}
+	if err != nil {
+		// Leave no partial entry behind for the next ones to follow
+		if truncateErr := s.log.Truncate(s.size); truncateErr != nil {
+			s.err = fmt.Errorf("memory: log unusable after a failed write: %v", err)
+		}
+		return fmt.Errorf("memory: writing log: %v", err)
+	}
+	s.size += int64(len(line))
+	s.seq++
+	s.entries++
+	return nil
+}
+
+// due reports whether the log has grown enough to be compacted
+func (s *store) due() bool {
+	return s.entries >= s.compactAfter
+}
+
+// compact writes the data set to a new snapshot, then empties the log.
+// It must be called with the mutex held.
+func (memorySession *Session) compact() error {
+	s := memorySession.store
+	saved := snapshot{
+		Seq:    s.seq,
+		Users:  memorySession.users,
+		Lists:  make([]model.List, 0, len(memorySession.lists)),
+		Guests: memorySession.guests,
+		Items:  memorySession.items,
+	}
+	for _, l := range memorySession.lists {
+		saved.Lists = append(saved.Lists, *l)
+	}
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+
+	// The new snapshot replaces the old one only once fully written
+	path := filepath.Join(s.dir, snapshotFile)
+	file, err := ioutil.TempFile(s.dir, snapshotFile+".*")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	if err := syncDir(s.dir); err != nil {
+		return err
+	}
+
+	if err := s.log.Truncate(0); err != nil {
+		return err
+	}
+	if err := s.log.Sync(); err != nil {
+		return err
+	}
+	s.size = 0
+	s.entries = 0
+	s.err = nil
+	return nil
+}
@@ -0,0 +1,112 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+
+	// Registers the "sqlite" driver
+	_ "modernc.org/sqlite"
+)
+
+// schema creates the tables, unless they exist. Unlike in DynamoDB,
+// guests and items belong to their list by a foreign key, so deleting a
+// list deletes them too, and a guest must be an existing user.
+const schema = `
+CREATE TABLE IF NOT EXISTS users (
+	id    TEXT PRIMARY KEY,
+	email TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS users_email ON users (email);
+
+CREATE TABLE IF NOT EXISTS lists (
+	id             TEXT PRIMARY KEY,
+	user_id        TEXT NOT NULL REFERENCES users (id),
+	title          TEXT NOT NULL,
+	under_deletion INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS lists_user_id ON lists (user_id);
+
+CREATE TABLE IF NOT EXISTS guests (
+	list_id TEXT NOT NULL REFERENCES lists (id) ON DELETE CASCADE,
+	user_id TEXT NOT NULL REFERENCES users (id),
+	PRIMARY KEY (list_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS guests_user_id ON guests (user_id, list_id);
+
+CREATE TABLE IF NOT EXISTS items (
+	list_id     TEXT NOT NULL REFERENCES lists (id) ON DELETE CASCADE,
+	datetime    TEXT NOT NULL,
+	description TEXT NOT NULL,
+	done        INTEGER NOT NULL DEFAULT 0,
+	item_order  REAL NOT NULL,
+	version     INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (list_id, datetime)
+);
+CREATE INDEX IF NOT EXISTS items_order ON items (list_id, item_order, datetime);
+`
+
+// Open returns a Session whose data is kept in the SQLite database file at
+// path, which is created if need be, along with the tables. An empty path
+// keeps the data in memory until Close. A new database starts with
+// model.InitialUsers.
+//
+// The session uses a single connection, which serialises its calls: that
+// is as many writers as SQLite allows anyway, and it lets an in-memory
+// database outlive its first call.
+func Open(path string) (*Session, error) {
+	if path == "" {
+		path = ":memory:"
+	}
+	if strings.ContainsRune(path, '?') {
+		return nil, fmt.Errorf("sqlite: %q: database paths may not contain '?'", path)
+	}
+	db, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxLifetime(0)
+
+	sqlSession := &Session{db: db}
+	if err := sqlSession.prepare(context.Background(), path); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlite: opening %s: %v", path, err)
+	}
+	return sqlSession, nil
+}
+
+// prepare creates the tables and the initial users
+func (sqlSession *Session) prepare(ctx context.Context, path string) error {
+	if _, err := sqlSession.db.ExecContext(ctx, schema); err != nil {
+		return err
+	}
+	return sqlSession.transaction(ctx, func(tx *sql.Tx) error {
+		var count int
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		log.Printf("SQLite backend: starting afresh in %s", path)
+		for _, u := range model.InitialUsers() {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO users (id, email) VALUES (?, ?)", u.ID, u.Email); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close closes the database. Every change is committed by the call that
+// makes it, so not calling Close loses nothing, unless the database is
+// in memory.
+func (sqlSession *Session) Close() error {
+	return sqlSession.db.Close()
+}
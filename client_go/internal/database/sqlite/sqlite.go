@@ -0,0 +1,798 @@
+// Package sqlite implements model.Interface on database/sql, with an
+// embedded SQLite database holding one table per entity.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+	"github.com/google/uuid"
+)
+
+// Session is a type
+type Session struct {
+	db              *sql.DB
+	mutex           sync.RWMutex
+	slowdownSeconds int
+}
+
+// slowdown delays method by the seconds set with Slowdown, or until ctx
+// is done, and returns ctx's error
+func slowdown(ctx context.Context, sqlSession *Session, method string, description string) error {
+	sqlSession.mutex.RLock()
+	seconds := sqlSession.slowdownSeconds
+	sqlSession.mutex.RUnlock()
+	if seconds > 0 {
+		log.Printf("%s (%s) Sleeping for %d seconds", method, description, seconds)
+		select {
+		case <-time.After(time.Duration(seconds) * time.Second):
+		case <-ctx.Done():
+		}
+	}
+	return ctx.Err()
+}
+
+// transaction runs fn in a transaction, which is committed unless fn
+// fails
+func (sqlSession *Session) transaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := sqlSession.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// exists reports whether query, given args, returns a row
+func exists(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (bool, error) {
+	var found int
+	err := tx.QueryRowContext(ctx, "SELECT EXISTS ("+query+")", args...).Scan(&found)
+	return found == 1, err
+}
+
+// checkWritableList reports whether guests and items may be added to the
+// list
+func checkWritableList(ctx context.Context, tx *sql.Tx, listID string) error {
+	var underDeletion bool
+	err := tx.QueryRowContext(ctx, "SELECT under_deletion FROM lists WHERE id = ?", listID).Scan(&underDeletion)
+	if err == sql.ErrNoRows {
+		return &model.NotFoundError{
+			Entity: model.EntityList,
+			Key:    listID,
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if underDeletion {
+		return &model.UnderDeletionError{
+			ListID: listID,
+		}
+	}
+	return nil
+}
+
+// pageLimit checks max and returns the LIMIT that also tells whether
+// another page follows
+func pageLimit(cursor model.Cursor, max int64) (string, int64, error) {
+	if max < 1 {
+		return "", 0, &model.ValidationError{
+			Field:  "max",
+			Reason: fmt.Sprintf("%d is not a positive page size", max),
+		}
+	}
+	keys, err := cursor.Keys(1)
+	if err != nil {
+		return "", 0, err
+	}
+	after := ""
+	if keys != nil {
+		after = keys[0]
+	}
+	return after, max + 1, nil
+}
+
+// nextCursor returns the Cursor following a page of count entries, read
+// with the LIMIT given by pageLimit, whose last key is lastKey
+func nextCursor(count int, max int64, lastKey func() string) model.Cursor {
+	if int64(count) <= max {
+		return ""
+	}
+	return model.NewCursor(lastKey())
+}
+
+// ListUsers is a method
+func (sqlSession *Session) ListUsers(ctx context.Context, cursor model.Cursor, max int64) ([]model.User, model.Cursor, error) {
+	if err := slowdown(ctx, sqlSession, "ListUsers", "entry"); err != nil {
+		return nil, "", err
+	}
+	after, limit, err := pageLimit(cursor, max)
+	if err != nil {
+		return nil, "", err
+	}
+	users, err := sqlSession.queryUsers(ctx, "SELECT id, email FROM users WHERE id > ? ORDER BY id LIMIT ?", after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	next := nextCursor(len(users), max, func() string {
+		return users[max-1].ID
+	})
+	if next != "" {
+		users = users[:max]
+	}
+	return users, next, nil
+}
+
+// queryUsers returns the users selected by query
+func (sqlSession *Session) queryUsers(ctx context.Context, query string, args ...interface{}) ([]model.User, error) {
+	rows, err := sqlSession.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := make([]model.User, 0)
+	for rows.Next() {
+		var u model.User
+		if err := rows.Scan(&u.ID, &u.Email); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// Slowdown is a method
+func (sqlSession *Session) Slowdown(seconds int) {
+	sqlSession.mutex.Lock()
+	defer sqlSession.mutex.Unlock()
+	sqlSession.slowdownSeconds = seconds
+}
+
+// GetUsersByIDs is a method
+func (sqlSession *Session) GetUsersByIDs(ctx context.Context, ids []string) ([]model.User, error) {
+	if err := slowdown(ctx, sqlSession, "GetUsersByIDs", "entry"); err != nil {
+		return []model.User{}, err
+	}
+	if len(ids) == 0 {
+		return []model.User{}, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	placeholders := strings.Repeat(", ?", len(ids))[2:]
+	return sqlSession.queryUsers(ctx, "SELECT id, email FROM users WHERE id IN ("+placeholders+") ORDER BY id", args...)
+}
+
+// GetUserByEmail is a method
+func (sqlSession *Session) GetUserByEmail(ctx context.Context, email string) (model.User, error) {
+	if err := slowdown(ctx, sqlSession, "GetUserByEmail", "entry"); err != nil {
+		return model.User{}, err
+	}
+	users, err := sqlSession.queryUsers(ctx, "SELECT id, email FROM users WHERE email = ? ORDER BY rowid LIMIT 1", email)
+	if err != nil {
+		return model.User{}, err
+	}
+	if len(users) == 0 {
+		return model.User{}, &model.NotFoundError{
+			Entity: model.EntityUser,
+			Key:    email,
+		}
+	}
+	return users[0], nil
+}
+
+// CreateUser is a method
+//
+// The email column has no UNIQUE constraint, so that the table accepts
+// the same users as the DynamoDB backend.
+func (sqlSession *Session) CreateUser(ctx context.Context, email string) (string, error) {
+	if err := slowdown(ctx, sqlSession, "CreateUser", "entry"); err != nil {
+		return "", err
+	}
+	uuidString := uuid.New().String()
+	err := sqlSession.transaction(ctx, func(tx *sql.Tx) error {
+		found, err := exists(ctx, tx, "SELECT 1 FROM users WHERE id = ?", uuidString)
+		if err != nil {
+			return err
+		}
+		if found {
+			return &model.AlreadyExistsError{
+				Entity: model.EntityUser,
+				Key:    uuidString,
+			}
+		}
+		_, err = tx.ExecContext(ctx, "INSERT INTO users (id, email) VALUES (?, ?)", uuidString, email)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return uuidString, nil
+}
+
+// aggregateListsQuery selects the lists a user owns, then those the user
+// is a guest of, each keyed so that they sort in that order
+const aggregateListsQuery = `
+SELECT sort_key, l.id, l.title, l.user_id, l.under_deletion, l.as_guest,
+	(SELECT COUNT(*) FROM guests WHERE guests.list_id = l.id),
+	(SELECT COUNT(*) FROM items WHERE items.list_id = l.id)
+FROM (
+	SELECT '0' || id AS sort_key, id, title, user_id, under_deletion, 0 AS as_guest
+	FROM lists WHERE user_id = ?1
+	UNION ALL
+	SELECT '1' || lists.id, lists.id, lists.title, lists.user_id, lists.under_deletion, 1
+	FROM guests JOIN lists ON lists.id = guests.list_id WHERE guests.user_id = ?1
+) AS l
+WHERE sort_key > ?2 ORDER BY sort_key LIMIT ?3`
+
+// GetAggregateListsByUserID is a method
+func (sqlSession *Session) GetAggregateListsByUserID(ctx context.Context, userID string) ([]model.AggregateList, error) {
+	if err := slowdown(ctx, sqlSession, "GetAggregateListsByUserID", "entry"); err != nil {
+		return []model.AggregateList{}, err
+	}
+	alists, _, err := sqlSession.aggregateLists(ctx, userID, "", -1)
+	return alists, err
+}
+
+// GetAggregateListsByUserIDPage is a method
+func (sqlSession *Session) GetAggregateListsByUserIDPage(ctx context.Context, userID string, cursor model.Cursor, max int64) ([]model.AggregateList, model.Cursor, error) {
+	if err := slowdown(ctx, sqlSession, "GetAggregateListsByUserIDPage", "entry"); err != nil {
+		return nil, "", err
+	}
+	after, limit, err := pageLimit(cursor, max)
+	if err != nil {
+		return nil, "", err
+	}
+	alists, keys, err := sqlSession.aggregateLists(ctx, userID, after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	next := nextCursor(len(alists), max, func() string {
+		return keys[max-1]
+	})
+	if next != "" {
+		alists = alists[:max]
+	}
+	return alists, next, nil
+}
+
+// aggregateLists returns up to limit lists, or all of them if limit is
+// negative, whose keys follow after, together with those keys
+func (sqlSession *Session) aggregateLists(ctx context.Context, userID string, after string, limit int64) ([]model.AggregateList, []string, error) {
+	rows, err := sqlSession.db.QueryContext(ctx, aggregateListsQuery, userID, after, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	alists := make([]model.AggregateList, 0, 1)
+	keys := make([]string, 0, 1)
+	for rows.Next() {
+		var key string
+		var alist model.AggregateList
+		if err := rows.Scan(&key, &alist.ID, &alist.Title, &alist.UserID, &alist.UnderDeletion, &alist.AsGuest,
+			&alist.GuestCount, &alist.ItemCount); err != nil {
+			return nil, nil, err
+		}
+		alists = append(alists, alist)
+		keys = append(keys, key)
+	}
+	return alists, keys, rows.Err()
+}
+
+// GetListsByUserID is a method
+func (sqlSession *Session) GetListsByUserID(ctx context.Context, userID string) ([]model.List, error) {
+	if err := slowdown(ctx, sqlSession, "GetListsByUserID", "entry"); err != nil {
+		return []model.List{}, err
+	}
+	rows, err := sqlSession.db.QueryContext(ctx, "SELECT id, title, user_id, under_deletion FROM lists WHERE user_id = ? ORDER BY id", userID)
+	if err != nil {
+		return []model.List{}, err
+	}
+	defer rows.Close()
+	lists := make([]model.List, 0)
+	for rows.Next() {
+		var l model.List
+		if err := rows.Scan(&l.ID, &l.Title, &l.UserID, &l.UnderDeletion); err != nil {
+			return []model.List{}, err
+		}
+		lists = append(lists, l)
+	}
+	return lists, rows.Err()
+}
+
+// CreateList is a method
+func (sqlSession *Session) CreateList(ctx context.Context, userID string, title string) (string, error) {
+	if err := slowdown(ctx, sqlSession, "CreateList", "entry"); err != nil {
+		return "", err
+	}
+	uuidString := uuid.New().String()
+	err := sqlSession.transaction(ctx, func(tx *sql.Tx) error {
+		found, err := exists(ctx, tx, "SELECT 1 FROM users WHERE id = ?", userID)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return &model.NotFoundError{
+				Entity: model.EntityUser,
+				Key:    userID,
+			}
+		}
+		found, err = exists(ctx, tx, "SELECT 1 FROM lists WHERE id = ?", uuidString)
+		if err != nil {
+			return err
+		}
+		if found {
+			return &model.AlreadyExistsError{
+				Entity: model.EntityList,
+				Key:    uuidString,
+			}
+		}
+		_, err = tx.ExecContext(ctx, "INSERT INTO lists (id, user_id, title) VALUES (?, ?, ?)", uuidString, userID, title)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return uuidString, nil
+}
+
+// DeleteList is a method
+//
+// The list is first flagged as under deletion, as in the other backends,
+// so that the flag can be observed when combined with Slowdown. Deleting
+// it then deletes its guests and items in the same transaction, by
+// cascade, so unlike in DynamoDB no list is ever left half deleted.
+func (sqlSession *Session) DeleteList(ctx context.Context, listID string, userID string) error {
+	const method = "DeleteList"
+	if err := slowdown(ctx, sqlSession, method, "entry"); err != nil {
+		return err
+	}
+
+	//
+	// First mark the list as being deleted
+	//
+	if err := slowdown(ctx, sqlSession, method, "before setting under_deletion"); err != nil {
+		return err
+	}
+	err := sqlSession.transaction(ctx, func(tx *sql.Tx) error {
+		var ownerID string
+		err := tx.QueryRowContext(ctx, "SELECT user_id FROM lists WHERE id = ?", listID).Scan(&ownerID)
+		if err == sql.ErrNoRows {
+			return &model.NotFoundError{
+				Entity: model.EntityList,
+				Key:    listID,
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if ownerID != userID {
+			return &model.ForbiddenError{
+				UserID: userID,
+				ListID: listID,
+				Action: "delete",
+			}
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE lists SET under_deletion = 1 WHERE id = ?", listID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	//
+	// Then delete the list, and with it its guests and items
+	//
+	if err := slowdown(ctx, sqlSession, method, "before deleting list"); err != nil {
+		return err
+	}
+	result, err := sqlSession.db.ExecContext(ctx, "DELETE FROM lists WHERE id = ? AND user_id = ? AND under_deletion = 1", listID, userID)
+	if err != nil {
+		return err
+	}
+	if deleted, err := result.RowsAffected(); err != nil || deleted == 1 {
+		return err
+	}
+	// A concurrent call has completed the deletion
+	return &model.NotFoundError{
+		Entity: model.EntityList,
+		Key:    listID,
+	}
+}
+
+// GetListByListID is a method
+func (sqlSession *Session) GetListByListID(ctx context.Context, listID string) (model.List, error) {
+	if err := slowdown(ctx, sqlSession, "GetListByListID", "entry"); err != nil {
+		return model.List{}, err
+	}
+	var l model.List
+	err := sqlSession.db.QueryRowContext(ctx, "SELECT id, title, user_id, under_deletion FROM lists WHERE id = ?", listID).
+		Scan(&l.ID, &l.Title, &l.UserID, &l.UnderDeletion)
+	if err == sql.ErrNoRows {
+		return model.List{}, &model.NotFoundError{
+			Entity: model.EntityList,
+			Key:    listID,
+		}
+	}
+	if err != nil {
+		return model.List{}, err
+	}
+	return l, nil
+}
+
+// GetAggregateGuestsByListID is a method
+func (sqlSession *Session) GetAggregateGuestsByListID(ctx context.Context, listID string) ([]model.AggregateGuest, error) {
+	if err := slowdown(ctx, sqlSession, "GetAggregateGuestsByListID", "entry"); err != nil {
+		return []model.AggregateGuest{}, err
+	}
+	return sqlSession.aggregateGuests(ctx, listID, "", -1)
+}
+
+// GetAggregateGuestsByListIDPage is a method
+func (sqlSession *Session) GetAggregateGuestsByListIDPage(ctx context.Context, listID string, cursor model.Cursor, max int64) ([]model.AggregateGuest, model.Cursor, error) {
+	if err := slowdown(ctx, sqlSession, "GetAggregateGuestsByListIDPage", "entry"); err != nil {
+		return nil, "", err
+	}
+	after, limit, err := pageLimit(cursor, max)
+	if err != nil {
+		return nil, "", err
+	}
+	aggregateGuests, err := sqlSession.aggregateGuests(ctx, listID, after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	next := nextCursor(len(aggregateGuests), max, func() string {
+		return aggregateGuests[max-1].UserID
+	})
+	if next != "" {
+		aggregateGuests = aggregateGuests[:max]
+	}
+	return aggregateGuests, next, nil
+}
+
+// aggregateGuests returns up to limit guests, or all of them if limit is
+// negative, whose user IDs follow after
+func (sqlSession *Session) aggregateGuests(ctx context.Context, listID string, after string, limit int64) ([]model.AggregateGuest, error) {
+	rows, err := sqlSession.db.QueryContext(ctx, `
+		SELECT guests.list_id, guests.user_id, users.email
+		FROM guests JOIN users ON users.id = guests.user_id
+		WHERE guests.list_id = ? AND guests.user_id > ?
+		ORDER BY guests.user_id LIMIT ?`, listID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	aggregateGuests := make([]model.AggregateGuest, 0)
+	for rows.Next() {
+		var g model.AggregateGuest
+		if err := rows.Scan(&g.ListID, &g.UserID, &g.Email); err != nil {
+			return nil, err
+		}
+		aggregateGuests = append(aggregateGuests, g)
+	}
+	return aggregateGuests, rows.Err()
+}
+
+// GetGuestsByListID is a method
+func (sqlSession *Session) GetGuestsByListID(ctx context.Context, listID string) ([]model.Guest, error) {
+	if err := slowdown(ctx, sqlSession, "GetGuestsByListID", "entry"); err != nil {
+		return []model.Guest{}, err
+	}
+	return sqlSession.queryGuests(ctx, "SELECT list_id, user_id FROM guests WHERE list_id = ? ORDER BY user_id", listID)
+}
+
+// GetGuestsByUserID is a method
+func (sqlSession *Session) GetGuestsByUserID(ctx context.Context, userID string) ([]model.Guest, error) {
+	if err := slowdown(ctx, sqlSession, "GetGuestsByUserID", "entry"); err != nil {
+		return []model.Guest{}, err
+	}
+	return sqlSession.queryGuests(ctx, "SELECT list_id, user_id FROM guests WHERE user_id = ? ORDER BY list_id", userID)
+}
+
+// queryGuests returns the guests selected by query
+func (sqlSession *Session) queryGuests(ctx context.Context, query string, args ...interface{}) ([]model.Guest, error) {
+	rows, err := sqlSession.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return []model.Guest{}, err
+	}
+	defer rows.Close()
+	guests := make([]model.Guest, 0)
+	for rows.Next() {
+		var g model.Guest
+		if err := rows.Scan(&g.ListID, &g.UserID); err != nil {
+			return []model.Guest{}, err
+		}
+		guests = append(guests, g)
+	}
+	return guests, rows.Err()
+}
+
+// CreateGuest is a method
+func (sqlSession *Session) CreateGuest(ctx context.Context, listID string, userID string) error {
+	if err := slowdown(ctx, sqlSession, "CreateGuest", "entry"); err != nil {
+		return err
+	}
+	return sqlSession.transaction(ctx, func(tx *sql.Tx) error {
+		if err := checkWritableList(ctx, tx, listID); err != nil {
+			return err
+		}
+		found, err := exists(ctx, tx, "SELECT 1 FROM users WHERE id = ?", userID)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return &model.NotFoundError{
+				Entity: model.EntityUser,
+				Key:    userID,
+			}
+		}
+		found, err = exists(ctx, tx, "SELECT 1 FROM guests WHERE list_id = ? AND user_id = ?", listID, userID)
+		if err != nil {
+			return err
+		}
+		if found {
+			return &model.AlreadyExistsError{
+				Entity: model.EntityGuest,
+				ListID: listID,
+				Key:    userID,
+			}
+		}
+		_, err = tx.ExecContext(ctx, "INSERT INTO guests (list_id, user_id) VALUES (?, ?)", listID, userID)
+		return err
+	})
+}
+
+// DeleteGuest is a method
+func (sqlSession *Session) DeleteGuest(ctx context.Context, listID string, userID string) error {
+	if err := slowdown(ctx, sqlSession, "DeleteGuest", "entry"); err != nil {
+		return err
+	}
+	result, err := sqlSession.db.ExecContext(ctx, "DELETE FROM guests WHERE list_id = ? AND user_id = ?", listID, userID)
+	if err != nil {
+		return err
+	}
+	if deleted, err := result.RowsAffected(); err != nil || deleted == 1 {
+		return err
+	}
+	return &model.NotFoundError{
+		Entity: model.EntityGuest,
+		ListID: listID,
+		Key:    userID,
+	}
+}
+
+// IsPresentGuest is a method
+func (sqlSession *Session) IsPresentGuest(ctx context.Context, listID string, userID string) (bool, error) {
+	if err := slowdown(ctx, sqlSession, "IsPresentGuest", "entry"); err != nil {
+		return false, err
+	}
+	var found bool
+	err := sqlSession.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM guests WHERE list_id = ? AND user_id = ?)", listID, userID).
+		Scan(&found)
+	return found, err
+}
+
+// GetItemsByListID is a method
+func (sqlSession *Session) GetItemsByListID(ctx context.Context, listID string) ([]model.Item, error) {
+	if err := slowdown(ctx, sqlSession, "GetItemsByListID", "entry"); err != nil {
+		return []model.Item{}, err
+	}
+	// The order of model.SortItems
+	return queryItems(ctx, sqlSession.db, itemColumns+"WHERE list_id = ? ORDER BY item_order, datetime", listID)
+}
+
+// GetItemsByListIDPage is a method
+func (sqlSession *Session) GetItemsByListIDPage(ctx context.Context, listID string, cursor model.Cursor, max int64) ([]model.Item, model.Cursor, error) {
+	if err := slowdown(ctx, sqlSession, "GetItemsByListIDPage", "entry"); err != nil {
+		return nil, "", err
+	}
+	_, limit, err := pageLimit("", max)
+	if err != nil {
+		return nil, "", err
+	}
+	last, err := model.ItemCursorKey(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	query := itemColumns + "WHERE list_id = ?1 ORDER BY item_order, datetime LIMIT ?2"
+	args := []interface{}{listID, limit}
+	if last != nil {
+		query = itemColumns + "WHERE list_id = ?1 AND (item_order > ?3 OR (item_order = ?3 AND datetime > ?4)) ORDER BY item_order, datetime LIMIT ?2"
+		args = append(args, last.Order, last.Datetime)
+	}
+	items, err := queryItems(ctx, sqlSession.db, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(items)) <= max {
+		return items, "", nil
+	}
+	return items[:max], model.ItemCursor(items[max-1]), nil
+}
+
+// GetItem is a method
+func (sqlSession *Session) GetItem(ctx context.Context, listID string, datetime string) (model.Item, error) {
+	if err := slowdown(ctx, sqlSession, "GetItem", "entry"); err != nil {
+		return model.Item{}, err
+	}
+	items, err := queryItems(ctx, sqlSession.db, itemColumns+"WHERE list_id = ? AND datetime = ?", listID, datetime)
+	if err != nil {
+		return model.Item{}, err
+	}
+	if len(items) == 0 {
+		return model.Item{}, &model.NotFoundError{
+			Entity: model.EntityItem,
+			ListID: listID,
+			Key:    datetime,
+		}
+	}
+	return items[0], nil
+}
+
+// itemColumns starts the queries read by queryItems
+const itemColumns = "SELECT list_id, datetime, description, done, item_order, version FROM items "
+
+// queryer is what queryItems needs of sql.DB and sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// queryItems returns the items selected by query, which starts with
+// itemColumns
+func queryItems(ctx context.Context, db queryer, query string, args ...interface{}) ([]model.Item, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return []model.Item{}, err
+	}
+	defer rows.Close()
+	items := make([]model.Item, 0)
+	for rows.Next() {
+		var item model.Item
+		if err := rows.Scan(&item.ListID, &item.Datetime, &item.Description, &item.Done, &item.Order, &item.Version); err != nil {
+			return []model.Item{}, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// CreateItem is a method
+func (sqlSession *Session) CreateItem(ctx context.Context, listID string, description string) (string, error) {
+	if err := slowdown(ctx, sqlSession, "CreateItem", "entry"); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	datetime := now.Format("2006-01-02T15:04:05.999999")
+
+	err := sqlSession.transaction(ctx, func(tx *sql.Tx) error {
+		if err := checkWritableList(ctx, tx, listID); err != nil {
+			return err
+		}
+		found, err := exists(ctx, tx, "SELECT 1 FROM items WHERE list_id = ? AND datetime = ?", listID, datetime)
+		if err != nil {
+			return err
+		}
+		if found {
+			return &model.AlreadyExistsError{
+				Entity: model.EntityItem,
+				ListID: listID,
+				Key:    datetime,
+			}
+		}
+		_, err = tx.ExecContext(ctx, "INSERT INTO items (list_id, datetime, description, item_order) VALUES (?, ?, ?, ?)",
+			listID, datetime, description, model.NewItemOrder(now))
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return datetime, nil
+}
+
+// DeleteItem is a method
+func (sqlSession *Session) DeleteItem(ctx context.Context, listID string, datetime string) error {
+	if err := slowdown(ctx, sqlSession, "DeleteItem", "entry"); err != nil {
+		return err
+	}
+	result, err := sqlSession.db.ExecContext(ctx, "DELETE FROM items WHERE list_id = ? AND datetime = ?", listID, datetime)
+	if err != nil {
+		return err
+	}
+	if deleted, err := result.RowsAffected(); err != nil || deleted == 1 {
+		return err
+	}
+	return &model.NotFoundError{
+		Entity: model.EntityItem,
+		ListID: listID,
+		Key:    datetime,
+	}
+}
+
+// updateVersioned runs update, which sets an item's columns and bumps its
+// version provided that it is still at the given version, the last of
+// args. Should no item be updated, it tells why.
+func updateVersioned(ctx context.Context, tx *sql.Tx, listID string, datetime string, version int, update string, args ...interface{}) error {
+	result, err := tx.ExecContext(ctx, update, args...)
+	if err != nil {
+		return err
+	}
+	if updated, err := result.RowsAffected(); err != nil || updated == 1 {
+		return err
+	}
+	var current int
+	err = tx.QueryRowContext(ctx, "SELECT version FROM items WHERE list_id = ? AND datetime = ?", listID, datetime).Scan(&current)
+	if err == sql.ErrNoRows {
+		return &model.NotFoundError{
+			Entity: model.EntityItem,
+			ListID: listID,
+			Key:    datetime,
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return &model.VersionConflictError{
+		ListID:   listID,
+		Datetime: datetime,
+		Version:  version,
+		Current:  current,
+	}
+}
+
+// UpdateItem is a method
+func (sqlSession *Session) UpdateItem(ctx context.Context, listID string, datetime string, version int, description *string, done *bool) (int, error) {
+	if err := slowdown(ctx, sqlSession, "UpdateItem", "entry"); err != nil {
+		return 0, err
+	}
+	err := sqlSession.transaction(ctx, func(tx *sql.Tx) error {
+		// A nil argument leaves its column as it is
+		return updateVersioned(ctx, tx, listID, datetime, version, `
+			UPDATE items SET description = COALESCE(?, description), done = COALESCE(?, done), version = version + 1
+			WHERE list_id = ? AND datetime = ? AND version = ?`,
+			description, done, listID, datetime, version)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return version + 1, nil
+}
+
+// MoveItem is a method
+func (sqlSession *Session) MoveItem(ctx context.Context, listID string, datetime string, version int, position int) (int, error) {
+	if err := slowdown(ctx, sqlSession, "MoveItem", "entry"); err != nil {
+		return 0, err
+	}
+	err := sqlSession.transaction(ctx, func(tx *sql.Tx) error {
+		items, err := queryItems(ctx, tx, itemColumns+"WHERE list_id = ? ORDER BY item_order, datetime", listID)
+		if err != nil {
+			return err
+		}
+		moves, err := model.PlanMove(items, listID, datetime, version, position)
+		if err != nil {
+			return err
+		}
+		for _, move := range moves {
+			if err := updateVersioned(ctx, tx, listID, move.Datetime, move.Version, `
+				UPDATE items SET item_order = ?, version = version + 1
+				WHERE list_id = ? AND datetime = ? AND version = ?`,
+				move.Order, listID, move.Datetime, move.Version); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return version + 1, nil
+}
@@ -0,0 +1,102 @@
+package sqlite
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model/modeltest"
+)
+
+// open returns a session on a database file removed at the end of the
+// test
+func open(t *testing.T) (*Session, string) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "todo.db")
+	session, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { session.Close() })
+	return session, path
+}
+
+func TestConformance(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T) model.Interface {
+		session, _ := open(t)
+		return session
+	})
+}
+
+func TestInMemoryConformance(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T) model.Interface {
+		session, err := Open("")
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		t.Cleanup(func() { session.Close() })
+		return session
+	})
+}
+
+func TestResumeDeletion(t *testing.T) {
+	session, _ := open(t)
+	modeltest.RunResumeDeletion(t, session, func(t *testing.T, listID string) {
+		if _, err := session.db.Exec("UPDATE lists SET under_deletion = 1 WHERE id = ?", listID); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestReopen(t *testing.T) {
+	ctx := context.Background()
+	session, path := open(t)
+	users, _, err := session.ListUsers(ctx, "", 10)
+	if err != nil || len(users) != len(model.InitialUsers()) {
+		t.Fatalf("ListUsers = %v (%v), expected the %d initial users", users, err, len(model.InitialUsers()))
+	}
+	listID, err := session.CreateList(ctx, users[0].ID, "Kept")
+	if err != nil {
+		t.Fatalf("CreateList: %v", err)
+	}
+	if _, err := session.CreateItem(ctx, listID, "milk"); err != nil {
+		t.Fatalf("CreateItem: %v", err)
+	}
+	if err := session.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// The initial users are only added to an empty database
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer reopened.Close()
+	again, _, err := reopened.ListUsers(ctx, "", 10)
+	if err != nil || len(again) != len(model.InitialUsers()) {
+		t.Fatalf("ListUsers = %v (%v), expected the %d initial users", again, err, len(model.InitialUsers()))
+	}
+	items, err := reopened.GetItemsByListID(ctx, listID)
+	if err != nil || len(items) != 1 || items[0].Description != "milk" {
+		t.Fatalf("GetItemsByListID = %v (%v), expected the item created before reopening", items, err)
+	}
+}
+
+func TestForeignKeys(t *testing.T) {
+	session, _ := open(t)
+	// Integrity holds even for writes that bypass the Session's checks
+	if _, err := session.db.Exec("INSERT INTO lists (id, user_id, title) VALUES ('orphan', 'nobody', 'Orphan')"); err == nil {
+		t.Fatal("a list was created for a user that does not exist")
+	}
+	if _, err := session.db.Exec("INSERT INTO items (list_id, datetime, description, item_order) VALUES ('nowhere', 'now', 'lost', 0)"); err == nil {
+		t.Fatal("an item was created in a list that does not exist")
+	}
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// Cursor marks where a paginated call stopped. It is opaque to callers:
+// pass the zero Cursor to get the first page, and the Cursor returned
+// with a page to get the next one. A zero Cursor returned with a page
+// means that there are no further pages, although a non-zero one may
+// still lead to an empty page.
+type Cursor string
+
+// NewCursor encodes the keys a backend needs to resume a listing
+func NewCursor(keys ...string) Cursor {
+	if len(keys) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(keys)
+	if err != nil {
+		// A slice of strings always marshals
+		panic(err)
+	}
+	return Cursor(base64.RawURLEncoding.EncodeToString(data))
+}
+
+// Keys decodes the n keys given to NewCursor. The zero Cursor yields nil.
+func (cursor Cursor) Keys(n int) ([]string, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(string(cursor))
+	if err != nil {
+		return nil, &ValidationError{
+			Field:  "cursor",
+			Reason: fmt.Sprintf("%q is not a cursor", cursor),
+		}
+	}
+	var keys []string
+	if err := json.Unmarshal(data, &keys); err != nil || len(keys) != n {
+		return nil, &ValidationError{
+			Field:  "cursor",
+			Reason: fmt.Sprintf("%q is not a cursor for this listing", cursor),
+		}
+	}
+	return keys, nil
+}
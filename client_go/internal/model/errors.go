@@ -0,0 +1,185 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinels matched, via errors.Is, by the error types below, so that
+// callers can check the kind of a failure without caring for details
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrForbidden       = errors.New("forbidden")
+	ErrAlreadyExists   = errors.New("already exists")
+	ErrVersionConflict = errors.New("version conflict")
+	ErrUnderDeletion   = errors.New("under deletion")
+	ErrThrottled       = errors.New("throttled")
+	ErrTransient       = errors.New("transient failure")
+	ErrValidation      = errors.New("validation failed")
+)
+
+// Entities named by NotFoundError and AlreadyExistsError
+const (
+	EntityUser  = "user"
+	EntityList  = "list"
+	EntityGuest = "guest"
+	EntityItem  = "item"
+)
+
+// NotFoundError is returned when a user, list, guest or item does not
+// exist. Key is the user's ID or email, the list's ID, the guest's user
+// ID or the item's datetime; ListID is only set for guests and items.
+type NotFoundError struct {
+	Entity string
+	ListID string
+	Key    string
+}
+
+func (e *NotFoundError) Error() string {
+	if e.ListID != "" {
+		return fmt.Sprintf("%s %s not found in list %s", e.Entity, e.Key, e.ListID)
+	}
+	return fmt.Sprintf("%s %s not found", e.Entity, e.Key)
+}
+
+// Is matches ErrNotFound
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// ForbiddenError is returned when a user attempts an action reserved to
+// the list's owner
+type ForbiddenError struct {
+	UserID string
+	ListID string
+	Action string
+}
+
+func (e *ForbiddenError) Error() string {
+	return fmt.Sprintf("user %s may not %s list %s", e.UserID, e.Action, e.ListID)
+}
+
+// Is matches ErrForbidden
+func (e *ForbiddenError) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+// AlreadyExistsError is returned when creating a list, guest or item
+// whose key is taken. Key and ListID are as in NotFoundError.
+type AlreadyExistsError struct {
+	Entity string
+	ListID string
+	Key    string
+}
+
+func (e *AlreadyExistsError) Error() string {
+	if e.ListID != "" {
+		return fmt.Sprintf("%s %s already exists in list %s", e.Entity, e.Key, e.ListID)
+	}
+	return fmt.Sprintf("%s %s already exists", e.Entity, e.Key)
+}
+
+// Is matches ErrAlreadyExists
+func (e *AlreadyExistsError) Is(target error) bool {
+	return target == ErrAlreadyExists
+}
+
+// VersionConflictError is returned when an item is updated on the basis
+// of a version other than its current one
+type VersionConflictError struct {
+	ListID   string
+	Datetime string
+	Version  int
+	Current  int
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("item %s in list %s is at version %d, not %d", e.Datetime, e.ListID, e.Current, e.Version)
+}
+
+// Is matches ErrVersionConflict
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrVersionConflict
+}
+
+// UnderDeletionError is returned when adding guests or items to a list
+// that is being deleted
+type UnderDeletionError struct {
+	ListID string
+}
+
+func (e *UnderDeletionError) Error() string {
+	return fmt.Sprintf("list %s is under deletion", e.ListID)
+}
+
+// Is matches ErrUnderDeletion
+func (e *UnderDeletionError) Is(target error) bool {
+	return target == ErrUnderDeletion
+}
+
+// ThrottledError is returned when the store rejected Operation for lack
+// of capacity; it may be retried later
+type ThrottledError struct {
+	Operation string
+	Err       error
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%s throttled: %v", e.Operation, e.Err)
+}
+
+// Is matches ErrThrottled
+func (e *ThrottledError) Is(target error) bool {
+	return target == ErrThrottled
+}
+
+// Unwrap returns the store's own error
+func (e *ThrottledError) Unwrap() error {
+	return e.Err
+}
+
+// TransientError is returned when Operation failed for a reason, such as
+// a network or server error, that may not recur if it is retried
+type TransientError struct {
+	Operation string
+	Err       error
+}
+
+func (e *TransientError) Error() string {
+	return fmt.Sprintf("%s failed transiently: %v", e.Operation, e.Err)
+}
+
+// Is matches ErrTransient
+func (e *TransientError) Is(target error) bool {
+	return target == ErrTransient
+}
+
+// Unwrap returns the underlying error
+func (e *TransientError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError is returned when an argument is rejected. Field names
+// the argument, if known; Err is the store's own error, if any.
+type ValidationError struct {
+	Field  string
+	Reason string
+	Err    error
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field != "" {
+		return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+	}
+	return fmt.Sprintf("invalid request: %s", e.Reason)
+}
+
+// Is matches ErrValidation
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// Unwrap returns the store's own error, if any
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
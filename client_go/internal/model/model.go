@@ -1,6 +1,8 @@
 package model
 
-import "fmt"
+import (
+	"context"
+)
 
 // User is a type
 type User struct {
@@ -8,19 +10,44 @@ type User struct {
 	Email string `json:"email"`
 }
 
+// InitialUsers returns, as a new slice each time, the users that the
+// memory backend and new embedded databases start with
+func InitialUsers() []User {
+	return []User{
+		{
+			ID:    "7c2be6b9-746c-44be-bb33-78fb402ce6b8",
+			Email: "gwalker@hotmail.com",
+		},
+		{
+			ID:    "a10f9a38-f6dc-4e8a-ac1c-180486389697",
+			Email: "wdean@gmail.com",
+		},
+		{
+			ID:    "d5fc9ce9-5a5d-4ffc-9cc1-20a5c865bcc7",
+			Email: "millsshawn@henry.com",
+		},
+	}
+}
+
 // List is a type
+//
+// UnderDeletion is set while DeleteList is in progress, or if it was
+// interrupted, in which case calling DeleteList again resumes it. It is
+// always populated by GetListByListID; listings served by an index that
+// does not project it may leave it unset.
 type List struct {
-	ID     string `json:"id"`
-	Title  string `json:"title"`
-	UserID string `json:"user_id"`
+	ID            string `json:"id"`
+	Title         string `json:"title"`
+	UserID        string `json:"user_id"`
+	UnderDeletion bool   `json:"under_deletion,omitempty"`
 }
 
 // AggregateList is a type
 type AggregateList struct {
 	List
-	GuestCount int
-	ItemCount  int
-	AsGuest    bool
+	GuestCount int  `json:"guest_count"`
+	ItemCount  int  `json:"item_count"`
+	AsGuest    bool `json:"as_guest"`
 }
 
 // Guest is a type
@@ -32,74 +59,61 @@ type Guest struct {
 // AggregateGuest is a type
 type AggregateGuest struct {
 	Guest
-	Email string
+	Email string `json:"email"`
 }
 
 // Item is a type
+//
+// Order places the item in the list as its users arranged it; see
+// order.go.
 type Item struct {
-	ListID      string `json:"list_id"`
-	Datetime    string `json:"datetime"`
-	Description string `json:"description"`
-	Done        bool   `json:"done"`
-	Order       int    `json:"order"`
-	Version     int    `json:"version"`
-}
-
-// dbError Enumeration
-const (
-	ErrorNoMatch ErrorCode = iota
-	ErrorInvalidCount
-	ErrorMissingAttribute
-	ErrorMarshallingIssue
-	ErrorUnimplemented
-	ErrorDuplicateID
-)
-
-// ErrorCode is used for the dbError... enumeration
-type ErrorCode int
-
-// CustomError is a custom error
-type CustomError struct {
-	ErrorCode   ErrorCode
-	ErrorDetail string
-}
-
-func (e CustomError) Error() string {
-	description := "Unknown error"
-	switch e.ErrorCode {
-	case ErrorNoMatch:
-		description = "ErrorNoMatch: Item not found using provided lookup criteria"
-	case ErrorInvalidCount:
-		description = "ErrorInvalidCount: Constraint violated"
-	case ErrorMissingAttribute:
-		description = "ErrorMissingAttribute: "
-	case ErrorUnimplemented:
-		description = "ErrorUnimplemented: not implemented yet"
-	case ErrorDuplicateID:
-		description = "ErrorDuplicateID: "
-	}
-	return fmt.Sprintf("%s (%s)", description, e.ErrorDetail)
+	ListID      string  `json:"list_id"`
+	Datetime    string  `json:"datetime"`
+	Description string  `json:"description"`
+	Done        bool    `json:"done"`
+	Order       float64 `json:"order"`
+	Version     int     `json:"version"`
 }
 
 // Interface is what it says on the tin
+//
+// Every method but Slowdown takes a context.Context which bounds the
+// call, including any sleep introduced by Slowdown. Failures are
+// reported using the error types in errors.go.
+//
+// The methods taking a Cursor return up to max entries, along with the
+// Cursor to pass back for the next page.
+//
+// GetItemsByListID returns items in the order set by MoveItem, by Order
+// and then by Datetime, and GetItemsByListIDPage pages through them in the
+// same order. GetItem reads a single item by its key, as it was after
+// the last completed write, which listings need not show yet on DynamoDB.
+// MoveItem puts an item at position (from 0) in that order, provided it
+// is still at the given version, and returns its new version.
 type Interface interface {
 	Slowdown(seconds int)
-	ListUsers(lastUserID string, max int64) ([]User, string, error)
-	GetUsersByIDs(ids []string) ([]User, error)
-	GetUserByEmail(email string) (User, error)
-	GetListByListID(listID string) (List, error)
-	GetAggregateListsByUserID(userID string) ([]AggregateList, error)
-	GetListsByUserID(userID string) ([]List, error)
-	CreateList(userID string, title string) (string, error)
-	DeleteList(listID string, userID string) error
-	GetAggregateGuestsByListID(listID string) ([]AggregateGuest, error)
-	GetGuestsByListID(listID string) ([]Guest, error)
-	GetGuestsByUserID(userID string) ([]Guest, error)
-	CreateGuest(listID string, userID string) error
-	DeleteGuest(listID string, userID string) error
-	IsPresentGuest(listID string, userID string) (bool, error)
-	GetItemsByListID(listID string) ([]Item, error)
-	CreateItem(listID string, description string) error
-	DeleteItem(listID string, datetime string) error
-	UpdateItem(listID string, datetime string, version int, description *string, done *bool) (int, error)
+	ListUsers(ctx context.Context, cursor Cursor, max int64) ([]User, Cursor, error)
+	GetUsersByIDs(ctx context.Context, ids []string) ([]User, error)
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+	CreateUser(ctx context.Context, email string) (string, error)
+	GetListByListID(ctx context.Context, listID string) (List, error)
+	GetAggregateListsByUserID(ctx context.Context, userID string) ([]AggregateList, error)
+	GetAggregateListsByUserIDPage(ctx context.Context, userID string, cursor Cursor, max int64) ([]AggregateList, Cursor, error)
+	GetListsByUserID(ctx context.Context, userID string) ([]List, error)
+	CreateList(ctx context.Context, userID string, title string) (string, error)
+	DeleteList(ctx context.Context, listID string, userID string) error
+	GetAggregateGuestsByListID(ctx context.Context, listID string) ([]AggregateGuest, error)
+	GetAggregateGuestsByListIDPage(ctx context.Context, listID string, cursor Cursor, max int64) ([]AggregateGuest, Cursor, error)
+	GetGuestsByListID(ctx context.Context, listID string) ([]Guest, error)
+	GetGuestsByUserID(ctx context.Context, userID string) ([]Guest, error)
+	CreateGuest(ctx context.Context, listID string, userID string) error
+	DeleteGuest(ctx context.Context, listID string, userID string) error
+	IsPresentGuest(ctx context.Context, listID string, userID string) (bool, error)
+	GetItemsByListID(ctx context.Context, listID string) ([]Item, error)
+	GetItemsByListIDPage(ctx context.Context, listID string, cursor Cursor, max int64) ([]Item, Cursor, error)
+	GetItem(ctx context.Context, listID string, datetime string) (Item, error)
+	CreateItem(ctx context.Context, listID string, description string) (string, error)
+	DeleteItem(ctx context.Context, listID string, datetime string) error
+	UpdateItem(ctx context.Context, listID string, datetime string, version int, description *string, done *bool) (int, error)
+	MoveItem(ctx context.Context, listID string, datetime string, version int, position int) (int, error)
 }
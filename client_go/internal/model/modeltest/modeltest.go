@@ -0,0 +1,842 @@
+// Package modeltest provides a conformance suite that every
+// model.Interface implementation is expected to pass.
+package modeltest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+)
+
+// Factory returns a backend holding at least two users. Lists, guests
+// and items created by the suite are never assumed to be the only ones
+// present, so the same backing store may be shared across calls.
+type Factory func(t *testing.T) model.Interface
+
+const unknownID = "00000000-0000-0000-0000-000000000000"
+
+// Run executes the behavioural contract against the backends produced
+// by newBackend
+func Run(t *testing.T, newBackend Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, backend model.Interface)
+	}{
+		{"ListUsersPagination", testListUsersPagination},
+		{"Pagination", testPagination},
+		{"GetUsersByIDs", testGetUsersByIDs},
+		{"GetUserByEmail", testGetUserByEmail},
+		{"CreateUser", testCreateUser},
+		{"Lists", testLists},
+		{"Guests", testGuests},
+		{"AggregateLists", testAggregateLists},
+		{"Items", testItems},
+		{"UpdateItemVersion", testUpdateItemVersion},
+		{"MoveItem", testMoveItem},
+		{"DeleteList", testDeleteList},
+		{"DeleteLargeList", testDeleteLargeList},
+		{"Cancellation", testCancellation},
+	}
+	for _, tc := range tests {
+		test := tc.test
+		t.Run(tc.name, func(t *testing.T) {
+			test(t, newBackend(t))
+		})
+	}
+}
+
+// assertErrorIs fails the test unless err matches one of the model's
+// sentinel errors
+func assertErrorIs(t *testing.T, err error, target error) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("expected %v, got no error", target)
+	}
+	if !errors.Is(err, target) {
+		t.Fatalf("expected %v, got %T (%v)", target, err, err)
+	}
+}
+
+func allUsers(t *testing.T, backend model.Interface) []model.User {
+	t.Helper()
+	ctx := context.Background()
+	users := make([]model.User, 0)
+	var cursor model.Cursor
+	for {
+		page, next, err := backend.ListUsers(ctx, cursor, 100)
+		if err != nil {
+			t.Fatalf("ListUsers: %v", err)
+		}
+		users = append(users, page...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if len(users) < 2 {
+		t.Fatalf("the backend must hold at least two users, found %d", len(users))
+	}
+	return users
+}
+
+func createList(t *testing.T, backend model.Interface, userID string, title string) string {
+	t.Helper()
+	ctx := context.Background()
+	listID, err := backend.CreateList(ctx, userID, title)
+	if err != nil {
+		t.Fatalf("CreateList: %v", err)
+	}
+	if listID == "" {
+		t.Fatal("CreateList returned an empty list ID")
+	}
+	return listID
+}
+
+func getItems(t *testing.T, backend model.Interface, listID string) []model.Item {
+	t.Helper()
+	ctx := context.Background()
+	items, err := backend.GetItemsByListID(ctx, listID)
+	if err != nil {
+		t.Fatalf("GetItemsByListID: %v", err)
+	}
+	return items
+}
+
+// createItems adds descriptions to the list in order. Datetimes have
+// microsecond resolution so a clash is retried, as the client does.
+func createItems(t *testing.T, backend model.Interface, listID string, descriptions ...string) {
+	t.Helper()
+	ctx := context.Background()
+	for _, description := range descriptions {
+		var err error
+		for attempt := 0; attempt < 5; attempt++ {
+			if _, err = backend.CreateItem(ctx, listID, description); err == nil {
+				break
+			}
+			if !errors.Is(err, model.ErrAlreadyExists) {
+				break
+			}
+		}
+		if err != nil {
+			t.Fatalf("CreateItem(%s): %v", description, err)
+		}
+	}
+}
+
+func testListUsersPagination(t *testing.T, backend model.Interface) {
+	ctx := context.Background()
+	// Created users, whose random IDs sort anywhere, are paged as well
+	for i := 0; i < 3; i++ {
+		if _, err := backend.CreateUser(ctx, fmt.Sprintf("paged-%d-%d@example.invalid", i, time.Now().UnixNano())); err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+	}
+	users := allUsers(t, backend)
+	for max := int64(1); max <= int64(len(users))+1; max++ {
+		seen := make(map[string]bool)
+		var cursor model.Cursor
+		for pages := 0; ; pages++ {
+			if pages > len(users)+1 {
+				t.Fatalf("max=%d: pagination does not terminate", max)
+			}
+			page, next, err := backend.ListUsers(ctx, cursor, max)
+			if err != nil {
+				t.Fatalf("max=%d: ListUsers: %v", max, err)
+			}
+			if int64(len(page)) > max {
+				t.Fatalf("max=%d: page holds %d users", max, len(page))
+			}
+			for _, u := range page {
+				if seen[u.ID] {
+					t.Fatalf("max=%d: user %s returned twice", max, u.ID)
+				}
+				seen[u.ID] = true
+			}
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		if len(seen) != len(users) {
+			t.Fatalf("max=%d: paged through %d users, expected %d", max, len(seen), len(users))
+		}
+	}
+}
+
+// pageThrough calls next with every cursor until the last page and
+// returns the keys of the entries, failing on duplicates or oversized pages
+func pageThrough(t *testing.T, name string, max int64, next func(cursor model.Cursor) ([]string, model.Cursor, error)) []string {
+	t.Helper()
+	keys := make([]string, 0)
+	seen := make(map[string]bool)
+	var cursor model.Cursor
+	for pages := 0; ; pages++ {
+		if pages > 100 {
+			t.Fatalf("%s max=%d: pagination does not terminate", name, max)
+		}
+		page, nextCursor, err := next(cursor)
+		if err != nil {
+			t.Fatalf("%s max=%d: %v", name, max, err)
+		}
+		if int64(len(page)) > max {
+			t.Fatalf("%s max=%d: page holds %d entries", name, max, len(page))
+		}
+		for _, key := range page {
+			if seen[key] {
+				t.Fatalf("%s max=%d: %s returned twice", name, max, key)
+			}
+			seen[key] = true
+			keys = append(keys, key)
+		}
+		if nextCursor == "" {
+			return keys
+		}
+		cursor = nextCursor
+	}
+}
+
+func testPagination(t *testing.T, backend model.Interface) {
+	ctx := context.Background()
+	users := allUsers(t, backend)
+	owner, guest := users[0], users[1]
+	listID := createList(t, backend, owner.ID, "Paged")
+	createItems(t, backend, listID, "One", "Two", "Three", "Four", "Five")
+	for _, u := range users[1:] {
+		if err := backend.CreateGuest(ctx, listID, u.ID); err != nil {
+			t.Fatalf("CreateGuest: %v", err)
+		}
+	}
+	guestListID := createList(t, backend, guest.ID, "Shared")
+	if err := backend.CreateGuest(ctx, guestListID, owner.ID); err != nil {
+		t.Fatalf("CreateGuest: %v", err)
+	}
+
+	items := getItems(t, backend, listID)
+	aggregateGuests, err := backend.GetAggregateGuestsByListID(ctx, listID)
+	if err != nil {
+		t.Fatalf("GetAggregateGuestsByListID: %v", err)
+	}
+	aggregateLists, err := backend.GetAggregateListsByUserID(ctx, owner.ID)
+	if err != nil {
+		t.Fatalf("GetAggregateListsByUserID: %v", err)
+	}
+
+	for max := int64(1); max <= 6; max++ {
+		max := max
+		datetimes := pageThrough(t, "GetItemsByListIDPage", max, func(cursor model.Cursor) ([]string, model.Cursor, error) {
+			page, next, err := backend.GetItemsByListIDPage(ctx, listID, cursor, max)
+			keys := make([]string, len(page))
+			for i, item := range page {
+				keys[i] = item.Datetime
+			}
+			return keys, next, err
+		})
+		if len(datetimes) != len(items) {
+			t.Fatalf("max=%d: paged through %d items, expected %d", max, len(datetimes), len(items))
+		}
+		for i, item := range items {
+			if datetimes[i] != item.Datetime {
+				t.Fatalf("max=%d: items paged out of order: %v", max, datetimes)
+			}
+		}
+
+		userIDs := pageThrough(t, "GetAggregateGuestsByListIDPage", max, func(cursor model.Cursor) ([]string, model.Cursor, error) {
+			page, next, err := backend.GetAggregateGuestsByListIDPage(ctx, listID, cursor, max)
+			keys := make([]string, len(page))
+			for i, g := range page {
+				if g.Email == "" {
+					t.Fatalf("guest %s has no email", g.UserID)
+				}
+				keys[i] = g.UserID
+			}
+			return keys, next, err
+		})
+		if len(userIDs) != len(aggregateGuests) {
+			t.Fatalf("max=%d: paged through %d guests, expected %d", max, len(userIDs), len(aggregateGuests))
+		}
+
+		found := make(map[string]model.AggregateList)
+		pageThrough(t, "GetAggregateListsByUserIDPage", max, func(cursor model.Cursor) ([]string, model.Cursor, error) {
+			page, next, err := backend.GetAggregateListsByUserIDPage(ctx, owner.ID, cursor, max)
+			keys := make([]string, len(page))
+			for i, l := range page {
+				found[l.ID] = l
+				keys[i] = l.ID
+			}
+			return keys, next, err
+		})
+		if len(found) != len(aggregateLists) {
+			t.Fatalf("max=%d: paged through %d lists, expected %d", max, len(found), len(aggregateLists))
+		}
+		if l := found[listID]; l.AsGuest || l.ItemCount != len(items) || l.GuestCount != len(aggregateGuests) {
+			t.Fatalf("max=%d: unexpected owned list %+v", max, l)
+		}
+		if l := found[guestListID]; !l.AsGuest || l.GuestCount != 1 {
+			t.Fatalf("max=%d: unexpected guest list %+v", max, l)
+		}
+	}
+
+	_, _, err = backend.GetItemsByListIDPage(ctx, listID, model.Cursor("not a cursor"), 2)
+	assertErrorIs(t, err, model.ErrValidation)
+
+	// A page of no entries would never get anywhere
+	_, _, err = backend.ListUsers(ctx, "", 0)
+	assertErrorIs(t, err, model.ErrValidation)
+	_, _, err = backend.GetItemsByListIDPage(ctx, listID, "", 0)
+	assertErrorIs(t, err, model.ErrValidation)
+	_, _, err = backend.GetAggregateGuestsByListIDPage(ctx, listID, "", 0)
+	assertErrorIs(t, err, model.ErrValidation)
+	_, _, err = backend.GetAggregateListsByUserIDPage(ctx, owner.ID, "", 0)
+	assertErrorIs(t, err, model.ErrValidation)
+}
+
+func testGetUsersByIDs(t *testing.T, backend model.Interface) {
+	ctx := context.Background()
+	users := allUsers(t, backend)
+	found, err := backend.GetUsersByIDs(ctx, []string{users[0].ID, unknownID, users[1].ID})
+	if err != nil {
+		t.Fatalf("GetUsersByIDs: %v", err)
+	}
+	if len(found) != 2 {
+		t.Fatalf("expected 2 users, got %d (%v)", len(found), found)
+	}
+	for _, u := range found {
+		if u != users[0] && u != users[1] {
+			t.Fatalf("unexpected user %+v", u)
+		}
+	}
+	none, err := backend.GetUsersByIDs(ctx, []string{unknownID})
+	if err != nil {
+		t.Fatalf("GetUsersByIDs (unknown): %v", err)
+	}
+	if len(none) != 0 {
+		t.Fatalf("expected no users, got %v", none)
+	}
+	empty, err := backend.GetUsersByIDs(ctx, []string{})
+	if err != nil {
+		t.Fatalf("GetUsersByIDs (empty): %v", err)
+	}
+	if len(empty) != 0 {
+		t.Fatalf("expected no users, got %v", empty)
+	}
+}
+
+func testGetUserByEmail(t *testing.T, backend model.Interface) {
+	ctx := context.Background()
+	users := allUsers(t, backend)
+	user, err := backend.GetUserByEmail(ctx, users[0].Email)
+	if err != nil {
+		t.Fatalf("GetUserByEmail: %v", err)
+	}
+	if user != users[0] {
+		t.Fatalf("expected %+v, got %+v", users[0], user)
+	}
+	_, err = backend.GetUserByEmail(ctx, "nobody@example.invalid")
+	assertErrorIs(t, err, model.ErrNotFound)
+}
+
+func testCreateUser(t *testing.T, backend model.Interface) {
+	ctx := context.Background()
+	// The backing store may be shared, so the email must be fresh
+	email := fmt.Sprintf("created-%d@example.invalid", time.Now().UnixNano())
+	userID, err := backend.CreateUser(ctx, email)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	user, err := backend.GetUserByEmail(ctx, email)
+	if err != nil {
+		t.Fatalf("GetUserByEmail: %v", err)
+	}
+	if user.ID != userID {
+		t.Fatalf("expected user %s, got %+v", userID, user)
+	}
+	users, err := backend.GetUsersByIDs(ctx, []string{userID})
+	if err != nil {
+		t.Fatalf("GetUsersByIDs: %v", err)
+	}
+	if len(users) != 1 || users[0].Email != email {
+		t.Fatalf("unexpected users %v", users)
+	}
+}
+
+func testLists(t *testing.T, backend model.Interface) {
+	ctx := context.Background()
+	users := allUsers(t, backend)
+	owner := users[0]
+	listID := createList(t, backend, owner.ID, "Groceries")
+
+	list, err := backend.GetListByListID(ctx, listID)
+	if err != nil {
+		t.Fatalf("GetListByListID: %v", err)
+	}
+	expected := model.List{ID: listID, Title: "Groceries", UserID: owner.ID}
+	if list != expected {
+		t.Fatalf("expected %+v, got %+v", expected, list)
+	}
+
+	lists, err := backend.GetListsByUserID(ctx, owner.ID)
+	if err != nil {
+		t.Fatalf("GetListsByUserID: %v", err)
+	}
+	found := false
+	for _, l := range lists {
+		if l.UserID != owner.ID {
+			t.Fatalf("list %+v does not belong to %s", l, owner.ID)
+		}
+		if l == expected {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("list %s missing from %v", listID, lists)
+	}
+
+	_, err = backend.CreateList(ctx, unknownID, "Orphan")
+	assertErrorIs(t, err, model.ErrNotFound)
+
+	_, err = backend.GetListByListID(ctx, unknownID)
+	assertErrorIs(t, err, model.ErrNotFound)
+}
+
+func testGuests(t *testing.T, backend model.Interface) {
+	ctx := context.Background()
+	users := allUsers(t, backend)
+	owner, guest := users[0], users[1]
+	listID := createList(t, backend, owner.ID, "Party")
+
+	isPresent, err := backend.IsPresentGuest(ctx, listID, guest.ID)
+	if err != nil {
+		t.Fatalf("IsPresentGuest: %v", err)
+	}
+	if isPresent {
+		t.Fatal("guest present before being added")
+	}
+
+	if err := backend.CreateGuest(ctx, listID, guest.ID); err != nil {
+		t.Fatalf("CreateGuest: %v", err)
+	}
+	assertErrorIs(t, backend.CreateGuest(ctx, listID, guest.ID), model.ErrAlreadyExists)
+	assertErrorIs(t, backend.CreateGuest(ctx, unknownID, guest.ID), model.ErrNotFound)
+	assertErrorIs(t, backend.CreateGuest(ctx, listID, unknownID), model.ErrNotFound)
+
+	isPresent, err = backend.IsPresentGuest(ctx, listID, guest.ID)
+	if err != nil {
+		t.Fatalf("IsPresentGuest: %v", err)
+	}
+	if !isPresent {
+		t.Fatal("guest missing after being added")
+	}
+
+	expected := model.Guest{ListID: listID, UserID: guest.ID}
+	byList, err := backend.GetGuestsByListID(ctx, listID)
+	if err != nil {
+		t.Fatalf("GetGuestsByListID: %v", err)
+	}
+	if len(byList) != 1 || byList[0] != expected {
+		t.Fatalf("expected [%+v], got %v", expected, byList)
+	}
+
+	byUser, err := backend.GetGuestsByUserID(ctx, guest.ID)
+	if err != nil {
+		t.Fatalf("GetGuestsByUserID: %v", err)
+	}
+	found := false
+	for _, g := range byUser {
+		if g == expected {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("guest %+v missing from %v", expected, byUser)
+	}
+
+	aggregate, err := backend.GetAggregateGuestsByListID(ctx, listID)
+	if err != nil {
+		t.Fatalf("GetAggregateGuestsByListID: %v", err)
+	}
+	if len(aggregate) != 1 || aggregate[0].Guest != expected || aggregate[0].Email != guest.Email {
+		t.Fatalf("unexpected aggregate guests %v", aggregate)
+	}
+
+	if err := backend.DeleteGuest(ctx, listID, guest.ID); err != nil {
+		t.Fatalf("DeleteGuest: %v", err)
+	}
+	assertErrorIs(t, backend.DeleteGuest(ctx, listID, guest.ID), model.ErrNotFound)
+
+	byList, err = backend.GetGuestsByListID(ctx, listID)
+	if err != nil {
+		t.Fatalf("GetGuestsByListID: %v", err)
+	}
+	if len(byList) != 0 {
+		t.Fatalf("expected no guests, got %v", byList)
+	}
+}
+
+func testAggregateLists(t *testing.T, backend model.Interface) {
+	ctx := context.Background()
+	users := allUsers(t, backend)
+	owner, guest := users[0], users[1]
+	listID := createList(t, backend, owner.ID, "Holiday")
+	createItems(t, backend, listID, "Passport", "Tickets")
+	if err := backend.CreateGuest(ctx, listID, guest.ID); err != nil {
+		t.Fatalf("CreateGuest: %v", err)
+	}
+
+	find := func(userID string) model.AggregateList {
+		t.Helper()
+		lists, err := backend.GetAggregateListsByUserID(ctx, userID)
+		if err != nil {
+			t.Fatalf("GetAggregateListsByUserID: %v", err)
+		}
+		for _, l := range lists {
+			if l.ID == listID {
+				return l
+			}
+		}
+		t.Fatalf("list %s missing from %v", listID, lists)
+		return model.AggregateList{}
+	}
+
+	asOwner := find(owner.ID)
+	if asOwner.AsGuest || asOwner.GuestCount != 1 || asOwner.ItemCount != 2 || asOwner.Title != "Holiday" {
+		t.Fatalf("unexpected owner view %+v", asOwner)
+	}
+	asGuest := find(guest.ID)
+	if !asGuest.AsGuest || asGuest.GuestCount != 1 || asGuest.ItemCount != 2 || asGuest.UserID != owner.ID {
+		t.Fatalf("unexpected guest view %+v", asGuest)
+	}
+
+	// Failed writes leave the counts alone
+	assertErrorIs(t, backend.CreateGuest(ctx, listID, guest.ID), model.ErrAlreadyExists)
+	assertErrorIs(t, backend.DeleteItem(ctx, listID, unknownID), model.ErrNotFound)
+	if l := find(owner.ID); l.GuestCount != 1 || l.ItemCount != 2 {
+		t.Fatalf("unexpected counts after failed writes %+v", l)
+	}
+
+	items := getItems(t, backend, listID)
+	if err := backend.DeleteItem(ctx, listID, items[0].Datetime); err != nil {
+		t.Fatalf("DeleteItem: %v", err)
+	}
+	if err := backend.DeleteGuest(ctx, listID, guest.ID); err != nil {
+		t.Fatalf("DeleteGuest: %v", err)
+	}
+	if l := find(owner.ID); l.GuestCount != 0 || l.ItemCount != 1 {
+		t.Fatalf("unexpected counts after deletes %+v", l)
+	}
+}
+
+func testItems(t *testing.T, backend model.Interface) {
+	ctx := context.Background()
+	users := allUsers(t, backend)
+	listID := createList(t, backend, users[0].ID, "Chores")
+	createItems(t, backend, listID, "Wash", "Dry", "Iron")
+
+	items := getItems(t, backend, listID)
+	if len(items) != 3 {
+		t.Fatalf("expected 3 items, got %v", items)
+	}
+	for i, description := range []string{"Wash", "Dry", "Iron"} {
+		item := items[i]
+		if item.ListID != listID || item.Description != description || item.Done || item.Version != 0 {
+			t.Fatalf("unexpected item #%d %+v", i, item)
+		}
+		if i > 0 && items[i-1].Datetime >= item.Datetime {
+			t.Fatalf("items not sorted by datetime: %v", items)
+		}
+	}
+
+	item, err := backend.GetItem(ctx, listID, items[1].Datetime)
+	if err != nil {
+		t.Fatalf("GetItem: %v", err)
+	}
+	if item != items[1] {
+		t.Fatalf("GetItem = %+v, expected %+v", item, items[1])
+	}
+	_, err = backend.GetItem(ctx, unknownID, items[1].Datetime)
+	assertErrorIs(t, err, model.ErrNotFound)
+
+	_, err = backend.CreateItem(ctx, unknownID, "Nowhere")
+	assertErrorIs(t, err, model.ErrNotFound)
+
+	if err := backend.DeleteItem(ctx, listID, items[1].Datetime); err != nil {
+		t.Fatalf("DeleteItem: %v", err)
+	}
+	assertErrorIs(t, backend.DeleteItem(ctx, listID, items[1].Datetime), model.ErrNotFound)
+	_, err = backend.GetItem(ctx, listID, items[1].Datetime)
+	assertErrorIs(t, err, model.ErrNotFound)
+
+	remaining := getItems(t, backend, listID)
+	if len(remaining) != 2 || remaining[0].Description != "Wash" || remaining[1].Description != "Iron" {
+		t.Fatalf("unexpected items after deletion %v", remaining)
+	}
+}
+
+func testUpdateItemVersion(t *testing.T, backend model.Interface) {
+	ctx := context.Background()
+	users := allUsers(t, backend)
+	listID := createList(t, backend, users[0].ID, "Errands")
+	createItems(t, backend, listID, "Post office")
+	item := getItems(t, backend, listID)[0]
+
+	description := "Bank"
+	version, err := backend.UpdateItem(ctx, listID, item.Datetime, item.Version, &description, nil)
+	if err != nil {
+		t.Fatalf("UpdateItem: %v", err)
+	}
+	if version != item.Version+1 {
+		t.Fatalf("expected version %d, got %d", item.Version+1, version)
+	}
+
+	// A stale version must be rejected without applying the change
+	done := true
+	_, err = backend.UpdateItem(ctx, listID, item.Datetime, item.Version, nil, &done)
+	assertErrorIs(t, err, model.ErrVersionConflict)
+	var conflict *model.VersionConflictError
+	if !errors.As(err, &conflict) || conflict.Current != version {
+		t.Fatalf("expected a conflict reporting version %d, got %v", version, err)
+	}
+
+	version, err = backend.UpdateItem(ctx, listID, item.Datetime, version, nil, &done)
+	if err != nil {
+		t.Fatalf("UpdateItem: %v", err)
+	}
+	if version != item.Version+2 {
+		t.Fatalf("expected version %d, got %d", item.Version+2, version)
+	}
+
+	updated := getItems(t, backend, listID)[0]
+	if updated.Description != "Bank" || !updated.Done || updated.Version != version {
+		t.Fatalf("unexpected item after updates %+v", updated)
+	}
+	// GetItem reflects the updates straight away
+	updated, err = backend.GetItem(ctx, listID, item.Datetime)
+	if err != nil || updated.Description != "Bank" || !updated.Done || updated.Version != version {
+		t.Fatalf("GetItem = %+v (%v) after updates", updated, err)
+	}
+
+	_, err = backend.UpdateItem(ctx, listID, "1970-01-01T00:00:00", 0, &description, nil)
+	assertErrorIs(t, err, model.ErrNotFound)
+}
+
+// descriptions returns the descriptions of the list's items in order
+func descriptions(t *testing.T, backend model.Interface, listID string) []string {
+	t.Helper()
+	items := getItems(t, backend, listID)
+	descriptions := make([]string, len(items))
+	for i, item := range items {
+		descriptions[i] = item.Description
+	}
+	return descriptions
+}
+
+func testMoveItem(t *testing.T, backend model.Interface) {
+	ctx := context.Background()
+	users := allUsers(t, backend)
+	listID := createList(t, backend, users[0].ID, "Packing")
+	createItems(t, backend, listID, "A", "B", "C", "D")
+	expected := []string{"A", "B", "C", "D"}
+	if actual := descriptions(t, backend, listID); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected new items last, got %v", actual)
+	}
+
+	// move puts description at position, both in the backend and in
+	// expected
+	move := func(description string, position int) {
+		t.Helper()
+		for _, item := range getItems(t, backend, listID) {
+			if item.Description != description {
+				continue
+			}
+			version, err := backend.MoveItem(ctx, listID, item.Datetime, item.Version, position)
+			if err != nil {
+				t.Fatalf("MoveItem(%s, %d): %v", description, position, err)
+			}
+			if version != item.Version+1 {
+				t.Fatalf("expected version %d, got %d", item.Version+1, version)
+			}
+		}
+		for i, d := range expected {
+			if d == description {
+				expected = append(expected[:i], expected[i+1:]...)
+				break
+			}
+		}
+		if position > len(expected) {
+			position = len(expected)
+		}
+		expected = append(expected[:position], append([]string{description}, expected[position:]...)...)
+		if actual := descriptions(t, backend, listID); !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("after moving %s to %d, expected %v, got %v", description, position, expected, actual)
+		}
+		// Pages follow the same order
+		paged := pageThrough(t, "GetItemsByListIDPage", 3, func(cursor model.Cursor) ([]string, model.Cursor, error) {
+			page, next, err := backend.GetItemsByListIDPage(ctx, listID, cursor, 3)
+			keys := make([]string, len(page))
+			for i, item := range page {
+				keys[i] = item.Description
+			}
+			return keys, next, err
+		})
+		if !reflect.DeepEqual(paged, expected) {
+			t.Fatalf("after moving %s to %d, expected pages of %v, got %v", description, position, expected, paged)
+		}
+	}
+	move("D", 0)
+	move("D", 2)
+	move("A", 10)
+	move("C", 1)
+
+	// Moving items into the same gap again and again eventually leaves no
+	// room between their neighbours, so that the list is renumbered
+	for i := 0; i < 60; i++ {
+		move(expected[len(expected)-1], 1)
+	}
+
+	item := getItems(t, backend, listID)[0]
+	_, err := backend.MoveItem(ctx, listID, item.Datetime, item.Version+1, 3)
+	assertErrorIs(t, err, model.ErrVersionConflict)
+	if actual := descriptions(t, backend, listID); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("a rejected move changed the order to %v", actual)
+	}
+	_, err = backend.MoveItem(ctx, listID, "1970-01-01T00:00:00", 0, 0)
+	assertErrorIs(t, err, model.ErrNotFound)
+}
+
+func testDeleteList(t *testing.T, backend model.Interface) {
+	ctx := context.Background()
+	users := allUsers(t, backend)
+	owner, other := users[0], users[1]
+	listID := createList(t, backend, owner.ID, "Temporary")
+	createItems(t, backend, listID, "One", "Two", "Three")
+	if err := backend.CreateGuest(ctx, listID, other.ID); err != nil {
+		t.Fatalf("CreateGuest: %v", err)
+	}
+
+	assertErrorIs(t, backend.DeleteList(ctx, listID, other.ID), model.ErrForbidden)
+	if _, err := backend.GetListByListID(ctx, listID); err != nil {
+		t.Fatalf("list deleted by a user who does not own it: %v", err)
+	}
+
+	if err := backend.DeleteList(ctx, listID, owner.ID); err != nil {
+		t.Fatalf("DeleteList: %v", err)
+	}
+	_, err := backend.GetListByListID(ctx, listID)
+	assertErrorIs(t, err, model.ErrNotFound)
+	if items := getItems(t, backend, listID); len(items) != 0 {
+		t.Fatalf("items left behind after list deletion: %v", items)
+	}
+	guests, err := backend.GetGuestsByListID(ctx, listID)
+	if err != nil {
+		t.Fatalf("GetGuestsByListID: %v", err)
+	}
+	if len(guests) != 0 {
+		t.Fatalf("guests left behind after list deletion: %v", guests)
+	}
+	byUser, err := backend.GetGuestsByUserID(ctx, other.ID)
+	if err != nil {
+		t.Fatalf("GetGuestsByUserID: %v", err)
+	}
+	for _, g := range byUser {
+		if g.ListID == listID {
+			t.Fatalf("guest left behind after list deletion: %+v", g)
+		}
+	}
+	_, err = backend.CreateItem(ctx, listID, "Too late")
+	assertErrorIs(t, err, model.ErrNotFound)
+	assertErrorIs(t, backend.CreateGuest(ctx, listID, other.ID), model.ErrNotFound)
+	assertErrorIs(t, backend.DeleteList(ctx, listID, owner.ID), model.ErrNotFound)
+}
+
+// testDeleteLargeList exceeds the 25 requests a single BatchWriteItem
+// call accepts
+func testDeleteLargeList(t *testing.T, backend model.Interface) {
+	ctx := context.Background()
+	users := allUsers(t, backend)
+	listID := createList(t, backend, users[0].ID, "Large")
+	descriptions := make([]string, 40)
+	for i := range descriptions {
+		descriptions[i] = fmt.Sprintf("Item #%02d", i)
+	}
+	createItems(t, backend, listID, descriptions...)
+	if items := getItems(t, backend, listID); len(items) != len(descriptions) {
+		t.Fatalf("expected %d items, got %d", len(descriptions), len(items))
+	}
+	if err := backend.DeleteList(ctx, listID, users[0].ID); err != nil {
+		t.Fatalf("DeleteList: %v", err)
+	}
+	if items := getItems(t, backend, listID); len(items) != 0 {
+		t.Fatalf("%d items left behind after list deletion", len(items))
+	}
+}
+
+func testCancellation(t *testing.T, backend model.Interface) {
+	users := allUsers(t, backend)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := backend.GetUserByEmail(ctx, users[0].Email); err == nil {
+		t.Fatal("GetUserByEmail succeeded with a cancelled context")
+	}
+	if _, err := backend.CreateList(ctx, users[0].ID, "Never"); err == nil {
+		t.Fatal("CreateList succeeded with a cancelled context")
+	}
+	lists, err := backend.GetListsByUserID(context.Background(), users[0].ID)
+	if err != nil {
+		t.Fatalf("GetListsByUserID: %v", err)
+	}
+	for _, l := range lists {
+		if l.Title == "Never" {
+			t.Fatalf("list created with a cancelled context: %+v", l)
+		}
+	}
+}
+
+// RunResumeDeletion checks that a DeleteList interrupted after flagging
+// the list blocks new guests and items, and that calling DeleteList
+// again completes it. markUnderDeletion must flag the list the way the
+// backend's DeleteList does before removing any children.
+func RunResumeDeletion(t *testing.T, backend model.Interface, markUnderDeletion func(t *testing.T, listID string)) {
+	ctx := context.Background()
+	users := allUsers(t, backend)
+	owner, guest := users[0], users[1]
+	listID := createList(t, backend, owner.ID, "Interrupted")
+	createItems(t, backend, listID, "Left", "Behind")
+	if err := backend.CreateGuest(ctx, listID, guest.ID); err != nil {
+		t.Fatalf("CreateGuest: %v", err)
+	}
+
+	markUnderDeletion(t, listID)
+
+	list, err := backend.GetListByListID(ctx, listID)
+	if err != nil {
+		t.Fatalf("GetListByListID: %v", err)
+	}
+	if !list.UnderDeletion {
+		t.Fatalf("list not flagged as under deletion: %+v", list)
+	}
+	_, err = backend.CreateItem(ctx, listID, "Sneaky")
+	assertErrorIs(t, err, model.ErrUnderDeletion)
+	assertErrorIs(t, backend.CreateGuest(ctx, listID, users[len(users)-1].ID), model.ErrUnderDeletion)
+	assertErrorIs(t, backend.DeleteList(ctx, listID, guest.ID), model.ErrForbidden)
+
+	if err := backend.DeleteList(ctx, listID, owner.ID); err != nil {
+		t.Fatalf("DeleteList (resume): %v", err)
+	}
+	_, err = backend.GetListByListID(ctx, listID)
+	assertErrorIs(t, err, model.ErrNotFound)
+	if items := getItems(t, backend, listID); len(items) != 0 {
+		t.Fatalf("items left behind after list deletion: %v", items)
+	}
+	guests, err := backend.GetGuestsByListID(ctx, listID)
+	if err != nil {
+		t.Fatalf("GetGuestsByListID: %v", err)
+	}
+	if len(guests) != 0 {
+		t.Fatalf("guests left behind after list deletion: %v", guests)
+	}
+}
@@ -0,0 +1,165 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Items are kept in the order their users give them by a fractional
+// Order: moving an item gives it an Order between those of its new
+// neighbours, so that no other item changes. New items get the time they
+// were created at, in seconds, which puts them last. Items whose Order is
+// the same, such as those created before Order was used, fall back on
+// their Datetime.
+
+// NewItemOrder returns the Order of an item created at t
+func NewItemOrder(t time.Time) float64 {
+	return float64(t.UnixNano()/int64(time.Microsecond)) / 1e6
+}
+
+// ItemLess reports whether a comes before b, by Order and then by Datetime
+func ItemLess(a Item, b Item) bool {
+	if a.Order != b.Order {
+		return a.Order < b.Order
+	}
+	return a.Datetime < b.Datetime
+}
+
+// SortItems sorts items with ItemLess
+func SortItems(items []Item) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return ItemLess(items[i], items[j])
+	})
+}
+
+// ItemCursor returns the Cursor of a page of items ending with item,
+// which keeps its Order and Datetime
+func ItemCursor(item Item) Cursor {
+	return NewCursor(strconv.FormatFloat(item.Order, 'g', -1, 64), item.Datetime)
+}
+
+// ItemCursorKey decodes a Cursor made by ItemCursor into an Item holding
+// only the Order and Datetime of the last item of the previous page. The
+// zero Cursor yields nil.
+func ItemCursorKey(cursor Cursor) (*Item, error) {
+	keys, err := cursor.Keys(2)
+	if err != nil || keys == nil {
+		return nil, err
+	}
+	order, err := strconv.ParseFloat(keys[0], 64)
+	if err != nil {
+		return nil, &ValidationError{
+			Field:  "cursor",
+			Reason: fmt.Sprintf("%q is not a cursor for this listing", cursor),
+		}
+	}
+	return &Item{Datetime: keys[1], Order: order}, nil
+}
+
+// PageItems returns up to max of items, sorted with SortItems, following
+// the position kept by cursor, along with the Cursor of the next page
+func PageItems(items []Item, cursor Cursor, max int64) ([]Item, Cursor, error) {
+	if max < 1 {
+		return nil, "", &ValidationError{
+			Field:  "max",
+			Reason: fmt.Sprintf("%d is not a positive page size", max),
+		}
+	}
+	last, err := ItemCursorKey(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	start := 0
+	if last != nil {
+		start = sort.Search(len(items), func(i int) bool {
+			return ItemLess(*last, items[i])
+		})
+	}
+	end := len(items)
+	if int64(end-start) > max {
+		end = start + int(max)
+	}
+	if end == len(items) {
+		return items[start:end], "", nil
+	}
+	return items[start:end], ItemCursor(items[end-1]), nil
+}
+
+// Move is a change of an item's Order, conditioned on its Version
+type Move struct {
+	Datetime string
+	Version  int
+	Order    float64
+}
+
+// PlanMove returns the changes of Order that put the item at datetime,
+// expected to be at the given version, at position (from 0) among items,
+// which must be sorted with SortItems. The first change is always that of
+// the moved item. When there is no room left between its new neighbours,
+// which takes many moves to the same place, every item is renumbered.
+func PlanMove(items []Item, listID string, datetime string, version int, position int) ([]Move, error) {
+	others := make([]Item, 0, len(items))
+	var moved *Item
+	for i := range items {
+		if items[i].Datetime == datetime {
+			moved = &items[i]
+		} else {
+			others = append(others, items[i])
+		}
+	}
+	if moved == nil {
+		return nil, &NotFoundError{
+			Entity: EntityItem,
+			ListID: listID,
+			Key:    datetime,
+		}
+	}
+	if moved.Version != version {
+		return nil, &VersionConflictError{
+			ListID:   listID,
+			Datetime: datetime,
+			Version:  version,
+			Current:  moved.Version,
+		}
+	}
+	if position < 0 {
+		position = 0
+	}
+	if position > len(others) {
+		position = len(others)
+	}
+
+	order := moved.Order
+	switch {
+	case len(others) == 0:
+	case position == 0:
+		order = others[0].Order - 1
+	case position == len(others):
+		order = others[position-1].Order + 1
+	default:
+		before, after := others[position-1].Order, others[position].Order
+		order = before + (after-before)/2
+		if order <= before || order >= after {
+			return renumber(others, *moved, position), nil
+		}
+	}
+	return []Move{{Datetime: datetime, Version: version, Order: order}}, nil
+}
+
+// renumber gives moved and others the Orders 1, 2, 3... with moved at
+// position
+func renumber(others []Item, moved Item, position int) []Move {
+	moves := []Move{{Datetime: moved.Datetime, Version: moved.Version, Order: float64(position + 1)}}
+	for i, item := range others {
+		order := float64(i + 1)
+		if i >= position {
+			order++
+		}
+		if item.Order != order {
+			moves = append(moves, Move{Datetime: item.Datetime, Version: item.Version, Order: order})
+		}
+	}
+	return moves
+}
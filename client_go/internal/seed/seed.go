@@ -0,0 +1,152 @@
+// Package seed populates any model.Interface implementation with
+// sample data, as init_database.py does for the DynamoDB tables.
+//
+// The text is produced by faker from a fixed seed so that two runs with
+// the same Config yield the same emails, titles, descriptions, guests
+// and done flags. IDs and item datetimes are assigned by the backend and
+// therefore differ between runs.
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+	"syreclabs.com/go/faker"
+)
+
+// Config sets how much data Run creates
+type Config struct {
+	Users         int
+	ListsPerUser  int
+	ItemsPerList  int
+	GuestsPerList int
+	Seed          int64
+}
+
+// DefaultConfig matches the volumes of init_database.py
+func DefaultConfig() Config {
+	return Config{
+		Users:         50,
+		ListsPerUser:  3,
+		ItemsPerList:  3,
+		GuestsPerList: 3,
+		Seed:          1,
+	}
+}
+
+// Stats counts the entries created by Run
+type Stats struct {
+	Users   int
+	Lists   int
+	Guests  int
+	Items   int
+	Elapsed time.Duration
+}
+
+// Total is the number of entries inserted
+func (stats Stats) Total() int {
+	return stats.Users + stats.Lists + stats.Guests + stats.Items
+}
+
+// PerSecond is the insertion throughput
+func (stats Stats) PerSecond() float64 {
+	if stats.Elapsed <= 0 {
+		return 0
+	}
+	return float64(stats.Total()) / stats.Elapsed.Seconds()
+}
+
+// Report prints the summary init_database.py ends with
+func (stats Stats) Report(out io.Writer) {
+	fmt.Fprintf(out, "Data upload time taken: %f seconds\n", stats.Elapsed.Seconds())
+	fmt.Fprintf(out, "Items inserted: %d items (users=%d,lists=%d,guests=%d,items=%d)\n",
+		stats.Total(), stats.Users, stats.Lists, stats.Guests, stats.Items)
+	fmt.Fprintf(out, "Performance: %f items/second\n", stats.PerSecond())
+}
+
+// Run creates config.Users users, each owning config.ListsPerUser lists
+// holding config.ItemsPerList items. Every list is shared with up to
+// config.GuestsPerList of the users created before its owner. A line is
+// written to out for each entry created.
+//
+// faker's generator is global, so concurrent calls are not reproducible.
+func Run(ctx context.Context, backend model.Interface, config Config, out io.Writer) (Stats, error) {
+	faker.Seed(config.Seed)
+	random := rand.New(rand.NewSource(config.Seed))
+	start := time.Now()
+	var stats Stats
+
+	userIDs := make([]string, 0, config.Users)
+	emails := make(map[string]bool, config.Users)
+	for u := 0; u < config.Users; u++ {
+		email := faker.Internet().Email()
+		for emails[email] {
+			email = faker.Internet().Email()
+		}
+		emails[email] = true
+		userID, err := backend.CreateUser(ctx, email)
+		if err != nil {
+			return stats, err
+		}
+		stats.Users++
+		fmt.Fprintf(out, "user|id=%s,email=%s\n", userID, email)
+
+		for l := 0; l < config.ListsPerUser; l++ {
+			title := faker.Lorem().Sentence(3)
+			listID, err := backend.CreateList(ctx, userID, title)
+			if err != nil {
+				return stats, err
+			}
+			stats.Lists++
+			fmt.Fprintf(out, "list|id=%s,user_id=%s,title=%s\n", listID, userID, title)
+
+			guests := config.GuestsPerList
+			if guests > len(userIDs) {
+				guests = len(userIDs)
+			}
+			for _, i := range random.Perm(len(userIDs))[:guests] {
+				if err := backend.CreateGuest(ctx, listID, userIDs[i]); err != nil {
+					return stats, err
+				}
+				stats.Guests++
+				fmt.Fprintf(out, "guest|list_id=%s,user_id=%s\n", listID, userIDs[i])
+			}
+
+			for i := 0; i < config.ItemsPerList; i++ {
+				description := faker.Lorem().Sentence(4)
+				done := random.Intn(2) == 0
+				datetime, err := createItem(ctx, backend, listID, description)
+				if err != nil {
+					return stats, err
+				}
+				stats.Items++
+				if done {
+					if _, err := backend.UpdateItem(ctx, listID, datetime, 0, nil, &done); err != nil {
+						return stats, err
+					}
+				}
+				fmt.Fprintf(out, "item|list_id=%s,datetime=%s,description=%s,done=%t\n", listID, datetime, description, done)
+			}
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	stats.Elapsed = time.Since(start)
+	return stats, nil
+}
+
+// createItem retries datetime clashes, which are likely when items are
+// added in quick succession since datetimes have microsecond resolution
+func createItem(ctx context.Context, backend model.Interface, listID string, description string) (string, error) {
+	for attempt := 0; ; attempt++ {
+		datetime, err := backend.CreateItem(ctx, listID, description)
+		if err == nil || attempt == 4 || !errors.Is(err, model.ErrAlreadyExists) {
+			return datetime, err
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package seed
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"testing"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/database/memory"
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+)
+
+// fixtures describes the seeded data without the IDs and datetimes
+// assigned by the backend
+func fixtures(t *testing.T, backend model.Interface, config Config) []string {
+	t.Helper()
+	ctx := context.Background()
+	stats, err := Run(ctx, backend, config, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	lists := config.Users * config.ListsPerUser
+	if stats.Users != config.Users || stats.Lists != lists || stats.Items != lists*config.ItemsPerList {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	users, _, err := backend.ListUsers(ctx, "", 1000)
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	result := make([]string, 0)
+	guests := 0
+	for _, user := range users {
+		aggregates, err := backend.GetAggregateListsByUserID(ctx, user.ID)
+		if err != nil {
+			t.Fatalf("GetAggregateListsByUserID: %v", err)
+		}
+		for _, list := range aggregates {
+			if list.AsGuest {
+				result = append(result, fmt.Sprintf("%s guest of %s", user.Email, list.Title))
+				guests++
+				continue
+			}
+			result = append(result, fmt.Sprintf("%s owns %s", user.Email, list.Title))
+			items, err := backend.GetItemsByListID(ctx, list.ID)
+			if err != nil {
+				t.Fatalf("GetItemsByListID: %v", err)
+			}
+			for _, item := range items {
+				result = append(result, fmt.Sprintf("%s has %s (done=%t)", list.Title, item.Description, item.Done))
+			}
+		}
+	}
+	if guests != stats.Guests {
+		t.Fatalf("found %d guests, expected %d", guests, stats.Guests)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func TestReproducible(t *testing.T) {
+	config := Config{Users: 6, ListsPerUser: 2, ItemsPerList: 3, GuestsPerList: 2, Seed: 42}
+	first := fixtures(t, memory.New(), config)
+	second := fixtures(t, memory.New(), config)
+	if len(first) != len(second) {
+		t.Fatalf("runs differ in size: %d and %d", len(first), len(second))
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("runs differ: %q and %q", first[i], second[i])
+		}
+	}
+
+	config.Seed = 43
+	third := fixtures(t, memory.New(), config)
+	if len(third) == len(first) && third[0] == first[0] {
+		t.Fatalf("different seeds produced the same fixtures")
+	}
+}
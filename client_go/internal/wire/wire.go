@@ -0,0 +1,64 @@
+// Package wire defines the JSON documents shared by the REST server and
+// the structured output of the CLI, so that a script reading one can read
+// the other.
+package wire
+
+import (
+	"context"
+	"errors"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+)
+
+// CodeUnknown is the code of the errors ErrorCode cannot classify
+const CodeUnknown = "error"
+
+// Page is a page of a collection. The following page, when there is one,
+// starts after NextCursor.
+type Page struct {
+	Data       interface{}  `json:"data"`
+	NextCursor model.Cursor `json:"next_cursor,omitempty"`
+}
+
+// ErrorBody is how an error is reported
+type ErrorBody struct {
+	Error Error `json:"error"`
+}
+
+// Error is a machine-readable code along with the message of an error
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// NewError returns the Error of err, coded by ErrorCode
+func NewError(err error) Error {
+	return Error{Code: ErrorCode(err), Message: err.Error()}
+}
+
+// ErrorCode classifies err after the model's typed errors
+func ErrorCode(err error) string {
+	switch {
+	case errors.Is(err, model.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, model.ErrForbidden):
+		return "forbidden"
+	case errors.Is(err, model.ErrAlreadyExists):
+		return "already_exists"
+	case errors.Is(err, model.ErrVersionConflict):
+		return "version_conflict"
+	case errors.Is(err, model.ErrUnderDeletion):
+		return "under_deletion"
+	case errors.Is(err, model.ErrValidation):
+		return "validation"
+	case errors.Is(err, model.ErrThrottled):
+		return "throttled"
+	case errors.Is(err, model.ErrTransient):
+		return "transient"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "deadline_exceeded"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	}
+	return CodeUnknown
+}
@@ -0,0 +1,28 @@
+package wire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/egarbarino/dry_dynamodb/client_go/internal/model"
+)
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{fmt.Errorf("script:1: %w", &model.NotFoundError{Entity: model.EntityList, Key: "l1"}), "not_found"},
+		{&model.VersionConflictError{}, "version_conflict"},
+		{&model.ValidationError{Field: "max", Reason: "0 is not a positive page size"}, "validation"},
+		{context.DeadlineExceeded, "deadline_exceeded"},
+		{errors.New("disk full"), CodeUnknown},
+	}
+	for _, test := range tests {
+		if code := ErrorCode(test.err); code != test.expected {
+			t.Errorf("%v: expected %s, got %s", test.err, test.expected, code)
+		}
+	}
+}
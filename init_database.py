@@ -4,6 +4,7 @@ import boto3
 from faker import Faker
 import uuid 
 import datetime
+import os
 import time
 import random
 
@@ -16,9 +17,14 @@ WRU_UPLOAD = 2
 WRU_DEFAULT = 1
 
 faker = Faker()
-session = boto3.Session(profile_name='dynamodb_profile')
-client = session.client('dynamodb')
-resource = session.resource('dynamodb')
+# DYNAMODB_ENDPOINT points to a local stand-in such as cmd/emulator
+endpoint = os.environ.get('DYNAMODB_ENDPOINT')
+if endpoint:
+  session = boto3.Session(aws_access_key_id='local', aws_secret_access_key='local', region_name='eu-west-2')
+else:
+  session = boto3.Session(profile_name='dynamodb_profile')
+client = session.client('dynamodb', endpoint_url=endpoint)
+resource = session.resource('dynamodb', endpoint_url=endpoint)
 
 
 print('*** Looking for tables ***\n')
@@ -103,4 +103,17 @@ resource "aws_dynamodb_table" "dynamodb-table-items" {
     type = "S"
   }
 
+  attribute {
+    name = "order"
+    type = "N"
+  }
+
+  global_secondary_index {
+    name               = "items_by_order"
+    hash_key           = "list_id"
+    range_key          = "order"
+    write_capacity     = 2 
+    read_capacity      = 2 
+    projection_type    = "ALL"
+  }
 }